@@ -9,31 +9,42 @@ import (
 	"time"
 	"voxly/internal/bot"
 	"voxly/internal/config"
+	"voxly/internal/monitor"
 	"voxly/internal/queue"
 	"voxly/internal/storage"
-	"voxly/pkg/cache"
 	"voxly/pkg/logger"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
+// resultsConsumer names the bot's results queue; bot replicas share it
+const resultsConsumer = "bot"
+
 func main() {
 	// Load .env file first
 	_ = godotenv.Load()
 
 	// Parse command line flags
 	resetDB := flag.Bool("reset-db", false, "Reset database by dropping all tables and re-running migrations")
+	migrateVersion := flag.Bool("migrate-version", false, "Print the current migration version and dirty state, then exit")
+	migrateDown := flag.Int("migrate-down", 0, "Roll back N migrations, then exit")
+	migrateSteps := flag.Int("migrate-steps", 0, "Apply N migrations (negative N rolls back), then exit")
 	flag.Parse()
 
-	// Initialize the logger first
-	debug := true // or false, depending on your needs
-	if err := logger.Init(debug); err != nil {
+	// Load configuration first: the environment decides the log format
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+
+	// Initialize the logger
+	if err := logger.Init(cfg.ConsoleLogs()); err != nil {
 		panic("Failed to init logger: " + err.Error())
 	}
 	defer logger.Sync()
 
-	logger.Info("Starting voxly bot service")
+	logger.Info("Starting voxly bot service", zap.String("environment", cfg.Environment))
 
 	// Get database URL
 	databaseURL := os.Getenv("DATABASE_URL")
@@ -53,19 +64,54 @@ func main() {
 		return
 	}
 
-	// Create context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Report migration state if flag is provided
+	if *migrateVersion {
+		version, dirty, err := storage.MigrationVersion(databaseURL)
+		if err != nil {
+			logger.Fatal("Failed to get migration version", zap.Error(err))
+			return
+		}
+		logger.Info("Current migration version",
+			zap.Uint("version", version),
+			zap.Bool("dirty", dirty))
+		return
+	}
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatal("Failed to load config", zap.Error(err))
+	// Step migrations if flag is provided
+	if *migrateDown != 0 || *migrateSteps != 0 {
+		if *migrateDown != 0 && *migrateSteps != 0 {
+			logger.Fatal("Use either --migrate-down or --migrate-steps, not both")
+			return
+		}
+		if *migrateDown < 0 {
+			logger.Fatal("--migrate-down expects a positive number of migrations")
+			return
+		}
+
+		steps := *migrateSteps
+		if *migrateDown > 0 {
+			steps = -*migrateDown
+			logger.Warn("Rolling back migrations, data in dropped tables will be lost",
+				zap.Int("migrations", *migrateDown))
+		}
+
+		if err := storage.MigrateSteps(databaseURL, steps); err != nil {
+			logger.Fatal("Failed to step migrations", zap.Error(err))
+			return
+		}
 		return
 	}
 
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Initialize database connection
-	db, err := storage.NewPostgresStorage(databaseURL)
+	db, err := storage.NewPostgresStorage(databaseURL, storage.PoolOptions{
+		MaxConns:        int32(cfg.Postgres.MaxConns),
+		MinConns:        int32(cfg.Postgres.MinConns),
+		MaxConnLifetime: cfg.Postgres.MaxConnLifetime,
+	})
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 		return
@@ -75,37 +121,68 @@ func main() {
 	logger.Info("Database connection established")
 
 	// Initialize Redis cache
-	redisCache, err := cache.NewRedisCache(
-		cfg.Redis.Addr,
-		cfg.Redis.Password,
-		cfg.Redis.DB,
-		24*time.Hour, // Default TTL 24 hours
-	)
+	redisCache, err := cfg.NewCache()
 	if err != nil {
-		logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		logger.Fatal("Failed to initialize cache", zap.Error(err))
 		return
 	}
 	defer redisCache.Close()
 
-	logger.Info("Redis cache connection established")
+	logger.Info("Cache initialized", zap.String("driver", cfg.Cache.Driver))
 
-	// Connect to RabbitMQ
-	rabbitMQ, err := queue.NewRabbitMQ(cfg.RabbitMQ.URL)
-	if err != nil {
-		logger.Fatal("Failed to connect to RabbitMQ", zap.Error(err))
-		return
+	// Without RabbitMQ, tasks are only saved to the database and workers poll for them
+	var rabbitMQ *queue.RabbitMQ
+	var publisher bot.QueuePublisher
+	if cfg.UsesRabbitMQ() {
+		rabbitMQ, err = queue.NewRabbitMQ(cfg.RabbitMQ.URL)
+		if err != nil {
+			logger.Fatal("Failed to connect to RabbitMQ", zap.Error(err))
+			return
+		}
+		defer rabbitMQ.Close()
+
+		// Long audio can go to a queue of its own, consumed by dedicated workers
+		rabbitMQ.Route(cfg.TaskRouting())
+
+		publisher = rabbitMQ
+		logger.Info("RabbitMQ connection established")
 	}
-	defer rabbitMQ.Close()
 
-	logger.Info("RabbitMQ connection established")
+	// Expose health and metrics endpoints
+	var monitorServer *monitor.Server
+	if cfg.Monitor.Addr != "" {
+		monitorServer = monitor.NewServer(cfg.Monitor.Addr, db)
+		if rabbitMQ != nil {
+			monitorServer.TrackQueues(rabbitMQ, queue.QueueNameVoiceProcessing, queue.QueueNameVoiceLong, queue.ResultsQueueName(resultsConsumer))
+		}
+		go monitorServer.Start()
+	}
 
 	// Initialize bot with database, queue, and cache
-	botInstance, err := bot.NewBot(cfg, db, rabbitMQ, redisCache)
+	botInstance, err := bot.NewBot(cfg, db, publisher, redisCache)
 	if err != nil {
 		logger.Fatal("Failed to initialize bot", zap.Error(err))
 		return
 	}
 
+	// Audio of transcripts deleted with /delete goes with them
+	rawS3Storage, err := storage.NewS3Storage(
+		cfg.S3.Endpoint,
+		cfg.S3.AccessKey,
+		cfg.S3.SecretKey,
+		cfg.S3.Bucket,
+		storage.ClientOptions{
+			RetryMode:        cfg.S3.SDKRetryMode,
+			MaxAttempts:      cfg.S3.SDKMaxAttempts,
+			OperationTimeout: cfg.S3.OperationTimeout,
+		},
+	)
+	if err != nil {
+		logger.Warn("Failed to initialize S3 storage, deleted transcripts keep their audio until cleanup", zap.Error(err))
+	} else {
+		botInstance.UseObjectStorage(storage.NewResilientS3(rawS3Storage, cfg.S3BreakerOptions()))
+	}
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -116,6 +193,15 @@ func main() {
 		botInstance.Start()
 	}()
 
+	// Warm caches from results published by the workers
+	if rabbitMQ != nil {
+		go func() {
+			if err := rabbitMQ.ConsumeResults(resultsConsumer, botInstance.HandleResult); err != nil {
+				logger.Error("Failed to consume transcription results", zap.Error(err))
+			}
+		}()
+	}
+
 	select {
 	case sig := <-sigChan:
 		logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
@@ -127,5 +213,13 @@ func main() {
 	cancel()
 	botInstance.Stop()
 
+	if monitorServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := monitorServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to stop monitoring server", zap.Error(err))
+		}
+		shutdownCancel()
+	}
+
 	logger.Info("Bot service shutdown complete")
 }
@@ -7,12 +7,16 @@ import (
 	"syscall"
 	"time"
 	"voxly/internal/config"
+	"voxly/internal/monitor"
 	"voxly/internal/queue"
 	"voxly/internal/speechkit"
 	"voxly/internal/storage"
+	"voxly/internal/webhook"
 	"voxly/internal/worker"
 	"voxly/pkg/cache"
+	"voxly/pkg/httpclient"
 	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -23,21 +27,19 @@ func main() {
 	// Load .env file
 	_ = godotenv.Load()
 
+	// Load configuration first: the environment decides the log format
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+
 	// Initialize logger
-	debug := true
-	if err := logger.Init(debug); err != nil {
+	if err := logger.Init(cfg.ConsoleLogs()); err != nil {
 		panic("Failed to init logger: " + err.Error())
 	}
 	defer logger.Sync()
 
-	logger.Info("Starting voxly worker service")
-
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatal("Failed to load config", zap.Error(err))
-		return
-	}
+	logger.Info("Starting voxly worker service", zap.String("environment", cfg.Environment))
 
 	// Connect to database
 	databaseURL := os.Getenv("DATABASE_URL")
@@ -46,7 +48,11 @@ func main() {
 		return
 	}
 
-	db, err := storage.NewPostgresStorage(databaseURL)
+	db, err := storage.NewPostgresStorage(databaseURL, storage.PoolOptions{
+		MaxConns:        int32(cfg.Postgres.MaxConns),
+		MinConns:        int32(cfg.Postgres.MinConns),
+		MaxConnLifetime: cfg.Postgres.MaxConnLifetime,
+	})
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 		return
@@ -56,26 +62,48 @@ func main() {
 	logger.Info("Database connection established")
 
 	// Initialize S3 storage from config
-	s3Storage, err := storage.NewS3Storage(
+	rawS3Storage, err := storage.NewS3Storage(
 		cfg.S3.Endpoint,
 		cfg.S3.AccessKey,
 		cfg.S3.SecretKey,
 		cfg.S3.Bucket,
+		storage.ClientOptions{
+			RetryMode:        cfg.S3.SDKRetryMode,
+			MaxAttempts:      cfg.S3.SDKMaxAttempts,
+			OperationTimeout: cfg.S3.OperationTimeout,
+			PartSize:         cfg.S3.MultipartPartSize,
+		},
 	)
 	if err != nil {
 		logger.Fatal("Failed to initialize S3 storage", zap.Error(err))
 		return
 	}
 
+	// Ride out transient Object Storage failures instead of failing tasks
+	s3Storage := storage.NewResilientS3(rawS3Storage, cfg.S3BreakerOptions())
+
 	logger.Info("S3 storage initialized")
 
+	// Shared by SpeechKit polling and Telegram downloads to reuse connections
+	httpClient := httpclient.New(httpclient.DefaultOptions())
+
 	// Initialize SpeechKit client
-	speechkitClient := speechkit.NewClient(cfg.SpeechKit.APIKey, cfg.SpeechKit.FolderID)
+	speechkitClient := speechkit.NewClient(cfg.SpeechKit.APIKey, cfg.SpeechKit.FolderID, httpClient, speechkit.Timeouts{
+		Start: cfg.SpeechKit.StartTimeout,
+		Poll:  cfg.SpeechKit.PollTimeout,
+	}, speechkit.Endpoints{
+		Recognize: cfg.SpeechKit.RecognizeURL,
+		Operation: cfg.SpeechKit.OperationURL,
+	})
+
+	// Runs after the recognition polls are drained
+	defer speechkitClient.Close()
 
 	logger.Info("SpeechKit client initialized")
 
 	// Initialize Telegram bot
 	botSettings := tele.Settings{
+		URL:   cfg.Telegram.APIURL,
 		Token: cfg.Telegram.Token,
 		Poller: &tele.LongPoller{
 			Timeout: 10 * time.Second,
@@ -91,48 +119,160 @@ func main() {
 	logger.Info("Telegram bot initialized")
 
 	// Initialize Redis cache
-	redisCache, err := cache.NewRedisCache(
-		cfg.Redis.Addr,
-		cfg.Redis.Password,
-		cfg.Redis.DB,
-		24*time.Hour, // Default TTL 24 hours
-	)
+	redisCache, err := cfg.NewCache()
 	if err != nil {
-		logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		logger.Fatal("Failed to initialize cache", zap.Error(err))
 		return
 	}
 	defer redisCache.Close()
 
-	logger.Info("Redis cache connection established")
+	logger.Info("Cache initialized", zap.String("driver", cfg.Cache.Driver))
 
-	// Connect to RabbitMQ
-	rabbitMQ, err := queue.NewRabbitMQ(cfg.RabbitMQ.URL)
-	if err != nil {
-		logger.Fatal("Failed to connect to RabbitMQ", zap.Error(err))
-		return
+	// Without RabbitMQ, tasks are only taken from the database
+	var rabbitMQ *queue.RabbitMQ
+	if cfg.UsesRabbitMQ() {
+		if err := queue.ValidateTaskQueues(cfg.WorkerQueues()); err != nil {
+			logger.Fatal("Invalid WORKER_QUEUES", zap.Error(err))
+			return
+		}
+
+		// Connect to RabbitMQ
+		rabbitMQ, err = queue.NewRabbitMQ(cfg.RabbitMQ.URL)
+		if err != nil {
+			logger.Fatal("Failed to connect to RabbitMQ", zap.Error(err))
+			return
+		}
+		defer rabbitMQ.Close()
+
+		logger.Info("RabbitMQ connection established")
+
+		// Republished tasks follow the same routing as the bot's
+		rabbitMQ.Route(cfg.TaskRouting())
+
+		// Handled task IDs are remembered in Redis, so a repeated publish isn't recognized twice
+		if claimer, ok := redisCache.(cache.Claimer); ok && cfg.RabbitMQ.DedupWindow > 0 {
+			rabbitMQ.Deduplicate(queue.NewDeduplicator(claimer, cfg.RabbitMQ.DedupWindow))
+		}
 	}
-	defer rabbitMQ.Close()
 
-	logger.Info("RabbitMQ connection established")
+	// Breaker state changes are logged and exported to /metrics
+	breakers := resilience.NewBreakerRegistry()
+	breakers.Register("speechkit", speechkitClient.CircuitBreaker())
+	breakers.Register("s3", s3Storage.CircuitBreaker())
 
 	// Create processor with cache
-	processor := worker.NewProcessor(db, s3Storage, speechkitClient, bot, redisCache)
+	processor := worker.NewProcessor(cfg, db, s3Storage, speechkitClient, bot, redisCache, httpClient)
+	breakers.Register("telegram", processor.TelegramBreaker())
+
+	// Replies Telegram is unavailable for wait in the database
+	if cfg.Telegram.OutboxFlushInterval > 0 {
+		processor.UseOutbox(db)
+	}
+
+	// Tasks to retry go back to RabbitMQ, or to the database queue without it
+	var tasks worker.TaskPublisher
+	var dbPoller *worker.DBPoller
+	if cfg.PollsDB() {
+		// The poller claims apart from queue deliveries, which take back only their own claims
+		dbPoller = worker.NewDBPoller(db, processor.ProcessTask, worker.DefaultWorkerID()+"/poller", cfg.Worker.DBPollInterval, cfg.Worker.DBPollBatch)
+		tasks = dbPoller
+	}
+	if rabbitMQ != nil {
+		processor.PublishResults(rabbitMQ)
+		tasks = rabbitMQ
+	}
+
+	// Recognition polls run beside consumption when a pool is configured
+	var pollers *worker.PollerPool
+	if cfg.Worker.PollConcurrency > 0 {
+		pollers = worker.NewPollerPool(cfg.Worker.PollConcurrency)
+		processor.PollInBackground(pollers, tasks)
+	}
+
+	// Expose health, metrics and self-test endpoints
+	var monitorServer *monitor.Server
+	if cfg.Monitor.Addr != "" {
+		monitorServer = monitor.NewServer(cfg.Monitor.Addr, db)
+		if rabbitMQ != nil {
+			monitorServer.TrackQueues(rabbitMQ, queue.QueueNameVoiceProcessing, queue.QueueNameVoiceLong)
+		}
+		monitorServer.EnableSelfTest(monitor.SelfTesterFunc(func(ctx context.Context) (bool, any) {
+			report := processor.SelfTest(ctx)
+			return report.OK, report
+		}), cfg.Monitor.SelfTestToken)
+		monitorServer.TrackBreakers(breakers)
+		if cfg.Worker.ResultCacheTTL > 0 {
+			monitorServer.TrackResultCache(processor)
+		}
+		go monitorServer.Start()
+	}
 
 	// Graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Deliver transcripts to the integrator's endpoint in the background
+	if cfg.Webhook.URL != "" {
+		sender := webhook.NewSender(webhook.Options{
+			URL:            cfg.Webhook.URL,
+			Secret:         cfg.Webhook.Secret,
+			RetryAttempts:  cfg.Webhook.RetryAttempts,
+			RequestTimeout: cfg.Webhook.RequestTimeout,
+			QueueSize:      cfg.Webhook.QueueSize,
+		}, httpClient)
+		processor.OnComplete(sender.Hook)
+		go sender.Run(ctx)
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start consuming messages
-	go func() {
-		logger.Info("Starting to consume messages from queue")
-		if err := rabbitMQ.Consume(queue.QueueNameVoiceProcessing, processor.ProcessTask); err != nil {
-			logger.Error("Failed to consume messages", zap.Error(err))
-			cancel()
+	// Periodically remove audio left behind by failed tasks, unless it's kept for debugging
+	cleanupInterval := cfg.S3.CleanupInterval
+	if cfg.KeepAudio() {
+		cleanupInterval = 0
+	}
+	cleaner := worker.NewCleaner(s3Storage, db, cfg.S3.CleanupRetention, cleanupInterval, cfg.S3.MultipartStaleAfter)
+	cleaner.BoundCalls(cfg.S3CallTimeout())
+	go cleaner.Run(ctx)
+
+	// Deliver replies saved while Telegram was unavailable
+	go processor.RunOutbox(ctx, cfg.Telegram.OutboxFlushInterval)
+
+	// Background polls acknowledge tasks early and claimed tasks are skipped by
+	// other deliveries; put back those a stopped worker left
+	if pollers != nil || cfg.PollsDB() {
+		reclaimer := worker.NewReclaimer(db, tasks, cfg.Worker.TaskTimeoutMultiplier, cfg.Worker.ReclaimInterval)
+		go reclaimer.Run(ctx)
+	}
+
+	// Delete transcripts past the retention period for privacy
+	retention := worker.NewRetention(db, cfg.Postgres.TranscriptRetention, cfg.Postgres.RetentionDeleteTasks, cfg.Postgres.RetentionInterval)
+	retention.UseBackups(s3Storage, cfg.S3CallTimeout())
+	go retention.Run(ctx)
+
+	// Don't take tasks until SpeechKit and S3 are usable
+	if err := processor.Warmup(ctx); err != nil {
+		logger.Fatal("Worker dependencies are unavailable", zap.Error(err))
+	}
+
+	// Start consuming messages from each configured queue
+	if rabbitMQ != nil {
+		for _, name := range cfg.WorkerQueues() {
+			go func(name string) {
+				logger.Info("Starting to consume messages from queue", zap.String("queue", name))
+				if err := rabbitMQ.Consume(name, processor.ProcessTask); err != nil {
+					logger.Error("Failed to consume messages", zap.String("queue", name), zap.Error(err))
+					cancel()
+				}
+			}(name)
 		}
-	}()
+	}
+
+	// Pick up tasks saved without a queue message, e.g. while RabbitMQ is not used
+	if dbPoller != nil {
+		go dbPoller.Run(ctx)
+	}
 
 	// Wait for shutdown signal
 	select {
@@ -142,5 +282,26 @@ func main() {
 		logger.Info("Context cancelled")
 	}
 
+	// Hand tasks held by the maintenance pause back to the queue
+	processor.Stop()
+
+	if pollers != nil {
+		logger.Info("Waiting for background recognition polls",
+			zap.Int("outstanding", pollers.Outstanding()))
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := pollers.Wait(drainCtx); err != nil {
+			logger.Warn("Recognition polls still running at shutdown", zap.Error(err))
+		}
+		drainCancel()
+	}
+
+	if monitorServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := monitorServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to stop monitoring server", zap.Error(err))
+		}
+		shutdownCancel()
+	}
+
 	logger.Info("Worker service shutdown complete")
 }
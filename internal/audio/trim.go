@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFFmpegUnavailable is returned when no ffmpeg binary is found in PATH
+var ErrFFmpegUnavailable = errors.New("ffmpeg is not available")
+
+// Defaults for silence detection
+const (
+	DefaultSilenceThresholdDB = -50
+	DefaultKeepSilence        = 250 * time.Millisecond
+)
+
+// SilenceTrimmer removes leading and trailing silence from OGG/Opus audio with ffmpeg
+type SilenceTrimmer struct {
+	ffmpegPath string
+	// Audio quieter than thresholdDB counts as silence
+	thresholdDB float64
+	// keep is how much silence is left at each end, so the first and last words aren't clipped
+	keep time.Duration
+}
+
+// NewSilenceTrimmer finds ffmpeg in PATH. A zero threshold and a negative keep
+// fall back to the defaults.
+func NewSilenceTrimmer(thresholdDB float64, keep time.Duration) (*SilenceTrimmer, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFFmpegUnavailable, err)
+	}
+
+	if thresholdDB == 0 {
+		thresholdDB = DefaultSilenceThresholdDB
+	}
+	if keep < 0 {
+		keep = DefaultKeepSilence
+	}
+
+	return &SilenceTrimmer{ffmpegPath: path, thresholdDB: thresholdDB, keep: keep}, nil
+}
+
+// Trim returns data without silence at the start and the end, re-encoded as OGG/Opus,
+// and how much audio was cut from the start. Timings recognized in the trimmed audio
+// are that much earlier than in the original.
+func (t *SilenceTrimmer) Trim(ctx context.Context, data []byte) ([]byte, time.Duration, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, t.ffmpegPath, trimArgs(t.thresholdDB, t.keep)...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, 0, fmt.Errorf("failed to trim silence: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, 0, errors.New("failed to trim silence: ffmpeg produced no output")
+	}
+	return stdout.Bytes(), leadingCut(stderr.String(), t.keep), nil
+}
+
+// trimArgs builds an ffmpeg command line that reads audio from stdin and writes
+// the trimmed OGG/Opus to stdout
+func trimArgs(thresholdDB float64, keep time.Duration) []string {
+	return []string{
+		"-hide_banner",
+		"-nostats",
+		// silencedetect reports at info level
+		"-loglevel", "info",
+		"-i", "pipe:0",
+		"-af", detectFilter(thresholdDB, keep) + "," + silenceFilter(thresholdDB, keep),
+		"-c:a", "libopus",
+		"-f", "ogg",
+		"pipe:1",
+	}
+}
+
+// silenceFilter removes silence from the start, then reverses the audio to do the
+// same at the end. silenceremove only trims trailing silence with stop_periods,
+// which also cuts pauses in the middle.
+func silenceFilter(thresholdDB float64, keep time.Duration) string {
+	trimStart := fmt.Sprintf("silenceremove=start_periods=1:start_silence=%s:start_threshold=%sdB",
+		strconv.FormatFloat(keep.Seconds(), 'f', -1, 64),
+		strconv.FormatFloat(thresholdDB, 'f', -1, 64))
+
+	return strings.Join([]string{trimStart, "areverse", trimStart, "areverse"}, ",")
+}
+
+// detectFilter logs the silence in the original audio, so the cut at the start can
+// be measured. It uses the same threshold and minimum length as silenceFilter.
+func detectFilter(thresholdDB float64, keep time.Duration) string {
+	return fmt.Sprintf("silencedetect=noise=%sdB:d=%s",
+		strconv.FormatFloat(thresholdDB, 'f', -1, 64),
+		strconv.FormatFloat(keep.Seconds(), 'f', -1, 64))
+}
+
+var (
+	silenceStartRe = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+// leadingStartTolerance absorbs the frame rounding of silencedetect, which may put
+// the start of the leading silence slightly off zero
+const leadingStartTolerance = 0.05
+
+// leadingCut reads the silencedetect log and returns how much silence silenceremove
+// cut from the start: the leading silence minus what it keeps. Audio that starts
+// with speech, or is silent throughout, reports no cut.
+func leadingCut(log string, keep time.Duration) time.Duration {
+	start := silenceStartRe.FindStringSubmatch(log)
+	end := silenceEndRe.FindStringSubmatch(log)
+	if start == nil || end == nil {
+		return 0
+	}
+
+	startSec, err := strconv.ParseFloat(start[1], 64)
+	if err != nil || startSec > leadingStartTolerance {
+		return 0
+	}
+	endSec, err := strconv.ParseFloat(end[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	cut := time.Duration(endSec*float64(time.Second)) - keep
+	if cut < 0 {
+		return 0
+	}
+	return cut.Round(time.Millisecond)
+}
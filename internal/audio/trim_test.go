@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilenceFilter(t *testing.T) {
+	trimStart := "silenceremove=start_periods=1:start_silence=0.25:start_threshold=-50dB"
+	assert.Equal(t, trimStart+",areverse,"+trimStart+",areverse", silenceFilter(-50, 250*time.Millisecond))
+
+	assert.Equal(t,
+		"silenceremove=start_periods=1:start_silence=0:start_threshold=-42.5dB,areverse,"+
+			"silenceremove=start_periods=1:start_silence=0:start_threshold=-42.5dB,areverse",
+		silenceFilter(-42.5, 0))
+}
+
+func TestTrimArgs(t *testing.T) {
+	args := trimArgs(-50, time.Second)
+
+	assert.Equal(t, []string{
+		"-hide_banner",
+		"-nostats",
+		"-loglevel", "info",
+		"-i", "pipe:0",
+		"-af", "silencedetect=noise=-50dB:d=1," + silenceFilter(-50, time.Second),
+		"-c:a", "libopus",
+		"-f", "ogg",
+		"pipe:1",
+	}, args)
+}
+
+func TestLeadingCut(t *testing.T) {
+	keep := 250 * time.Millisecond
+	tests := []struct {
+		name string
+		log  string
+		want time.Duration
+	}{
+		{
+			name: "leading silence",
+			log: "[silencedetect @ 0x1] silence_start: 0\n" +
+				"[silencedetect @ 0x1] silence_end: 1.75 | silence_duration: 1.75\n" +
+				"[silencedetect @ 0x1] silence_start: 4.2\n",
+			want: 1500 * time.Millisecond,
+		},
+		{
+			name: "frame rounding before zero",
+			log: "[silencedetect @ 0x1] silence_start: -0.0125\n" +
+				"[silencedetect @ 0x1] silence_end: 0.76 | silence_duration: 0.7725\n",
+			want: 510 * time.Millisecond,
+		},
+		{
+			name: "speech from the start",
+			log: "[silencedetect @ 0x1] silence_start: 2.5\n" +
+				"[silencedetect @ 0x1] silence_end: 3.5 | silence_duration: 1\n",
+			want: 0,
+		},
+		{
+			name: "silence shorter than kept",
+			log: "[silencedetect @ 0x1] silence_start: 0\n" +
+				"[silencedetect @ 0x1] silence_end: 0.1 | silence_duration: 0.1\n",
+			want: 0,
+		},
+		{
+			name: "silent throughout",
+			log:  "[silencedetect @ 0x1] silence_start: 0\n",
+			want: 0,
+		},
+		{
+			name: "no silence",
+			log:  "",
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, leadingCut(tt.log, keep))
+		})
+	}
+}
+
+func TestNewSilenceTrimmerWithoutFFmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	trimmer, err := NewSilenceTrimmer(-50, time.Second)
+	assert.ErrorIs(t, err, ErrFFmpegUnavailable)
+	assert.Nil(t, trimmer)
+}
@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"voxly/internal/i18n"
+	"voxly/internal/queue"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// isAdmin проверяет, входит ли пользователь в список администраторов
+func (b *Bot) isAdmin(userID int64) bool {
+	for _, id := range b.cfg.Telegram.AdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleStatus показывает состояние задачи, длительность этапов обработки и
+// начало расшифровки
+func (b *Bot) handleStatus(c tele.Context) error {
+	if c.Sender() == nil || !b.isAdmin(c.Sender().ID) {
+		return nil
+	}
+
+	taskID := strings.TrimSpace(c.Message().Payload)
+	if taskID == "" {
+		return c.Send(b.texts.Text(i18n.StatusUsage))
+	}
+
+	task, err := b.storage.GetTaskByID(context.Background(), taskID)
+	if err != nil {
+		logger.Error("Failed to get task for status",
+			zap.Error(err),
+			zap.String("task_id", taskID))
+		return c.Send(b.texts.Text(i18n.TaskNotFound))
+	}
+
+	report := formatTaskStatus(b.texts, task)
+	if task.Status == model.TaskStatusDone {
+		if transcript := b.transcriptFor(context.Background(), task.ID); transcript != nil {
+			report += "\n\n" + b.texts.Text(i18n.TaskReportTranscript, transcript.Version, transcriptPreview(transcript.Text))
+		}
+	}
+	return c.Send(report)
+}
+
+// transcriptPreviewLength ограничивает расшифровку в отчёте /status
+const transcriptPreviewLength = 300
+
+// transcriptPreview сокращает длинную расшифровку для отчёта
+func transcriptPreview(text string) string {
+	if utf8.RuneCountInString(text) > transcriptPreviewLength {
+		return string([]rune(text)[:transcriptPreviewLength]) + "…"
+	}
+	return text
+}
+
+// formatTaskStatus формирует текстовый отчёт о задаче
+func formatTaskStatus(texts *i18n.Catalog, task *model.Task) string {
+	lines := []string{
+		texts.Text(i18n.TaskReportID, task.ID),
+		texts.Text(i18n.TaskReportStatus, task.Status),
+		texts.Text(i18n.TaskReportAttempts, task.Attempts),
+	}
+
+	if task.ErrorText != nil {
+		lines = append(lines, texts.Text(i18n.TaskReportError, *task.ErrorText))
+	}
+
+	if timings, ok := task.Timings(); ok {
+		lines = append(lines, "",
+			texts.Text(i18n.TaskReportDownload, timings.DownloadMs),
+			texts.Text(i18n.TaskReportUpload, timings.UploadMs),
+			texts.Text(i18n.TaskReportRecognize, timings.RecognitionMs),
+			texts.Text(i18n.TaskReportTotal, timings.TotalMs))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// handleReprocess заново ставит задачу в очередь: /reprocess <task_id>.
+// Счётчик попыток и текст ошибки сбрасываются, поэтому задача перезапускается
+// даже после исчерпания попыток.
+func (b *Bot) handleReprocess(c tele.Context) error {
+	if c.Sender() == nil || !b.isAdmin(c.Sender().ID) {
+		return nil
+	}
+
+	taskID := strings.TrimSpace(c.Message().Payload)
+	if taskID == "" {
+		return c.Send(b.texts.Text(i18n.ReprocessUsage))
+	}
+
+	ctx := context.Background()
+	task, err := b.storage.GetTaskByID(ctx, taskID)
+	if err != nil {
+		logger.Error("Failed to get task for reprocessing",
+			zap.Error(err),
+			zap.String("task_id", taskID))
+		return c.Send(b.texts.Text(i18n.TaskNotFound))
+	}
+
+	task.Requeue()
+	if err := b.storage.UpdateTask(ctx, task); err != nil {
+		logger.Error("Failed to reset task for reprocessing",
+			zap.Error(err),
+			zap.String("task_id", taskID))
+		return c.Send(b.texts.Text(i18n.ReprocessFailed))
+	}
+
+	if err := b.republish(task); err != nil {
+		logger.Error("Failed to republish task",
+			zap.Error(err),
+			zap.String("task_id", taskID))
+		return c.Send(b.texts.Text(i18n.EnqueueFailed))
+	}
+
+	logger.Warn("Task requeued by admin",
+		zap.String("task_id", taskID),
+		zap.Int64("admin_id", c.Sender().ID))
+
+	return c.Send(b.texts.Text(i18n.TaskRequeued, task.ID))
+}
+
+// republish снова ставит сохранённую задачу в очередь. Без RabbitMQ она уже
+// в статусе queued, и её заберут воркеры, опрашивающие базу.
+func (b *Bot) republish(task *model.Task) error {
+	if b.q == nil {
+		return nil
+	}
+	return b.q.PublishTask(voiceTaskFor(task))
+}
+
+// voiceTaskFor восстанавливает сообщение для очереди по сохранённой задаче.
+// Отметка о повторной постановке не даёт принять его за дубликат.
+func voiceTaskFor(task *model.Task) *queue.VoiceTask {
+	voiceTask := queue.NewVoiceTask(task)
+	voiceTask.RequeuedAt = time.Now()
+	return voiceTask
+}
+
+// handleMaintenance включает и выключает режим обслуживания: /maintenance on|off
+func (b *Bot) handleMaintenance(c tele.Context) error {
+	if c.Sender() == nil || !b.isAdmin(c.Sender().ID) {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+	case "":
+		return c.Send(formatMaintenance(b.texts, cache.MaintenanceEnabled(ctx, b.cache)))
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return c.Send(b.texts.Text(i18n.MaintenanceUsage))
+	}
+
+	if err := cache.SetMaintenance(ctx, b.cache, enabled); err != nil {
+		logger.Error("Failed to switch maintenance mode", zap.Error(err))
+		return c.Send(b.texts.Text(i18n.MaintenanceFailed))
+	}
+
+	logger.Warn("Maintenance mode switched",
+		zap.Bool("enabled", enabled),
+		zap.Int64("admin_id", c.Sender().ID))
+
+	return c.Send(formatMaintenance(b.texts, enabled))
+}
+
+// formatMaintenance описывает состояние режима обслуживания
+func formatMaintenance(texts *i18n.Catalog, enabled bool) string {
+	if enabled {
+		return texts.Text(i18n.MaintenanceOn)
+	}
+	return texts.Text(i18n.MaintenanceOff)
+}
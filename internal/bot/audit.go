@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"context"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// auditTimeout bounds a single audit write
+const auditTimeout = 5 * time.Second
+
+// Действия, которые попадают в журнал аудита помимо команд
+const (
+	auditActionVoice    = "voice"
+	auditActionDocument = "document"
+	auditActionAudioURL = "audio_url"
+)
+
+// withAudit записывает действие пользователя в журнал аудита перед вызовом обработчика
+func (b *Bot) withAudit(action string) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			b.recordAudit(newAuditEntry(c, action))
+			return next(c)
+		}
+	}
+}
+
+// newAuditEntry собирает запись аудита из входящего обновления
+func newAuditEntry(c tele.Context, action string) *model.AuditEntry {
+	entry := &model.AuditEntry{
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+	if sender := c.Sender(); sender != nil {
+		entry.UserID = sender.ID
+	}
+	if chat := c.Chat(); chat != nil {
+		entry.ChatID = chat.ID
+	}
+	if msg := c.Message(); msg != nil {
+		entry.Payload = msg.Payload
+	}
+	return entry
+}
+
+// recordAudit сохраняет запись в фоне: сбой аудита не должен мешать обработке
+func (b *Bot) recordAudit(entry *model.AuditEntry) {
+	if b.storage == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+		defer cancel()
+
+		if err := b.storage.RecordAudit(ctx, entry); err != nil {
+			logger.Warn("Failed to record audit entry",
+				zap.String("action", entry.Action),
+				zap.Int64("chat_id", entry.ChatID),
+				zap.Int64("user_id", entry.UserID),
+				zap.Error(err))
+		}
+	}()
+}
@@ -2,12 +2,15 @@ package bot
 
 import (
 	"context"
+	"sync"
 	"time"
 	"voxly/internal/config"
+	"voxly/internal/i18n"
+	"voxly/internal/preferences"
 	"voxly/internal/queue"
-	"voxly/internal/storage"
 	"voxly/pkg/cache"
 	"voxly/pkg/logger"
+	"voxly/pkg/model"
 
 	tele "gopkg.in/telebot.v4"
 
@@ -17,24 +20,58 @@ import (
 type QueuePublisher interface {
 	Publish(queueName string, body []byte) error
 	PublishTask(task *queue.VoiceTask) error
+	QueueDepth(queueName string) (int, error)
+}
+
+// TaskStore is the subset of the database used by the bot
+type TaskStore interface {
+	CreateTask(ctx context.Context, task *model.Task) error
+	GetTaskByID(ctx context.Context, id string) (*model.Task, error)
+	GetTaskByChatAndMessageID(ctx context.Context, chatID, messageID int64) (*model.Task, error)
+	GetTaskByReplyMessageID(ctx context.Context, chatID int64, replyMessageID int) (*model.Task, error)
+	UpdateTask(ctx context.Context, task *model.Task) error
+	DeleteTask(ctx context.Context, id string) error
+	GetTranscriptByTaskID(ctx context.Context, taskID string) (*model.Transcript, error)
+	RecordAudit(ctx context.Context, entry *model.AuditEntry) error
+	preferences.Storage
+}
+
+// ObjectDeleter удаляет объекты из S3
+type ObjectDeleter interface {
+	DeleteFile(ctx context.Context, key string) error
 }
 
 type Bot struct {
 	cfg     *config.Config
 	tb      *tele.Bot
 	q       QueuePublisher
-	storage *storage.PostgresStorage
+	storage TaskStore
 	cache   cache.Cache
+	prefs   *preferences.Store
+	texts   *i18n.Catalog
+	// objects удаляет аудио удалённых задач; nil оставляет его очистке в воркере
+	objects ObjectDeleter
+
+	// handlers counts running handlers so Stop can wait for them
+	handlers sync.WaitGroup
+	// pool runs handlers when Telegram.HandlerWorkers is set; nil runs each update in its own goroutine
+	pool *handlerPool
 }
 
-func NewBot(cfg *config.Config, db *storage.PostgresStorage, q QueuePublisher, redisCache cache.Cache) (*Bot, error) {
+// defaultShutdownTimeout applies when no shutdown timeout is configured
+const defaultShutdownTimeout = 10 * time.Second
+
+func NewBot(cfg *config.Config, db TaskStore, q QueuePublisher, redisCache cache.Cache) (*Bot, error) {
 	logger.Info("Starting bot initialization")
 
 	pref := tele.Settings{
+		URL:   cfg.Telegram.APIURL,
 		Token: cfg.Telegram.Token,
 		Poller: &tele.LongPoller{
 			Timeout: 10 * time.Second,
 		},
+		// The handler pool takes updates from the poller in order
+		Synchronous: cfg.Telegram.HandlerWorkers > 0,
 	}
 
 	if pref.Token == "" {
@@ -50,72 +87,112 @@ func NewBot(cfg *config.Config, db *storage.PostgresStorage, q QueuePublisher, r
 
 	logger.Info("Bot created successfully")
 
+	texts, err := i18n.New(cfg.Telegram.DefaultLocale)
+	if err != nil {
+		logger.Error("Unknown bot locale, using default",
+			zap.String("default", i18n.DefaultLocale),
+			zap.Error(err))
+	}
+
 	bot := &Bot{
 		cfg:     cfg,
 		tb:      tb,
 		storage: db,
 		q:       q,
 		cache:   redisCache,
+		prefs:   preferences.NewStore(db, redisCache),
+		texts:   texts,
+	}
+	if cfg.Telegram.HandlerWorkers > 0 {
+		bot.pool = newHandlerPool(cfg.Telegram.HandlerWorkers, handlerQueueSize)
 	}
 
 	bot.registerHandlers()
 	return bot, nil
 }
 
+// UseObjectStorage включает удаление аудио из S3 вместе с расшифровкой по /delete
+func (b *Bot) UseObjectStorage(objects ObjectDeleter) {
+	b.objects = objects
+}
+
 func (b *Bot) registerHandlers() {
-	b.tb.Handle("/start", b.handleStart)
-	b.tb.Handle("/stop", b.handleStop)
-	b.tb.Handle(tele.OnVoice, b.handleVoice)
+	// Global middleware only wraps handlers registered after it
+	if b.pool != nil {
+		b.tb.Use(b.runInPool, recoverPanics)
+	} else {
+		b.tb.Use(b.trackHandlers, recoverPanics)
+	}
+
+	b.tb.Handle("/start", b.handleStart, b.withAudit("/start"))
+	b.tb.Handle("/stop", b.handleStop, b.withAudit("/stop"))
+	b.tb.Handle("/status", b.handleStatus, b.withAudit("/status"))
+	b.tb.Handle("/threshold", b.handleThreshold, b.withAudit("/threshold"))
+	b.tb.Handle("/timestamps", b.handleTimestamps, b.withAudit("/timestamps"))
+	b.tb.Handle("/settings", b.handleSettings, b.withAudit("/settings"))
+	b.tb.Handle("/delete", b.handleDelete, b.withAudit("/delete"))
+	b.tb.Handle(&btnToggleActive, b.handleToggleActive)
+	b.tb.Handle(&btnToggleProfanity, b.handleToggleProfanity)
+	b.tb.Handle(&btnReprocessModel, b.handleReprocessModel)
+	b.tb.Handle("/maintenance", b.handleMaintenance, b.withAudit("/maintenance"))
+	b.tb.Handle("/reprocess", b.handleReprocess, b.withAudit("/reprocess"))
+	b.tb.Handle(tele.OnVoice, b.handleVoice, b.withAudit(auditActionVoice))
+	b.tb.Handle(tele.OnDocument, b.handleDocument, b.withAudit(auditActionDocument))
+	b.tb.Handle(tele.OnText, b.handleText)
+	b.tb.Handle(tele.OnEdited, b.handleEdited)
+	b.tb.Handle(tele.OnChannelPost, b.handleChannelPost)
 }
 
 // handleStart включает обработку голосовых сообщений для данного чата
 func (b *Bot) handleStart(c tele.Context) error {
 	chatID := c.Chat().ID
-	ctx := context.Background()
 
-	// Сохраняем в Redis с TTL 30 дней
-	key := cache.ChatActiveCacheKey(chatID)
-	if err := b.cache.SetWithTTL(ctx, key, "true", 30*24*time.Hour); err != nil {
-		logger.Error("Failed to save chat active state to cache", zap.Error(err))
+	if err := b.setActive(chatID, true); err != nil {
+		logger.Error("Failed to save chat active state", zap.Error(err))
 	}
 
 	logger.Info("Bot activated for chat",
 		zap.Int64("chat_id", chatID))
 
-	return c.Send("Бот запущен!")
+	return c.Send(b.texts.Text(i18n.BotStarted))
 }
 
 // handleStop выключает обработку голосовых сообщений для данного чата
 func (b *Bot) handleStop(c tele.Context) error {
 	chatID := c.Chat().ID
-	ctx := context.Background()
 
-	// Удаляем из Redis
-	key := cache.ChatActiveCacheKey(chatID)
-	if err := b.cache.Delete(ctx, key); err != nil {
-		logger.Error("Failed to delete chat active state from cache", zap.Error(err))
+	if err := b.setActive(chatID, false); err != nil {
+		logger.Error("Failed to save chat inactive state", zap.Error(err))
 	}
 
 	logger.Info("Bot deactivated for chat",
 		zap.Int64("chat_id", chatID))
 
-	return c.Send("Бот остановлен.\nЧтобы возобновить работу, отправьте /start")
+	return c.Send(b.texts.Text(i18n.BotStopped))
 }
 
-// isActive проверяет, активен ли бот для данного чата
-func (b *Bot) isActive(chatID int64) bool {
-	ctx := context.Background()
-	key := cache.ChatActiveCacheKey(chatID)
+// setActive включает или выключает обработку голосовых сообщений в чате
+func (b *Bot) setActive(chatID int64, active bool) error {
+	_, err := b.prefs.Update(context.Background(), chatID, func(prefs *model.ChatPreferences) {
+		prefs.SetActive(active)
+	})
+	return err
+}
 
-	var value string
-	err := b.cache.Get(ctx, key, &value)
+// chatPreferences возвращает настройки чата; при ошибке чтения — пустые,
+// чтобы действовали значения из конфигурации
+func (b *Bot) chatPreferences(chatID int64) *model.ChatPreferences {
+	prefs, err := b.prefs.Get(context.Background(), chatID)
 	if err != nil {
-		// Ключ не найден или ошибка - бот неактивен
-		return false
+		logger.WithChat(chatID).Error("Failed to get chat preferences", zap.Error(err))
+		return &model.ChatPreferences{ChatID: chatID}
 	}
+	return prefs
+}
 
-	// Проверяем значение
-	return value == "true"
+// isActive проверяет, активен ли бот для данного чата
+func (b *Bot) isActive(chatID int64) bool {
+	return b.chatPreferences(chatID).IsActive(b.cfg.Telegram.DefaultActive)
 }
 
 func (b *Bot) Start() {
@@ -123,7 +200,47 @@ func (b *Bot) Start() {
 	logger.Info("Bot started")
 }
 
+// Stop останавливает получение обновлений и ждёт завершения начатых обработчиков,
+// чтобы задачи не остались созданными наполовину. Ожидание ограничено таймаутом.
 func (b *Bot) Stop() {
 	b.tb.Stop()
+
+	timeout := b.cfg.Telegram.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	if !b.waitHandlers(timeout) {
+		logger.Warn("Bot handlers still running after shutdown timeout",
+			zap.Duration("timeout", timeout))
+	} else if b.pool != nil {
+		// Queues are drained and no updates arrive anymore
+		b.pool.close()
+	}
+
 	logger.Info("Bot stopped")
 }
+
+// trackHandlers учитывает выполняющиеся обработчики для Stop
+func (b *Bot) trackHandlers(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		b.handlers.Add(1)
+		defer b.handlers.Done()
+		return next(c)
+	}
+}
+
+// waitHandlers ждёт завершения обработчиков не дольше timeout и сообщает, дождался ли
+func (b *Bot) waitHandlers(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		b.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
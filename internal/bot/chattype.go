@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"strings"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// isChannel сообщает, что чат — канал: сообщения в нём видят все подписчики
+func isChannel(chat *tele.Chat) bool {
+	return chat != nil && (chat.Type == tele.ChatChannel || chat.Type == tele.ChatChannelPrivate)
+}
+
+// isGroup сообщает, что чат — группа, где ответ нужно привязать к сообщению участника
+func isGroup(chat *tele.Chat) bool {
+	return chat != nil && (chat.Type == tele.ChatGroup || chat.Type == tele.ChatSuperGroup)
+}
+
+// respond отвечает на входящее сообщение с учётом типа чата: в группах —
+// ответом на него, чтобы было понятно, к чьему сообщению он относится;
+// в личных чатах и каналах — отдельным сообщением
+func (b *Bot) respond(c tele.Context, text string) error {
+	if isGroup(c.Chat()) {
+		return c.Reply(text)
+	}
+	return c.Send(text)
+}
+
+// acknowledge отправляет подтверждение приёма аудио и возвращает его, чтобы
+// воркер мог потом его изменить или удалить. В каналах подтверждение не
+// отправляется: его увидели бы все подписчики.
+func (b *Bot) acknowledge(c tele.Context, text string) (*tele.Message, error) {
+	msg := c.Message()
+	switch {
+	case isChannel(msg.Chat):
+		return nil, nil
+	case isGroup(msg.Chat):
+		return c.Bot().Reply(msg, text)
+	default:
+		return c.Bot().Send(msg.Chat, text, &tele.SendOptions{ThreadID: msg.ThreadID})
+	}
+}
+
+// handleChannelPost обрабатывает публикации в каналах. Telegram присылает их
+// отдельным типом обновления, поэтому голосовые, аудиофайлы и команды
+// включения разбираются здесь.
+func (b *Bot) handleChannelPost(c tele.Context) error {
+	msg := c.Message()
+	if msg == nil {
+		return nil
+	}
+
+	switch {
+	case msg.Voice != nil:
+		b.recordAudit(newAuditEntry(c, auditActionVoice))
+		return b.handleVoice(c)
+	case msg.Document != nil:
+		b.recordAudit(newAuditEntry(c, auditActionDocument))
+		return b.handleDocument(c)
+	}
+
+	switch channelCommand(msg.Text) {
+	case "/start":
+		b.recordAudit(newAuditEntry(c, "/start"))
+		return b.handleStart(c)
+	case "/stop":
+		b.recordAudit(newAuditEntry(c, "/stop"))
+		return b.handleStop(c)
+	}
+
+	logger.WithChat(msg.Chat.ID).Debug("Ignoring channel post",
+		zap.Int("message_id", msg.ID))
+	return nil
+}
+
+// channelCommand возвращает команду из текста публикации без упоминания бота,
+// например "/start" из "/start@voxly_bot", или пустую строку
+func channelCommand(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+	command, _, _ := strings.Cut(fields[0], "@")
+	return command
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"voxly/internal/i18n"
+	"voxly/internal/storage"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// handleDelete удаляет расшифровку, её задачу и аудио в S3 по ответу командой
+// /delete на расшифровку или на само голосовое сообщение. Удалить может
+// отправитель голосового сообщения или администратор чата.
+func (b *Bot) handleDelete(c tele.Context) error {
+	msg := c.Message()
+	if msg.ReplyTo == nil {
+		return c.Reply(b.texts.Text(i18n.DeleteUsage))
+	}
+
+	task := b.taskForReply(msg.Chat.ID, msg.ReplyTo.ID)
+	if task == nil {
+		return c.Reply(b.texts.Text(i18n.DeleteNotFound))
+	}
+
+	log := logger.WithTask(task.ID)
+
+	// A running worker would recreate the transcript
+	if !task.IsCompleted() {
+		return c.Reply(b.texts.Text(i18n.DeleteInProgress))
+	}
+
+	if !b.canDelete(c, task) {
+		log.Info("Transcript deletion refused", zap.Int64("user_id", senderID(c)))
+		return c.Reply(b.texts.Text(i18n.DeleteForbidden))
+	}
+
+	ctx := context.Background()
+	if err := b.storage.DeleteTask(ctx, task.ID); err != nil && !errors.Is(err, storage.ErrTaskNotFound) {
+		log.Error("Failed to delete task", zap.Error(err))
+		return c.Reply(b.texts.Text(i18n.DeleteFailed))
+	}
+
+	// Cached copies would outlive the task, the recognition result included
+	keys := []string{cache.TaskCacheKey(task.ID), cache.TranscriptCacheKey(task.ID)}
+	if resultKey := task.ResultCacheKey(); resultKey != "" {
+		keys = append(keys, resultKey)
+	}
+	for _, key := range keys {
+		if err := b.cache.Delete(ctx, key); err != nil {
+			log.Warn("Failed to delete cached task", zap.String("key", key), zap.Error(err))
+		}
+	}
+
+	b.deleteAudio(log, task)
+	b.deleteTranscriptMessage(log, task)
+
+	log.Info("Transcript deleted on request", zap.Int64("user_id", senderID(c)))
+	return c.Send(b.texts.Text(i18n.TranscriptDeleted))
+}
+
+// taskForReply находит задачу по сообщению, на которое ответили: сначала как по
+// расшифровке, затем как по голосовому сообщению. Возвращает nil, если задачи нет.
+func (b *Bot) taskForReply(chatID int64, messageID int) *model.Task {
+	ctx := context.Background()
+	log := logger.WithChat(chatID)
+
+	task, err := b.storage.GetTaskByReplyMessageID(ctx, chatID, messageID)
+	if err == nil {
+		return task
+	}
+	if !errors.Is(err, storage.ErrTaskNotFound) {
+		log.Warn("Failed to get task by transcript message", zap.Int("message_id", messageID), zap.Error(err))
+	}
+
+	task, err = b.storage.GetTaskByChatAndMessageID(ctx, chatID, int64(messageID))
+	if err != nil {
+		if !errors.Is(err, storage.ErrTaskNotFound) {
+			log.Warn("Failed to get task by voice message", zap.Int("message_id", messageID), zap.Error(err))
+		}
+		return nil
+	}
+	return task
+}
+
+// canDelete сообщает, может ли автор команды удалить расшифровку задачи.
+// В личном чате других участников нет, поэтому там это разрешено всегда.
+func (b *Bot) canDelete(c tele.Context, task *model.Task) bool {
+	sender := c.Sender()
+	if sender == nil {
+		return false
+	}
+	if task.SenderID() == sender.ID || c.Chat().Type == tele.ChatPrivate {
+		return true
+	}
+
+	member, err := b.tb.ChatMemberOf(c.Chat(), sender)
+	if err != nil {
+		logger.WithChat(c.Chat().ID).Warn("Failed to get chat member", zap.Int64("user_id", sender.ID), zap.Error(err))
+		return false
+	}
+	return member.Role == tele.Administrator || member.Role == tele.Creator
+}
+
+// deleteAudio удаляет из S3 аудио задачи. Ошибка не мешает удалению
+// расшифровки: аудио в voice/ позже уберёт очистка осиротевших объектов.
+func (b *Bot) deleteAudio(log *zap.Logger, task *model.Task) {
+	if b.objects == nil {
+		return
+	}
+
+	for _, key := range task.AudioKeys() {
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.S3CallTimeout())
+		err := b.objects.DeleteFile(ctx, key)
+		cancel()
+		if err != nil {
+			log.Warn("Failed to delete task audio", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// deleteTranscriptMessage удаляет из чата сообщение с расшифровкой. Если она
+// была разбита на несколько сообщений, известно и удаляется только первое.
+func (b *Bot) deleteTranscriptMessage(log *zap.Logger, task *model.Task) {
+	messageID := task.ReplyMessageID()
+	if messageID == 0 {
+		return
+	}
+
+	msg := &tele.StoredMessage{MessageID: strconv.Itoa(messageID), ChatID: task.ChatID}
+	if err := b.tb.Delete(msg); err != nil {
+		log.Warn("Failed to delete transcript message", zap.Int("message_id", messageID), zap.Error(err))
+	}
+}
+
+// senderID возвращает ID автора обновления или 0, если он неизвестен
+func senderID(c tele.Context) int64 {
+	if c.Sender() == nil {
+		return 0
+	}
+	return c.Sender().ID
+}
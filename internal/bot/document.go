@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"path/filepath"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// allowedAudioMIMETypes lists document MIME types accepted for recognition.
+// SpeechKit is configured for OGG/Opus, the format of Telegram voice messages.
+var allowedAudioMIMETypes = []string{"audio/ogg", "audio/opus", "audio/x-opus+ogg", "application/ogg"}
+
+// allowedAudioExtensions are used when the MIME type is missing or generic
+var allowedAudioExtensions = []string{".ogg", ".oga", ".opus"}
+
+// handleDocument принимает аудиофайлы, отправленные документом
+func (b *Bot) handleDocument(c tele.Context) error {
+	msg := c.Message()
+	if msg == nil || msg.Document == nil {
+		return nil
+	}
+
+	if !b.isActive(msg.Chat.ID) {
+		return b.hintInactive(c)
+	}
+
+	doc := msg.Document
+	if reason := documentRejection(doc.MIME, doc.FileName); reason != "" {
+		logger.Info("Rejected document",
+			zap.Int64("chat_id", msg.Chat.ID),
+			zap.String("mime_type", doc.MIME),
+			zap.String("file_name", doc.FileName))
+
+		return b.respond(c, b.texts.Text(reason))
+	}
+
+	return b.enqueueAudio(c, audioInput{
+		FileID:   doc.FileID,
+		FileName: doc.FileName,
+		FileSize: doc.FileSize,
+		MIME:     doc.MIME,
+	})
+}
+
+// documentRejection returns the reply for a document that can't be
+// recognized, or an empty key if the document is supported audio
+func documentRejection(mime, fileName string) i18n.Key {
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = strings.TrimSpace(mime[:i])
+	}
+
+	if mime != "" && mime != "application/octet-stream" {
+		for _, allowed := range allowedAudioMIMETypes {
+			if mime == allowed {
+				return ""
+			}
+		}
+		if strings.HasPrefix(mime, "audio/") {
+			return i18n.UnsupportedAudio
+		}
+		return i18n.NotAudio
+	}
+
+	// Without a meaningful MIME type, trust the file extension
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, allowed := range allowedAudioExtensions {
+		if ext == allowed {
+			return ""
+		}
+	}
+	return i18n.NotAudio
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"voxly/internal/i18n"
+	"voxly/internal/storage"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// handleEdited заново распознаёт отредактированное голосовое сообщение.
+// Если по сообщению уже была задача, она ставится в очередь повторно и воркер
+// обновляет отправленную расшифровку; иначе сообщение обрабатывается как новое.
+func (b *Bot) handleEdited(c tele.Context) error {
+	msg := c.Message()
+	if msg == nil || msg.Voice == nil {
+		return nil
+	}
+
+	task := b.taskForMessage(msg)
+	if task == nil {
+		return b.handleVoice(c)
+	}
+
+	log := logger.WithTask(task.ID)
+
+	// The running recognition will reply on its own
+	if !task.IsCompleted() {
+		log.Info("Ignoring edit of a voice message still being processed")
+		return nil
+	}
+
+	if !b.isActive(msg.Chat.ID) {
+		return nil
+	}
+
+	if cache.MaintenanceEnabled(context.Background(), b.cache) {
+		return b.respond(c, b.texts.Text(i18n.Maintenance))
+	}
+
+	// The edit may have replaced the recording itself
+	task.FileID = msg.Voice.FileID
+	task.Meta["voice_duration"] = msg.Voice.Duration
+	task.Meta["file_size"] = msg.Voice.FileSize
+	task.Meta["mime_type"] = msg.Voice.MIME
+	task.Requeue()
+
+	ctx := context.Background()
+	if err := b.storage.UpdateTask(ctx, task); err != nil {
+		log.Error("Failed to reset task of edited message", zap.Error(err))
+		return b.respond(c, b.texts.Text(i18n.TaskSaveFailed))
+	}
+
+	if err := b.republish(task); err != nil {
+		log.Error("Failed to republish task of edited message", zap.Error(err))
+		return b.respond(c, b.texts.Text(i18n.EnqueueFailed))
+	}
+
+	log.Info("Task requeued after voice message edit",
+		zap.Int("reply_message_id", task.ReplyMessageID()))
+	return nil
+}
+
+// taskForMessage возвращает задачу, созданную по сообщению, или nil, если её нет
+func (b *Bot) taskForMessage(msg *tele.Message) *model.Task {
+	task, err := b.storage.GetTaskByChatAndMessageID(context.Background(), msg.Chat.ID, int64(msg.ID))
+	if err != nil {
+		if !errors.Is(err, storage.ErrTaskNotFound) {
+			logger.WithChat(msg.Chat.ID).Warn("Failed to get task of edited message",
+				zap.Int("message_id", msg.ID),
+				zap.Error(err))
+		}
+		return nil
+	}
+	if task.Meta == nil {
+		task.Meta = model.JSONB{}
+	}
+	return task
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"strings"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// forwardedFrom возвращает имя исходного отправителя пересланного сообщения.
+// Для пользователей, скрывших аккаунт, Telegram передаёт только имя;
+// пустая строка означает, что сообщение не переслано или автор неизвестен.
+func forwardedFrom(msg *tele.Message) string {
+	switch {
+	case msg.OriginalSender != nil:
+		return userDisplayName(msg.OriginalSender)
+	case msg.OriginalSenderName != "":
+		return msg.OriginalSenderName
+	case msg.OriginalChat != nil:
+		return chatDisplayName(msg.OriginalChat, msg.OriginalSignature)
+	case msg.Origin != nil:
+		origin := msg.Origin
+		switch {
+		case origin.Sender != nil:
+			return userDisplayName(origin.Sender)
+		case origin.SenderUsername != "":
+			return origin.SenderUsername
+		case origin.SenderChat != nil:
+			return chatDisplayName(origin.SenderChat, origin.Signature)
+		case origin.Chat != nil:
+			return chatDisplayName(origin.Chat, origin.Signature)
+		}
+	}
+	return ""
+}
+
+// userDisplayName формирует имя пользователя вида «Имя Фамилия (@username)»
+func userDisplayName(user *tele.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	switch {
+	case user.Username == "":
+		return name
+	case name == "":
+		return "@" + user.Username
+	default:
+		return name + " (@" + user.Username + ")"
+	}
+}
+
+// chatDisplayName возвращает название канала или группы с подписью автора, если она есть
+func chatDisplayName(chat *tele.Chat, signature string) string {
+	name := chat.Title
+	if name == "" && chat.Username != "" {
+		name = "@" + chat.Username
+	}
+	if signature != "" {
+		if name == "" {
+			return signature
+		}
+		return name + " (" + signature + ")"
+	}
+	return name
+}
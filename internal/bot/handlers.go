@@ -2,8 +2,11 @@ package bot
 
 import (
 	"context"
+	"math"
 	"time"
+	"voxly/internal/i18n"
 	"voxly/internal/queue"
+	"voxly/pkg/cache"
 	"voxly/pkg/logger"
 	"voxly/pkg/model"
 
@@ -15,20 +18,124 @@ import (
 func (b *Bot) handleVoice(c tele.Context) error {
 	msg := c.Message()
 	if msg == nil || msg.Voice == nil {
-		return c.Reply("Ошибка: голосовое сообщение не найдено")
+		return b.respond(c, b.texts.Text(i18n.VoiceNotFound))
 	}
 
 	// Check if bot is active for this chat
 	if !b.isActive(msg.Chat.ID) {
-		logger.Info("Ignoring voice message from inactive chat",
-			zap.Int64("chat_id", msg.Chat.ID),
+		logger.WithChat(msg.Chat.ID).Info("Ignoring voice message from inactive chat",
 			zap.Int("message_id", msg.ID))
 
-		return nil
+		return b.hintInactive(c)
 	}
 
-	if err := c.Reply("Обработка..."); err != nil {
-		logger.Error("Failed to send processing message", zap.Error(err))
+	// Accidental taps produce sub-second recordings with nothing to recognize
+	if tooShort(msg.Voice.Duration, b.cfg.Worker.MinDurationSeconds) {
+		logger.WithChat(msg.Chat.ID).Info("Skipping too short voice message",
+			zap.Int("duration", msg.Voice.Duration))
+
+		return b.respond(c, b.texts.Text(i18n.TooShort))
+	}
+
+	return b.enqueueAudio(c, audioInput{
+		FileID:   msg.Voice.FileID,
+		Duration: msg.Voice.Duration,
+		FileSize: msg.Voice.FileSize,
+		MIME:     msg.Voice.MIME,
+	})
+}
+
+// tooShort сообщает, что запись короче минимальной длительности; 0 отключает проверку
+func tooShort(duration, minSeconds int) bool {
+	return minSeconds > 0 && duration < minSeconds
+}
+
+// queueOverloaded сообщает, что в очереди, куда попадёт аудио, больше задач, чем
+// допускает конфигурация. Если глубину очереди узнать не удалось, сообщения
+// принимаются как обычно.
+func (b *Bot) queueOverloaded(audio audioInput) bool {
+	limit := b.cfg.RabbitMQ.MaxQueueDepth
+	if limit <= 0 || b.q == nil {
+		return false
+	}
+
+	name := b.cfg.TaskRouting().QueueFor(&queue.VoiceTask{Duration: audio.Duration, MimeType: audio.MIME})
+	depth, err := b.q.QueueDepth(name)
+	if err != nil {
+		logger.Warn("Failed to get queue depth", zap.String("queue", name), zap.Error(err))
+		return false
+	}
+
+	if depth >= limit {
+		logger.Warn("Queue overloaded, rejecting voice message",
+			zap.String("queue", name),
+			zap.Int("depth", depth),
+			zap.Int("limit", limit))
+		return true
+	}
+	return false
+}
+
+// etaLookupTimeout bounds reading the processing rate for an acknowledgment
+const etaLookupTimeout = 500 * time.Millisecond
+
+// processingText возвращает подтверждение приёма; для записей, обработка которых
+// займёт хотя бы минуту, в нём указывается примерное время по средней скорости
+// обработки прошлых задач
+func (b *Bot) processingText(log *zap.Logger, durationSec int) string {
+	// A slow cache mustn't hold up the acknowledgment; without an ETA it is sent plain
+	ctx, cancel := context.WithTimeout(context.Background(), etaLookupTimeout)
+	defer cancel()
+
+	eta, ok := cache.EstimateProcessing(ctx, b.cache, time.Duration(durationSec)*time.Second)
+	log.Info("Processing started",
+		zap.Int("duration", durationSec),
+		zap.Bool("has_eta", ok),
+		zap.Duration("eta", eta))
+
+	if !ok || eta < time.Minute {
+		return b.texts.Text(i18n.Processing)
+	}
+	return b.texts.Text(i18n.ProcessingETA, int(math.Ceil(eta.Minutes())))
+}
+
+// audioInput описывает аудиофайл из голосового сообщения или документа
+type audioInput struct {
+	FileID    string
+	FileName  string
+	Duration  int // секунды, 0 если неизвестна
+	FileSize  int64
+	MIME      string
+	SourceURL string // ссылка на аудио, присланная текстом, вместо FileID
+}
+
+// enqueueAudio создаёт задачу на распознавание и отправляет её в очередь
+func (b *Bot) enqueueAudio(c tele.Context, audio audioInput) error {
+	msg := c.Message()
+	log := logger.WithChat(msg.Chat.ID)
+
+	// Telegram won't let the worker download larger files, don't create a doomed task
+	if audio.FileSize > queue.MaxFileSize {
+		log.Info("Rejected oversized file",
+			zap.Int64("file_size", audio.FileSize))
+
+		return b.respond(c, b.texts.Text(i18n.FileTooLarge))
+	}
+
+	// New tasks are not accepted while the service is paused
+	if cache.MaintenanceEnabled(context.Background(), b.cache) {
+		return b.respond(c, b.texts.Text(i18n.Maintenance))
+	}
+
+	// Don't pile up work the workers can't keep up with
+	if b.queueOverloaded(audio) {
+		return b.respond(c, b.texts.Text(i18n.QueueOverloaded))
+	}
+
+	// Keep the acknowledgment's ID so the worker can edit or delete it later
+	processing, err := b.acknowledge(c, b.processingText(log, audio.Duration))
+	if err != nil {
+		log.Error("Failed to send processing message", zap.Error(err))
 	}
 
 	// Creating task
@@ -36,33 +143,44 @@ func (b *Bot) handleVoice(c tele.Context) error {
 		ID:                uuid.New().String(),
 		TelegramMessageID: int64(msg.ID),
 		ChatID:            msg.Chat.ID,
-		FileID:            msg.Voice.FileID,
+		FileID:            audio.FileID,
 		Status:            model.TaskStatusQueued,
 		OperationID:       nil,
 		Attempts:          0,
 		ErrorText:         nil,
 		Meta: model.JSONB{
-			"voice_duration": msg.Voice.Duration,
-			"file_size":      msg.Voice.FileSize,
-			"mime_type":      msg.Voice.MIME,
+			"voice_duration": audio.Duration,
+			"file_size":      audio.FileSize,
+			"mime_type":      audio.MIME,
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	task.SetThreadID(msg.ThreadID)
+	task.SetChatType(string(msg.Chat.Type))
+	if msg.Sender != nil {
+		task.SetSenderID(msg.Sender.ID)
+	}
+	task.SetForwardFrom(forwardedFrom(msg))
+	task.SetSourceURL(audio.SourceURL)
+	if processing != nil {
+		task.SetProcessingMessageID(processing.ID)
+	}
+	if audio.FileName != "" {
+		task.Meta["file_name"] = audio.FileName
+	}
+
+	log = log.With(zap.String("task_id", task.ID))
 
 	// Saving task to database
 	ctx := context.Background()
 	if err := b.storage.CreateTask(ctx, &task); err != nil {
-		logger.Error("Failed to create task in database",
-			zap.Error(err),
-			zap.String("task_id", task.ID))
-		return c.Reply("Ошибка при сохранении задачи")
+		log.Error("Failed to create task in database", zap.Error(err))
+		return b.respond(c, b.texts.Text(i18n.TaskSaveFailed))
 	}
 
-	logger.Info("Task created in database",
-		zap.String("task_id", task.ID),
-		zap.Int64("telegram_message_id", task.TelegramMessageID),
-		zap.Int64("chat_id", task.ChatID))
+	log.Info("Task created in database",
+		zap.Int64("telegram_message_id", task.TelegramMessageID))
 
 	// Sending task to RabbitMQ
 	if b.q != nil {
@@ -71,21 +189,19 @@ func (b *Bot) handleVoice(c tele.Context) error {
 			ChatID:            task.ChatID,
 			TelegramMessageID: task.TelegramMessageID,
 			FileID:            task.FileID,
-			Duration:          msg.Voice.Duration,
-			FileSize:          int64(msg.Voice.FileSize),
-			MimeType:          msg.Voice.MIME,
+			Duration:          audio.Duration,
+			FileSize:          audio.FileSize,
+			MimeType:          audio.MIME,
+			SourceURL:         audio.SourceURL,
 			CreatedAt:         task.CreatedAt,
 		}
 
 		if err := b.q.PublishTask(voiceTask); err != nil {
-			logger.Error("Failed to publish task to queue",
-				zap.Error(err),
-				zap.String("task_id", task.ID))
-			return c.Reply("Ошибка при отправке задачи в очередь")
+			log.Error("Failed to publish task to queue", zap.Error(err))
+			return b.respond(c, b.texts.Text(i18n.EnqueueFailed))
 		}
 
-		logger.Info("Task published to queue",
-			zap.String("task_id", task.ID))
+		log.Info("Task published to queue")
 	}
 
 	return nil
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"time"
+	"voxly/internal/i18n"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// inactiveHintTimeout bounds the cache call of a hint
+const inactiveHintTimeout = 2 * time.Second
+
+// hintInactive подсказывает неактивному чату, как включить распознавание, —
+// не чаще раза за InactiveHintInterval. Если кэш недоступен, бот молчит.
+func (b *Bot) hintInactive(c tele.Context) error {
+	// A hint in a channel would be posted to every subscriber
+	if !b.cfg.Telegram.InactiveHint || isChannel(c.Chat()) {
+		return nil
+	}
+	// Claiming the hint in one call keeps messages handled at once from each sending it
+	claimer, ok := b.cache.(cache.Claimer)
+	if !ok {
+		return nil
+	}
+
+	chatID := c.Chat().ID
+	log := logger.WithChat(chatID)
+	key := cache.InactiveHintCacheKey(chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), inactiveHintTimeout)
+	defer cancel()
+
+	claimed, err := claimer.SetIfAbsent(ctx, key, true, b.cfg.Telegram.InactiveHintInterval)
+	if err != nil {
+		log.Warn("Failed to claim inactive chat hint", zap.Error(err))
+		return nil
+	}
+	if !claimed {
+		return nil
+	}
+
+	return b.respond(c, b.texts.Text(i18n.InactiveHint))
+}
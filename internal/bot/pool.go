@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"sync"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// handlerQueueSize — сколько обновлений ждёт своей очереди у одного воркера;
+// когда очередь заполнена, поллер ждёт, пока она освободится
+const handlerQueueSize = 16
+
+// handlerPool выполняет обработчики на ограниченном числе воркеров, чтобы долгая
+// работа с базой и очередью не задерживала получение обновлений. Обновления
+// одного чата всегда достаются одному воркеру и обрабатываются по порядку.
+type handlerPool struct {
+	shards []chan func()
+	wg     sync.WaitGroup
+}
+
+// newHandlerPool запускает workers воркеров, у каждого очередь на queueSize задач
+func newHandlerPool(workers, queueSize int) *handlerPool {
+	p := &handlerPool{shards: make([]chan func(), workers)}
+	for i := range p.shards {
+		p.shards[i] = make(chan func(), queueSize)
+		p.wg.Add(1)
+		go p.work(p.shards[i])
+	}
+	return p
+}
+
+func (p *handlerPool) work(jobs <-chan func()) {
+	defer p.wg.Done()
+	for job := range jobs {
+		job()
+	}
+}
+
+// submit ставит задачу в очередь воркера чата; блокируется, пока очередь полна
+func (p *handlerPool) submit(chatID int64, job func()) {
+	p.shards[uint64(chatID)%uint64(len(p.shards))] <- job
+}
+
+// close дожидается выполнения поставленных задач и останавливает воркеры.
+// После close задачи ставить нельзя.
+func (p *handlerPool) close() {
+	for _, jobs := range p.shards {
+		close(jobs)
+	}
+	p.wg.Wait()
+}
+
+// runInPool передаёт обработку обновления в пул и сразу возвращает управление
+// поллеру. Обработчик учитывается для Stop с момента постановки в очередь.
+func (b *Bot) runInPool(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		b.handlers.Add(1)
+		b.pool.submit(updateChatID(c), func() {
+			defer b.handlers.Done()
+			if err := next(c); err != nil {
+				b.tb.OnError(err, c)
+			}
+		})
+		return nil
+	}
+}
+
+// updateChatID возвращает чат обновления, а если его нет — отправителя
+func updateChatID(c tele.Context) int64 {
+	if chat := c.Chat(); chat != nil {
+		return chat.ID
+	}
+	if sender := c.Sender(); sender != nil {
+		return sender.ID
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"fmt"
+	"runtime/debug"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// recoverPanics не даёт панике в обработчике уронить бота:
+// она логируется со стеком и превращается в ошибку обработчика
+func recoverPanics(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				var chatID int64
+				if chat := c.Chat(); chat != nil {
+					chatID = chat.ID
+				}
+				logger.Error("Bot handler panicked",
+					zap.Int64("chat_id", chatID),
+					zap.Any("panic", r),
+					zap.ByteString("stack", debug.Stack()))
+				err = fmt.Errorf("handler panicked: %v", r)
+			}
+		}()
+		return next(c)
+	}
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"voxly/internal/i18n"
+	"voxly/internal/storage"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// btnReprocessModel — кнопка под расшифровкой с низкой уверенностью, которую
+// воркер добавляет сам. Данные кнопки — ID задачи.
+var btnReprocessModel = tele.Btn{Unique: model.ButtonReprocessModel}
+
+// handleReprocessModel заново распознаёт расшифровку с низкой уверенностью
+// моделью из настроек. Каждую задачу так можно перераспознать один раз.
+func (b *Bot) handleReprocessModel(c tele.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+
+	task, err := b.storage.GetTaskByID(ctx, c.Callback().Data)
+	if err != nil || task.ChatID != chatID {
+		if err != nil && !errors.Is(err, storage.ErrTaskNotFound) {
+			logger.WithChat(chatID).Error("Failed to get task for reprocessing with another model", zap.Error(err))
+		}
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.TaskNotFound)})
+	}
+	if task.Meta == nil {
+		task.Meta = model.JSONB{}
+	}
+
+	if !task.IsCompleted() || task.ModelOverride() != "" {
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.ReprocessModelUnavailable)})
+	}
+
+	log := logger.WithTask(task.ID)
+
+	task.SetModelOverride(b.cfg.SpeechKit.ReprocessModel)
+	task.Requeue()
+	if err := b.storage.UpdateTask(ctx, task); err != nil {
+		log.Error("Failed to reset task for reprocessing with another model", zap.Error(err))
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.TaskSaveFailed)})
+	}
+
+	if err := b.republish(task); err != nil {
+		log.Error("Failed to republish task for reprocessing with another model", zap.Error(err))
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.EnqueueFailed)})
+	}
+
+	// The worker edits the transcript when done; until then the button is just removed
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+		log.Warn("Failed to remove reprocess button", zap.Error(err))
+	}
+
+	log.Info("Task requeued with another model",
+		zap.String("model", b.cfg.SpeechKit.ReprocessModel))
+
+	return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.ReprocessModelStarted)})
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"voxly/internal/queue"
+	"voxly/internal/storage"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// HandleResult warms the transcript cache from a TranscriptionResult published
+// by the worker, for transcriptFor to read. Consume the results queue with it.
+func (b *Bot) HandleResult(body []byte) error {
+	var result queue.TranscriptionResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		// Redelivery won't fix a malformed message, drop it
+		logger.Error("Failed to unmarshal transcription result", zap.Error(err))
+		return nil
+	}
+
+	// Failed tasks have nothing to cache; an earlier transcript stays valid
+	if !result.Success || result.Text == "" {
+		return nil
+	}
+
+	transcript := &model.Transcript{
+		ID:          result.TranscriptID,
+		TaskID:      result.TaskID,
+		Text:        result.Text,
+		RawResponse: result.RawResponse,
+		Version:     result.Version,
+		CreatedAt:   result.CreatedAt,
+	}
+
+	// The cache is only a shortcut to the database; requeueing the result while
+	// it is down would just redeliver it in a loop
+	key := cache.TranscriptCacheKey(result.TaskID)
+	if err := b.cache.SetWithTTL(context.Background(), key, transcript, b.cfg.TranscriptCacheTTL()); err != nil {
+		logger.Error("Failed to cache transcript",
+			zap.String("task_id", result.TaskID),
+			zap.Error(err))
+		return nil
+	}
+
+	logger.Debug("Transcript cached from result", zap.String("task_id", result.TaskID))
+	return nil
+}
+
+// transcriptFor возвращает последнюю расшифровку задачи: из кэша, который
+// наполняет HandleResult, а при промахе из базы, заодно кэшируя её.
+// Возвращает nil, если расшифровки нет.
+func (b *Bot) transcriptFor(ctx context.Context, taskID string) *model.Transcript {
+	log := logger.WithTask(taskID)
+	key := cache.TranscriptCacheKey(taskID)
+
+	var cached model.Transcript
+	if err := b.cache.Get(ctx, key, &cached); err == nil && cached.Text != "" {
+		return &cached
+	}
+
+	transcript, err := b.storage.GetTranscriptByTaskID(ctx, taskID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrTranscriptNotFound) {
+			log.Warn("Failed to get transcript", zap.Error(err))
+		}
+		return nil
+	}
+
+	if err := b.cache.SetWithTTL(ctx, key, transcript, b.cfg.TranscriptCacheTTL()); err != nil {
+		log.Warn("Failed to cache transcript", zap.Error(err))
+	}
+	return transcript
+}
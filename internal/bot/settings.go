@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Кнопки сообщения /settings для переключения логических настроек
+var (
+	btnToggleActive    = tele.Btn{Unique: "settings_active"}
+	btnToggleProfanity = tele.Btn{Unique: "settings_profanity"}
+)
+
+// chatSettings собирает все настройки чата в одном месте
+type chatSettings struct {
+	Active          bool
+	Threshold       float64
+	Language        string // язык распознавания или speechkit.LanguageAuto
+	Detected        string // последний определённый язык при автоопределении
+	ProfanityFilter bool
+	Timestamps      bool
+}
+
+// chatSettings читает сохранённые настройки чата, подставляя значения по умолчанию
+func (b *Bot) chatSettings(chatID int64) chatSettings {
+	prefs := b.chatPreferences(chatID)
+	settings := chatSettings{
+		Active:          prefs.IsActive(b.cfg.Telegram.DefaultActive),
+		Threshold:       prefs.ConfidenceThreshold(b.cfg.Reply.ConfidenceThreshold),
+		Language:        prefs.Language,
+		ProfanityFilter: prefs.ProfanityFilter,
+		Timestamps:      prefs.Timestamps,
+	}
+	if settings.Language == "" {
+		settings.Language = b.cfg.SpeechKit.Language
+	}
+	if settings.Language == "" {
+		settings.Language = speechkit.DefaultLanguage
+	}
+
+	if settings.Language == speechkit.LanguageAuto {
+		var history []string
+		if err := b.cache.Get(context.Background(), cache.ChatLanguageCacheKey(chatID), &history); err == nil && len(history) > 0 {
+			settings.Detected = history[len(history)-1]
+		}
+	}
+
+	return settings
+}
+
+// handleSettings показывает текущие настройки чата
+func (b *Bot) handleSettings(c tele.Context) error {
+	settings := b.chatSettings(c.Chat().ID)
+	return c.Send(formatSettings(b.texts, settings), settingsMarkup(b.texts, settings))
+}
+
+// handleToggleActive переключает активность бота по кнопке из /settings
+func (b *Bot) handleToggleActive(c tele.Context) error {
+	chatID := c.Chat().ID
+	active := !b.isActive(chatID)
+
+	if err := b.setActive(chatID, active); err != nil {
+		logger.Error("Failed to toggle chat active state", zap.Error(err))
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.SettingSaveFailed)})
+	}
+
+	logger.WithChat(chatID).Info("Chat active state toggled from settings",
+		zap.Bool("active", active))
+
+	return b.refreshSettings(c, chatID)
+}
+
+// handleToggleProfanity переключает фильтр ненормативной лексики по кнопке из /settings
+func (b *Bot) handleToggleProfanity(c tele.Context) error {
+	chatID := c.Chat().ID
+
+	prefs, err := b.prefs.Update(context.Background(), chatID, func(prefs *model.ChatPreferences) {
+		prefs.ProfanityFilter = !prefs.ProfanityFilter
+	})
+	if err != nil {
+		logger.Error("Failed to toggle profanity filter", zap.Error(err))
+		return c.Respond(&tele.CallbackResponse{Text: b.texts.Text(i18n.SettingSaveFailed)})
+	}
+
+	logger.WithChat(chatID).Info("Profanity filter toggled from settings",
+		zap.Bool("profanity_filter", prefs.ProfanityFilter))
+
+	return b.refreshSettings(c, chatID)
+}
+
+// refreshSettings обновляет сообщение /settings после изменения настройки
+func (b *Bot) refreshSettings(c tele.Context, chatID int64) error {
+	settings := b.chatSettings(chatID)
+	if err := c.Edit(formatSettings(b.texts, settings), settingsMarkup(b.texts, settings)); err != nil {
+		logger.Warn("Failed to update settings message", zap.Error(err))
+	}
+	return c.Respond()
+}
+
+// formatSettings описывает настройки чата для пользователя
+func formatSettings(texts *i18n.Catalog, s chatSettings) string {
+	lines := []string{texts.Text(i18n.SettingsTitle), ""}
+
+	if s.Active {
+		lines = append(lines, texts.Text(i18n.SettingsActiveOn))
+	} else {
+		lines = append(lines, texts.Text(i18n.SettingsActiveOff))
+	}
+
+	if s.Threshold > 0 {
+		lines = append(lines, texts.Text(i18n.SettingsThreshold, s.Threshold))
+	} else {
+		lines = append(lines, texts.Text(i18n.SettingsThresholdOff))
+	}
+
+	switch {
+	case s.Language != speechkit.LanguageAuto:
+		lines = append(lines, texts.Text(i18n.SettingsLanguage, s.Language))
+	case s.Detected != "":
+		lines = append(lines, texts.Text(i18n.SettingsLanguageLast, s.Detected))
+	default:
+		lines = append(lines, texts.Text(i18n.SettingsLanguageAuto))
+	}
+
+	if s.ProfanityFilter {
+		lines = append(lines, texts.Text(i18n.SettingsProfanityOn))
+	} else {
+		lines = append(lines, texts.Text(i18n.SettingsProfanityOff))
+	}
+
+	if s.Timestamps {
+		lines = append(lines, texts.Text(i18n.SettingsTimestampsOn))
+	} else {
+		lines = append(lines, texts.Text(i18n.SettingsTimestampsOff))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// settingsMarkup строит кнопки для переключения логических настроек
+func settingsMarkup(texts *i18n.Catalog, s chatSettings) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	active := texts.Text(i18n.ButtonEnableActive)
+	if s.Active {
+		active = texts.Text(i18n.ButtonDisableActive)
+	}
+	profanity := texts.Text(i18n.ButtonEnableProfanity)
+	if s.ProfanityFilter {
+		profanity = texts.Text(i18n.ButtonDisableProfanity)
+	}
+	markup.Inline(
+		markup.Row(markup.Data(active, btnToggleActive.Unique)),
+		markup.Row(markup.Data(profanity, btnToggleProfanity.Unique)),
+	)
+
+	return markup
+}
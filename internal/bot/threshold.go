@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+var errInvalidThreshold = errors.New("threshold must be a number between 0 and 1")
+
+// handleThreshold задаёт минимальную уверенность распознавания для предупреждения.
+// Без аргумента показывает текущее значение.
+func (b *Bot) handleThreshold(c tele.Context) error {
+	chatID := c.Chat().ID
+	payload := strings.TrimSpace(c.Message().Payload)
+
+	if payload == "" {
+		return c.Send(formatThreshold(b.texts, b.chatThreshold(chatID)))
+	}
+
+	threshold, err := parseThreshold(payload)
+	if err != nil {
+		return c.Send(b.texts.Text(i18n.ThresholdUsage))
+	}
+
+	_, err = b.prefs.Update(context.Background(), chatID, func(prefs *model.ChatPreferences) {
+		prefs.SetThreshold(threshold)
+	})
+	if err != nil {
+		logger.Error("Failed to save confidence threshold", zap.Error(err))
+		return c.Send(b.texts.Text(i18n.ThresholdSaveFailed))
+	}
+
+	logger.Info("Confidence threshold updated",
+		zap.Int64("chat_id", chatID),
+		zap.Float64("threshold", threshold))
+
+	return c.Send(formatThreshold(b.texts, threshold))
+}
+
+// chatThreshold возвращает порог уверенности чата или значение из конфигурации
+func (b *Bot) chatThreshold(chatID int64) float64 {
+	return b.chatPreferences(chatID).ConfidenceThreshold(b.cfg.Reply.ConfidenceThreshold)
+}
+
+// parseThreshold разбирает порог уверенности в диапазоне [0, 1]
+func parseThreshold(s string) (float64, error) {
+	threshold, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return 0, errInvalidThreshold
+	}
+	return threshold, nil
+}
+
+// formatThreshold описывает текущий порог для пользователя
+func formatThreshold(texts *i18n.Catalog, threshold float64) string {
+	if threshold <= 0 {
+		return texts.Text(i18n.ThresholdOff)
+	}
+	return texts.Text(i18n.ThresholdCurrent, threshold)
+}
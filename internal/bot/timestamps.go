@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// handleTimestamps включает и выключает временные метки в расшифровках: /timestamps on|off.
+// Без аргумента показывает текущее значение.
+func (b *Bot) handleTimestamps(c tele.Context) error {
+	chatID := c.Chat().ID
+
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+	case "":
+		return c.Send(formatTimestamps(b.texts, b.chatPreferences(chatID).Timestamps))
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return c.Send(b.texts.Text(i18n.TimestampsUsage))
+	}
+
+	_, err := b.prefs.Update(context.Background(), chatID, func(prefs *model.ChatPreferences) {
+		prefs.Timestamps = enabled
+	})
+	if err != nil {
+		logger.Error("Failed to save timestamps preference", zap.Error(err))
+		return c.Send(b.texts.Text(i18n.SettingSaveFailed))
+	}
+
+	logger.WithChat(chatID).Info("Timestamps preference updated",
+		zap.Bool("timestamps", enabled))
+
+	return c.Send(formatTimestamps(b.texts, enabled))
+}
+
+// formatTimestamps описывает настройку временных меток для пользователя
+func formatTimestamps(texts *i18n.Catalog, enabled bool) string {
+	if enabled {
+		return texts.Text(i18n.TimestampsOn)
+	}
+	return texts.Text(i18n.TimestampsOff)
+}
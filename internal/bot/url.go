@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/pkg/httpclient"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// handleText распознаёт аудио по прямой ссылке, присланной текстом.
+// Остальные текстовые сообщения бот пропускает молча.
+func (b *Bot) handleText(c tele.Context) error {
+	if !b.cfg.Telegram.AudioURLs {
+		return nil
+	}
+
+	msg := c.Message()
+	if msg == nil {
+		return nil
+	}
+
+	link, ok := audioURLFromText(msg.Text)
+	if !ok || !b.isActive(msg.Chat.ID) {
+		return nil
+	}
+
+	b.recordAudit(newAuditEntry(c, auditActionAudioURL))
+
+	if err := validateAudioURL(link); err != nil {
+		logger.WithChat(msg.Chat.ID).Info("Rejected audio URL",
+			zap.String("url", link.Redacted()),
+			zap.Error(err))
+
+		return b.respond(c, b.texts.Text(i18n.AudioURLRejected))
+	}
+
+	return b.enqueueAudio(c, audioInput{
+		FileName:  path.Base(link.Path),
+		SourceURL: link.String(),
+	})
+}
+
+// audioURLFromText возвращает ссылку, если сообщение состоит из одной ссылки
+// на файл с аудиорасширением
+func audioURLFromText(text string) (*url.URL, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, " \t\n") {
+		return nil, false
+	}
+
+	link, err := url.Parse(text)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+		return nil, false
+	}
+
+	ext := strings.ToLower(path.Ext(link.Path))
+	for _, allowed := range allowedAudioExtensions {
+		if ext == allowed {
+			return link, true
+		}
+	}
+	return nil, false
+}
+
+// validateAudioURL отсекает ссылки, которые воркер всё равно не скачает.
+// Адрес, в который разрешается имя хоста, проверяет воркер при подключении.
+func validateAudioURL(link *url.URL) error {
+	if link.User != nil {
+		return errors.New("credentials in URL")
+	}
+
+	host := strings.ToLower(link.Hostname())
+	if host == "" {
+		return errors.New("empty host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("local host")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !httpclient.IsPublicAddr(addr) {
+		return errors.New("internal address")
+	}
+	return nil
+}
@@ -1,28 +1,129 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+	"voxly/internal/i18n"
+	"voxly/internal/queue"
+	"voxly/internal/storage"
+	"voxly/pkg/cache"
 	"voxly/pkg/logger"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
+// Known values of Environment
+const (
+	EnvDevelopment = "dev"
+	EnvStaging     = "staging"
+	EnvProduction  = "prod"
+)
+
+// Known values of Worker.TaskSource
+const (
+	TaskSourceRabbitMQ = "rabbitmq"
+	TaskSourceDB       = "db"
+	TaskSourceBoth     = "both"
+)
+
 type Config struct {
+	// Environment is dev, staging or prod. dev logs to the console and keeps
+	// stored audio; staging and prod log JSON and remove orphaned audio. Unset
+	// means prod, so a deployment that forgets it isn't run with dev settings.
+	Environment string `yaml:"environment" env:"APP_ENV" env-default:"prod"`
+
 	Telegram struct {
-		Token string `yaml:"token" env:"TELEGRAM_BOT_TOKEN"`
+		Token    string  `yaml:"token" env:"TELEGRAM_BOT_TOKEN"`
+		AdminIDs []int64 `yaml:"admin_ids" env:"TELEGRAM_ADMIN_IDS" env-separator:","`
+		// APIURL points the bot and workers at a local Bot API server; empty uses api.telegram.org
+		APIURL string `yaml:"api_url" env:"TELEGRAM_API_URL" env-default:""`
+		// DefaultActive is used for chats that have no stored /start or /stop state
+		DefaultActive bool `yaml:"default_active" env:"BOT_DEFAULT_ACTIVE" env-default:"false"`
+		// DownloadTimeout bounds fetching a voice file from Telegram
+		DownloadTimeout time.Duration `yaml:"download_timeout" env:"TELEGRAM_DOWNLOAD_TIMEOUT" env-default:"60s"`
+		// SendRate caps worker replies per second across all chats
+		SendRate int `yaml:"send_rate" env:"TELEGRAM_SEND_RATE" env-default:"30"`
+		// GroupSendRate caps worker replies per minute to one group or channel
+		GroupSendRate int `yaml:"group_send_rate" env:"TELEGRAM_GROUP_SEND_RATE" env-default:"20"`
+		// LogChatID receives a copy of every transcript with its chat, for moderation;
+		// 0 disables it. LogChatRate caps the copies per minute, the rest are skipped.
+		LogChatID   int64 `yaml:"log_chat_id" env:"TELEGRAM_LOG_CHAT_ID" env-default:"0"`
+		LogChatRate int   `yaml:"log_chat_rate" env:"TELEGRAM_LOG_CHAT_RATE" env-default:"20"`
+		// Replies are retried SendRetryAttempts times; after BreakerMaxFailures failed
+		// replies in a row Telegram is skipped for BreakerTimeout. Replies that can't be
+		// sent wait in the outbox, which is flushed every OutboxFlushInterval; 0 disables it.
+		SendRetryAttempts   int           `yaml:"send_retry_attempts" env:"TELEGRAM_SEND_RETRY_ATTEMPTS" env-default:"3"`
+		BreakerMaxFailures  int           `yaml:"breaker_max_failures" env:"TELEGRAM_BREAKER_MAX_FAILURES" env-default:"5"`
+		BreakerTimeout      time.Duration `yaml:"breaker_timeout" env:"TELEGRAM_BREAKER_TIMEOUT" env-default:"30s"`
+		OutboxFlushInterval time.Duration `yaml:"outbox_flush_interval" env:"TELEGRAM_OUTBOX_FLUSH_INTERVAL" env-default:"15s"`
+		// DefaultLocale is the language of messages the bot writes itself: ru or en
+		DefaultLocale string `yaml:"default_locale" env:"BOT_DEFAULT_LOCALE" env-default:"ru"`
+		// ShutdownTimeout bounds how long the bot waits for running handlers when stopping
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"BOT_SHUTDOWN_TIMEOUT" env-default:"10s"`
+		// InactiveHint answers audio in chats without /start with a hint how to turn
+		// recognition on, at most once per InactiveHintInterval per chat
+		InactiveHint         bool          `yaml:"inactive_hint" env:"BOT_INACTIVE_HINT" env-default:"false"`
+		InactiveHintInterval time.Duration `yaml:"inactive_hint_interval" env:"BOT_INACTIVE_HINT_INTERVAL" env-default:"24h"`
+		// AudioURLs makes the bot transcribe direct links to OGG/Opus files sent as text
+		AudioURLs bool `yaml:"audio_urls" env:"BOT_AUDIO_URLS" env-default:"false"`
+		// HandlerWorkers bounds how many updates the bot handles at once; updates of
+		// one chat are handled in order. 0 handles each update in its own goroutine.
+		HandlerWorkers int `yaml:"handler_workers" env:"BOT_HANDLER_WORKERS" env-default:"8"`
 	} `yaml:"telegram"`
 
 	RabbitMQ struct {
 		URL string `yaml:"url" env:"RABBITMQ_URL"`
+		// MaxQueueDepth makes the bot turn away voice messages while more tasks wait; 0 disables it
+		MaxQueueDepth int `yaml:"max_queue_depth" env:"RABBITMQ_MAX_QUEUE_DEPTH" env-default:"0"`
+		// DedupWindow makes the worker skip a task published again within it; 0 disables it
+		DedupWindow time.Duration `yaml:"dedup_window" env:"RABBITMQ_DEDUP_WINDOW" env-default:"1h"`
+		// Tasks at least LongAudioAfter long or with one of LongAudioMimeTypes go to the
+		// voice_processing_long queue instead of voice_processing; 0 and empty disable it
+		LongAudioAfter     time.Duration `yaml:"long_audio_after" env:"RABBITMQ_LONG_AUDIO_AFTER" env-default:"0"`
+		LongAudioMimeTypes []string      `yaml:"long_audio_mime_types" env:"RABBITMQ_LONG_AUDIO_MIME_TYPES" env-separator:","`
 	} `yaml:"rabbitmq"`
 
 	SpeechKit struct {
 		FolderID string `yaml:"folder_id" env:"YANDEX_FOLDER_ID"`
 		APIKey   string `yaml:"api_key" env:"YANDEX_API_KEY"`
+		// Model is general or general:rc. Audio at least LongAudioAfter long uses
+		// LongAudioModel when it is set. deferred-general is refused here: its results
+		// can take hours, longer than the worker waits for a recognition.
+		Model          string        `yaml:"model" env:"SPEECHKIT_MODEL" env-default:"general:rc"`
+		LongAudioModel string        `yaml:"long_audio_model" env:"SPEECHKIT_LONG_AUDIO_MODEL" env-default:""`
+		LongAudioAfter time.Duration `yaml:"long_audio_after" env:"SPEECHKIT_LONG_AUDIO_AFTER" env-default:"5m"`
+		// NarrowbandModel recognizes phone-quality audio, recorded below 16kHz, that
+		// isn't long; empty keeps Model for it
+		NarrowbandModel string `yaml:"narrowband_model" env:"SPEECHKIT_NARROWBAND_MODEL" env-default:""`
+		// ReprocessModel is offered for transcripts below Reply.ReprocessBelow
+		ReprocessModel string `yaml:"reprocess_model" env:"SPEECHKIT_REPROCESS_MODEL" env-default:"general"`
+		// Language is a language code such as ru-RU, or auto to detect it. With auto,
+		// each chat's recently detected language is requested while it is remembered.
+		Language string `yaml:"language" env:"SPEECHKIT_LANGUAGE" env-default:"ru-RU"`
+		// Per-request timeouts for starting recognition and for each status poll
+		StartTimeout time.Duration `yaml:"start_timeout" env:"SPEECHKIT_START_TIMEOUT" env-default:"30s"`
+		PollTimeout  time.Duration `yaml:"poll_timeout" env:"SPEECHKIT_POLL_TIMEOUT" env-default:"10s"`
+		// RecognizeURL and OperationURL override the API endpoints, e.g. for another
+		// region or a mock server; empty keeps the public Yandex Cloud endpoints
+		RecognizeURL string `yaml:"recognize_url" env:"SPEECHKIT_RECOGNIZE_URL" env-default:""`
+		OperationURL string `yaml:"operation_url" env:"SPEECHKIT_OPERATION_URL" env-default:""`
 	} `yaml:"speechkit"`
 
 	Postgres struct {
 		DSN string `yaml:"dsn" env:"POSTGRES_DSN"`
+		// Pool settings; zero keeps the pgx defaults
+		MaxConns        int           `yaml:"max_conns" env:"POSTGRES_MAX_CONNS" env-default:"0"`
+		MinConns        int           `yaml:"min_conns" env:"POSTGRES_MIN_CONNS" env-default:"0"`
+		MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"POSTGRES_MAX_CONN_LIFETIME" env-default:"0"`
+		// Transcripts older than TranscriptRetention are deleted every RetentionInterval,
+		// along with unsent replies, S3 transcript backups and, when RetentionDeleteTasks
+		// is set, finished tasks; 0 keeps them forever. Redis caches of transcripts and
+		// recognition results expire within it, see TranscriptCacheTTL and ResultCacheTTL.
+		TranscriptRetention  time.Duration `yaml:"transcript_retention" env:"POSTGRES_TRANSCRIPT_RETENTION" env-default:"0"`
+		RetentionDeleteTasks bool          `yaml:"retention_delete_tasks" env:"POSTGRES_RETENTION_DELETE_TASKS" env-default:"false"`
+		RetentionInterval    time.Duration `yaml:"retention_interval" env:"POSTGRES_RETENTION_INTERVAL" env-default:"24h"`
 	} `yaml:"postgres"`
 
 	S3 struct {
@@ -30,16 +131,147 @@ type Config struct {
 		AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY"`
 		SecretKey string `yaml:"secret_key" env:"S3_SECRET_KEY"`
 		Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+
+		// BackupTranscripts also stores transcript text and raw response under transcripts/
+		BackupTranscripts bool `yaml:"backup_transcripts" env:"S3_BACKUP_TRANSCRIPTS" env-default:"false"`
+
+		// Orphaned audio of failed or deleted tasks is removed after CleanupRetention.
+		// A zero CleanupInterval disables the cleaner.
+		CleanupRetention time.Duration `yaml:"cleanup_retention" env:"S3_CLEANUP_RETENTION" env-default:"168h"`
+		CleanupInterval  time.Duration `yaml:"cleanup_interval" env:"S3_CLEANUP_INTERVAL" env-default:"1h"`
+		// KeepAudio disables the cleaner, keeping all uploaded audio for debugging.
+		// Empty follows the environment: dev keeps audio, staging and prod don't.
+		KeepAudio string `yaml:"keep_audio" env:"S3_KEEP_AUDIO" env-default:""`
+
+		// ContentKeys stores audio under a hash of its bytes, so a file sent again isn't
+		// uploaded twice. These objects are shared and the orphan cleaner skips them.
+		ContentKeys bool `yaml:"content_keys" env:"S3_CONTENT_KEYS" env-default:"false"`
+
+		// CallTimeout bounds each S3 call from the worker, retries included, apart from
+		// the task deadline: uploads as well as lookups, listings and deletes. It must
+		// leave room for every retry to use up OperationTimeout; 0 derives it from them.
+		CallTimeout time.Duration `yaml:"call_timeout" env:"S3_CALL_TIMEOUT" env-default:"0"`
+
+		// Uploads, downloads and deletes are retried RetryAttempts times; after
+		// BreakerMaxFailures failed operations in a row S3 is skipped for BreakerTimeout
+		RetryAttempts      int           `yaml:"retry_attempts" env:"S3_RETRY_ATTEMPTS" env-default:"3"`
+		BreakerMaxFailures int           `yaml:"breaker_max_failures" env:"S3_BREAKER_MAX_FAILURES" env-default:"5"`
+		BreakerTimeout     time.Duration `yaml:"breaker_timeout" env:"S3_BREAKER_TIMEOUT" env-default:"30s"`
+
+		// The SDK retries each request itself in SDKRetryMode ("standard" or "adaptive",
+		// which also backs off on throttling) up to SDKMaxAttempts times. OperationTimeout
+		// bounds every call with its SDK retries; 0 disables it.
+		SDKRetryMode     string        `yaml:"sdk_retry_mode" env:"S3_SDK_RETRY_MODE" env-default:"adaptive"`
+		SDKMaxAttempts   int           `yaml:"sdk_max_attempts" env:"S3_SDK_MAX_ATTEMPTS" env-default:"3"`
+		OperationTimeout time.Duration `yaml:"operation_timeout" env:"S3_OPERATION_TIMEOUT" env-default:"60s"`
+
+		// Files of at least MultipartPartSize bytes are uploaded in parts of that size
+		// (0 disables). Uploads left unfinished for MultipartStaleAfter are aborted by
+		// the cleaner so their parts aren't billed; 0 disables that.
+		MultipartPartSize   int64         `yaml:"multipart_part_size" env:"S3_MULTIPART_PART_SIZE" env-default:"16777216"`
+		MultipartStaleAfter time.Duration `yaml:"multipart_stale_after" env:"S3_MULTIPART_STALE_AFTER" env-default:"24h"`
 	} `yaml:"s3"`
 
+	Cache struct {
+		Driver string `yaml:"driver" env:"CACHE_DRIVER" env-default:"redis"` // redis, memory or noop
+	} `yaml:"cache"`
+
 	Redis struct {
 		Addr     string `yaml:"addr" env:"REDIS_ADDR" env-default:"localhost:6379"`
 		Password string `yaml:"password" env:"REDIS_PASSWORD" env-default:""`
 		DB       int    `yaml:"db" env:"REDIS_DB" env-default:"0"`
+		// Sentinel mode: set the master name and sentinel addresses
+		MasterName    string   `yaml:"master_name" env:"REDIS_MASTER_NAME" env-default:""`
+		SentinelAddrs []string `yaml:"sentinel_addrs" env:"REDIS_SENTINEL_ADDRS" env-separator:","`
+		// Cluster mode: set the cluster node addresses
+		ClusterAddrs []string `yaml:"cluster_addrs" env:"REDIS_CLUSTER_ADDRS" env-separator:","`
+		// Optional lets services start with an in-memory cache when Redis is down
+		Optional bool `yaml:"optional" env:"REDIS_OPTIONAL" env-default:"false"`
+		// Startup pings before giving up; the interval doubles after each failure
+		ConnectAttempts int           `yaml:"connect_attempts" env:"REDIS_CONNECT_ATTEMPTS" env-default:"5"`
+		ConnectInterval time.Duration `yaml:"connect_interval" env:"REDIS_CONNECT_INTERVAL" env-default:"1s"`
 	} `yaml:"redis"`
 
+	Reply struct {
+		// FooterTemplate is a text/template appended to transcripts; empty disables the footer.
+		// Available fields: .Duration, .Language, .Confidence, .ProcessingTime.
+		FooterTemplate string `yaml:"footer_template" env:"REPLY_FOOTER_TEMPLATE" env-default:""`
+		ParseMode      string `yaml:"parse_mode" env:"REPLY_PARSE_MODE" env-default:""` // empty, Markdown, MarkdownV2 or HTML
+		// ConfidenceThreshold is the default minimum confidence below which a warning
+		// is appended; chats override it with /threshold. Zero disables the warning.
+		ConfidenceThreshold float64 `yaml:"confidence_threshold" env:"REPLY_CONFIDENCE_THRESHOLD" env-default:"0"`
+		// ReprocessBelow offers a button to recognize the whole transcript again with
+		// SpeechKit.ReprocessModel when its average confidence is below it; 0 disables it
+		ReprocessBelow float64 `yaml:"reprocess_below" env:"REPLY_REPROCESS_BELOW" env-default:"0"`
+		// ForwardAttribution names the original sender when replying to forwarded voice messages
+		ForwardAttribution bool `yaml:"forward_attribution" env:"REPLY_FORWARD_ATTRIBUTION" env-default:"false"`
+		// To is what replies go to: reply-to-original (the voice message),
+		// reply-to-processing (the bot's "processing" message) or no-reply
+		To string `yaml:"to" env:"REPLY_TO" env-default:"reply-to-original"`
+		// ChunkNumbering prefixes each part of a transcript longer than one message with "(1/3)".
+		// ChunkSeparator goes between the number and the text; empty means a space.
+		ChunkNumbering bool   `yaml:"chunk_numbering" env:"REPLY_CHUNK_NUMBERING" env-default:"false"`
+		ChunkSeparator string `yaml:"chunk_separator" env:"REPLY_CHUNK_SEPARATOR" env-default:""`
+	} `yaml:"reply"`
+
+	Webhook struct {
+		// URL receives every completed transcript as JSON; empty disables delivery.
+		// With Secret set the body is signed in the X-Voxly-Signature header.
+		URL            string        `yaml:"url" env:"WEBHOOK_URL" env-default:""`
+		Secret         string        `yaml:"secret" env:"WEBHOOK_SECRET" env-default:""`
+		RetryAttempts  int           `yaml:"retry_attempts" env:"WEBHOOK_RETRY_ATTEMPTS" env-default:"3"`
+		RequestTimeout time.Duration `yaml:"request_timeout" env:"WEBHOOK_REQUEST_TIMEOUT" env-default:"10s"`
+		QueueSize      int           `yaml:"queue_size" env:"WEBHOOK_QUEUE_SIZE" env-default:"100"`
+	} `yaml:"webhook"`
+
+	Monitor struct {
+		// Addr for the /healthz and /metrics server, e.g. ":8080"; empty disables it
+		Addr string `yaml:"addr" env:"MONITOR_ADDR" env-default:""`
+		// SelfTestToken is the bearer token POST /selftest requires; empty disables the endpoint
+		SelfTestToken string `yaml:"selftest_token" env:"MONITOR_SELFTEST_TOKEN" env-default:""`
+	} `yaml:"monitor"`
+
 	Worker struct {
 		Concurrency string `yaml:"concurrency" env:"WORKER_CONCURRENCY" env-default:"4"`
+		MaxAttempts int    `yaml:"max_attempts" env:"WORKER_MAX_ATTEMPTS" env-default:"3"`
+		// TaskTimeoutMultiplier bounds processing to max(2m, duration*multiplier); 0 disables it
+		TaskTimeoutMultiplier float64 `yaml:"task_timeout_multiplier" env:"WORKER_TASK_TIMEOUT_MULTIPLIER" env-default:"5"`
+		// MinDurationSeconds makes the bot skip shorter voice messages, e.g. accidental taps; 0 disables it
+		MinDurationSeconds int `yaml:"min_duration_seconds" env:"WORKER_MIN_DURATION_SECONDS" env-default:"1"`
+		// SegmentDuration splits longer recordings into parts recognized in parallel; 0 disables it
+		SegmentDuration time.Duration `yaml:"segment_duration" env:"WORKER_SEGMENT_DURATION" env-default:"0"`
+		// SegmentConcurrency bounds how many parts of one recording are recognized at once
+		SegmentConcurrency int `yaml:"segment_concurrency" env:"WORKER_SEGMENT_CONCURRENCY" env-default:"4"`
+		// PollConcurrency waits for up to this many recognitions in the background while the
+		// next tasks are consumed; 0 waits for each one before taking the next task
+		PollConcurrency int `yaml:"poll_concurrency" env:"WORKER_POLL_CONCURRENCY" env-default:"0"`
+		// ReclaimInterval is how often tasks left in progress past their deadline by a
+		// stopped worker are published again when polling in the background or taking
+		// tasks from the database; 0 disables it
+		ReclaimInterval time.Duration `yaml:"reclaim_interval" env:"WORKER_RECLAIM_INTERVAL" env-default:"5m"`
+		// ResultCacheTTL keeps full recognition results keyed by a hash of the audio,
+		// so the same recording sent again isn't recognized twice; 0 disables it
+		ResultCacheTTL time.Duration `yaml:"result_cache_ttl" env:"WORKER_RESULT_CACHE_TTL" env-default:"0"`
+		// Queues the worker takes tasks from, so short and long audio can be scaled apart.
+		// Each queue is consumed one task at a time, so a worker on both runs two at once.
+		// Empty means voice_processing, plus voice_processing_long when tasks are routed there.
+		Queues []string `yaml:"queues" env:"WORKER_QUEUES" env-separator:","`
+		// TrimSilence cuts silence at both ends of a recording with ffmpeg before upload; it is
+		// skipped when ffmpeg is not installed. Audio below SilenceThresholdDB counts as
+		// silence, and SilenceKeep of it is left at each end.
+		TrimSilence        bool          `yaml:"trim_silence" env:"WORKER_TRIM_SILENCE" env-default:"false"`
+		SilenceThresholdDB float64       `yaml:"silence_threshold_db" env:"WORKER_SILENCE_THRESHOLD_DB" env-default:"-50"`
+		SilenceKeep        time.Duration `yaml:"silence_keep" env:"WORKER_SILENCE_KEEP" env-default:"250ms"`
+		// WarmupAttempts checks SpeechKit and S3 access up to this many times, with backoff
+		// starting at WarmupInterval, before consuming starts; 0 disables the check
+		WarmupAttempts int           `yaml:"warmup_attempts" env:"WORKER_WARMUP_ATTEMPTS" env-default:"5"`
+		WarmupInterval time.Duration `yaml:"warmup_interval" env:"WORKER_WARMUP_INTERVAL" env-default:"1s"`
+		// TaskSource is where workers take tasks from: rabbitmq, db or both. With db
+		// the bot and workers run without RabbitMQ and workers poll the database for
+		// queued tasks every DBPollInterval, claiming up to DBPollBatch at a time.
+		TaskSource     string        `yaml:"task_source" env:"WORKER_TASK_SOURCE" env-default:"rabbitmq"`
+		DBPollInterval time.Duration `yaml:"db_poll_interval" env:"WORKER_DB_POLL_INTERVAL" env-default:"5s"`
+		DBPollBatch    int           `yaml:"db_poll_batch" env:"WORKER_DB_POLL_BATCH" env-default:"10"`
 	} `yaml:"worker"`
 }
 
@@ -57,6 +289,204 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if err := validateEnvironment(cfg.Environment); err != nil {
+		return nil, fmt.Errorf("invalid APP_ENV: %w", err)
+	}
+
+	if err := validateOptionalBool(cfg.S3.KeepAudio); err != nil {
+		return nil, fmt.Errorf("invalid S3_KEEP_AUDIO: %w", err)
+	}
+
+	if err := validateTaskSource(cfg.Worker.TaskSource); err != nil {
+		return nil, fmt.Errorf("invalid WORKER_TASK_SOURCE: %w", err)
+	}
+
+	if err := validateCallTimeout(cfg.S3.CallTimeout, cfg.s3CallBudget()); err != nil {
+		return nil, fmt.Errorf("invalid S3_CALL_TIMEOUT: %w", err)
+	}
+
+	if err := validatePartSize(cfg.S3.MultipartPartSize); err != nil {
+		return nil, fmt.Errorf("invalid S3_MULTIPART_PART_SIZE: %w", err)
+	}
+
+	if _, err := i18n.New(cfg.Telegram.DefaultLocale); err != nil {
+		return nil, fmt.Errorf("invalid BOT_DEFAULT_LOCALE: %w", err)
+	}
+
 	logger.Info("Config loaded successfully")
 	return &cfg, nil
 }
+
+// ConsoleLogs reports whether logs are human-readable instead of JSON
+func (c *Config) ConsoleLogs() bool {
+	return c.Environment == EnvDevelopment
+}
+
+// KeepAudio reports whether uploaded audio is kept instead of being cleaned up:
+// S3_KEEP_AUDIO when set, otherwise only in dev
+func (c *Config) KeepAudio() bool {
+	if keep, err := strconv.ParseBool(c.S3.KeepAudio); err == nil {
+		return keep
+	}
+	return c.Environment == EnvDevelopment
+}
+
+// S3BreakerOptions configures retries and the circuit breaker around S3 calls
+func (c *Config) S3BreakerOptions() storage.BreakerOptions {
+	return storage.BreakerOptions{
+		MaxFailures:   uint32(c.S3.BreakerMaxFailures),
+		Timeout:       c.S3.BreakerTimeout,
+		RetryAttempts: c.S3.RetryAttempts,
+	}
+}
+
+// defaultS3CallTimeout bounds S3 calls when attempts themselves are unbounded
+const defaultS3CallTimeout = 60 * time.Second
+
+// s3CallSlack is added to the derived S3 call timeout, so the last attempt
+// isn't cut short by the time spent between attempts
+const s3CallSlack = time.Second
+
+// S3CallTimeout bounds a single S3 call from the worker with all its retries:
+// S3_CALL_TIMEOUT, or enough for every attempt to use up S3_OPERATION_TIMEOUT
+func (c *Config) S3CallTimeout() time.Duration {
+	if c.S3.CallTimeout > 0 {
+		return c.S3.CallTimeout
+	}
+	if budget := c.s3CallBudget(); budget > 0 {
+		return budget + s3CallSlack
+	}
+	return defaultS3CallTimeout
+}
+
+// s3CallBudget is how long a call takes when every retry times out, or zero
+// when single attempts aren't bounded
+func (c *Config) s3CallBudget() time.Duration {
+	if c.S3.OperationTimeout <= 0 {
+		return 0
+	}
+	return c.S3BreakerOptions().CallBudget(c.S3.OperationTimeout)
+}
+
+// TaskRouting picks the queue each task is published to
+func (c *Config) TaskRouting() queue.Routing {
+	return queue.Routing{
+		LongAfter:     c.RabbitMQ.LongAudioAfter,
+		LongMimeTypes: c.RabbitMQ.LongAudioMimeTypes,
+	}
+}
+
+// WorkerQueues returns the queues the worker consumes: WORKER_QUEUES, or every
+// queue tasks are routed to
+func (c *Config) WorkerQueues() []string {
+	if len(c.Worker.Queues) > 0 {
+		return c.Worker.Queues
+	}
+	if c.TaskRouting().RoutesLong() {
+		return []string{queue.QueueNameVoiceProcessing, queue.QueueNameVoiceLong}
+	}
+	return []string{queue.QueueNameVoiceProcessing}
+}
+
+// defaultCacheTTL applies to cache entries stored without their own TTL
+const defaultCacheTTL = 24 * time.Hour
+
+// NewCache creates the cache of CACHE_DRIVER. With REDIS_OPTIONAL set, an
+// unreachable Redis is replaced by an in-memory cache instead of failing startup.
+func (c *Config) NewCache() (cache.Cache, error) {
+	return cache.New(c.Cache.Driver, c.RedisOptions(), defaultCacheTTL, c.Redis.Optional)
+}
+
+// RedisOptions collects the connection settings of REDIS_*
+func (c *Config) RedisOptions() cache.RedisOptions {
+	return cache.RedisOptions{
+		Addr:            c.Redis.Addr,
+		Password:        c.Redis.Password,
+		DB:              c.Redis.DB,
+		MasterName:      c.Redis.MasterName,
+		SentinelAddrs:   c.Redis.SentinelAddrs,
+		ClusterAddrs:    c.Redis.ClusterAddrs,
+		ConnectAttempts: c.Redis.ConnectAttempts,
+		ConnectInterval: c.Redis.ConnectInterval,
+	}
+}
+
+// TranscriptCacheTTL is how long transcripts and finished tasks stay cached in
+// Redis: cache.TranscriptTTL, cut to the transcript retention
+func (c *Config) TranscriptCacheTTL() time.Duration {
+	return c.retained(cache.TranscriptTTL)
+}
+
+// ResultCacheTTL is how long recognition results stay cached by audio hash:
+// WORKER_RESULT_CACHE_TTL, cut to the transcript retention
+func (c *Config) ResultCacheTTL() time.Duration {
+	return c.retained(c.Worker.ResultCacheTTL)
+}
+
+// retained cuts ttl to POSTGRES_TRANSCRIPT_RETENTION when it is set, so cached
+// text doesn't outlive the copy retention deletes from the database
+func (c *Config) retained(ttl time.Duration) time.Duration {
+	if retention := c.Postgres.TranscriptRetention; retention > 0 && ttl > retention {
+		return retention
+	}
+	return ttl
+}
+
+// UsesRabbitMQ reports whether tasks go through RabbitMQ
+func (c *Config) UsesRabbitMQ() bool {
+	return c.Worker.TaskSource != TaskSourceDB
+}
+
+// PollsDB reports whether workers poll the database for queued tasks
+func (c *Config) PollsDB() bool {
+	return c.Worker.TaskSource == TaskSourceDB || c.Worker.TaskSource == TaskSourceBoth
+}
+
+func validateEnvironment(env string) error {
+	switch env {
+	case EnvDevelopment, EnvStaging, EnvProduction:
+		return nil
+	default:
+		return fmt.Errorf("unknown environment %q, expected %s, %s or %s",
+			env, EnvDevelopment, EnvStaging, EnvProduction)
+	}
+}
+
+// validateOptionalBool accepts an empty value, which leaves the setting to its
+// derived default, or anything strconv.ParseBool understands
+func validateOptionalBool(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func validateTaskSource(source string) error {
+	switch source {
+	case TaskSourceRabbitMQ, TaskSourceDB, TaskSourceBoth:
+		return nil
+	default:
+		return fmt.Errorf("unknown task source %q, expected %s, %s or %s",
+			source, TaskSourceRabbitMQ, TaskSourceDB, TaskSourceBoth)
+	}
+}
+
+// validateCallTimeout rejects a call timeout that a single stalled attempt
+// would use up before the retries get their turn
+func validateCallTimeout(timeout, budget time.Duration) error {
+	if timeout > 0 && budget > 0 && timeout <= budget {
+		return fmt.Errorf("call timeout %s must exceed %s, the retries of S3_OPERATION_TIMEOUT with backoff", timeout, budget)
+	}
+	return nil
+}
+
+// minPartSize is the smallest part S3 accepts, except for the last one
+const minPartSize = 5 << 20
+
+func validatePartSize(size int64) error {
+	if size != 0 && size < minPartSize {
+		return fmt.Errorf("part size %d is below the S3 minimum of %d bytes", size, minPartSize)
+	}
+	return nil
+}
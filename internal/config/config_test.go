@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+	"voxly/internal/queue"
+	"voxly/pkg/cache"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironmentDerivedSettings(t *testing.T) {
+	tests := []struct {
+		env         string
+		consoleLogs bool
+		keepAudio   bool
+	}{
+		{EnvDevelopment, true, true},
+		{EnvStaging, false, false},
+		{EnvProduction, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			cfg := &Config{Environment: tt.env}
+
+			assert.NoError(t, validateEnvironment(tt.env))
+			assert.Equal(t, tt.consoleLogs, cfg.ConsoleLogs())
+			assert.Equal(t, tt.keepAudio, cfg.KeepAudio())
+
+			// An explicit S3_KEEP_AUDIO wins over the environment
+			cfg.S3.KeepAudio = "true"
+			assert.True(t, cfg.KeepAudio())
+			cfg.S3.KeepAudio = "false"
+			assert.False(t, cfg.KeepAudio())
+		})
+	}
+}
+
+func TestEnvironmentDefaultsToProduction(t *testing.T) {
+	// Setenv restores APP_ENV once the test is done
+	t.Setenv("APP_ENV", "")
+	assert.NoError(t, os.Unsetenv("APP_ENV"))
+	var cfg Config
+	assert.NoError(t, cleanenv.ReadEnv(&cfg))
+
+	// Without APP_ENV a deployment logs JSON and cleans up audio
+	assert.Equal(t, EnvProduction, cfg.Environment)
+	assert.False(t, cfg.ConsoleLogs())
+	assert.False(t, cfg.KeepAudio())
+}
+
+func TestValidateOptionalBool(t *testing.T) {
+	for _, value := range []string{"", "true", "false", "1", "0"} {
+		assert.NoError(t, validateOptionalBool(value), value)
+	}
+	assert.Error(t, validateOptionalBool("yes"))
+}
+
+func TestCacheTTLsFollowRetention(t *testing.T) {
+	cfg := &Config{}
+	cfg.Worker.ResultCacheTTL = 30 * 24 * time.Hour
+	assert.Equal(t, cache.TranscriptTTL, cfg.TranscriptCacheTTL())
+	assert.Equal(t, 30*24*time.Hour, cfg.ResultCacheTTL())
+
+	// Cached text doesn't outlive the retention
+	cfg.Postgres.TranscriptRetention = 24 * time.Hour
+	assert.Equal(t, 24*time.Hour, cfg.TranscriptCacheTTL())
+	assert.Equal(t, 24*time.Hour, cfg.ResultCacheTTL())
+}
+
+func TestValidateEnvironmentRejectsUnknown(t *testing.T) {
+	for _, env := range []string{"", "production", "DEV"} {
+		assert.Error(t, validateEnvironment(env), env)
+	}
+}
+
+func TestTaskSourceSettings(t *testing.T) {
+	tests := []struct {
+		source     string
+		usesRabbit bool
+		pollsDB    bool
+	}{
+		{TaskSourceRabbitMQ, true, false},
+		{TaskSourceDB, false, true},
+		{TaskSourceBoth, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Worker.TaskSource = tt.source
+
+			assert.NoError(t, validateTaskSource(tt.source))
+			assert.Equal(t, tt.usesRabbit, cfg.UsesRabbitMQ())
+			assert.Equal(t, tt.pollsDB, cfg.PollsDB())
+		})
+	}
+
+	assert.Error(t, validateTaskSource("postgres"))
+}
+
+func TestValidatePartSize(t *testing.T) {
+	assert.NoError(t, validatePartSize(0))
+	assert.NoError(t, validatePartSize(minPartSize))
+	assert.Error(t, validatePartSize(1<<20))
+}
+
+func TestS3CallTimeout(t *testing.T) {
+	cfg := &Config{}
+	cfg.S3.RetryAttempts = 3
+	cfg.S3.OperationTimeout = 60 * time.Second
+
+	// Every attempt may stall for the whole operation timeout, with backoff in between
+	budget := 180*time.Second + 600*time.Millisecond
+	assert.Equal(t, budget, cfg.s3CallBudget())
+	assert.Equal(t, budget+s3CallSlack, cfg.S3CallTimeout())
+	assert.NoError(t, validateCallTimeout(0, budget))
+
+	cfg.S3.CallTimeout = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, cfg.S3CallTimeout())
+	assert.NoError(t, validateCallTimeout(cfg.S3.CallTimeout, budget))
+	// One stalled attempt would use up the whole call
+	assert.Error(t, validateCallTimeout(60*time.Second, budget))
+
+	cfg.S3.CallTimeout = 0
+	cfg.S3.OperationTimeout = 0
+	assert.Equal(t, defaultS3CallTimeout, cfg.S3CallTimeout())
+	assert.NoError(t, validateCallTimeout(10*time.Second, cfg.s3CallBudget()))
+}
+
+func TestWorkerQueues(t *testing.T) {
+	cfg := &Config{}
+	// Without long routing every task lands in one queue
+	assert.Equal(t, []string{queue.QueueNameVoiceProcessing}, cfg.WorkerQueues())
+
+	cfg.RabbitMQ.LongAudioMimeTypes = []string{"audio/mpeg"}
+	assert.Equal(t, []string{queue.QueueNameVoiceProcessing, queue.QueueNameVoiceLong}, cfg.WorkerQueues())
+
+	cfg.Worker.Queues = []string{queue.QueueNameVoiceLong}
+	assert.Equal(t, []string{queue.QueueNameVoiceLong}, cfg.WorkerQueues())
+}
+
+func TestNewCacheWithUnavailableRedis(t *testing.T) {
+	// Nothing listens on port 1, so the ping fails immediately
+	cfg := &Config{}
+	cfg.Redis.Addr = "127.0.0.1:1"
+	cfg.Redis.ConnectAttempts = 1
+
+	_, err := cfg.NewCache()
+	assert.Error(t, err)
+
+	cfg.Redis.Optional = true
+	c, err := cfg.NewCache()
+	assert.NoError(t, err)
+	assert.IsType(t, &cache.MemoryCache{}, c)
+}
@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Locales with a message catalog
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+// DefaultLocale is used when none is configured
+const DefaultLocale = Russian
+
+// ErrUnknownLocale is returned for locales without a catalog
+var ErrUnknownLocale = errors.New("unknown locale")
+
+// Key identifies a message in the catalogs
+type Key string
+
+// Catalog renders messages in one locale. A nil catalog renders the default locale.
+type Catalog struct {
+	locale   string
+	messages map[Key]string
+}
+
+// New returns the catalog for locale
+func New(locale string) (*Catalog, error) {
+	messages, ok := catalogs[locale]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, supported: %v", ErrUnknownLocale, locale, Locales())
+	}
+	return &Catalog{locale: locale, messages: messages}, nil
+}
+
+// Locales lists the supported locales
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Locale returns the catalog's locale
+func (c *Catalog) Locale() string {
+	if c == nil {
+		return DefaultLocale
+	}
+	return c.locale
+}
+
+// Text renders the message for key, formatting args into it like fmt.Sprintf.
+// Messages missing from the catalog fall back to the default locale.
+func (c *Catalog) Text(key Key, args ...interface{}) string {
+	var msg string
+	var ok bool
+	if c != nil {
+		msg, ok = c.messages[key]
+	}
+	if !ok {
+		msg = catalogs[DefaultLocale][key]
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
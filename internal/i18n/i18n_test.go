@@ -0,0 +1,38 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for locale, messages := range catalogs {
+		for key := range catalogs[DefaultLocale] {
+			assert.NotEmpty(t, messages[key], "%s is missing %s", locale, key)
+		}
+		for key := range messages {
+			_, ok := catalogs[DefaultLocale][key]
+			assert.True(t, ok, "%s has %s, the default locale does not", locale, key)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	en, err := New(English)
+	assert.NoError(t, err)
+	assert.Equal(t, English, en.Locale())
+	assert.Equal(t, "Bot started!", en.Text(BotStarted))
+	assert.Equal(t, "Task t-1 is queued again", en.Text(TaskRequeued, "t-1"))
+
+	_, err = New("de")
+	assert.ErrorIs(t, err, ErrUnknownLocale)
+	assert.Equal(t, []string{English, Russian}, Locales())
+}
+
+func TestNilCatalogUsesDefaultLocale(t *testing.T) {
+	var texts *Catalog
+	assert.Equal(t, DefaultLocale, texts.Locale())
+	assert.Equal(t, "Бот запущен!", texts.Text(BotStarted))
+	assert.Equal(t, "⚠️ Низкая уверенность распознавания (60%), текст может содержать ошибки.", texts.Text(LowConfidence, 60.0))
+}
@@ -0,0 +1,282 @@
+package i18n
+
+// Activation
+const (
+	BotStarted   Key = "bot_started"
+	BotStopped   Key = "bot_stopped"
+	InactiveHint Key = "inactive_hint"
+)
+
+// Voice message intake
+const (
+	Processing       Key = "processing"
+	ProcessingETA    Key = "processing_eta"
+	VoiceNotFound    Key = "voice_not_found"
+	TooShort         Key = "too_short"
+	FileTooLarge     Key = "file_too_large"
+	QueueOverloaded  Key = "queue_overloaded"
+	Maintenance      Key = "maintenance"
+	TaskSaveFailed   Key = "task_save_failed"
+	EnqueueFailed    Key = "enqueue_failed"
+	NotAudio         Key = "not_audio"
+	UnsupportedAudio Key = "unsupported_audio"
+	AudioURLRejected Key = "audio_url_rejected"
+)
+
+// Recognition results and failures
+const (
+	NoSpeech           Key = "no_speech"
+	LowConfidence      Key = "low_confidence"
+	PartialResult      Key = "partial_result"
+	ForwardedFrom      Key = "forwarded_from"
+	LogChatTranscript  Key = "log_chat_transcript"
+	UnsupportedFormat  Key = "unsupported_format"
+	RecognitionTimeout Key = "recognition_timeout"
+	InternalError      Key = "internal_error"
+	DownloadTooLarge   Key = "download_too_large"
+	StorageUnavailable Key = "storage_unavailable"
+	DownloadFailed     Key = "download_failed"
+	RecognitionFailed  Key = "recognition_failed"
+)
+
+// Recognition with another model on low confidence
+const (
+	ButtonReprocessModel      Key = "button_reprocess_model"
+	ReprocessModelStarted     Key = "reprocess_model_started"
+	ReprocessModelUnavailable Key = "reprocess_model_unavailable"
+)
+
+// Chat settings
+const (
+	SettingSaveFailed      Key = "setting_save_failed"
+	SettingsTitle          Key = "settings_title"
+	SettingsActiveOn       Key = "settings_active_on"
+	SettingsActiveOff      Key = "settings_active_off"
+	SettingsThreshold      Key = "settings_threshold"
+	SettingsThresholdOff   Key = "settings_threshold_off"
+	SettingsLanguage       Key = "settings_language"
+	SettingsLanguageAuto   Key = "settings_language_auto"
+	SettingsLanguageLast   Key = "settings_language_last"
+	SettingsProfanityOn    Key = "settings_profanity_on"
+	SettingsProfanityOff   Key = "settings_profanity_off"
+	ButtonEnableActive     Key = "button_enable_active"
+	ButtonDisableActive    Key = "button_disable_active"
+	ButtonEnableProfanity  Key = "button_enable_profanity"
+	ButtonDisableProfanity Key = "button_disable_profanity"
+	ThresholdUsage         Key = "threshold_usage"
+	ThresholdSaveFailed    Key = "threshold_save_failed"
+	ThresholdOff           Key = "threshold_off"
+	ThresholdCurrent       Key = "threshold_current"
+	SettingsTimestampsOn   Key = "settings_timestamps_on"
+	SettingsTimestampsOff  Key = "settings_timestamps_off"
+	TimestampsUsage        Key = "timestamps_usage"
+	TimestampsOn           Key = "timestamps_on"
+	TimestampsOff          Key = "timestamps_off"
+)
+
+// Admin commands
+const (
+	StatusUsage          Key = "status_usage"
+	TaskNotFound         Key = "task_not_found"
+	TaskReportID         Key = "task_report_id"
+	TaskReportStatus     Key = "task_report_status"
+	TaskReportAttempts   Key = "task_report_attempts"
+	TaskReportError      Key = "task_report_error"
+	TaskReportDownload   Key = "task_report_download"
+	TaskReportUpload     Key = "task_report_upload"
+	TaskReportRecognize  Key = "task_report_recognize"
+	TaskReportTotal      Key = "task_report_total"
+	TaskReportTranscript Key = "task_report_transcript"
+	ReprocessUsage       Key = "reprocess_usage"
+	ReprocessFailed      Key = "reprocess_failed"
+	TaskRequeued         Key = "task_requeued"
+	MaintenanceOn        Key = "maintenance_on"
+	MaintenanceOff       Key = "maintenance_off"
+	MaintenanceUsage     Key = "maintenance_usage"
+	MaintenanceFailed    Key = "maintenance_failed"
+)
+
+// Deleting transcripts
+const (
+	DeleteUsage       Key = "delete_usage"
+	DeleteNotFound    Key = "delete_not_found"
+	DeleteInProgress  Key = "delete_in_progress"
+	DeleteForbidden   Key = "delete_forbidden"
+	DeleteFailed      Key = "delete_failed"
+	TranscriptDeleted Key = "transcript_deleted"
+)
+
+var catalogs = map[string]map[Key]string{
+	Russian: {
+		BotStarted:   "Бот запущен!",
+		BotStopped:   "Бот остановлен.\nЧтобы возобновить работу, отправьте /start",
+		InactiveHint: "Распознавание в этом чате выключено. Отправьте /start, чтобы включить его.",
+
+		Processing:       "Обработка...",
+		ProcessingETA:    "Обработка... Займёт около %d мин.",
+		VoiceNotFound:    "Ошибка: голосовое сообщение не найдено",
+		TooShort:         "Сообщение слишком короткое, распознавать нечего.",
+		FileTooLarge:     "Файл слишком большой: Telegram позволяет ботам скачивать файлы не больше 20 МБ. Разделите запись на несколько частей и отправьте их по отдельности.",
+		QueueOverloaded:  "Очередь перегружена, попробуйте позже.",
+		Maintenance:      "Сервис на обслуживании, попробуйте позже.",
+		TaskSaveFailed:   "Ошибка при сохранении задачи",
+		EnqueueFailed:    "Ошибка при отправке задачи в очередь",
+		NotAudio:         "Это не аудиофайл. Отправьте голосовое сообщение или аудиофайл в формате OGG/Opus.",
+		UnsupportedAudio: "Этот аудиоформат не поддерживается. Отправьте голосовое сообщение или файл в формате OGG/Opus.",
+		AudioURLRejected: "Не удалось скачать аудио по ссылке. Нужна прямая публичная ссылка на файл OGG/Opus до 20 МБ.",
+
+		NoSpeech:           "Речь не распознана.",
+		LowConfidence:      "⚠️ Низкая уверенность распознавания (%.0f%%), текст может содержать ошибки.",
+		PartialResult:      "(частично распознано)",
+		ForwardedFrom:      "Переслано от %s:",
+		LogChatTranscript:  "Чат %d, сообщение %d (задача %s):",
+		UnsupportedFormat:  "Формат аудио не поддерживается.",
+		RecognitionTimeout: "Распознавание заняло слишком много времени. Попробуйте отправить сообщение покороче.",
+		InternalError:      "Произошла внутренняя ошибка при обработке голосового сообщения.",
+		DownloadTooLarge:   "Файл слишком большой: Telegram позволяет ботам скачивать файлы не больше 20 МБ. Разделите запись на несколько частей.",
+		StorageUnavailable: "Хранилище файлов временно недоступно. Попробуйте отправить сообщение позже.",
+		DownloadFailed:     "Не удалось скачать голосовое сообщение: файл недоступен.",
+		RecognitionFailed:  "Не удалось распознать голосовое сообщение после нескольких попыток.",
+
+		ButtonReprocessModel:      "🔁 Перераспознать с другой моделью",
+		ReprocessModelStarted:     "Распознаю заново…",
+		ReprocessModelUnavailable: "Это сообщение уже перераспознаётся или перераспознано.",
+
+		SettingSaveFailed:      "Не удалось сохранить настройку",
+		SettingsTitle:          "Настройки чата",
+		SettingsActiveOn:       "Распознавание: включено",
+		SettingsActiveOff:      "Распознавание: выключено",
+		SettingsThreshold:      "Порог уверенности: %.2f",
+		SettingsThresholdOff:   "Порог уверенности: выключен",
+		SettingsLanguage:       "Язык: %s",
+		SettingsLanguageAuto:   "Язык: автоопределение",
+		SettingsLanguageLast:   "Язык: автоопределение (последний: %s)",
+		SettingsProfanityOn:    "Фильтр ненормативной лексики: включён",
+		SettingsProfanityOff:   "Фильтр ненормативной лексики: выключен",
+		ButtonEnableActive:     "Включить распознавание",
+		ButtonDisableActive:    "Выключить распознавание",
+		ButtonEnableProfanity:  "Включить фильтр лексики",
+		ButtonDisableProfanity: "Выключить фильтр лексики",
+		ThresholdUsage:         "Укажите число от 0 до 1, например: /threshold 0.7",
+		ThresholdSaveFailed:    "Не удалось сохранить порог уверенности",
+		ThresholdOff:           "Предупреждения о низкой уверенности выключены.\nЧтобы включить, отправьте /threshold 0.7",
+		ThresholdCurrent:       "Порог уверенности: %.2f\nРасшифровки с меньшей уверенностью будут помечены предупреждением.",
+		SettingsTimestampsOn:   "Временные метки: включены",
+		SettingsTimestampsOff:  "Временные метки: выключены",
+		TimestampsUsage:        "Использование: /timestamps on|off",
+		TimestampsOn:           "Временные метки включены: каждая фраза расшифровки начнётся с момента записи, где она прозвучала.",
+		TimestampsOff:          "Временные метки выключены.\nЧтобы включить, отправьте /timestamps on",
+
+		StatusUsage:          "Использование: /status <task_id>",
+		TaskNotFound:         "Задача не найдена",
+		TaskReportID:         "Задача: %s",
+		TaskReportStatus:     "Статус: %s",
+		TaskReportAttempts:   "Попыток: %d",
+		TaskReportError:      "Ошибка: %s",
+		TaskReportDownload:   "Скачивание: %d мс",
+		TaskReportUpload:     "Загрузка в S3: %d мс",
+		TaskReportRecognize:  "Распознавание: %d мс",
+		TaskReportTotal:      "Всего: %d мс",
+		TaskReportTranscript: "Расшифровка (версия %d):\n%s",
+		ReprocessUsage:       "Использование: /reprocess <task_id>",
+		ReprocessFailed:      "Не удалось сбросить задачу",
+		TaskRequeued:         "Задача %s снова поставлена в очередь",
+		MaintenanceOn:        "Режим обслуживания включён: новые голосовые сообщения не принимаются.",
+		MaintenanceOff:       "Режим обслуживания выключен.",
+		MaintenanceUsage:     "Использование: /maintenance on|off",
+		MaintenanceFailed:    "Не удалось переключить режим обслуживания",
+
+		DeleteUsage:       "Ответьте командой /delete на расшифровку, которую нужно удалить.",
+		DeleteNotFound:    "Расшифровка этого сообщения не найдена.",
+		DeleteInProgress:  "Сообщение ещё распознаётся, удалите расшифровку, когда она придёт.",
+		DeleteForbidden:   "Удалить расшифровку может только отправитель голосового сообщения или администратор чата.",
+		DeleteFailed:      "Не удалось удалить расшифровку",
+		TranscriptDeleted: "Расшифровка удалена.",
+	},
+	English: {
+		BotStarted:   "Bot started!",
+		BotStopped:   "Bot stopped.\nSend /start to resume.",
+		InactiveHint: "Recognition is off in this chat. Send /start to turn it on.",
+
+		Processing:       "Processing...",
+		ProcessingETA:    "Processing... This takes about %d min.",
+		VoiceNotFound:    "Error: voice message not found",
+		TooShort:         "The message is too short, there is nothing to transcribe.",
+		FileTooLarge:     "The file is too large: Telegram lets bots download files up to 20 MB. Split the recording into several parts and send them separately.",
+		QueueOverloaded:  "The queue is overloaded, please try again later.",
+		Maintenance:      "The service is under maintenance, please try again later.",
+		TaskSaveFailed:   "Failed to save the task",
+		EnqueueFailed:    "Failed to queue the task",
+		NotAudio:         "This is not an audio file. Send a voice message or an OGG/Opus audio file.",
+		UnsupportedAudio: "This audio format is not supported. Send a voice message or an OGG/Opus file.",
+		AudioURLRejected: "Failed to download audio from the link. Send a direct public link to an OGG/Opus file up to 20 MB.",
+
+		NoSpeech:           "No speech recognized.",
+		LowConfidence:      "⚠️ Low recognition confidence (%.0f%%), the text may contain errors.",
+		PartialResult:      "(partially recognized)",
+		ForwardedFrom:      "Forwarded from %s:",
+		LogChatTranscript:  "Chat %d, message %d (task %s):",
+		UnsupportedFormat:  "The audio format is not supported.",
+		RecognitionTimeout: "Recognition took too long. Try sending a shorter message.",
+		InternalError:      "An internal error occurred while processing the voice message.",
+		DownloadTooLarge:   "The file is too large: Telegram lets bots download files up to 20 MB. Split the recording into several parts.",
+		StorageUnavailable: "File storage is temporarily unavailable. Please try again later.",
+		DownloadFailed:     "Failed to download the voice message: the file is unavailable.",
+		RecognitionFailed:  "Failed to recognize the voice message after several attempts.",
+
+		ButtonReprocessModel:      "🔁 Recognize with another model",
+		ReprocessModelStarted:     "Recognizing again…",
+		ReprocessModelUnavailable: "This message is already being recognized again or was recognized again.",
+
+		SettingSaveFailed:      "Failed to save the setting",
+		SettingsTitle:          "Chat settings",
+		SettingsActiveOn:       "Recognition: on",
+		SettingsActiveOff:      "Recognition: off",
+		SettingsThreshold:      "Confidence threshold: %.2f",
+		SettingsThresholdOff:   "Confidence threshold: off",
+		SettingsLanguage:       "Language: %s",
+		SettingsLanguageAuto:   "Language: auto-detect",
+		SettingsLanguageLast:   "Language: auto-detect (last: %s)",
+		SettingsProfanityOn:    "Profanity filter: on",
+		SettingsProfanityOff:   "Profanity filter: off",
+		ButtonEnableActive:     "Turn recognition on",
+		ButtonDisableActive:    "Turn recognition off",
+		ButtonEnableProfanity:  "Turn profanity filter on",
+		ButtonDisableProfanity: "Turn profanity filter off",
+		ThresholdUsage:         "Send a number from 0 to 1, for example: /threshold 0.7",
+		ThresholdSaveFailed:    "Failed to save the confidence threshold",
+		ThresholdOff:           "Low confidence warnings are off.\nSend /threshold 0.7 to turn them on.",
+		ThresholdCurrent:       "Confidence threshold: %.2f\nTranscripts with lower confidence will be marked with a warning.",
+		SettingsTimestampsOn:   "Timestamps: on",
+		SettingsTimestampsOff:  "Timestamps: off",
+		TimestampsUsage:        "Usage: /timestamps on|off",
+		TimestampsOn:           "Timestamps are on: every phrase of a transcript starts with the moment of the recording it was said at.",
+		TimestampsOff:          "Timestamps are off.\nSend /timestamps on to turn them on.",
+
+		StatusUsage:          "Usage: /status <task_id>",
+		TaskNotFound:         "Task not found",
+		TaskReportID:         "Task: %s",
+		TaskReportStatus:     "Status: %s",
+		TaskReportAttempts:   "Attempts: %d",
+		TaskReportError:      "Error: %s",
+		TaskReportDownload:   "Download: %d ms",
+		TaskReportUpload:     "S3 upload: %d ms",
+		TaskReportRecognize:  "Recognition: %d ms",
+		TaskReportTotal:      "Total: %d ms",
+		TaskReportTranscript: "Transcript (version %d):\n%s",
+		ReprocessUsage:       "Usage: /reprocess <task_id>",
+		ReprocessFailed:      "Failed to reset the task",
+		TaskRequeued:         "Task %s is queued again",
+		MaintenanceOn:        "Maintenance mode is on: new voice messages are not accepted.",
+		MaintenanceOff:       "Maintenance mode is off.",
+		MaintenanceUsage:     "Usage: /maintenance on|off",
+		MaintenanceFailed:    "Failed to switch maintenance mode",
+
+		DeleteUsage:       "Reply /delete to the transcript you want to delete.",
+		DeleteNotFound:    "No transcript was found for this message.",
+		DeleteInProgress:  "The message is still being recognized, delete the transcript once it arrives.",
+		DeleteForbidden:   "Only the sender of the voice message or a chat admin can delete its transcript.",
+		DeleteFailed:      "Failed to delete the transcript",
+		TranscriptDeleted: "The transcript was deleted.",
+	},
+}
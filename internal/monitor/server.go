@@ -0,0 +1,262 @@
+package monitor
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"voxly/internal/storage"
+	"voxly/internal/worker"
+	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+)
+
+// PoolStatsSource reports database connection pool usage
+type PoolStatsSource interface {
+	Stats() storage.PoolStats
+}
+
+// QueueDepthSource reports how many messages wait in a queue
+type QueueDepthSource interface {
+	QueueDepth(queueName string) (int, error)
+}
+
+// BreakerStatusSource reports the state and transitions of circuit breakers
+type BreakerStatusSource interface {
+	Statuses() []resilience.BreakerStatus
+}
+
+// ResultCacheSource reports hits and misses of the recognition result cache
+type ResultCacheSource interface {
+	ResultCacheStats() worker.ResultCacheStats
+}
+
+// SelfTester runs a synthetic task through the recognition pipeline. It reports
+// whether the run passed and the report to return as JSON.
+type SelfTester interface {
+	SelfTest(ctx context.Context) (ok bool, report any)
+}
+
+// SelfTesterFunc adapts an ordinary function to SelfTester
+type SelfTesterFunc func(ctx context.Context) (bool, any)
+
+// SelfTest calls f(ctx)
+func (f SelfTesterFunc) SelfTest(ctx context.Context) (bool, any) {
+	return f(ctx)
+}
+
+// selfTestTimeout bounds a /selftest run so a stuck recognition can't hold the request
+const selfTestTimeout = 2 * time.Minute
+
+// Server exposes /healthz, /metrics and the optional /selftest for monitoring
+type Server struct {
+	db       PoolStatsSource
+	queue    QueueDepthSource
+	queues   []string
+	selfTest SelfTester
+	token    string
+	breakers BreakerStatusSource
+	results  ResultCacheSource
+	srv      *http.Server
+}
+
+// HealthResponse is the /healthz payload
+type HealthResponse struct {
+	Status string             `json:"status"`
+	DB     *storage.PoolStats `json:"db,omitempty"`
+}
+
+// NewServer creates a monitoring server listening on addr
+func NewServer(addr string, db PoolStatsSource) *Server {
+	s := &Server{db: db}
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// TrackQueues adds the depth of the named queues to /metrics. Call it before Start.
+func (s *Server) TrackQueues(source QueueDepthSource, queueNames ...string) {
+	s.queue = source
+	s.queues = queueNames
+}
+
+// TrackBreakers adds circuit breaker states and transitions to /metrics. Call it before Start.
+func (s *Server) TrackBreakers(source BreakerStatusSource) {
+	s.breakers = source
+}
+
+// TrackResultCache adds recognition result cache hits and misses to /metrics. Call it before Start.
+func (s *Server) TrackResultCache(source ResultCacheSource) {
+	s.results = source
+}
+
+// EnableSelfTest serves POST /selftest for deployment validation to requests
+// carrying token as a bearer token. Every run costs a recognition, so the
+// endpoint stays disabled without a token. Call it before Start.
+func (s *Server) EnableSelfTest(tester SelfTester, token string) {
+	if token == "" {
+		logger.Warn("Self-test endpoint disabled: no token configured")
+		return
+	}
+	s.selfTest = tester
+	s.token = token
+}
+
+// Handler returns the HTTP handler serving the monitoring endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/selftest", s.handleSelfTest)
+	return mux
+}
+
+// Start serves requests until Shutdown is called
+func (s *Server) Start() {
+	logger.Info("Monitoring server started", zap.String("addr", s.srv.Addr))
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Monitoring server failed", zap.Error(err))
+	}
+}
+
+// Shutdown stops the server, waiting for in-flight requests
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := HealthResponse{Status: "ok"}
+	if s.db != nil {
+		stats := s.db.Stats()
+		resp.DB = &stats
+		if stats.MaxConns > 0 && stats.AcquiredConns >= stats.MaxConns {
+			// Still serving, but new queries wait for a free connection
+			resp.Status = "db_pool_saturated"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to write health response", zap.Error(err))
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if s.db != nil {
+		stats := s.db.Stats()
+		writeMetric(w, "voxly_db_pool_acquired_conns", "gauge", "Connections currently in use", int64(stats.AcquiredConns))
+		writeMetric(w, "voxly_db_pool_idle_conns", "gauge", "Idle connections in the pool", int64(stats.IdleConns))
+		writeMetric(w, "voxly_db_pool_total_conns", "gauge", "Open connections in the pool", int64(stats.TotalConns))
+		writeMetric(w, "voxly_db_pool_max_conns", "gauge", "Maximum pool size", int64(stats.MaxConns))
+		writeMetric(w, "voxly_db_pool_empty_acquire_total", "counter", "Acquires that waited for a free connection", stats.EmptyAcquireCount)
+	}
+
+	if s.queue != nil && len(s.queues) > 0 {
+		fmt.Fprint(w, "# HELP voxly_queue_depth Messages waiting in the queue\n# TYPE voxly_queue_depth gauge\n")
+		for _, name := range s.queues {
+			depth, err := s.queue.QueueDepth(name)
+			if err != nil {
+				logger.Warn("Failed to get queue depth", zap.String("queue", name), zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "voxly_queue_depth{queue=%q} %d\n", name, depth)
+		}
+	}
+
+	if s.breakers != nil {
+		writeBreakerMetrics(w, s.breakers.Statuses())
+	}
+
+	if s.results != nil {
+		stats := s.results.ResultCacheStats()
+		writeMetric(w, "voxly_result_cache_hits_total", "counter", "Recognitions skipped thanks to a cached result", stats.Hits)
+		writeMetric(w, "voxly_result_cache_misses_total", "counter", "Recognition result cache lookups that found nothing", stats.Misses)
+	}
+}
+
+// writeBreakerMetrics exports each breaker's state (0 closed, 1 open, 2 half-open)
+// and how many times it moved between states
+func writeBreakerMetrics(w io.Writer, statuses []resilience.BreakerStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, "# HELP voxly_circuit_breaker_state Circuit breaker state: 0 closed, 1 open, 2 half-open\n# TYPE voxly_circuit_breaker_state gauge\n")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "voxly_circuit_breaker_state{breaker=%q} %d\n", status.Name, status.State)
+	}
+
+	fmt.Fprint(w, "# HELP voxly_circuit_breaker_transitions_total Circuit breaker state changes\n# TYPE voxly_circuit_breaker_transitions_total counter\n")
+	for _, status := range statuses {
+		transitions := make([]resilience.Transition, 0, len(status.Transitions))
+		for t := range status.Transitions {
+			transitions = append(transitions, t)
+		}
+		sort.Slice(transitions, func(i, j int) bool {
+			if transitions[i].From != transitions[j].From {
+				return transitions[i].From < transitions[j].From
+			}
+			return transitions[i].To < transitions[j].To
+		})
+		for _, t := range transitions {
+			fmt.Fprintf(w, "voxly_circuit_breaker_transitions_total{breaker=%q,from=%q,to=%q} %d\n",
+				status.Name, t.From, t.To, status.Transitions[t])
+		}
+	}
+}
+
+// handleSelfTest runs the pipeline self-test. It is POST-only because every run
+// uploads audio and starts a billable recognition.
+func (s *Server) handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	if s.selfTest == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), selfTestTimeout)
+	defer cancel()
+
+	ok, report := s.selfTest.SelfTest(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error("Failed to write self-test response", zap.Error(err))
+	}
+}
+
+// authorized reports whether r carries the self-test token
+func (s *Server) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// writeMetric writes a single sample in the Prometheus text format
+func writeMetric(w io.Writer, name, kind, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
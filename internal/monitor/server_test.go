@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"voxly/internal/storage"
+	"voxly/internal/worker"
+	"voxly/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticStats storage.PoolStats
+
+func (s staticStats) Stats() storage.PoolStats {
+	return storage.PoolStats(s)
+}
+
+func TestServer_Healthz(t *testing.T) {
+	tests := []struct {
+		name   string
+		stats  staticStats
+		status string
+	}{
+		{"ok", staticStats{AcquiredConns: 2, IdleConns: 3, TotalConns: 5, MaxConns: 10}, "ok"},
+		{"saturated", staticStats{AcquiredConns: 10, TotalConns: 10, MaxConns: 10}, "db_pool_saturated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewServer("", tt.stats).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp HealthResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tt.status, resp.Status)
+			if assert.NotNil(t, resp.DB) {
+				assert.Equal(t, storage.PoolStats(tt.stats), *resp.DB)
+			}
+		})
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	stats := staticStats{AcquiredConns: 2, IdleConns: 3, TotalConns: 5, MaxConns: 10, EmptyAcquireCount: 4}
+
+	rec := httptest.NewRecorder()
+	NewServer("", stats).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE voxly_db_pool_acquired_conns gauge\nvoxly_db_pool_acquired_conns 2\n")
+	assert.Contains(t, body, "voxly_db_pool_idle_conns 3\n")
+	assert.Contains(t, body, "voxly_db_pool_total_conns 5\n")
+	assert.Contains(t, body, "voxly_db_pool_max_conns 10\n")
+	assert.Contains(t, body, "voxly_db_pool_empty_acquire_total 4\n")
+}
+
+type staticDepths map[string]int
+
+func (d staticDepths) QueueDepth(queueName string) (int, error) {
+	depth, ok := d[queueName]
+	if !ok {
+		return 0, errors.New("queue not found")
+	}
+	return depth, nil
+}
+
+func TestServer_MetricsQueueDepth(t *testing.T) {
+	s := NewServer("", nil)
+	s.TrackQueues(staticDepths{"voice_processing": 12}, "voice_processing", "missing")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE voxly_queue_depth gauge\n")
+	assert.Contains(t, body, "voxly_queue_depth{queue=\"voice_processing\"} 12\n")
+	assert.NotContains(t, body, "missing")
+}
+
+type staticBreakers []resilience.BreakerStatus
+
+func (b staticBreakers) Statuses() []resilience.BreakerStatus {
+	return b
+}
+
+func TestServer_MetricsCircuitBreakers(t *testing.T) {
+	s := NewServer("", nil)
+	s.TrackBreakers(staticBreakers{
+		{
+			Name:  "s3",
+			State: resilience.StateOpen,
+			Transitions: map[resilience.Transition]uint64{
+				{From: resilience.StateClosed, To: resilience.StateOpen}:   2,
+				{From: resilience.StateOpen, To: resilience.StateHalfOpen}: 1,
+			},
+		},
+		{Name: "speechkit", State: resilience.StateClosed},
+	})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE voxly_circuit_breaker_state gauge\n")
+	assert.Contains(t, body, "voxly_circuit_breaker_state{breaker=\"s3\"} 1\n")
+	assert.Contains(t, body, "voxly_circuit_breaker_state{breaker=\"speechkit\"} 0\n")
+	assert.Contains(t, body, "# TYPE voxly_circuit_breaker_transitions_total counter\n")
+	assert.Contains(t, body, "voxly_circuit_breaker_transitions_total{breaker=\"s3\",from=\"closed\",to=\"open\"} 2\n")
+	assert.Contains(t, body, "voxly_circuit_breaker_transitions_total{breaker=\"s3\",from=\"open\",to=\"half-open\"} 1\n")
+	assert.NotContains(t, body, "transitions_total{breaker=\"speechkit\"")
+}
+
+type staticResultCache worker.ResultCacheStats
+
+func (s staticResultCache) ResultCacheStats() worker.ResultCacheStats {
+	return worker.ResultCacheStats(s)
+}
+
+func TestServer_MetricsResultCache(t *testing.T) {
+	s := NewServer("", nil)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.NotContains(t, rec.Body.String(), "voxly_result_cache")
+
+	s.TrackResultCache(staticResultCache{Hits: 3, Misses: 5})
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE voxly_result_cache_hits_total counter\nvoxly_result_cache_hits_total 3\n")
+	assert.Contains(t, body, "# TYPE voxly_result_cache_misses_total counter\nvoxly_result_cache_misses_total 5\n")
+}
+
+type staticSelfTest worker.SelfTestReport
+
+func (r staticSelfTest) SelfTest(ctx context.Context) (bool, any) {
+	return r.OK, worker.SelfTestReport(r)
+}
+
+func TestServer_SelfTest(t *testing.T) {
+	failed := staticSelfTest{Stages: []worker.SelfTestStage{{Name: worker.SelfTestStageUpload, Error: "access denied"}}}
+	passed := staticSelfTest{OK: true, Stages: []worker.SelfTestStage{{Name: worker.SelfTestStageUpload, OK: true}}}
+
+	tests := []struct {
+		name   string
+		tester SelfTester
+		token  string
+		method string
+		auth   string
+		code   int
+	}{
+		{"disabled", nil, "", http.MethodPost, "", http.StatusNotFound},
+		{"no token configured", passed, "", http.MethodPost, "Bearer ", http.StatusNotFound},
+		{"get", passed, "secret", http.MethodGet, "Bearer secret", http.StatusMethodNotAllowed},
+		{"no auth", passed, "secret", http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", passed, "secret", http.MethodPost, "Bearer wrong", http.StatusUnauthorized},
+		{"passed", passed, "secret", http.MethodPost, "Bearer secret", http.StatusOK},
+		{"failed", failed, "secret", http.MethodPost, "Bearer secret", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("", nil)
+			if tt.tester != nil {
+				s.EnableSelfTest(tt.tester, tt.token)
+			}
+
+			req := httptest.NewRequest(tt.method, "/selftest", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			assert.Equal(t, tt.code, rec.Code)
+
+			if tt.code == http.StatusOK || tt.code == http.StatusServiceUnavailable {
+				var report worker.SelfTestReport
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+				assert.Equal(t, tt.code == http.StatusOK, report.OK)
+				assert.Len(t, report.Stages, 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package preferences
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// Storage persists chat preferences
+type Storage interface {
+	GetChatPreferences(ctx context.Context, chatID int64) (*model.ChatPreferences, error)
+	SaveChatPreferences(ctx context.Context, prefs *model.ChatPreferences) error
+}
+
+// Store is the single source of per-chat settings for the bot and the worker.
+// The database holds the preferences; Redis caches them.
+type Store struct {
+	db    Storage
+	cache cache.Cache
+}
+
+// NewStore creates a preferences store over the database and cache
+func NewStore(db Storage, c cache.Cache) *Store {
+	return &Store{db: db, cache: c}
+}
+
+// Get returns the chat's preferences. Chats that never saved any get empty
+// preferences, so callers fall back to configuration.
+func (s *Store) Get(ctx context.Context, chatID int64) (*model.ChatPreferences, error) {
+	key := cache.ChatPreferencesCacheKey(chatID)
+
+	var cached model.ChatPreferences
+	if err := s.cache.Get(ctx, key, &cached); err == nil {
+		cached.ChatID = chatID
+		return &cached, nil
+	}
+
+	prefs, err := s.db.GetChatPreferences(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.UpdatedAt.IsZero() {
+		s.importLegacy(ctx, prefs)
+	}
+
+	if err := s.cache.SetWithTTL(ctx, key, prefs, cache.ChatPreferencesTTL); err != nil {
+		logger.Warn("Failed to cache chat preferences",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err))
+	}
+	return prefs, nil
+}
+
+// Update applies change to the chat's preferences and saves them
+func (s *Store) Update(ctx context.Context, chatID int64, change func(*model.ChatPreferences)) (*model.ChatPreferences, error) {
+	prefs, err := s.Get(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	change(prefs)
+	prefs.UpdatedAt = time.Now()
+	if err := s.db.SaveChatPreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+
+	key := cache.ChatPreferencesCacheKey(chatID)
+	if err := s.cache.SetWithTTL(ctx, key, prefs, cache.ChatPreferencesTTL); err != nil {
+		// A stale entry would hide the change until it expires
+		if delErr := s.cache.Delete(ctx, key); delErr != nil {
+			return nil, fmt.Errorf("failed to invalidate cached chat preferences: %w", delErr)
+		}
+	}
+	return prefs, nil
+}
+
+// importLegacy fills unsaved preferences from the Redis keys used before
+// preferences moved to the database, so chats keep their settings
+func (s *Store) importLegacy(ctx context.Context, prefs *model.ChatPreferences) {
+	var active string
+	if err := s.cache.Get(ctx, cache.ChatActiveCacheKey(prefs.ChatID), &active); err == nil {
+		prefs.SetActive(active == "true")
+	}
+
+	var threshold float64
+	if err := s.cache.Get(ctx, cache.ChatThresholdCacheKey(prefs.ChatID), &threshold); err == nil {
+		prefs.SetThreshold(threshold)
+	}
+}
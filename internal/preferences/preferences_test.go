@@ -0,0 +1,104 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/pkg/cache"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStorage struct {
+	prefs map[int64]model.ChatPreferences
+	reads int
+	err   error
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{prefs: make(map[int64]model.ChatPreferences)}
+}
+
+func (f *fakeStorage) GetChatPreferences(ctx context.Context, chatID int64) (*model.ChatPreferences, error) {
+	f.reads++
+	if f.err != nil {
+		return nil, f.err
+	}
+	prefs := f.prefs[chatID]
+	prefs.ChatID = chatID
+	return &prefs, nil
+}
+
+func (f *fakeStorage) SaveChatPreferences(ctx context.Context, prefs *model.ChatPreferences) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.prefs[prefs.ChatID] = *prefs
+	return nil
+}
+
+func TestStore_GetCachesPreferences(t *testing.T) {
+	db := newFakeStorage()
+	store := NewStore(db, cache.NewMemoryCache(time.Hour))
+	ctx := context.Background()
+
+	prefs, err := store.Get(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), prefs.ChatID)
+	assert.True(t, prefs.IsActive(true))
+
+	_, err = store.Get(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.reads)
+}
+
+func TestStore_GetImportsLegacyKeys(t *testing.T) {
+	memory := cache.NewMemoryCache(time.Hour)
+	ctx := context.Background()
+	assert.NoError(t, memory.SetWithTTL(ctx, cache.ChatActiveCacheKey(42), "false", time.Hour))
+	assert.NoError(t, memory.SetWithTTL(ctx, cache.ChatThresholdCacheKey(42), 0.8, time.Hour))
+
+	store := NewStore(newFakeStorage(), memory)
+
+	prefs, err := store.Get(ctx, 42)
+	assert.NoError(t, err)
+	assert.False(t, prefs.IsActive(true))
+	assert.Equal(t, 0.8, prefs.ConfidenceThreshold(0.5))
+}
+
+func TestStore_Update(t *testing.T) {
+	db := newFakeStorage()
+	store := NewStore(db, cache.NewMemoryCache(time.Hour))
+	ctx := context.Background()
+
+	// Warm the cache so the update has to replace it
+	_, err := store.Get(ctx, 42)
+	assert.NoError(t, err)
+
+	updated, err := store.Update(ctx, 42, func(prefs *model.ChatPreferences) {
+		prefs.Language = "en-US"
+		prefs.ProfanityFilter = true
+	})
+	assert.NoError(t, err)
+	assert.False(t, updated.UpdatedAt.IsZero())
+	assert.Equal(t, "en-US", db.prefs[42].Language)
+
+	prefs, err := store.Get(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "en-US", prefs.Language)
+	assert.True(t, prefs.ProfanityFilter)
+}
+
+func TestStore_UpdateStorageError(t *testing.T) {
+	db := newFakeStorage()
+	db.err = errors.New("db down")
+	store := NewStore(db, cache.NewNoopCache())
+
+	_, err := store.Update(context.Background(), 42, func(prefs *model.ChatPreferences) {
+		prefs.SetActive(false)
+	})
+	assert.Error(t, err)
+	assert.Empty(t, db.prefs)
+}
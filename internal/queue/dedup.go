@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"time"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// dedupTimeout bounds a single dedup claim or release
+const dedupTimeout = 2 * time.Second
+
+// Deduplicator remembers the IDs of handled messages for a while, so a message
+// published twice is only handled once
+type Deduplicator struct {
+	cache  cache.Claimer
+	window time.Duration
+}
+
+// NewDeduplicator remembers handled message IDs in c for window
+func NewDeduplicator(c cache.Claimer, window time.Duration) *Deduplicator {
+	return &Deduplicator{cache: c, window: window}
+}
+
+// TryClaim records the message with id as handled and reports whether it is
+// new, in one step, so consumers given two copies at once don't both handle
+// it. If the cache can't be reached, the message is treated as new.
+func (d *Deduplicator) TryClaim(id string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), dedupTimeout)
+	defer cancel()
+
+	claimed, err := d.cache.SetIfAbsent(ctx, cache.HandledMessageCacheKey(id), true, d.window)
+	if err != nil {
+		logger.Warn("Failed to check message for duplicates",
+			zap.String("message_id", id),
+			zap.Error(err))
+		return true
+	}
+	return claimed
+}
+
+// Release forgets the message with id, so its redelivery is handled again
+func (d *Deduplicator) Release(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dedupTimeout)
+	defer cancel()
+
+	if err := d.cache.Delete(ctx, cache.HandledMessageCacheKey(id)); err != nil {
+		logger.Warn("Failed to release message claim",
+			zap.String("message_id", id),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"voxly/pkg/cache"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockClaimer struct {
+	mock.Mock
+}
+
+func (m *mockClaimer) SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, key, value, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockClaimer) Delete(ctx context.Context, key string) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+func TestHandleDeliverySkipsDuplicate(t *testing.T) {
+	c := new(mockClaimer)
+	c.On("SetIfAbsent", mock.Anything, "queue:handled:task-1", true, time.Hour).Return(false, nil)
+
+	ack := &recordingAcknowledger{}
+	handled := false
+	handleDelivery(amqp.Delivery{Acknowledger: ack, MessageId: "task-1"}, func([]byte) error {
+		handled = true
+		return nil
+	}, NewDeduplicator(c, time.Hour))
+
+	assert.False(t, handled)
+	assert.True(t, ack.acked)
+}
+
+func TestHandleDeliveryClaimsMessageOnce(t *testing.T) {
+	dedup := NewDeduplicator(cache.NewMemoryCache(time.Hour), time.Hour)
+
+	// Two copies delivered to two consumers at once are handled once
+	var handled atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handleDelivery(amqp.Delivery{Acknowledger: &recordingAcknowledger{}, MessageId: "task-1"}, func([]byte) error {
+				handled.Add(1)
+				return nil
+			}, dedup)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), handled.Load())
+}
+
+func TestHandleDeliveryReleasesFailedMessage(t *testing.T) {
+	c := new(mockClaimer)
+	c.On("SetIfAbsent", mock.Anything, "queue:handled:task-1", true, time.Hour).Return(true, nil)
+	c.On("Delete", mock.Anything, "queue:handled:task-1").Return(nil)
+
+	// A requeued message must be handled again on redelivery
+	ack := &recordingAcknowledger{}
+	handleDelivery(amqp.Delivery{Acknowledger: ack, MessageId: "task-1"}, func([]byte) error {
+		return errors.New("boom")
+	}, NewDeduplicator(c, time.Hour))
+
+	assert.True(t, ack.nacked)
+	assert.True(t, ack.requeue)
+	c.AssertExpectations(t)
+}
+
+func TestHandleDeliveryHandlesRedelivery(t *testing.T) {
+	c := new(mockClaimer)
+	c.On("SetIfAbsent", mock.Anything, "queue:handled:task-1", true, time.Hour).Return(false, nil)
+
+	// The consumer that claimed it stopped before settling it
+	ack := &recordingAcknowledger{}
+	handled := false
+	handleDelivery(amqp.Delivery{Acknowledger: ack, MessageId: "task-1", Redelivered: true}, func([]byte) error {
+		handled = true
+		return nil
+	}, NewDeduplicator(c, time.Hour))
+
+	assert.True(t, handled)
+	assert.True(t, ack.acked)
+}
+
+func TestHandleDeliveryDedupCacheErrors(t *testing.T) {
+	c := new(mockClaimer)
+	c.On("SetIfAbsent", mock.Anything, "queue:handled:task-1", true, time.Hour).Return(false, errors.New("redis down"))
+
+	// Without the cache every message is handled
+	ack := &recordingAcknowledger{}
+	handled := false
+	handleDelivery(amqp.Delivery{Acknowledger: ack, MessageId: "task-1"}, func([]byte) error {
+		handled = true
+		return nil
+	}, NewDeduplicator(c, time.Hour))
+
+	assert.True(t, handled)
+	assert.True(t, ack.acked)
+}
+
+func TestHandleDeliveryWithoutMessageID(t *testing.T) {
+	c := new(mockClaimer)
+
+	ack := &recordingAcknowledger{}
+	handleDelivery(amqp.Delivery{Acknowledger: ack}, func([]byte) error { return nil }, NewDeduplicator(c, time.Hour))
+
+	assert.True(t, ack.acked)
+	c.AssertNotCalled(t, "SetIfAbsent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVoiceTask_MessageID(t *testing.T) {
+	task := &VoiceTask{TaskID: "task-1"}
+	assert.Equal(t, "task-1", task.MessageID())
+
+	task.RequeuedAt = time.Unix(0, 42)
+	assert.Equal(t, "task-1:42", task.MessageID())
+}
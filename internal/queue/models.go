@@ -1,6 +1,13 @@
 package queue
 
-import "time"
+import (
+	"fmt"
+	"time"
+	"voxly/pkg/model"
+)
+
+// MaxFileSize is the largest file the Telegram Bot API lets bots download
+const MaxFileSize = 20 << 20
 
 // VoiceTask represents a voice message processing task
 type VoiceTask struct {
@@ -12,13 +19,48 @@ type VoiceTask struct {
 	FileSize          int64     `json:"file_size"`
 	MimeType          string    `json:"mime_type"`
 	CreatedAt         time.Time `json:"created_at"`
+	// RequeuedAt is set when the task is published again to be reprocessed
+	RequeuedAt time.Time `json:"requeued_at"`
+	// SourceURL is set for audio sent as a link; FileID is empty then
+	SourceURL string `json:"source_url,omitempty"`
+	// ClaimedBy is set by a database poller that already claimed the task
+	ClaimedBy string `json:"claimed_by,omitempty"`
+}
+
+// NewVoiceTask rebuilds the queue message of a stored task from its meta
+func NewVoiceTask(task *model.Task) *VoiceTask {
+	voiceTask := &VoiceTask{
+		TaskID:            task.ID,
+		ChatID:            task.ChatID,
+		TelegramMessageID: task.TelegramMessageID,
+		FileID:            task.FileID,
+		SourceURL:         task.SourceURL(),
+		CreatedAt:         task.CreatedAt,
+	}
+	task.Meta.Decode("voice_duration", &voiceTask.Duration)
+	task.Meta.Decode("file_size", &voiceTask.FileSize)
+	task.Meta.Decode("mime_type", &voiceTask.MimeType)
+	return voiceTask
+}
+
+// MessageID identifies one publication of the task for deduplication. A
+// reprocessing request gets its own ID, so it isn't taken for a duplicate.
+func (t *VoiceTask) MessageID() string {
+	if t.RequeuedAt.IsZero() {
+		return t.TaskID
+	}
+	return fmt.Sprintf("%s:%d", t.TaskID, t.RequeuedAt.UnixNano())
 }
 
-// TranscriptionResult represents the result of speech recognition
+// TranscriptionResult represents the result of speech recognition. Successful
+// results carry the saved transcript, so consumers can cache it as stored.
 type TranscriptionResult struct {
-	TaskID       string `json:"task_id"`
-	Text         string `json:"text"`
-	RawResponse  []byte `json:"raw_response,omitempty"`
-	Success      bool   `json:"success"`
-	ErrorMessage string `json:"error_message,omitempty"`
+	TaskID       string    `json:"task_id"`
+	TranscriptID string    `json:"transcript_id,omitempty"`
+	Version      int       `json:"version,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	Text         string    `json:"text"`
+	RawResponse  []byte    `json:"raw_response,omitempty"`
+	Success      bool      `json:"success"`
+	ErrorMessage string    `json:"error_message,omitempty"`
 }
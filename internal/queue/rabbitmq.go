@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"runtime/debug"
 	"time"
 	"voxly/pkg/logger"
 
@@ -13,13 +14,36 @@ import (
 
 const (
 	QueueNameVoiceProcessing = "voice_processing"
-	ExchangeName             = "voxly"
+	// QueueNameResults prefixes the results queue of each consumer, see ResultsQueueName
+	QueueNameResults = "transcription_results"
+	ExchangeName     = "voxly"
+	// ResultsExchangeName fans every TranscriptionResult out to all results queues
+	ResultsExchangeName = "voxly.results"
 )
 
+// queueNames lists the queues declared and bound to the exchange on connect
+var queueNames = []string{QueueNameVoiceProcessing, QueueNameVoiceLong}
+
+// ResultsQueueName returns the results queue of consumer. Every consumer gets
+// each result; replicas of a service share its queue and split the results.
+func ResultsQueueName(consumer string) string {
+	return QueueNameResults + "." + consumer
+}
+
+// queueDeclarer is the part of *amqp.Channel that sets up queues
+type queueDeclarer interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
 type RabbitMQ struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
 	url     string
+	// dedup, when set, skips consumed messages that were handled recently
+	dedup *Deduplicator
+	// routing picks the queue PublishTask sends a task to
+	routing Routing
 }
 
 // New RabbitMQ client
@@ -51,33 +75,26 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		return nil, fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
-	// Declare queue
-	_, err = ch.QueueDeclare(
-		QueueNameVoiceProcessing, // name
-		true,                     // durable
-		false,                    // delete when unused
-		false,                    // exclusive
-		false,                    // no-wait
-		nil,                      // arguments
+	// Results go to every consumer, so they get an exchange of their own
+	err = ch.ExchangeDeclare(
+		ResultsExchangeName, // name
+		"fanout",            // type
+		true,                // durable
+		false,               // auto-deleted
+		false,               // internal
+		false,               // no-wait
+		nil,                 // arguments
 	)
 	if err != nil {
 		ch.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return nil, fmt.Errorf("failed to declare results exchange: %w", err)
 	}
 
-	// Bind queue to exchange
-	err = ch.QueueBind(
-		QueueNameVoiceProcessing, // queue name
-		QueueNameVoiceProcessing, // routing key
-		ExchangeName,             // exchange
-		false,
-		nil,
-	)
-	if err != nil {
+	if err := declareQueues(ch); err != nil {
 		ch.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to bind queue: %w", err)
+		return nil, err
 	}
 
 	logger.Info("RabbitMQ connected successfully")
@@ -89,22 +106,91 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 	}, nil
 }
 
+// declareQueues declares every queue in queueNames
+func declareQueues(ch queueDeclarer) error {
+	for _, name := range queueNames {
+		if err := declareQueue(ch, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declareQueue declares a durable queue bound to the exchange under its own name
+func declareQueue(ch queueDeclarer, name string) error {
+	_, err := ch.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", name, err)
+	}
+
+	err = ch.QueueBind(
+		name,         // queue name
+		name,         // routing key
+		ExchangeName, // exchange
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// declareResultsQueue declares the durable results queue of consumer and binds
+// it to the results exchange. It returns the queue name.
+func declareResultsQueue(ch queueDeclarer, consumer string) (string, error) {
+	name := ResultsQueueName(consumer)
+	_, err := ch.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to declare queue %s: %w", name, err)
+	}
+
+	// A fanout exchange ignores the routing key
+	if err := ch.QueueBind(name, "", ResultsExchangeName, false, nil); err != nil {
+		return "", fmt.Errorf("failed to bind queue %s: %w", name, err)
+	}
+
+	return name, nil
+}
+
 // Publish publishes a message to the queue
 func (r *RabbitMQ) Publish(queueName string, body []byte) error {
+	return r.publish(ExchangeName, queueName, "", body)
+}
+
+// publish sends body to exchange under routing key queueName, with messageID
+// as the AMQP message ID; empty means none
+func (r *RabbitMQ) publish(exchange, queueName, messageID string, body []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	err := r.channel.PublishWithContext(
 		ctx,
-		ExchangeName, // exchange
-		queueName,    // routing key
-		false,        // mandatory
-		false,        // immediate
+		exchange,  // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
 			Timestamp:    time.Now(),
+			MessageId:    messageID,
 		},
 	)
 
@@ -119,14 +205,47 @@ func (r *RabbitMQ) Publish(queueName string, body []byte) error {
 	return nil
 }
 
-// PublishTask publishes a VoiceTask to the queue
+// PublishTask publishes a VoiceTask to the queue chosen by the routing. The
+// message ID lets a deduplicating consumer drop the task if the publish is repeated.
 func (r *RabbitMQ) PublishTask(task *VoiceTask) error {
 	body, err := json.Marshal(task)
 	if err != nil {
 		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
-	return r.Publish(QueueNameVoiceProcessing, body)
+	return r.publish(ExchangeName, r.routing.QueueFor(task), task.MessageID(), body)
+}
+
+// Route makes PublishTask choose the queue of each task with routing.
+// Call it before publishing.
+func (r *RabbitMQ) Route(routing Routing) {
+	r.routing = routing
+}
+
+// PublishResult publishes a finished task's TranscriptionResult to every results consumer
+func (r *RabbitMQ) PublishResult(result *TranscriptionResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return r.publish(ResultsExchangeName, "", "", body)
+}
+
+// ConsumeResults consumes TranscriptionResults from the results queue of
+// consumer, declaring it first
+func (r *RabbitMQ) ConsumeResults(consumer string, handler func([]byte) error) error {
+	name, err := declareResultsQueue(r.channel, consumer)
+	if err != nil {
+		return err
+	}
+	return r.Consume(name, handler)
+}
+
+// Deduplicate makes Consume skip messages whose ID was handled within the
+// deduplicator's window. Call it before consuming.
+func (r *RabbitMQ) Deduplicate(d *Deduplicator) {
+	r.dedup = d
 }
 
 // Consume starts consuming messages from the queue
@@ -157,22 +276,76 @@ func (r *RabbitMQ) Consume(queueName string, handler func([]byte) error) error {
 	logger.Info("Starting to consume messages", zap.String("queue", queueName))
 
 	for msg := range msgs {
-		logger.Debug("Received message", zap.Int("size", len(msg.Body)))
-
-		err := handler(msg.Body)
-		if err != nil {
-			logger.Error("Failed to handle message", zap.Error(err))
-			// Reject and requeue
-			msg.Nack(false, true)
-		} else {
-			// Acknowledge
-			msg.Ack(false)
-		}
+		handleDelivery(msg, handler, r.dedup)
 	}
 
 	return nil
 }
 
+// handleDelivery runs the handler for one message and settles it: Ack on
+// success, Nack with requeue on error. A panicking handler is logged and
+// its message rejected without requeue, so it can't crash the consumer or
+// be redelivered forever. With dedup set, a message is claimed before it is
+// handled and copies claimed before are acked unhandled; a requeued message
+// is released for its redelivery. RabbitMQ redelivers a message only when its
+// consumer didn't settle it, so a redelivery is handled even if claimed.
+func handleDelivery(msg amqp.Delivery, handler func([]byte) error, dedup *Deduplicator) {
+	logger.Debug("Received message", zap.Int("size", len(msg.Body)))
+
+	checkDuplicate := dedup != nil && msg.MessageId != ""
+	if checkDuplicate && !dedup.TryClaim(msg.MessageId) && !msg.Redelivered {
+		logger.Info("Skipping duplicate message", zap.String("message_id", msg.MessageId))
+		msg.Ack(false)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Message handler panicked",
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			msg.Nack(false, false)
+		}
+	}()
+
+	err := handler(msg.Body)
+	if err != nil {
+		logger.Error("Failed to handle message", zap.Error(err))
+		if checkDuplicate {
+			dedup.Release(msg.MessageId)
+		}
+		// Reject and requeue
+		msg.Nack(false, true)
+	} else {
+		// Acknowledge
+		msg.Ack(false)
+	}
+}
+
+// QueueDepth returns the number of messages waiting in the queue.
+// A failed passive declare closes its channel, so it gets a channel of its own.
+func (r *RabbitMQ) QueueDepth(queueName string) (int, error) {
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(
+		queueName, // name
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		nil,       // arguments
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+
+	return q.Messages, nil
+}
+
 // Close RabbitMQ connection
 func (r *RabbitMQ) Close() error {
 	if r.channel != nil {
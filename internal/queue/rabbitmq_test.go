@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"voxly/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// recordingAcknowledger records how deliveries are settled
+type recordingAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+// observeLogs captures log entries for the duration of the test
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = previous })
+	return logs
+}
+
+func TestHandleDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func([]byte) error
+		acked   bool
+		nacked  bool
+		requeue bool
+	}{
+		{"success", func([]byte) error { return nil }, true, false, false},
+		{"error is requeued", func([]byte) error { return errors.New("boom") }, false, true, true},
+		{"panic is rejected", func([]byte) error { panic("nil map") }, false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &recordingAcknowledger{}
+
+			assert.NotPanics(t, func() {
+				handleDelivery(amqp.Delivery{Acknowledger: ack, Body: []byte("{}")}, tt.handler, nil)
+			})
+			assert.Equal(t, tt.acked, ack.acked)
+			assert.Equal(t, tt.nacked, ack.nacked)
+			assert.Equal(t, tt.requeue, ack.requeue)
+		})
+	}
+}
+
+func TestHandleDeliveryLogsPanicWithStack(t *testing.T) {
+	logs := observeLogs(t)
+	ack := &recordingAcknowledger{}
+
+	handleDelivery(amqp.Delivery{Acknowledger: ack}, func([]byte) error { panic("nil map") }, nil)
+
+	entries := logs.FilterMessage("Message handler panicked").All()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "nil map", fields["panic"])
+		assert.Contains(t, fields["stack"], "handleDelivery")
+	}
+}
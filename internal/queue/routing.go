@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueueNameVoiceLong receives tasks the Routing considers long, so they can be
+// handled by workers of their own
+const QueueNameVoiceLong = "voice_processing_long"
+
+// taskQueues lists the queues a VoiceTask can be published to
+var taskQueues = []string{QueueNameVoiceProcessing, QueueNameVoiceLong}
+
+// Routing picks the queue for a VoiceTask. Tasks at least LongAfter long, or
+// with one of LongMimeTypes, go to QueueNameVoiceLong; the rest go to
+// QueueNameVoiceProcessing. The zero Routing sends everything to
+// QueueNameVoiceProcessing.
+type Routing struct {
+	LongAfter     time.Duration
+	LongMimeTypes []string
+}
+
+// RoutesLong reports whether any task can be sent to QueueNameVoiceLong
+func (r Routing) RoutesLong() bool {
+	return r.LongAfter > 0 || len(r.LongMimeTypes) > 0
+}
+
+// QueueFor returns the queue task is published to
+func (r Routing) QueueFor(task *VoiceTask) string {
+	if r.LongAfter > 0 && time.Duration(task.Duration)*time.Second >= r.LongAfter {
+		return QueueNameVoiceLong
+	}
+
+	for _, mime := range r.LongMimeTypes {
+		if strings.EqualFold(strings.TrimSpace(mime), task.MimeType) {
+			return QueueNameVoiceLong
+		}
+	}
+
+	return QueueNameVoiceProcessing
+}
+
+// ValidateTaskQueues checks that every name is a queue tasks are published to
+func ValidateTaskQueues(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no task queues given")
+	}
+
+	for _, name := range names {
+		if !isTaskQueue(name) {
+			return fmt.Errorf("unknown task queue %q, expected one of %s",
+				name, strings.Join(taskQueues, ", "))
+		}
+	}
+	return nil
+}
+
+func isTaskQueue(name string) bool {
+	for _, q := range taskQueues {
+		if q == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouting_QueueFor(t *testing.T) {
+	routing := Routing{LongAfter: 5 * time.Minute, LongMimeTypes: []string{"audio/mpeg", " audio/wav"}}
+
+	tests := []struct {
+		name    string
+		routing Routing
+		task    VoiceTask
+		want    string
+	}{
+		{"short voice", routing, VoiceTask{Duration: 30, MimeType: "audio/ogg"}, QueueNameVoiceProcessing},
+		{"long voice", routing, VoiceTask{Duration: 300, MimeType: "audio/ogg"}, QueueNameVoiceLong},
+		{"long mime type", routing, VoiceTask{Duration: 10, MimeType: "audio/mpeg"}, QueueNameVoiceLong},
+		{"mime type ignores case and spaces", routing, VoiceTask{Duration: 10, MimeType: "Audio/WAV"}, QueueNameVoiceLong},
+		{"zero routing", Routing{}, VoiceTask{Duration: 3600, MimeType: "audio/mpeg"}, QueueNameVoiceProcessing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.routing.QueueFor(&tt.task))
+		})
+	}
+}
+
+func TestRouting_RoutesLong(t *testing.T) {
+	assert.False(t, Routing{}.RoutesLong())
+	assert.True(t, Routing{LongAfter: time.Minute}.RoutesLong())
+	assert.True(t, Routing{LongMimeTypes: []string{"audio/wav"}}.RoutesLong())
+}
+
+func TestValidateTaskQueues(t *testing.T) {
+	assert.NoError(t, ValidateTaskQueues([]string{QueueNameVoiceProcessing}))
+	assert.NoError(t, ValidateTaskQueues([]string{QueueNameVoiceProcessing, QueueNameVoiceLong}))
+	assert.Error(t, ValidateTaskQueues(nil))
+	assert.Error(t, ValidateTaskQueues([]string{QueueNameResults}))
+	assert.Error(t, ValidateTaskQueues([]string{"voice"}))
+}
+
+// recordingDeclarer records the queues and bindings it is asked to set up
+type recordingDeclarer struct {
+	declared  []string
+	bindings  map[string]string
+	exchanges map[string]string
+	bindErr   error
+}
+
+func (d *recordingDeclarer) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	d.declared = append(d.declared, name)
+	return amqp.Queue{Name: name}, nil
+}
+
+func (d *recordingDeclarer) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	if d.bindErr != nil {
+		return d.bindErr
+	}
+	if d.bindings == nil {
+		d.bindings = map[string]string{}
+		d.exchanges = map[string]string{}
+	}
+	d.bindings[key] = name
+	d.exchanges[name] = exchange
+	return nil
+}
+
+func TestDeclareQueuesBindsEveryTaskQueue(t *testing.T) {
+	d := &recordingDeclarer{}
+
+	assert.NoError(t, declareQueues(d))
+
+	assert.ElementsMatch(t, []string{QueueNameVoiceProcessing, QueueNameVoiceLong}, d.declared)
+	// Every queue a task can be routed to must receive it
+	routing := Routing{LongAfter: time.Minute}
+	for _, task := range []VoiceTask{{Duration: 1}, {Duration: 60}} {
+		key := routing.QueueFor(&task)
+		assert.Equal(t, key, d.bindings[key])
+	}
+}
+
+func TestDeclareQueuesFailsOnBindError(t *testing.T) {
+	d := &recordingDeclarer{bindErr: errors.New("access refused")}
+
+	err := declareQueues(d)
+
+	assert.ErrorContains(t, err, "failed to bind queue "+QueueNameVoiceProcessing)
+	assert.Equal(t, []string{QueueNameVoiceProcessing}, d.declared)
+}
+
+func TestDeclareResultsQueueGivesEachConsumerItsOwnQueue(t *testing.T) {
+	d := &recordingDeclarer{}
+
+	bot, err := declareResultsQueue(d, "bot")
+	assert.NoError(t, err)
+	archive, err := declareResultsQueue(d, "archive")
+	assert.NoError(t, err)
+
+	// Both queues are bound to the fanout exchange, so each gets every result
+	assert.Equal(t, []string{"transcription_results.bot", "transcription_results.archive"}, d.declared)
+	assert.Equal(t, ResultsExchangeName, d.exchanges[bot])
+	assert.Equal(t, ResultsExchangeName, d.exchanges[archive])
+}
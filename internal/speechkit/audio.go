@@ -0,0 +1,128 @@
+package speechkit
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Audio encodings understood by SpeechKit
+const (
+	EncodingOggOpus     = "OGG_OPUS"
+	EncodingLinear16PCM = "LINEAR16_PCM"
+)
+
+// Audio parameters used when the recording can't be probed
+const (
+	DefaultSampleRate   = 48000
+	DefaultChannelCount = 1
+)
+
+// opusDecodeRate is the rate Opus always decodes at, whatever the input rate was
+const opusDecodeRate = 48000
+
+// NarrowbandBelow is the recording sample rate under which audio is treated as
+// phone quality: telephony is usually sampled at 8kHz
+const NarrowbandBelow = 16000
+
+// AudioFormat describes how the uploaded audio is encoded
+type AudioFormat struct {
+	Encoding     string
+	SampleRate   int
+	ChannelCount int
+	// InputSampleRate is the rate the audio was recorded at. Opus stores it
+	// only as a hint, SpeechKit gets SampleRate.
+	InputSampleRate int
+}
+
+// Narrowband reports whether the audio was recorded at a phone-quality sample rate
+func (f AudioFormat) Narrowband() bool {
+	rate := f.InputSampleRate
+	if rate <= 0 {
+		rate = f.SampleRate
+	}
+	return rate > 0 && rate < NarrowbandBelow
+}
+
+// withDefaults fills unset fields with the Telegram voice message format
+func (f AudioFormat) withDefaults() AudioFormat {
+	if f.Encoding == "" {
+		f.Encoding = EncodingOggOpus
+	}
+	if f.SampleRate <= 0 {
+		f.SampleRate = DefaultSampleRate
+	}
+	if f.ChannelCount <= 0 {
+		f.ChannelCount = DefaultChannelCount
+	}
+	return f
+}
+
+// ProbeAudioFormat reads the audio parameters from the file header.
+// It recognises OGG Opus and PCM WAV; ok is false for anything else.
+func ProbeAudioFormat(data []byte) (format AudioFormat, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return probeOggOpus(data)
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE")):
+		return probeWAV(data)
+	default:
+		return AudioFormat{}, false
+	}
+}
+
+// probeOggOpus reads the channel count and input sample rate from the OpusHead
+// packet in the first Ogg page
+func probeOggOpus(data []byte) (AudioFormat, bool) {
+	const pageHeaderSize = 27
+	if len(data) < pageHeaderSize {
+		return AudioFormat{}, false
+	}
+	segments := int(data[26])
+	packet := pageHeaderSize + segments
+	// OpusHead: magic(8) version(1) channels(1) pre-skip(2) input rate(4) ...
+	if len(data) < packet+16 || !bytes.Equal(data[packet:packet+8], []byte("OpusHead")) {
+		return AudioFormat{}, false
+	}
+	channels := int(data[packet+9])
+	if channels == 0 {
+		return AudioFormat{}, false
+	}
+	return AudioFormat{
+		Encoding:        EncodingOggOpus,
+		SampleRate:      opusDecodeRate,
+		ChannelCount:    channels,
+		InputSampleRate: int(binary.LittleEndian.Uint32(data[packet+12:])),
+	}, true
+}
+
+// probeWAV reads the fmt chunk of a RIFF WAVE file holding 16-bit PCM
+func probeWAV(data []byte) (AudioFormat, bool) {
+	const (
+		formatPCM     = 1
+		bitsPerSample = 16
+	)
+	for offset := 12; offset+8 <= len(data); {
+		id := data[offset : offset+4]
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		if bytes.Equal(id, []byte("fmt ")) {
+			if size < 16 || body+16 > len(data) {
+				return AudioFormat{}, false
+			}
+			if binary.LittleEndian.Uint16(data[body:]) != formatPCM ||
+				binary.LittleEndian.Uint16(data[body+14:]) != bitsPerSample {
+				return AudioFormat{}, false
+			}
+			rate := int(binary.LittleEndian.Uint32(data[body+4:]))
+			return AudioFormat{
+				Encoding:        EncodingLinear16PCM,
+				SampleRate:      rate,
+				ChannelCount:    int(binary.LittleEndian.Uint16(data[body+2:])),
+				InputSampleRate: rate,
+			}, true
+		}
+		// Chunks are padded to an even size
+		offset = body + size + size%2
+	}
+	return AudioFormat{}, false
+}
@@ -0,0 +1,136 @@
+package speechkit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oggOpusHeader builds the first Ogg page of an Opus stream
+func oggOpusHeader(channels byte, inputRate uint32) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, channels, 0x38, 0x01)
+	head = binary.LittleEndian.AppendUint32(head, inputRate)
+	head = append(head, 0, 0, 0)
+
+	page := []byte("OggS")
+	page = append(page, make([]byte, 22)...) // version, flags, granule, serial, sequence, checksum
+	page = append(page, 1, byte(len(head)))  // one segment
+	return append(page, head...)
+}
+
+// wavHeader builds a RIFF WAVE header with an extra chunk before fmt
+func wavHeader(format, channels uint16, rate uint32, bits uint16) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WAVE")
+	data = append(data, "LIST\x03\x00\x00\x00abc\x00"...) // odd-sized chunk with padding
+	data = append(data, "fmt "...)
+	data = binary.LittleEndian.AppendUint32(data, 16)
+	data = binary.LittleEndian.AppendUint16(data, format)
+	data = binary.LittleEndian.AppendUint16(data, channels)
+	data = binary.LittleEndian.AppendUint32(data, rate)
+	data = binary.LittleEndian.AppendUint32(data, rate*uint32(channels)*uint32(bits/8))
+	data = binary.LittleEndian.AppendUint16(data, channels*bits/8)
+	data = binary.LittleEndian.AppendUint16(data, bits)
+	return data
+}
+
+func TestProbeAudioFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		format AudioFormat
+		ok     bool
+	}{
+		{"mono opus", oggOpusHeader(1, 48000), AudioFormat{EncodingOggOpus, 48000, 1, 48000}, true},
+		{"stereo opus recorded at 44.1kHz", oggOpusHeader(2, 44100), AudioFormat{EncodingOggOpus, 48000, 2, 44100}, true},
+		{"16kHz wav", wavHeader(1, 1, 16000, 16), AudioFormat{EncodingLinear16PCM, 16000, 1, 16000}, true},
+		{"8kHz stereo wav", wavHeader(1, 2, 8000, 16), AudioFormat{EncodingLinear16PCM, 8000, 2, 8000}, true},
+		{"float wav", wavHeader(3, 1, 16000, 32), AudioFormat{}, false},
+		{"ogg without opus", append([]byte("OggS"), make([]byte, 40)...), AudioFormat{}, false},
+		{"truncated ogg", []byte("OggS\x00"), AudioFormat{}, false},
+		{"unknown", []byte("ID3\x04 mp3 data"), AudioFormat{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, ok := ProbeAudioFormat(tt.data)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.format, format)
+		})
+	}
+}
+
+func TestAudioFormat_Narrowband(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		narrowband bool
+	}{
+		{"voice message", oggOpusHeader(1, 48000), false},
+		{"opus phone recording", oggOpusHeader(1, 8000), true},
+		{"opus without input rate", oggOpusHeader(1, 0), false},
+		{"16kHz wav", wavHeader(1, 1, 16000, 16), false},
+		{"8kHz wav", wavHeader(1, 1, 8000, 16), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, ok := ProbeAudioFormat(tt.data)
+			assert.True(t, ok)
+			assert.Equal(t, tt.narrowband, format.Narrowband())
+		})
+	}
+
+	// Unprobed audio is treated as a voice message
+	assert.False(t, AudioFormat{}.Narrowband())
+}
+
+// specTransport captures the recognition specification of a start request
+type specTransport struct {
+	spec Specification
+}
+
+func (t *specTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body RecognitionRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	t.spec = body.Config.Specification
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"id":"op-1"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestClient_StartRecognitionAudioFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		audio    AudioFormat
+		encoding string
+		rate     int
+		channels int
+	}{
+		{"defaults", AudioFormat{}, EncodingOggOpus, 48000, 1},
+		{"probed wav", AudioFormat{EncodingLinear16PCM, 16000, 2, 16000}, EncodingLinear16PCM, 16000, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &specTransport{}
+			c := NewClient("test-key", "folder", &http.Client{Transport: transport}, Timeouts{}, Endpoints{})
+
+			_, err := c.StartRecognition("s3://bucket/voice", RecognitionOptions{Audio: tt.audio})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.encoding, transport.spec.AudioEncoding)
+			assert.Equal(t, tt.rate, transport.spec.SampleRateHertz)
+			assert.Equal(t, tt.channels, transport.spec.AudioChannelCount)
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 	"voxly/pkg/logger"
 	"voxly/pkg/resilience"
@@ -19,6 +21,19 @@ const (
 	OperationURL  = "https://operation.api.cloud.yandex.net/operations"
 	OperationPoll = 5 * time.Second
 	MaxWaitTime   = 30 * time.Minute
+
+	// Per-request timeouts used when Timeouts leaves them unset
+	DefaultStartTimeout = 30 * time.Second
+	DefaultPollTimeout  = 10 * time.Second
+
+	// MaxResponseSize bounds a single API response. Results of multi-hour
+	// recordings stay well below it.
+	MaxResponseSize = 32 << 20
+
+	// DefaultLanguage is the language code requested from SpeechKit
+	DefaultLanguage = "ru-RU"
+	// LanguageAuto asks SpeechKit to detect the language
+	LanguageAuto = "auto"
 )
 
 type Client struct {
@@ -27,24 +42,117 @@ type Client struct {
 	client         *http.Client
 	circuitBreaker *resilience.CircuitBreaker
 	rateLimiter    *resilience.RateLimiter
+
+	recognizeURL    string
+	operationURL    string
+	maxResponseSize int64
+	startTimeout    time.Duration
+	pollTimeout     time.Duration
+	pollInterval    time.Duration
+	minPoll         time.Duration
+	maxPoll         time.Duration
+
+	// closed is cancelled by Close and stops requests and polls in flight
+	closed context.Context
+	close  context.CancelFunc
+}
+
+// Timeouts bounds individual SpeechKit requests
+type Timeouts struct {
+	Start time.Duration // recognition start call
+	Poll  time.Duration // each operation status check
+}
+
+// Endpoints overrides the SpeechKit API URLs, e.g. for a regional endpoint or
+// a mock server; empty fields keep RecognizeURL and OperationURL
+type Endpoints struct {
+	Recognize string // longRunningRecognize
+	Operation string // operations, without a trailing slash
 }
 
-// New Yandex SpeechKit client
-func NewClient(apiKey, folderID string) *Client {
+// New Yandex SpeechKit client. httpClient is shared with other integrations
+// (nil uses http.DefaultClient); requests are bounded by timeouts instead
+// of a client-wide timeout.
+func NewClient(apiKey, folderID string, httpClient *http.Client, timeouts Timeouts, endpoints Endpoints) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if timeouts.Start <= 0 {
+		timeouts.Start = DefaultStartTimeout
+	}
+	if timeouts.Poll <= 0 {
+		timeouts.Poll = DefaultPollTimeout
+	}
+
+	if endpoints.Recognize == "" {
+		endpoints.Recognize = RecognizeURL
+	}
+	if endpoints.Operation == "" {
+		endpoints.Operation = OperationURL
+	}
+
+	closed, closeFn := context.WithCancel(context.Background())
+
 	return &Client{
-		apiKey:   apiKey,
-		folderID: folderID,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		circuitBreaker: resilience.NewCircuitBreaker(5, 1*time.Minute),
-		rateLimiter:    resilience.NewRateLimiter(10, 1*time.Second),
+		apiKey:          apiKey,
+		folderID:        folderID,
+		client:          httpClient,
+		circuitBreaker:  resilience.NewCircuitBreaker(5, 1*time.Minute),
+		rateLimiter:     resilience.NewRateLimiter(10, 1*time.Second),
+		recognizeURL:    endpoints.Recognize,
+		operationURL:    strings.TrimSuffix(endpoints.Operation, "/"),
+		maxResponseSize: MaxResponseSize,
+		startTimeout:    timeouts.Start,
+		pollTimeout:     timeouts.Poll,
+		pollInterval:    OperationPoll,
+		minPoll:         MinOperationPoll,
+		maxPoll:         MaxOperationPoll,
+		closed:          closed,
+		close:           closeFn,
+	}
+}
+
+// Close stops requests and polls in flight and drops idle connections of the
+// HTTP client, which may be shared, so call it at shutdown. The client must not
+// be used afterwards.
+func (c *Client) Close() error {
+	c.close()
+	c.client.CloseIdleConnections()
+	logger.Debug("SpeechKit client closed")
+	return nil
+}
+
+// CircuitBreaker returns the breaker guarding recognition starts
+func (c *Client) CircuitBreaker() *resilience.CircuitBreaker {
+	return c.circuitBreaker
+}
+
+// untilClosed returns ctx cancelled also when the client is closed
+func (c *Client) untilClosed(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.closed, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
 	}
 }
 
 // Async voice recognition
-func (c *Client) StartRecognition(s3URI string) (string, error) {
-	ctx := context.Background()
+func (c *Client) StartRecognition(s3URI string, opts RecognitionOptions) (string, error) {
+	if c.closed.Err() != nil {
+		return "", ErrClientClosed
+	}
+	ctx := c.closed
+
+	model := opts.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	audio := opts.Audio.withDefaults()
+	language := opts.Language
+	if language == "" {
+		language = DefaultLanguage
+	}
 
 	if err := c.rateLimiter.Wait(ctx); err != nil {
 		return "", fmt.Errorf("rate limit exceeded: %w", err)
@@ -55,12 +163,12 @@ func (c *Client) StartRecognition(s3URI string) (string, error) {
 		reqBody := RecognitionRequest{
 			Config: RecognitionConfig{
 				Specification: Specification{
-					LanguageCode:      "ru-RU",
-					Model:             "general:rc",
-					AudioEncoding:     "OGG_OPUS",
-					SampleRateHertz:   48000,
-					AudioChannelCount: 1,
-					ProfanityFilter:   false,
+					LanguageCode:      language,
+					Model:             string(model),
+					AudioEncoding:     audio.Encoding,
+					SampleRateHertz:   audio.SampleRate,
+					AudioChannelCount: audio.ChannelCount,
+					ProfanityFilter:   opts.ProfanityFilter,
 					LiteratureText:    true,
 					RawResults:        false,
 				},
@@ -75,7 +183,10 @@ func (c *Client) StartRecognition(s3URI string) (string, error) {
 			return fmt.Errorf("failed to marshal request: %w", err)
 		}
 
-		req, err := http.NewRequest("POST", RecognizeURL, bytes.NewReader(body))
+		reqCtx, cancel := context.WithTimeout(ctx, c.startTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, "POST", c.recognizeURL, bytes.NewReader(body))
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
@@ -84,7 +195,9 @@ func (c *Client) StartRecognition(s3URI string) (string, error) {
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("x-folder-id", c.folderID)
 
-		logger.Debug("Starting speech recognition", zap.String("s3_uri", s3URI))
+		logger.Debug("Starting speech recognition",
+			zap.String("s3_uri", s3URI),
+			zap.String("model", string(model)))
 
 		resp, err := c.client.Do(req)
 		if err != nil {
@@ -92,7 +205,7 @@ func (c *Client) StartRecognition(s3URI string) (string, error) {
 		}
 		defer resp.Body.Close()
 
-		respBody, err := io.ReadAll(resp.Body)
+		respBody, err := readLimited(resp.Body, c.maxResponseSize)
 		if err != nil {
 			return fmt.Errorf("failed to read response: %w", err)
 		}
@@ -119,83 +232,339 @@ func (c *Client) StartRecognition(s3URI string) (string, error) {
 	return operationID, nil
 }
 
-// Polling operation status and returns result
-func (c *Client) WaitForResult(operationID string) (*RecognitionResult, error) {
-	url := fmt.Sprintf("%s/%s", OperationURL, operationID)
+// Polling operation status and returns result.
+// When the operation reports progress, the poll interval adapts to the estimated
+// time left and onProgress (if not nil) receives every update.
+// Polling stops early when ctx is cancelled or the client is closed. If the wait ends before the
+// operation is done but it already reported some chunks, the error is a
+// *PartialResultError carrying them.
+func (c *Client) WaitForResult(ctx context.Context, operationID string, onProgress ProgressFunc) (*RecognitionResult, error) {
+	ctx, cancel := c.untilClosed(ctx)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/%s", c.operationURL, operationID)
 	startTime := time.Now()
 
-	for {
-		if time.Since(startTime) > MaxWaitTime {
-			return nil, fmt.Errorf("recognition timeout exceeded")
+	// partial holds the chunks a running operation reported so far
+	var partial *RecognitionResult
+	// metadata is what the operation reported on the latest poll
+	var metadata OperationMetadata
+	// Only running out of time keeps the recognized chunks; a cancelled wait,
+	// e.g. on shutdown, returns the error so the task is retried in full
+	stopped := func(err error) error {
+		timedOut := errors.Is(err, ErrRecognitionTimeout) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if partial == nil || !timedOut {
+			return err
 		}
+		return &PartialResultError{Result: partial, Err: err}
+	}
 
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
-
-		resp, err := c.client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to send request: %w", err)
+	for {
+		if time.Since(startTime) > MaxWaitTime {
+			return nil, stopped(ErrRecognitionTimeout)
 		}
 
-		respBody, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		opResp, err := c.fetchOperation(ctx, url)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("operation check failed: status=%d, body=%s", resp.StatusCode, string(respBody))
-		}
-
-		var opResp OperationResponse
-		if err := json.Unmarshal(respBody, &opResp); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+			if ctx.Err() != nil {
+				return nil, stopped(err)
+			}
+			return nil, err
 		}
 
 		if opResp.Done {
 			if opResp.Error != nil {
-				return nil, fmt.Errorf("recognition failed: %s (code: %d)", opResp.Error.Message, opResp.Error.Code)
+				return nil, opResp.Error
 			}
 
-			// Parse response
-			var result RecognitionResult
-			if opResp.Response != nil {
-				responseBytes, err := json.Marshal(opResp.Response)
-				if err != nil {
-					return nil, fmt.Errorf("failed to marshal response: %w", err)
-				}
-
-				if err := json.Unmarshal(responseBytes, &result); err != nil {
-					return nil, fmt.Errorf("failed to unmarshal result: %w", err)
-				}
+			result, err := decodeResult(opResp.Response)
+			if err != nil {
+				return nil, err
 			}
+			result.Operation = newOperationInfo(opResp, metadata)
 
 			logger.Info("Recognition completed",
 				zap.String("operation_id", operationID),
 				zap.Int("chunks", len(result.Chunks)))
 
-			return &result, nil
+			return result, nil
 		}
 
+		if result, err := decodeResult(opResp.Response); err == nil && len(result.Chunks) > 0 {
+			partial = result
+		}
+
+		elapsed := time.Since(startTime)
+		metadata = ParseOperationMetadata(opResp.Metadata)
+		percent, hasProgress := metadata.ProgressPercent, metadata.HasProgress
+		if hasProgress && onProgress != nil {
+			onProgress(Progress{Percent: percent, Elapsed: elapsed})
+		}
+
+		interval := nextPollInterval(percent, hasProgress, elapsed, c.pollInterval, c.minPoll, c.maxPoll)
+
 		logger.Debug("Recognition in progress",
 			zap.String("operation_id", operationID),
-			zap.Duration("elapsed", time.Since(startTime)))
+			zap.Duration("elapsed", elapsed),
+			zap.Int("progress_percent", percent),
+			zap.Duration("next_poll", interval))
+
+		select {
+		case <-ctx.Done():
+			return nil, stopped(ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
 
-		time.Sleep(OperationPoll)
+// decodeResult parses the response of an operation; no response means no chunks
+func decodeResult(response interface{}) (*RecognitionResult, error) {
+	var result RecognitionResult
+	if response == nil {
+		return &result, nil
 	}
+
+	responseBytes, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	if err := json.Unmarshal(responseBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// CancelOperation cancels a recognition operation that is no longer needed,
+// e.g. one superseded by a retry
+func (c *Client) CancelOperation(ctx context.Context, operationID string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.pollTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/%s:cancel", c.operationURL, operationID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readLimited(resp.Body, c.maxResponseSize)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("operation cancel failed: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	logger.Info("Recognition operation cancelled", zap.String("operation_id", operationID))
+	return nil
+}
+
+// credentialsProbeOperation never exists; looking it up is free and gets past
+// authentication only with a valid API key
+const credentialsProbeOperation = "voxly-credentials-check"
+
+// CheckCredentials verifies that SpeechKit accepts the API key with a free
+// operation lookup. A rejected key is reported as ErrUnauthorized.
+func (c *Client) CheckCredentials(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.pollTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/%s", c.operationURL, credentialsProbeOperation)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: status=%d", ErrUnauthorized, resp.StatusCode)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("credentials check failed: status=%d", resp.StatusCode)
+	}
+
+	// Not found is the expected answer for the made-up operation
+	return nil
+}
+
+// fetchOperation checks the operation status once, bounded by the poll timeout
+func (c *Client) fetchOperation(ctx context.Context, url string) (*OperationResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.pollTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readLimited(resp.Body, c.maxResponseSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("operation check failed: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	var opResp OperationResponse
+	if err := json.Unmarshal(respBody, &opResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return &opResp, nil
+}
+
+// readLimited reads r to the end, failing with ErrResponseTooLarge instead
+// of buffering more than limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, limit)
+	}
+	return data, nil
 }
 
 // Extracting complete text from recognition result
 func (r *RecognitionResult) GetFullText() string {
-	var text string
+	var parts []string
 	for _, chunk := range r.Chunks {
 		for _, alt := range chunk.Alternatives {
-			text += alt.Text + " "
+			if text := normalizeSpaces(alt.Text); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// BestText joins the highest-confidence alternative of every chunk
+func (r *RecognitionResult) BestText() string {
+	var parts []string
+	for _, chunk := range r.Chunks {
+		best, ok := chunk.BestAlternative()
+		if !ok {
+			continue
+		}
+		if text := normalizeSpaces(best.Text); text != "" {
+			parts = append(parts, text)
 		}
 	}
-	return text
+	return strings.Join(parts, " ")
+}
+
+// GetTextWithTimestamps puts the highest-confidence alternative of every chunk
+// on its own line, prefixed with the chunk's start time in the recording
+func (r *RecognitionResult) GetTextWithTimestamps() string {
+	var lines []string
+	for _, chunk := range r.Chunks {
+		best, ok := chunk.BestAlternative()
+		if !ok {
+			continue
+		}
+		text := normalizeSpaces(best.Text)
+		if text == "" {
+			continue
+		}
+		start := chunk.StartTimeMs
+		if start == 0 && len(best.Words) > 0 {
+			start = best.Words[0].StartTimeMs
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s", formatTimestamp(start), text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTimestamp formats an offset in the recording as m:ss, or h:mm:ss past an hour
+func formatTimestamp(ms int64) string {
+	seconds := ms / 1000
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// DetectedLanguage returns the language reported for most chunks, or an empty
+// string when SpeechKit didn't detect one
+func (r *RecognitionResult) DetectedLanguage() string {
+	counts := make(map[string]int)
+	var detected string
+	for _, chunk := range r.Chunks {
+		best, ok := chunk.BestAlternative()
+		if !ok || best.LanguageCode == "" {
+			continue
+		}
+		counts[best.LanguageCode]++
+		if counts[best.LanguageCode] > counts[detected] {
+			detected = best.LanguageCode
+		}
+	}
+	return detected
+}
+
+// AverageConfidence averages the confidence of the best alternative of every chunk.
+// Chunks without a confidence score are skipped; ok is false when none had one.
+func (r *RecognitionResult) AverageConfidence() (float64, bool) {
+	var sum float64
+	var count int
+	for _, chunk := range r.Chunks {
+		best, ok := chunk.BestAlternative()
+		if !ok || best.Confidence <= 0 {
+			continue
+		}
+		sum += best.Confidence
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// BestAlternative returns the alternative with the highest confidence.
+// On equal confidence the earlier alternative wins, as Yandex orders them best-first.
+func (c Chunk) BestAlternative() (Alternative, bool) {
+	if len(c.Alternatives) == 0 {
+		return Alternative{}, false
+	}
+
+	best := c.Alternatives[0]
+	for _, alt := range c.Alternatives[1:] {
+		if alt.Confidence > best.Confidence {
+			best = alt
+		}
+	}
+	return best, true
+}
+
+// Collapses runs of whitespace into single spaces and trims the ends
+func normalizeSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
 }
@@ -0,0 +1,139 @@
+package speechkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecognitionResult_GetFullText(t *testing.T) {
+	result := &RecognitionResult{
+		Chunks: []Chunk{
+			{Alternatives: []Alternative{{Text: "Привет, мир."}}},
+			{Alternatives: []Alternative{{Text: "  Как  дела?  "}}},
+			{Alternatives: []Alternative{{Text: ""}}},
+			{Alternatives: []Alternative{{Text: "Всё\tхорошо!\n"}}},
+		},
+	}
+
+	assert.Equal(t, "Привет, мир. Как дела? Всё хорошо!", result.GetFullText())
+}
+
+func TestRecognitionResult_GetFullTextEmpty(t *testing.T) {
+	result := &RecognitionResult{}
+	assert.Equal(t, "", result.GetFullText())
+}
+
+func TestRecognitionResult_BestText(t *testing.T) {
+	result := &RecognitionResult{
+		Chunks: []Chunk{
+			{Alternatives: []Alternative{
+				{Text: "Привет мир", Confidence: 0.6},
+				{Text: "Привет, мир", Confidence: 0.9},
+			}},
+			{Alternatives: []Alternative{
+				{Text: "как дела", Confidence: 0.8},
+				{Text: "как тела", Confidence: 0.3},
+			}},
+			{Alternatives: nil},
+		},
+	}
+
+	assert.Equal(t, "Привет, мир как дела", result.BestText())
+	assert.Equal(t, "Привет мир Привет, мир как дела как тела", result.GetFullText())
+}
+
+func TestRecognitionResult_GetTextWithTimestamps(t *testing.T) {
+	result := &RecognitionResult{
+		Chunks: []Chunk{
+			{Alternatives: []Alternative{{Text: "Привет,  мир", Confidence: 0.9}}},
+			// Without the chunk's start time the first word's is used
+			{Alternatives: []Alternative{{Text: "как дела", Words: []Word{{Word: "как", StartTimeMs: 75500}}}}},
+			{StartTimeMs: 90000, Alternatives: []Alternative{{Text: "  "}}},
+			{StartTimeMs: 3725000, Alternatives: []Alternative{{Text: "пока"}}},
+		},
+	}
+
+	assert.Equal(t, "[0:00] Привет, мир\n[1:15] как дела\n[1:02:05] пока", result.GetTextWithTimestamps())
+	assert.Empty(t, (&RecognitionResult{}).GetTextWithTimestamps())
+}
+
+func TestChunk_BestAlternativeWithoutConfidence(t *testing.T) {
+	chunk := Chunk{Alternatives: []Alternative{{Text: "first"}, {Text: "second"}}}
+
+	best, ok := chunk.BestAlternative()
+	assert.True(t, ok)
+	assert.Equal(t, "first", best.Text)
+
+	_, ok = Chunk{}.BestAlternative()
+	assert.False(t, ok)
+}
+
+func TestRecognitionResult_AverageConfidence(t *testing.T) {
+	result := &RecognitionResult{Chunks: []Chunk{
+		{Alternatives: []Alternative{{Text: "a", Confidence: 0.6}, {Text: "b", Confidence: 0.8}}},
+		{Alternatives: []Alternative{{Text: "c", Confidence: 1.0}}},
+		{Alternatives: []Alternative{{Text: "d"}}},
+	}}
+
+	avg, ok := result.AverageConfidence()
+	assert.True(t, ok)
+	assert.InDelta(t, 0.9, avg, 1e-9)
+
+	_, ok = (&RecognitionResult{Chunks: []Chunk{{Alternatives: []Alternative{{Text: "x"}}}}}).AverageConfidence()
+	assert.False(t, ok)
+}
+
+func TestOperationError_Unwrap(t *testing.T) {
+	var err error = &OperationError{Code: 3, Message: "audio is corrupted"}
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.Equal(t, "recognition failed: audio is corrupted (code: 3)", err.Error())
+
+	err = &OperationError{Code: 13, Message: "internal"}
+	assert.NotErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestRecognitionResult_DetectedLanguage(t *testing.T) {
+	result := &RecognitionResult{Chunks: []Chunk{
+		{Alternatives: []Alternative{{Text: "Привет", LanguageCode: "ru-RU"}}},
+		{Alternatives: []Alternative{{Text: "hello", Confidence: 0.4, LanguageCode: "ru-RU"}, {Text: "hello", Confidence: 0.9, LanguageCode: "en-US"}}},
+		{Alternatives: []Alternative{{Text: "world", LanguageCode: "en-US"}}},
+		{Alternatives: []Alternative{{Text: "!"}}},
+	}}
+	assert.Equal(t, "en-US", result.DetectedLanguage())
+
+	// Without auto-detection SpeechKit reports no language
+	assert.Empty(t, (&RecognitionResult{Chunks: []Chunk{{Alternatives: []Alternative{{Text: "Привет"}}}}}).DetectedLanguage())
+}
+
+func TestClient_StartRecognitionCustomEndpoint(t *testing.T) {
+	var request RecognitionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/stt/v2/longRunningRecognize", r.URL.Path)
+		assert.Equal(t, "Api-Key test-key", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"id":"op-1"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{
+		Recognize: server.URL + "/stt/v2/longRunningRecognize",
+		Operation: server.URL + "/operations/",
+	})
+
+	operationID, err := c.StartRecognition("s3://bucket/voice", RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "op-1", operationID)
+	assert.Equal(t, "s3://bucket/voice", request.Audio.URI)
+	assert.Equal(t, server.URL+"/operations", c.operationURL)
+}
+
+func TestNewClient_DefaultEndpoints(t *testing.T) {
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	assert.Equal(t, RecognizeURL, c.recognizeURL)
+	assert.Equal(t, OperationURL, c.operationURL)
+}
@@ -0,0 +1,47 @@
+package speechkit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors that let callers tell recognition failure kinds apart
+var (
+	ErrRecognitionTimeout = errors.New("recognition timeout exceeded")
+	ErrUnsupportedFormat  = errors.New("unsupported audio format")
+	ErrResponseTooLarge   = errors.New("speechkit response too large")
+	ErrUnauthorized       = errors.New("speechkit rejected the credentials")
+	ErrClientClosed       = errors.New("speechkit client is closed")
+)
+
+// codeInvalidArgument is the gRPC status Yandex reports for audio it cannot decode
+const codeInvalidArgument = 3
+
+// Error implements the error interface for failed operations
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("recognition failed: %s (code: %d)", e.Message, e.Code)
+}
+
+// Unwrap maps operation error codes to the client's sentinel errors
+func (e *OperationError) Unwrap() error {
+	if e.Code == codeInvalidArgument {
+		return ErrUnsupportedFormat
+	}
+	return nil
+}
+
+// PartialResultError is returned when waiting for an operation times out
+// after the operation already reported some recognized chunks
+type PartialResultError struct {
+	Result *RecognitionResult
+	Err    error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("recognition incomplete with %d chunks recognized: %v", len(e.Result.Chunks), e.Err)
+}
+
+// Unwrap keeps the reason the wait stopped visible to errors.Is
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
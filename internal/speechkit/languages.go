@@ -0,0 +1,26 @@
+package speechkit
+
+// supportedLanguages are the language codes SpeechKit v2 recognizes
+var supportedLanguages = map[string]bool{
+	"de-DE": true,
+	"en-US": true,
+	"es-ES": true,
+	"fi-FI": true,
+	"fr-FR": true,
+	"he-IL": true,
+	"it-IT": true,
+	"kk-KZ": true,
+	"nl-NL": true,
+	"pl-PL": true,
+	"pt-BR": true,
+	"pt-PT": true,
+	"ru-RU": true,
+	"sv-SE": true,
+	"tr-TR": true,
+	"uz-UZ": true,
+}
+
+// SupportedLanguage reports whether SpeechKit can be asked to recognize the language code
+func SupportedLanguage(code string) bool {
+	return supportedLanguages[code]
+}
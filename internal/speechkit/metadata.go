@@ -0,0 +1,79 @@
+package speechkit
+
+import (
+	"strings"
+	"time"
+)
+
+// OperationMetadata holds the known fields of an operation's metadata. Fields
+// the operation doesn't report are left zero.
+type OperationMetadata struct {
+	Type            string // "@type" of the metadata message
+	ProgressPercent int
+	HasProgress     bool
+	// AudioDuration is the audio length as measured by SpeechKit
+	AudioDuration time.Duration
+}
+
+// ParseOperationMetadata reads the known fields of operation metadata and
+// ignores the rest
+func ParseOperationMetadata(metadata map[string]interface{}) OperationMetadata {
+	var parsed OperationMetadata
+	parsed.Type, _ = metadata["@type"].(string)
+	parsed.ProgressPercent, parsed.HasProgress = progressPercent(metadata)
+	parsed.AudioDuration = audioDuration(metadata["audioDuration"])
+	return parsed
+}
+
+// audioDuration reads a duration in the protobuf JSON form ("12.340s") or as seconds
+func audioDuration(value interface{}) time.Duration {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasSuffix(v, "s") {
+			return 0
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return 0
+		}
+		return d
+	case float64:
+		if v < 0 {
+			return 0
+		}
+		return time.Duration(v * float64(time.Second))
+	default:
+		return 0
+	}
+}
+
+// OperationInfo describes the operation a recognition result came from
+type OperationInfo struct {
+	ID         string
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	Metadata   OperationMetadata
+}
+
+// newOperationInfo collects what a finished operation reported about itself.
+// last is the metadata of an earlier poll, used for fields the final response
+// no longer reports.
+func newOperationInfo(op *OperationResponse, last OperationMetadata) *OperationInfo {
+	info := &OperationInfo{
+		ID:       op.ID,
+		Metadata: ParseOperationMetadata(op.Metadata),
+	}
+	info.CreatedAt, _ = time.Parse(time.RFC3339Nano, op.CreatedAt)
+	info.ModifiedAt, _ = time.Parse(time.RFC3339Nano, op.ModifiedAt)
+
+	if info.Metadata.Type == "" {
+		info.Metadata.Type = last.Type
+	}
+	if !info.Metadata.HasProgress {
+		info.Metadata.ProgressPercent, info.Metadata.HasProgress = last.ProgressPercent, last.HasProgress
+	}
+	if info.Metadata.AudioDuration == 0 {
+		info.Metadata.AudioDuration = last.AudioDuration
+	}
+	return info
+}
@@ -0,0 +1,83 @@
+package speechkit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOperationMetadata(t *testing.T) {
+	payload := `{
+		"id": "e03sup6d5h1qr574ht99",
+		"description": "Recognize speech",
+		"createdAt": "2024-03-01T10:00:00Z",
+		"createdBy": "ajes08feato88ehbbhqq",
+		"modifiedAt": "2024-03-01T10:00:07.500Z",
+		"done": false,
+		"metadata": {
+			"@type": "type.googleapis.com/yandex.cloud.ai.stt.v2.LongRunningRecognitionMetadata",
+			"progressPercent": 42,
+			"audioDuration": "12.340s",
+			"unknownField": true
+		}
+	}`
+
+	var op OperationResponse
+	assert.NoError(t, json.Unmarshal([]byte(payload), &op))
+
+	metadata := ParseOperationMetadata(op.Metadata)
+	assert.Equal(t, OperationMetadata{
+		Type:            "type.googleapis.com/yandex.cloud.ai.stt.v2.LongRunningRecognitionMetadata",
+		ProgressPercent: 42,
+		HasProgress:     true,
+		AudioDuration:   12340 * time.Millisecond,
+	}, metadata)
+
+	info := newOperationInfo(&op, OperationMetadata{})
+	assert.Equal(t, "e03sup6d5h1qr574ht99", info.ID)
+	assert.Equal(t, 7500*time.Millisecond, info.ModifiedAt.Sub(info.CreatedAt))
+}
+
+func TestParseOperationMetadataUnknownShapes(t *testing.T) {
+	assert.Equal(t, OperationMetadata{}, ParseOperationMetadata(nil))
+
+	// Durations are also accepted as seconds; malformed ones are ignored
+	assert.Equal(t, 1500*time.Millisecond, ParseOperationMetadata(map[string]interface{}{"audioDuration": 1.5}).AudioDuration)
+	assert.Zero(t, ParseOperationMetadata(map[string]interface{}{"audioDuration": "12"}).AudioDuration)
+	assert.Zero(t, ParseOperationMetadata(map[string]interface{}{"audioDuration": "-1s"}).AudioDuration)
+}
+
+func TestClient_WaitForResultKeepsOperationInfo(t *testing.T) {
+	stages := []string{
+		`{"id":"op-1","done":false,"createdAt":"2024-03-01T10:00:00Z","metadata":{"progressPercent":60,"audioDuration":"3s"}}`,
+		`{"id":"op-1","done":true,"createdAt":"2024-03-01T10:00:00Z","modifiedAt":"2024-03-01T10:00:02Z","response":{"chunks":[{"alternatives":[{"text":"Привет"}]}]}}`,
+	}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Write([]byte(stages[n-1]))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+	c.maxPoll = time.Millisecond
+
+	result, err := c.WaitForResult(context.Background(), "op-1", nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, result.Operation) {
+		// The final response no longer reports metadata, so the last poll's is kept
+		assert.Equal(t, 60, result.Operation.Metadata.ProgressPercent)
+		assert.Equal(t, 3*time.Second, result.Operation.Metadata.AudioDuration)
+		assert.Equal(t, 2*time.Second, result.Operation.ModifiedAt.Sub(result.Operation.CreatedAt))
+	}
+}
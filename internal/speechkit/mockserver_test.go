@@ -0,0 +1,152 @@
+package speechkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	mockRecognizePath = "/speech/stt/v2/longRunningRecognize"
+	mockOperationPath = "/operations/"
+)
+
+// mockSpeechKit emulates the longRunningRecognize and operations API: each
+// recognition gets an operation ID, which stays not done for pendingPolls
+// polls and then is done with response
+type mockSpeechKit struct {
+	server       *httptest.Server
+	pendingPolls int
+	response     string
+
+	mu         sync.Mutex
+	requests   []RecognitionRequest
+	operations map[string]int // polls per operation ID
+}
+
+// newMockSpeechKit starts a mock API answering every recognition with
+// response, a JSON object like {"chunks":[...]}
+func newMockSpeechKit(t *testing.T, pendingPolls int, response string) *mockSpeechKit {
+	m := &mockSpeechKit{
+		pendingPolls: pendingPolls,
+		response:     response,
+		operations:   make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+mockRecognizePath, m.recognize)
+	mux.HandleFunc("GET "+mockOperationPath+"{id}", m.operation)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// client returns a client pointed at the mock that polls without delay
+func (m *mockSpeechKit) client() *Client {
+	c := NewClient("test-key", "folder", m.server.Client(), Timeouts{}, Endpoints{
+		Recognize: m.server.URL + mockRecognizePath,
+		Operation: m.server.URL + strings.TrimSuffix(mockOperationPath, "/"),
+	})
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+	c.maxPoll = 5 * time.Millisecond
+	return c
+}
+
+func (m *mockSpeechKit) recognize(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Api-Key test-key" {
+		http.Error(w, `{"code":16,"message":"unauthenticated"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req RecognitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"code":3,"message":"invalid request"}`, http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	id := fmt.Sprintf("op-%d", len(m.requests))
+	m.operations[id] = 0
+	m.mu.Unlock()
+
+	writeJSON(w, fmt.Sprintf(`{"id":%q,"done":false}`, id))
+}
+
+func (m *mockSpeechKit) operation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	m.mu.Lock()
+	polls, ok := m.operations[id]
+	if ok {
+		polls++
+		m.operations[id] = polls
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		http.Error(w, `{"code":5,"message":"operation not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if polls <= m.pendingPolls {
+		percent := polls * 100 / (m.pendingPolls + 1)
+		writeJSON(w, fmt.Sprintf(`{"id":%q,"done":false,"metadata":{"progressPercent":%d}}`, id, percent))
+		return
+	}
+	writeJSON(w, fmt.Sprintf(`{"id":%q,"done":true,"response":%s}`, id, m.response))
+}
+
+// polls returns how many times the operation was polled
+func (m *mockSpeechKit) polls(id string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.operations[id]
+}
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+func TestClient_RecognizeAgainstMockServer(t *testing.T) {
+	mock := newMockSpeechKit(t, 2, `{"chunks":[{"alternatives":[{"text":"Привет, мир","confidence":0.93}],"channelTag":"1"}]}`)
+	c := mock.client()
+
+	operationID, err := c.StartRecognition("s3://voxly/voice/task-1.ogg", RecognitionOptions{Model: "general"})
+	assert.NoError(t, err)
+	assert.Equal(t, "op-1", operationID)
+
+	var updates []int
+	result, err := c.WaitForResult(context.Background(), operationID, func(p Progress) {
+		updates = append(updates, p.Percent)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Привет, мир", result.BestText())
+	assert.Equal(t, []int{33, 66}, updates)
+	assert.Equal(t, 3, mock.polls(operationID))
+
+	assert.Len(t, mock.requests, 1)
+	assert.Equal(t, "s3://voxly/voice/task-1.ogg", mock.requests[0].Audio.URI)
+	assert.Equal(t, "general", mock.requests[0].Config.Specification.Model)
+}
+
+func TestClient_WaitForUnknownOperationAgainstMockServer(t *testing.T) {
+	c := newMockSpeechKit(t, 0, `{"chunks":[]}`).client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := c.WaitForResult(ctx, "op-404", nil)
+	assert.Error(t, err)
+}
@@ -50,6 +50,9 @@ type OperationError struct {
 // RecognitionResult represents final recognition result
 type RecognitionResult struct {
 	Chunks []Chunk `json:"chunks"`
+	// Operation is what the operation reported about itself; nil for results
+	// not read from a single finished operation
+	Operation *OperationInfo `json:"-"`
 }
 
 // Chunk represents one chunk of recognized text
@@ -65,6 +68,8 @@ type Alternative struct {
 	Text       string  `json:"text"`
 	Confidence float64 `json:"confidence,omitempty"`
 	Words      []Word  `json:"words,omitempty"`
+	// LanguageCode is reported when the language is detected automatically
+	LanguageCode string `json:"languageCode,omitempty"`
 }
 
 // Word represents single word with timing
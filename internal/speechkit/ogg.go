@@ -0,0 +1,171 @@
+package speechkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ogg page header layout
+const (
+	oggHeaderSize   = 27
+	oggFlagContinue = 0x01
+	oggFlagEOS      = 0x04
+	oggCRCOffset    = 22
+)
+
+// ErrNotOggOpus is returned when audio can't be split as an Ogg Opus stream
+var ErrNotOggOpus = errors.New("not an ogg opus stream")
+
+// AudioSegment is a standalone part of a longer recording
+type AudioSegment struct {
+	Data   []byte
+	Offset time.Duration // where the segment starts in the original recording
+}
+
+// oggPage is one parsed page; raw holds the header and body
+type oggPage struct {
+	raw     []byte
+	granule int64
+}
+
+func (p oggPage) continued() bool {
+	return p.raw[5]&oggFlagContinue != 0
+}
+
+// SplitOggOpus cuts an Ogg Opus recording into segments of about length each.
+// Every segment repeats the stream headers so it can be recognized on its own.
+// Cuts fall on page boundaries that don't split a packet.
+func SplitOggOpus(data []byte, length time.Duration) ([]AudioSegment, error) {
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header pages (OpusHead, OpusTags) come first and carry no audio
+	var headers []oggPage
+	for len(pages) > 0 && pages[0].granule == 0 {
+		headers = append(headers, pages[0])
+		pages = pages[1:]
+	}
+	if len(headers) == 0 || !bytes.HasPrefix(headers[0].raw[oggHeaderSize+int(headers[0].raw[26]):], []byte("OpusHead")) {
+		return nil, ErrNotOggOpus
+	}
+
+	segmentSamples := int64(length / time.Second * opusDecodeRate)
+	if segmentSamples <= 0 {
+		return nil, fmt.Errorf("invalid segment length: %s", length)
+	}
+
+	var segments []AudioSegment
+	var current []oggPage
+	var start int64 // granule position where the current segment starts
+	for i, page := range pages {
+		current = append(current, page)
+
+		last := i == len(pages)-1
+		full := page.granule > 0 && page.granule-start >= segmentSamples
+		if !last && (!full || pages[i+1].continued()) {
+			continue
+		}
+
+		segments = append(segments, AudioSegment{
+			Data:   buildOggSegment(headers, current, start),
+			Offset: time.Duration(start) * time.Second / opusDecodeRate,
+		})
+		if page.granule > 0 {
+			start = page.granule
+		}
+		current = nil
+	}
+
+	return segments, nil
+}
+
+// parseOggPages splits data into pages, checking the framing
+func parseOggPages(data []byte) ([]oggPage, error) {
+	var pages []oggPage
+	for len(data) > 0 {
+		if len(data) < oggHeaderSize || !bytes.HasPrefix(data, []byte("OggS")) {
+			return nil, ErrNotOggOpus
+		}
+		segments := int(data[26])
+		if len(data) < oggHeaderSize+segments {
+			return nil, ErrNotOggOpus
+		}
+		size := oggHeaderSize + segments
+		for _, lacing := range data[oggHeaderSize : oggHeaderSize+segments] {
+			size += int(lacing)
+		}
+		if len(data) < size {
+			return nil, ErrNotOggOpus
+		}
+
+		pages = append(pages, oggPage{
+			raw:     data[:size],
+			granule: int64(binary.LittleEndian.Uint64(data[6:14])),
+		})
+		data = data[size:]
+	}
+	return pages, nil
+}
+
+// buildOggSegment assembles a standalone stream from the headers and audio pages.
+// Granule positions are shifted to start at zero and pages renumbered, so
+// decoders see a complete stream rather than a gap.
+func buildOggSegment(headers, audio []oggPage, start int64) []byte {
+	var out []byte
+	sequence := uint32(0)
+	write := func(page oggPage, granule int64, eos bool) {
+		raw := append([]byte(nil), page.raw...)
+		raw[5] &^= oggFlagEOS
+		if eos {
+			raw[5] |= oggFlagEOS
+		}
+		binary.LittleEndian.PutUint64(raw[6:14], uint64(granule))
+		binary.LittleEndian.PutUint32(raw[18:22], sequence)
+		binary.LittleEndian.PutUint32(raw[oggCRCOffset:oggCRCOffset+4], 0)
+		binary.LittleEndian.PutUint32(raw[oggCRCOffset:oggCRCOffset+4], oggCRC(raw))
+		out = append(out, raw...)
+		sequence++
+	}
+
+	for _, page := range headers {
+		write(page, page.granule, false)
+	}
+	for i, page := range audio {
+		granule := page.granule
+		if granule > 0 {
+			granule -= start
+		}
+		write(page, granule, i == len(audio)-1)
+	}
+	return out
+}
+
+// oggCRCTable is the CRC-32 table for polynomial 0x04c11db7 without bit reflection
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// oggCRC computes the page checksum; the checksum field must be zeroed
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
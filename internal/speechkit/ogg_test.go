@@ -0,0 +1,86 @@
+package speechkit
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oggPageBytes builds an Ogg page with a single segment body
+func oggPageBytes(flags byte, granule int64, body []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, flags)
+	page = binary.LittleEndian.AppendUint64(page, uint64(granule))
+	page = append(page, make([]byte, 12)...) // serial, sequence, checksum
+	page = append(page, 1, byte(len(body)))
+	return append(page, body...)
+}
+
+// oggOpusStream builds a stream of one-second audio pages after the headers
+func oggOpusStream(seconds int) []byte {
+	data := oggOpusHeader(1, 48000)
+	data = append(data, oggPageBytes(0, 0, []byte("OpusTags"))...)
+	for i := 1; i <= seconds; i++ {
+		data = append(data, oggPageBytes(0, int64(i)*opusDecodeRate, []byte{byte(i)})...)
+	}
+	return data
+}
+
+func TestSplitOggOpus(t *testing.T) {
+	segments, err := SplitOggOpus(oggOpusStream(5), 2*time.Second)
+	assert.NoError(t, err)
+
+	if assert.Len(t, segments, 3) {
+		assert.Equal(t, time.Duration(0), segments[0].Offset)
+		assert.Equal(t, 2*time.Second, segments[1].Offset)
+		assert.Equal(t, 4*time.Second, segments[2].Offset)
+	}
+
+	for i, segment := range segments {
+		pages, err := parseOggPages(segment.Data)
+		assert.NoError(t, err)
+
+		// Headers are repeated and audio granules restart from zero
+		assert.Equal(t, []byte("OpusHead"), pages[0].raw[28:36])
+		assert.Equal(t, []byte("OpusTags"), pages[1].raw[28:36])
+		assert.Equal(t, int64(opusDecodeRate), pages[2].granule)
+
+		for j, page := range pages {
+			assert.Equal(t, uint32(j), binary.LittleEndian.Uint32(page.raw[18:22]))
+
+			raw := append([]byte(nil), page.raw...)
+			checksum := binary.LittleEndian.Uint32(raw[oggCRCOffset:])
+			binary.LittleEndian.PutUint32(raw[oggCRCOffset:], 0)
+			assert.Equal(t, oggCRC(raw), checksum, "segment %d page %d", i, j)
+
+			eos := page.raw[5]&oggFlagEOS != 0
+			assert.Equal(t, j == len(pages)-1, eos, "segment %d page %d", i, j)
+		}
+	}
+}
+
+func TestSplitOggOpus_KeepsContinuedPacketsTogether(t *testing.T) {
+	data := oggOpusHeader(1, 48000)
+	data = append(data, oggPageBytes(0, 0, []byte("OpusTags"))...)
+	data = append(data, oggPageBytes(0, opusDecodeRate, []byte{1})...)
+	data = append(data, oggPageBytes(oggFlagContinue, 2*opusDecodeRate, []byte{2})...)
+	data = append(data, oggPageBytes(0, 3*opusDecodeRate, []byte{3})...)
+
+	segments, err := SplitOggOpus(data, time.Second)
+	assert.NoError(t, err)
+	if assert.Len(t, segments, 2) {
+		assert.Equal(t, time.Duration(0), segments[0].Offset)
+		assert.Equal(t, 2*time.Second, segments[1].Offset)
+	}
+}
+
+func TestSplitOggOpus_RejectsOtherAudio(t *testing.T) {
+	_, err := SplitOggOpus(wavHeader(1, 1, 16000, 16), time.Second)
+	assert.ErrorIs(t, err, ErrNotOggOpus)
+
+	vorbis := oggPageBytes(0, 0, []byte("\x01vorbis"))
+	_, err = SplitOggOpus(vorbis, time.Second)
+	assert.ErrorIs(t, err, ErrNotOggOpus)
+}
@@ -0,0 +1,55 @@
+package speechkit
+
+import "time"
+
+// Bounds for the adaptive poll interval used when operations report progress
+const (
+	MinOperationPoll = 1 * time.Second
+	MaxOperationPoll = 30 * time.Second
+)
+
+// Progress describes how far a recognition operation has advanced
+type Progress struct {
+	Percent int // 0-100 as reported in operation metadata
+	Elapsed time.Duration
+}
+
+// ProgressFunc receives progress updates while waiting for an operation
+type ProgressFunc func(Progress)
+
+// progressPercent extracts the completion percentage from operation metadata.
+// ok is false when the operation does not report progress.
+func progressPercent(metadata map[string]interface{}) (int, bool) {
+	for _, key := range []string{"progressPercent", "progress"} {
+		value, found := metadata[key].(float64)
+		if !found {
+			continue
+		}
+		if value < 0 {
+			value = 0
+		}
+		if value > 100 {
+			value = 100
+		}
+		return int(value), true
+	}
+	return 0, false
+}
+
+// nextPollInterval estimates the time left from the progress rate so far and
+// waits about that long, within [minPoll, maxPoll]. Without progress it
+// returns the fixed fallback interval.
+func nextPollInterval(percent int, hasProgress bool, elapsed, fallback, minPoll, maxPoll time.Duration) time.Duration {
+	if !hasProgress || percent <= 0 || percent >= 100 {
+		return fallback
+	}
+
+	remaining := elapsed * time.Duration(100-percent) / time.Duration(percent)
+	if remaining < minPoll {
+		return minPoll
+	}
+	if remaining > maxPoll {
+		return maxPoll
+	}
+	return remaining
+}
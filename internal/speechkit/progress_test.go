@@ -0,0 +1,403 @@
+package speechkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	const (
+		fallback = 5 * time.Second
+		minPoll  = time.Second
+		maxPoll  = 30 * time.Second
+	)
+
+	tests := []struct {
+		name        string
+		percent     int
+		hasProgress bool
+		elapsed     time.Duration
+		expected    time.Duration
+	}{
+		{"no progress", 0, false, 10 * time.Second, fallback},
+		{"zero percent", 0, true, 10 * time.Second, fallback},
+		{"halfway", 50, true, 10 * time.Second, 10 * time.Second},
+		{"almost done", 95, true, 10 * time.Second, minPoll},
+		{"slow start", 1, true, 10 * time.Second, maxPoll},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextPollInterval(tt.percent, tt.hasProgress, tt.elapsed, fallback, minPoll, maxPoll)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestProgressPercent(t *testing.T) {
+	percent, ok := progressPercent(map[string]interface{}{"progressPercent": 42.0})
+	assert.True(t, ok)
+	assert.Equal(t, 42, percent)
+
+	percent, ok = progressPercent(map[string]interface{}{"progress": 150.0})
+	assert.True(t, ok)
+	assert.Equal(t, 100, percent)
+
+	_, ok = progressPercent(map[string]interface{}{"@type": "LongRunningRecognitionMetadata"})
+	assert.False(t, ok)
+
+	_, ok = progressPercent(nil)
+	assert.False(t, ok)
+}
+
+func TestClient_WaitForResultReportsProgress(t *testing.T) {
+	stages := []string{
+		`{"id":"op-1","done":false,"metadata":{"progressPercent":10}}`,
+		`{"id":"op-1","done":false,"metadata":{"progressPercent":60}}`,
+		`{"id":"op-1","done":true,"response":{"chunks":[{"alternatives":[{"text":"Привет","confidence":0.9}]}]}}`,
+	}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/op-1", r.URL.Path)
+		assert.Equal(t, "Api-Key test-key", r.Header.Get("Authorization"))
+
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(stages[n-1]))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+	c.maxPoll = 5 * time.Millisecond
+
+	var updates []int
+	result, err := c.WaitForResult(context.Background(), "op-1", func(p Progress) {
+		updates = append(updates, p.Percent)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Привет", result.BestText())
+	assert.Equal(t, []int{10, 60}, updates)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestClient_WaitForResultOperationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"op-1","done":true,"error":{"code":3,"message":"bad audio"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+
+	_, err := c.WaitForResult(context.Background(), "op-1", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestClient_WaitForResultReturnsPartialResultOnTimeout(t *testing.T) {
+	stages := []string{
+		`{"id":"op-1","done":false,"metadata":{"progressPercent":10}}`,
+		`{"id":"op-1","done":false,"metadata":{"progressPercent":40},"response":{"chunks":[{"alternatives":[{"text":"Начало записи"}]}]}}`,
+	}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Write([]byte(stages[min(int(n), len(stages))-1]))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+	c.maxPoll = 5 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := c.WaitForResult(ctx, "op-1", nil)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var partial *PartialResultError
+	if assert.ErrorAs(t, err, &partial) {
+		assert.Equal(t, "Начало записи", partial.Result.BestText())
+	}
+}
+
+func TestClient_WaitForResultCancelledDropsPartialResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"op-1","done":false,"metadata":{"progressPercent":40},"response":{"chunks":[{"alternatives":[{"text":"Начало записи"}]}]}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+	c.maxPoll = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	// The worker is stopping: the task is retried rather than finished with part of the text
+	_, err := c.WaitForResult(ctx, "op-1", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var partial *PartialResultError
+	assert.False(t, errors.As(err, &partial))
+}
+
+func TestClient_WaitForResultTimeoutWithoutChunks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"op-1","done":false,"response":{"chunks":[]}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.WaitForResult(ctx, "op-1", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var partial *PartialResultError
+	assert.False(t, errors.As(err, &partial))
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_UsesInjectedHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"op-1","done":true,"response":{"chunks":[]}}`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	c := NewClient("test-key", "folder", &http.Client{Transport: transport}, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+
+	_, err := c.WaitForResult(context.Background(), "op-1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&transport.requests))
+}
+
+// deadlineTransport answers every request with body and records how much
+// time the request context had left
+type deadlineTransport struct {
+	body      string
+	remaining []time.Duration
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok {
+		t.remaining = append(t.remaining, time.Until(deadline))
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestClient_RequestTimeouts(t *testing.T) {
+	timeouts := Timeouts{Start: 3 * time.Second, Poll: 7 * time.Second}
+
+	t.Run("start", func(t *testing.T) {
+		transport := &deadlineTransport{body: `{"id":"op-1"}`}
+		c := NewClient("test-key", "folder", &http.Client{Transport: transport}, timeouts, Endpoints{})
+
+		_, err := c.StartRecognition("s3://bucket/voice.ogg", RecognitionOptions{})
+		assert.NoError(t, err)
+		if assert.Len(t, transport.remaining, 1) {
+			assert.InDelta(t, timeouts.Start, transport.remaining[0], float64(time.Second))
+		}
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		transport := &deadlineTransport{body: `{"id":"op-1","done":true,"response":{"chunks":[]}}`}
+		c := NewClient("test-key", "folder", &http.Client{Transport: transport}, timeouts, Endpoints{})
+
+		_, err := c.WaitForResult(context.Background(), "op-1", nil)
+		assert.NoError(t, err)
+		if assert.Len(t, transport.remaining, 1) {
+			assert.InDelta(t, timeouts.Poll, transport.remaining[0], float64(time.Second))
+		}
+	})
+}
+
+func TestNewClient_DefaultTimeouts(t *testing.T) {
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	assert.Equal(t, DefaultStartTimeout, c.startTimeout)
+	assert.Equal(t, DefaultPollTimeout, c.pollTimeout)
+}
+
+func TestClient_ResponseSizeLimit(t *testing.T) {
+	oversized := `{"id":"op-1","done":true,"response":{"chunks":[]},"padding":"` + strings.Repeat("x", 64) + `"}`
+
+	t.Run("start", func(t *testing.T) {
+		c := NewClient("test-key", "folder", &http.Client{Transport: &deadlineTransport{body: oversized}}, Timeouts{}, Endpoints{})
+		c.maxResponseSize = 32
+
+		_, err := c.StartRecognition("s3://bucket/voice.ogg", RecognitionOptions{})
+		assert.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		c := NewClient("test-key", "folder", &http.Client{Transport: &deadlineTransport{body: oversized}}, Timeouts{}, Endpoints{})
+		c.maxResponseSize = 32
+
+		_, err := c.WaitForResult(context.Background(), "op-1", nil)
+		assert.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		c := NewClient("test-key", "folder", &http.Client{Transport: &deadlineTransport{body: oversized}}, Timeouts{}, Endpoints{})
+		c.maxResponseSize = int64(len(oversized))
+
+		_, err := c.WaitForResult(context.Background(), "op-1", nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestClient_CancelOperation(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.Write([]byte(`{"id":"op-1","done":true}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+
+	assert.NoError(t, c.CancelOperation(context.Background(), "op-1"))
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/op-1:cancel", path)
+}
+
+func TestClient_CheckCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"accepted key finds no operation", http.StatusNotFound, nil},
+		{"rejected key", http.StatusUnauthorized, ErrUnauthorized},
+		{"no access to the folder", http.StatusForbidden, ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, "Api-Key test-key", r.Header.Get("Authorization"))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+			c.operationURL = server.URL
+
+			err := c.CheckCredentials(context.Background())
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_CheckCredentialsServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient("test-key", "folder", nil, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+
+	err := c.CheckCredentials(context.Background())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnauthorized)
+}
+
+// idleClosingTransport records whether idle connections were dropped
+type idleClosingTransport struct {
+	countingTransport
+	idleClosed atomic.Bool
+}
+
+func (t *idleClosingTransport) CloseIdleConnections() {
+	t.idleClosed.Store(true)
+}
+
+func TestClient_CloseStopsPolling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"op-1","done":false,"response":{"chunks":[]}}`))
+	}))
+	defer server.Close()
+
+	transport := &idleClosingTransport{}
+	c := NewClient("test-key", "folder", &http.Client{Transport: transport}, Timeouts{}, Endpoints{})
+	c.operationURL = server.URL
+	c.pollInterval = time.Millisecond
+	c.minPoll = time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.WaitForResult(context.Background(), "op-1", nil)
+		done <- err
+	}()
+
+	// Let the poll run for a while before closing
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, c.Close())
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("WaitForResult kept polling after Close")
+	}
+
+	assert.True(t, transport.idleClosed.Load())
+	assert.Positive(t, atomic.LoadInt32(&transport.requests))
+}
+
+func TestClient_StartRecognitionAfterClose(t *testing.T) {
+	transport := &countingTransport{}
+	c := NewClient("test-key", "folder", &http.Client{Transport: transport}, Timeouts{}, Endpoints{})
+	assert.NoError(t, c.Close())
+
+	_, err := c.StartRecognition("s3://bucket/key", RecognitionOptions{})
+
+	assert.ErrorIs(t, err, ErrClientClosed)
+	assert.Zero(t, atomic.LoadInt32(&transport.requests))
+}
@@ -0,0 +1,98 @@
+package speechkit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Model is a SpeechKit recognition model
+type Model string
+
+// Supported recognition models
+const (
+	ModelGeneral         Model = "general"
+	ModelGeneralRC       Model = "general:rc"
+	ModelDeferredGeneral Model = "deferred-general" // cheaper, results may take hours
+)
+
+// DefaultModel is used when no model is configured
+const DefaultModel = ModelGeneralRC
+
+// ErrDeferredModel means a deferred model was configured for selection. Its
+// results can take hours, longer than WaitForResult and task deadlines allow.
+var ErrDeferredModel = errors.New("deferred model results take longer than the recognition wait")
+
+// ParseModel validates a model name from configuration.
+// An empty name yields the default model.
+func ParseModel(name string) (Model, error) {
+	switch model := Model(name); model {
+	case "":
+		return DefaultModel, nil
+	case ModelGeneral, ModelGeneralRC, ModelDeferredGeneral:
+		return model, nil
+	default:
+		return "", fmt.Errorf("unknown speechkit model: %q", name)
+	}
+}
+
+// ParseSelectableModel is ParseModel for models picked by ModelSelection,
+// rejecting deferred-general with ErrDeferredModel
+func ParseSelectableModel(name string) (Model, error) {
+	model, err := ParseModel(name)
+	if err != nil {
+		return "", err
+	}
+	if model == ModelDeferredGeneral {
+		return "", fmt.Errorf("%q: %w", name, ErrDeferredModel)
+	}
+	return model, nil
+}
+
+// ModelSelection picks a model by audio duration and quality
+type ModelSelection struct {
+	Default        Model
+	LongAudio      Model         // model for long audio; empty disables auto-selection
+	LongAudioAfter time.Duration // audio at least this long uses LongAudio
+	Narrowband     Model         // model for phone-quality audio that isn't long; empty uses Default
+}
+
+// Select returns the model to use for audio of the given duration
+func (s ModelSelection) Select(duration time.Duration) Model {
+	if s.long(duration) {
+		return s.LongAudio
+	}
+	return s.defaultModel()
+}
+
+// SelectAudio returns the model to use for audio of the given duration and
+// format. Long audio keeps LongAudio, so a long phone call stays on the model
+// chosen for cost.
+func (s ModelSelection) SelectAudio(duration time.Duration, audio AudioFormat) Model {
+	if s.Narrowband != "" && audio.Narrowband() && !s.long(duration) {
+		return s.Narrowband
+	}
+	return s.Select(duration)
+}
+
+// long reports whether audio of the given duration goes to LongAudio
+func (s ModelSelection) long(duration time.Duration) bool {
+	return s.LongAudio != "" && s.LongAudioAfter > 0 && duration >= s.LongAudioAfter
+}
+
+// defaultModel returns Default, or DefaultModel when it's unset
+func (s ModelSelection) defaultModel() Model {
+	if s.Default == "" {
+		return DefaultModel
+	}
+	return s.Default
+}
+
+// RecognitionOptions are per-request recognition settings
+type RecognitionOptions struct {
+	Model    Model       // empty means DefaultModel
+	Language string      // language code or LanguageAuto; empty means DefaultLanguage
+	Audio    AudioFormat // unset fields fall back to a mono 48kHz OGG Opus voice message
+
+	ProfanityFilter bool // mask obscene words in the transcript
+}
@@ -0,0 +1,83 @@
+package speechkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelectableModel(t *testing.T) {
+	model, err := ParseSelectableModel("general")
+	assert.NoError(t, err)
+	assert.Equal(t, ModelGeneral, model)
+
+	_, err = ParseSelectableModel("deferred-general")
+	assert.ErrorIs(t, err, ErrDeferredModel)
+
+	_, err = ParseSelectableModel("general:turbo")
+	assert.Error(t, err)
+}
+
+func TestParseModel(t *testing.T) {
+	model, err := ParseModel("")
+	assert.NoError(t, err)
+	assert.Equal(t, ModelGeneralRC, model)
+
+	model, err = ParseModel("deferred-general")
+	assert.NoError(t, err)
+	assert.Equal(t, ModelDeferredGeneral, model)
+
+	_, err = ParseModel("general:turbo")
+	assert.Error(t, err)
+}
+
+func TestModelSelection_Select(t *testing.T) {
+	selection := ModelSelection{
+		Default:        ModelGeneralRC,
+		LongAudio:      ModelDeferredGeneral,
+		LongAudioAfter: 5 * time.Minute,
+	}
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		expected Model
+	}{
+		{"short", 30 * time.Second, ModelGeneralRC},
+		{"just below threshold", 5*time.Minute - time.Second, ModelGeneralRC},
+		{"at threshold", 5 * time.Minute, ModelDeferredGeneral},
+		{"long", time.Hour, ModelDeferredGeneral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, selection.Select(tt.duration))
+		})
+	}
+}
+
+func TestModelSelection_SelectWithoutAutoSelection(t *testing.T) {
+	assert.Equal(t, DefaultModel, ModelSelection{}.Select(time.Hour))
+	assert.Equal(t, ModelGeneral, ModelSelection{Default: ModelGeneral, LongAudioAfter: time.Minute}.Select(time.Hour))
+}
+
+func TestModelSelection_SelectAudio(t *testing.T) {
+	selection := ModelSelection{
+		Default:        ModelGeneralRC,
+		LongAudio:      ModelDeferredGeneral,
+		LongAudioAfter: 5 * time.Minute,
+		Narrowband:     ModelGeneral,
+	}
+	phone := AudioFormat{Encoding: EncodingLinear16PCM, SampleRate: 8000, ChannelCount: 1, InputSampleRate: 8000}
+	voice := AudioFormat{Encoding: EncodingOggOpus, SampleRate: 48000, ChannelCount: 1, InputSampleRate: 48000}
+
+	assert.Equal(t, ModelGeneral, selection.SelectAudio(time.Minute, phone))
+	assert.Equal(t, ModelGeneralRC, selection.SelectAudio(time.Minute, voice))
+	// Long phone calls stay on the long audio model
+	assert.Equal(t, ModelDeferredGeneral, selection.SelectAudio(time.Hour, phone))
+
+	// Without a narrowband model phone audio uses the default one
+	selection.Narrowband = ""
+	assert.Equal(t, ModelGeneralRC, selection.SelectAudio(time.Minute, phone))
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+	"voxly/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// abortTimeout bounds an abort when the operation timeout is disabled
+const abortTimeout = 30 * time.Second
+
+// multipartAPI is the part of the S3 client used for multipart uploads
+type multipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+}
+
+// readPart reads up to size bytes of the body
+func readPart(body io.Reader, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(body, size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// uploadMultipart sends first and the rest of the body in parts of partSize.
+// If any step fails the upload is aborted, so S3 doesn't keep the parts
+// already sent.
+func (s *S3Storage) uploadMultipart(ctx context.Context, key, contentType string, first []byte, rest io.Reader) error {
+	created, err := s.multipart.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	uploadID := aws.ToString(created.UploadId)
+
+	parts, err := s.uploadParts(ctx, key, uploadID, first, rest)
+	if err == nil {
+		_, err = s.multipart.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        aws.String(uploadID),
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+
+	if err != nil {
+		s.abortUpload(key, uploadID)
+		return err
+	}
+
+	logger.Debug("Multipart upload completed",
+		zap.String("key", key),
+		zap.Int("parts", len(parts)))
+
+	return nil
+}
+
+// uploadParts sends the parts in order and returns them for completion
+func (s *S3Storage) uploadParts(ctx context.Context, key, uploadID string, first []byte, rest io.Reader) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	part := first
+
+	for number := int32(1); len(part) > 0; number++ {
+		out, err := s.multipart.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(key),
+			UploadId:   aws.String(uploadID),
+			PartNumber: aws.Int32(number),
+			Body:       bytes.NewReader(part),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:       out.ETag,
+			PartNumber: aws.Int32(number),
+		})
+
+		part, err = readPart(rest, s.partSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %w", number+1, err)
+		}
+	}
+
+	return parts, nil
+}
+
+// abortUpload discards an unfinished upload. It doesn't use the upload's
+// context, which may be the reason the upload failed. A failed abort is
+// left to AbortStaleUploads.
+func (s *S3Storage) abortUpload(key, uploadID string) {
+	timeout := s.operationTimeout
+	if timeout <= 0 {
+		timeout = abortTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := s.multipart.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		logger.Error("Failed to abort multipart upload",
+			zap.String("key", key),
+			zap.String("upload_id", uploadID),
+			zap.Error(err))
+		return
+	}
+
+	logger.Warn("Multipart upload aborted",
+		zap.String("key", key),
+		zap.String("upload_id", uploadID))
+}
+
+// AbortStaleUploads aborts multipart uploads started more than olderThan ago.
+// They are left by workers that crashed mid-upload or failed to abort, and
+// their parts are billed until aborted.
+func (s *S3Storage) AbortStaleUploads(ctx context.Context, olderThan time.Duration) (int, error) {
+	paginator := s3.NewListMultipartUploadsPaginator(s.multipart, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucket),
+	})
+
+	cutoff := time.Now().Add(-olderThan)
+	aborted := 0
+
+	for paginator.HasMorePages() {
+		page, err := s.nextUploadsPage(ctx, paginator)
+		if err != nil {
+			return aborted, fmt.Errorf("failed to list multipart uploads: %w", err)
+		}
+
+		for _, upload := range page.Uploads {
+			if aws.ToTime(upload.Initiated).After(cutoff) {
+				continue
+			}
+
+			if err := s.abortStaleUpload(ctx, upload); err != nil {
+				logger.Error("Failed to abort stale multipart upload",
+					zap.String("key", aws.ToString(upload.Key)),
+					zap.String("upload_id", aws.ToString(upload.UploadId)),
+					zap.Error(err))
+				continue
+			}
+			aborted++
+		}
+	}
+
+	logger.Info("Stale multipart uploads aborted", zap.Int("aborted", aborted))
+
+	return aborted, nil
+}
+
+func (s *S3Storage) abortStaleUpload(ctx context.Context, upload types.MultipartUpload) error {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	_, err := s.multipart.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      upload.Key,
+		UploadId: upload.UploadId,
+	})
+	return err
+}
+
+// nextUploadsPage fetches one upload listing page, bounded by the operation timeout
+func (s *S3Storage) nextUploadsPage(ctx context.Context, paginator *s3.ListMultipartUploadsPaginator) (*s3.ListMultipartUploadsOutput, error) {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+	return paginator.NextPage(ctx)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubMultipart records multipart calls and fails the part numbered failPart
+type stubMultipart struct {
+	mu       sync.Mutex
+	failPart int32
+	parts    [][]byte
+	complete []types.CompletedPart
+	aborted  []string
+	uploads  []types.MultipartUpload
+	// abortCtxErr is the state of the context the last abort got
+	abortCtxErr error
+}
+
+func (s *stubMultipart) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (s *stubMultipart) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	number := aws.ToInt32(params.PartNumber)
+	if number == s.failPart {
+		return nil, errors.New("connection reset")
+	}
+	data, _ := io.ReadAll(params.Body)
+	s.parts = append(s.parts, data)
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", number))}, nil
+}
+
+func (s *stubMultipart) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	s.complete = params.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (s *stubMultipart) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abortCtxErr = ctx.Err()
+	s.aborted = append(s.aborted, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (s *stubMultipart) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	return &s3.ListMultipartUploadsOutput{Uploads: s.uploads, IsTruncated: aws.Bool(false)}, nil
+}
+
+func newMultipartS3Storage(t *testing.T, stub *stubMultipart) *S3Storage {
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}, ClientOptions{RetryMode: "standard", PartSize: 4})
+	s3Storage.multipart = stub
+	return s3Storage
+}
+
+func TestS3Storage_UploadFileInParts(t *testing.T) {
+	stub := &stubMultipart{}
+	s3Storage := newMultipartS3Storage(t, stub)
+
+	url, err := s3Storage.UploadFile(context.Background(), "voice/task-1.ogg", strings.NewReader("0123456789"), "audio/ogg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.yandexcloud.net/voxly/voice/task-1.ogg", url)
+	assert.Equal(t, [][]byte{[]byte("0123"), []byte("4567"), []byte("89")}, stub.parts)
+	assert.Len(t, stub.complete, 3)
+	assert.Equal(t, "etag-3", aws.ToString(stub.complete[2].ETag))
+	assert.Empty(t, stub.aborted)
+}
+
+func TestS3Storage_UploadFileAbortsFailedParts(t *testing.T) {
+	stub := &stubMultipart{failPart: 2}
+	s3Storage := newMultipartS3Storage(t, stub)
+
+	_, err := s3Storage.UploadFile(context.Background(), "voice/task-1.ogg", strings.NewReader("0123456789"), "audio/ogg")
+	assert.ErrorContains(t, err, "failed to upload part 2")
+	assert.Equal(t, []string{"upload-1"}, stub.aborted)
+	assert.Nil(t, stub.complete)
+}
+
+func TestS3Storage_UploadFileAbortsAfterCancel(t *testing.T) {
+	stub := &stubMultipart{}
+	s3Storage := newMultipartS3Storage(t, stub)
+
+	// The body fails after the first part, when the caller is already gone
+	ctx, cancel := context.WithCancel(context.Background())
+	body := io.MultiReader(strings.NewReader("0123"), &cancelingReader{cancel: cancel})
+
+	_, err := s3Storage.UploadFile(ctx, "voice/task-1.ogg", body, "audio/ogg")
+	assert.ErrorContains(t, err, "failed to read part 2")
+	assert.Equal(t, []string{"upload-1"}, stub.aborted)
+	assert.NoError(t, stub.abortCtxErr)
+}
+
+// cancelingReader cancels a context and fails the read
+type cancelingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return 0, errors.New("stream closed")
+}
+
+func TestS3Storage_SmallFileSkipsMultipart(t *testing.T) {
+	var body []byte
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		body, _ = io.ReadAll(r.Body)
+	}, ClientOptions{RetryMode: "standard", PartSize: 4})
+	stub := &stubMultipart{}
+	s3Storage.multipart = stub
+
+	_, err := s3Storage.UploadFile(context.Background(), "voice/task-1.ogg", bytes.NewReader([]byte("012")), "audio/ogg")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("012"), body)
+	assert.Empty(t, stub.parts)
+}
+
+func TestS3Storage_AbortStaleUploads(t *testing.T) {
+	now := time.Now()
+	stub := &stubMultipart{uploads: []types.MultipartUpload{
+		{Key: aws.String("voice/old.ogg"), UploadId: aws.String("old"), Initiated: aws.Time(now.Add(-48 * time.Hour))},
+		{Key: aws.String("voice/new.ogg"), UploadId: aws.String("new"), Initiated: aws.Time(now.Add(-time.Minute))},
+	}}
+	s3Storage := newMultipartS3Storage(t, stub)
+
+	aborted, err := s3Storage.AbortStaleUploads(context.Background(), 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, aborted)
+	assert.Equal(t, []string{"old"}, stub.aborted)
+}
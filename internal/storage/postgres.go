@@ -2,33 +2,102 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
-	"path/filepath"
-	"runtime"
+	"strconv"
+	"time"
+	"voxly/migrations"
 	"voxly/pkg/logger"
 	"voxly/pkg/model"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
 )
 
+// ErrTaskNotFound is returned when a task does not exist
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrTranscriptNotFound is returned when a task has no transcript
+var ErrTranscriptNotFound = errors.New("transcript not found")
+
+// ErrNoQueuedTask is returned by ClaimNextTask when no task is queued
+var ErrNoQueuedTask = errors.New("no queued task")
+
+// ErrTaskClaimed is returned by ClaimTask when another worker is processing the
+// task or it needs no more processing
+var ErrTaskClaimed = errors.New("task claimed by another worker or finished")
+
 type PostgresStorage struct {
 	pool *pgxpool.Pool
 }
 
-// New PostgreSQL storage instance
-func NewPostgresStorage(databaseURL string) (*PostgresStorage, error) {
+// PoolOptions tunes the connection pool; zero values keep the pgx defaults
+type PoolOptions struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+}
+
+// Upper bound for MaxConns, well above what a single service should hold
+const maxPoolConns = 1000
+
+func (o PoolOptions) validate() error {
+	if o.MaxConns < 0 || o.MaxConns > maxPoolConns {
+		return fmt.Errorf("max conns must be between 0 and %d, got %d", maxPoolConns, o.MaxConns)
+	}
+	if o.MinConns < 0 {
+		return fmt.Errorf("min conns must not be negative, got %d", o.MinConns)
+	}
+	if o.MaxConns > 0 && o.MinConns > o.MaxConns {
+		return fmt.Errorf("min conns (%d) must not exceed max conns (%d)", o.MinConns, o.MaxConns)
+	}
+	if o.MaxConnLifetime != 0 && o.MaxConnLifetime < time.Second {
+		return fmt.Errorf("max conn lifetime must be at least 1s, got %s", o.MaxConnLifetime)
+	}
+	return nil
+}
+
+// Parses the database URL and applies pool options on top of it
+func newPoolConfig(databaseURL string, opts PoolOptions) (*pgxpool.Config, error) {
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pool options: %w", err)
+	}
+
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
 
+	if opts.MaxConns > 0 {
+		config.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		config.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("invalid pool options: min conns (%d) exceed max conns (%d)", config.MinConns, config.MaxConns)
+	}
+
+	return config, nil
+}
+
+// New PostgreSQL storage instance
+func NewPostgresStorage(databaseURL string, opts PoolOptions) (*PostgresStorage, error) {
+	config, err := newPoolConfig(databaseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
@@ -54,106 +123,145 @@ func NewPostgresStorage(databaseURL string) (*PostgresStorage, error) {
 
 // Executing database migrations
 func runMigrations(databaseURL string) error {
-	// Get absolute path to migrations directory
-	migrationsPath, err := filepath.Abs("migrations")
+	m, closeMigrate, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to get migrations path: %w", err)
+		return err
 	}
+	defer closeMigrate()
 
-	// Create file URL from path (works on both Windows and Unix)
-	var migrationsURL string
-	if runtime.GOOS == "windows" {
-		// On Windows
-		u := &url.URL{
-			Scheme: "file",
-			Path:   filepath.ToSlash(migrationsPath),
-		}
-		migrationsURL = u.String()
+	// Run migrations up
+	err = m.Up()
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	if err == migrate.ErrNoChange {
+		logger.Info("No new migrations to apply")
 	} else {
-		// On Unix
-		migrationsURL = fmt.Sprintf("file://%s", migrationsPath)
+		logger.Info("Migrations applied successfully")
 	}
 
-	logger.Info("Running migrations", zap.String("path", migrationsURL))
+	return nil
+}
 
-	// Create a standard database connection for migrations
-	db := stdlib.OpenDB(*parseConfig(databaseURL))
-	defer db.Close()
+// MigrationVersion reports the current schema version and whether the last
+// migration left it dirty. Version 0 means no migration has been applied.
+func MigrationVersion(databaseURL string) (uint, bool, error) {
+	m, closeMigrate, err := newMigrate(databaseURL)
+	if err != nil {
+		return 0, false, err
+	}
+	defer closeMigrate()
 
-	// Create postgres driver instance
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	return migrationVersion(m)
+}
+
+// MigrateSteps applies n migrations up when n > 0 or rolls back -n migrations when n < 0
+func MigrateSteps(databaseURL string, n int) error {
+	m, closeMigrate, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create postgres driver: %w", err)
+		return err
 	}
+	defer closeMigrate()
 
-	// Create migrate instance
-	m, err := migrate.NewWithDatabaseInstance(
-		migrationsURL,
-		"postgres",
-		driver,
-	)
+	return migrateSteps(m, n)
+}
+
+// Runs migrate.Steps, refusing to touch a dirty schema and logging the versions
+func migrateSteps(m *migrate.Migrate, n int) error {
+	if n == 0 {
+		return errors.New("migration steps must not be zero")
+	}
+
+	from, dirty, err := migrationVersion(m)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("database is dirty at version %d, fix it before stepping migrations", from)
 	}
-	defer m.Close()
 
-	// Run migrations up
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	logger.Warn("Applying migration steps",
+		zap.Uint("from_version", from),
+		zap.Int("steps", n))
+
+	if err := m.Steps(n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply %d migration steps: %w", n, err)
 	}
 
-	if err == migrate.ErrNoChange {
-		logger.Info("No new migrations to apply")
-	} else {
-		logger.Info("Migrations applied successfully")
+	to, _, err := migrationVersion(m)
+	if err != nil {
+		return err
 	}
 
+	logger.Info("Migration steps applied",
+		zap.Uint("from_version", from),
+		zap.Uint("to_version", to))
 	return nil
 }
 
-// Drops all tables and re-runs migrations (for development)
-func ResetMigrations(databaseURL string) error {
-	logger.Warn("Resetting database - this will drop all data!")
-
-	// Get absolute path to migrations directory
-	migrationsPath, err := filepath.Abs("migrations")
+// Reads the version from a migrate instance, treating "no version" as 0
+func migrationVersion(m *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get migrations path: %w", err)
+		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
 	}
+	return version, dirty, nil
+}
 
-	// Create file URL from path 
-	var migrationsURL string
-	if runtime.GOOS == "windows" {
-		u := &url.URL{
-			Scheme: "file",
-			Path:   filepath.ToSlash(migrationsPath),
-		}
-		migrationsURL = u.String()
-	} else {
-		migrationsURL = fmt.Sprintf("file://%s", migrationsPath)
+// Creates a migrate instance for the embedded migrations.
+// The returned func closes the instance and its database connection.
+func newMigrate(databaseURL string) (*migrate.Migrate, func(), error) {
+	src, err := newMigrationSource()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Create a standard database connection for migrations
 	db := stdlib.OpenDB(*parseConfig(databaseURL))
-	defer db.Close()
 
 	// Create postgres driver instance
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("failed to create postgres driver: %w", err)
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to create postgres driver: %w", err)
 	}
 
 	// Create migrate instance
-	m, err := migrate.NewWithDatabaseInstance(
-		migrationsURL,
-		"postgres",
-		driver,
-	)
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+
+	closeMigrate := func() {
+		m.Close()
+		db.Close()
+	}
+	return m, closeMigrate, nil
+}
+
+// Opens the migrations compiled into the binary, so they don't depend on the working directory
+func newMigrationSource() (source.Driver, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	return src, nil
+}
+
+// Drops all tables and re-runs migrations (for development)
+func ResetMigrations(databaseURL string) error {
+	logger.Warn("Resetting database - this will drop all data!")
+
+	m, closeMigrate, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
-	defer m.Close()
+	defer closeMigrate()
 
 	// Drop everything
 	if err := m.Drop(); err != nil {
@@ -180,22 +288,43 @@ func parseConfig(databaseURL string) *pgx.ConnConfig {
 	return config
 }
 
+// PoolStats is a snapshot of connection pool usage
+type PoolStats struct {
+	AcquiredConns     int32 `json:"acquired_conns"`
+	IdleConns         int32 `json:"idle_conns"`
+	TotalConns        int32 `json:"total_conns"`
+	MaxConns          int32 `json:"max_conns"`
+	EmptyAcquireCount int64 `json:"empty_acquire_count"` // acquires that had to wait for a connection
+}
+
+// Stats reports current connection pool usage
+func (s *PostgresStorage) Stats() PoolStats {
+	stat := s.pool.Stat()
+	return PoolStats{
+		AcquiredConns:     stat.AcquiredConns(),
+		IdleConns:         stat.IdleConns(),
+		TotalConns:        stat.TotalConns(),
+		MaxConns:          stat.MaxConns(),
+		EmptyAcquireCount: stat.EmptyAcquireCount(),
+	}
+}
+
 // Closes the database connection pool
 func (s *PostgresStorage) Close() {
 	s.pool.Close()
 }
 
-// CreateTask inserts a new task into the database
-func (s *PostgresStorage) CreateTask(ctx context.Context, task *model.Task) error {
-	query := `
-		INSERT INTO tasks (
-			id, telegram_message_id, chat_id, file_id, status, 
-			operation_id, attempts, error_text, meta, created_at, updated_at
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
-		)`
-
-	_, err := s.pool.Exec(ctx, query,
+const insertTaskQuery = `
+	INSERT INTO tasks (
+		id, telegram_message_id, chat_id, file_id, status,
+		operation_id, attempts, error_text, meta, created_at, updated_at
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+	)`
+
+// Arguments for insertTaskQuery
+func insertTaskArgs(task *model.Task) []any {
+	return []any{
 		task.ID,
 		task.TelegramMessageID,
 		task.ChatID,
@@ -207,8 +336,12 @@ func (s *PostgresStorage) CreateTask(ctx context.Context, task *model.Task) erro
 		task.Meta,
 		task.CreatedAt,
 		task.UpdatedAt,
-	)
+	}
+}
 
+// CreateTask inserts a new task into the database
+func (s *PostgresStorage) CreateTask(ctx context.Context, task *model.Task) error {
+	_, err := s.pool.Exec(ctx, insertTaskQuery, insertTaskArgs(task)...)
 	if err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
@@ -216,6 +349,57 @@ func (s *PostgresStorage) CreateTask(ctx context.Context, task *model.Task) erro
 	return nil
 }
 
+// BatchInsertError identifies the row that made a batch insert fail
+type BatchInsertError struct {
+	Index  int
+	TaskID string
+	Err    error
+}
+
+func (e *BatchInsertError) Error() string {
+	return fmt.Sprintf("failed to create task %s (row %d): %v", e.TaskID, e.Index, e.Err)
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Err
+}
+
+// CreateTasks inserts tasks in a single round trip. The insert is atomic:
+// if any row fails nothing is stored, and a *BatchInsertError names the row.
+func (s *PostgresStorage) CreateTasks(ctx context.Context, tasks []*model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, task := range tasks {
+		batch.Queue(insertTaskQuery, insertTaskArgs(task)...)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for i, task := range tasks {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return &BatchInsertError{Index: i, TaskID: task.ID, Err: err}
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit tasks: %w", err)
+	}
+
+	return nil
+}
+
 // GetTaskByID retrieves a task by its ID
 func (s *PostgresStorage) GetTaskByID(ctx context.Context, id string) (*model.Task, error) {
 	query := `
@@ -243,7 +427,7 @@ func (s *PostgresStorage) GetTaskByID(ctx context.Context, id string) (*model.Ta
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
@@ -251,6 +435,94 @@ func (s *PostgresStorage) GetTaskByID(ctx context.Context, id string) (*model.Ta
 	return &task, nil
 }
 
+// GetTaskByChatAndMessageID retrieves the task created for a Telegram message.
+// The unique (chat_id, telegram_message_id) index serves the lookup.
+func (s *PostgresStorage) GetTaskByChatAndMessageID(ctx context.Context, chatID, messageID int64) (*model.Task, error) {
+	query := `
+		SELECT id, telegram_message_id, chat_id, file_id, status,
+		       operation_id, attempts, error_text, meta, created_at, updated_at
+		FROM tasks
+		WHERE chat_id = $1 AND telegram_message_id = $2`
+
+	var task model.Task
+	row := s.pool.QueryRow(ctx, query, chatID, messageID)
+
+	err := row.Scan(
+		&task.ID,
+		&task.TelegramMessageID,
+		&task.ChatID,
+		&task.FileID,
+		&task.Status,
+		&task.OperationID,
+		&task.Attempts,
+		&task.ErrorText,
+		&task.Meta,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to get task by message: %w", err)
+	}
+
+	return &task, nil
+}
+
+// GetTaskByReplyMessageID retrieves the task whose transcript was sent as the
+// given message of the chat
+func (s *PostgresStorage) GetTaskByReplyMessageID(ctx context.Context, chatID int64, replyMessageID int) (*model.Task, error) {
+	query := `
+		SELECT id, telegram_message_id, chat_id, file_id, status,
+		       operation_id, attempts, error_text, meta, created_at, updated_at
+		FROM tasks
+		WHERE chat_id = $1 AND meta->>'reply_message_id' = $2`
+
+	var task model.Task
+	row := s.pool.QueryRow(ctx, query, chatID, strconv.Itoa(replyMessageID))
+
+	err := row.Scan(
+		&task.ID,
+		&task.TelegramMessageID,
+		&task.ChatID,
+		&task.FileID,
+		&task.Status,
+		&task.OperationID,
+		&task.Attempts,
+		&task.ErrorText,
+		&task.Meta,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to get task by reply message: %w", err)
+	}
+
+	return &task, nil
+}
+
+// DeleteTask deletes a task. Its transcripts go in the same statement through
+// ON DELETE CASCADE, so either both are deleted or neither is.
+func (s *PostgresStorage) DeleteTask(ctx context.Context, id string) error {
+	query := `DELETE FROM tasks WHERE id = $1`
+
+	tag, err := s.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
 // UpdateTaskStatus updates the status of a task
 func (s *PostgresStorage) UpdateTaskStatus(ctx context.Context, id string, status model.TaskStatus) error {
 	query := `
@@ -264,12 +536,116 @@ func (s *PostgresStorage) UpdateTaskStatus(ctx context.Context, id string, statu
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("task not found")
+		return ErrTaskNotFound
 	}
 
 	return nil
 }
 
+// ClaimTask moves a task delivered by the queue to in_progress on behalf of
+// workerID, so a task both published and picked up by a database poller is
+// processed once. Queued tasks, failed and timed out ones with attempts left out
+// of maxAttempts, tasks workerID claimed before, redelivered after a failed
+// attempt, and claims not updated for staleAfter, left by a worker that
+// stopped, can be claimed; ErrTaskClaimed is returned for others.
+func (s *PostgresStorage) ClaimTask(ctx context.Context, id, workerID string, maxAttempts int, staleAfter time.Duration) (*model.Task, error) {
+	query := `
+		UPDATE tasks
+		SET status = $2, claimed_by = $3, updated_at = NOW()
+		WHERE id = $1
+		  AND (status = $4
+		       OR (status IN ($5, $6) AND attempts < $7)
+		       OR (status = $2 AND claimed_by = $3)
+		       OR (status = $2 AND updated_at < NOW() - make_interval(secs => $8)))
+		RETURNING id, telegram_message_id, chat_id, file_id, status,
+		          operation_id, attempts, error_text, meta, created_at, updated_at`
+
+	var task model.Task
+	row := s.pool.QueryRow(ctx, query,
+		id,
+		model.TaskStatusInProgress,
+		workerID,
+		model.TaskStatusQueued,
+		model.TaskStatusFailed,
+		model.TaskStatusTimeout,
+		maxAttempts,
+		staleAfter.Seconds(),
+	)
+
+	err := row.Scan(
+		&task.ID,
+		&task.TelegramMessageID,
+		&task.ChatID,
+		&task.FileID,
+		&task.Status,
+		&task.OperationID,
+		&task.Attempts,
+		&task.ErrorText,
+		&task.Meta,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		// Tell a claimed task from a missing one
+		if _, err := s.GetTaskByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrTaskClaimed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// ClaimNextTask moves the oldest queued task to in_progress on behalf of
+// workerID and returns it. Rows locked by concurrent claims are skipped, so
+// each task is claimed by exactly one worker. ErrNoQueuedTask is returned when
+// nothing is left to claim.
+func (s *PostgresStorage) ClaimNextTask(ctx context.Context, workerID string) (*model.Task, error) {
+	query := `
+		UPDATE tasks
+		SET status = $1, claimed_by = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM tasks
+			WHERE status = $3
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, telegram_message_id, chat_id, file_id, status,
+		          operation_id, attempts, error_text, meta, created_at, updated_at`
+
+	var task model.Task
+	row := s.pool.QueryRow(ctx, query, model.TaskStatusInProgress, workerID, model.TaskStatusQueued)
+
+	err := row.Scan(
+		&task.ID,
+		&task.TelegramMessageID,
+		&task.ChatID,
+		&task.FileID,
+		&task.Status,
+		&task.OperationID,
+		&task.Attempts,
+		&task.ErrorText,
+		&task.Meta,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoQueuedTask
+		}
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	return &task, nil
+}
+
 // UpdateTask updates a full task
 func (s *PostgresStorage) UpdateTask(ctx context.Context, task *model.Task) error {
 	query := `
@@ -296,7 +672,7 @@ func (s *PostgresStorage) UpdateTask(ctx context.Context, task *model.Task) erro
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("task not found")
+		return ErrTaskNotFound
 	}
 
 	return nil
@@ -347,33 +723,314 @@ func (s *PostgresStorage) GetQueuedTasks(ctx context.Context, limit int) ([]*mod
 	return tasks, nil
 }
 
-// CreateTranscript inserts a new transcript into the database
+// GetStaleTasks returns up to limit in_progress tasks not updated for
+// olderThan, oldest first
+func (s *PostgresStorage) GetStaleTasks(ctx context.Context, olderThan time.Duration, limit int) ([]*model.Task, error) {
+	query := `
+		SELECT id, telegram_message_id, chat_id, file_id, status,
+		       operation_id, attempts, error_text, meta, created_at, updated_at
+		FROM tasks
+		WHERE status = $1 AND updated_at < NOW() - make_interval(secs => $2)
+		ORDER BY updated_at ASC
+		LIMIT $3`
+
+	rows, err := s.pool.Query(ctx, query, model.TaskStatusInProgress, olderThan.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stale tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*model.Task
+	for rows.Next() {
+		var task model.Task
+		err := rows.Scan(
+			&task.ID,
+			&task.TelegramMessageID,
+			&task.ChatID,
+			&task.FileID,
+			&task.Status,
+			&task.OperationID,
+			&task.Attempts,
+			&task.ErrorText,
+			&task.Meta,
+			&task.CreatedAt,
+			&task.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, &task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// CreateTranscript stores a new version of the task's transcript and fills in
+// Version. Reprocessed tasks keep their earlier transcripts as history. The
+// task row is locked while the next version is picked, so concurrent saves for
+// one task get consecutive versions instead of colliding.
 func (s *PostgresStorage) CreateTranscript(ctx context.Context, transcript *model.Transcript) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM tasks WHERE id = $1 FOR UPDATE`, transcript.TaskID); err != nil {
+		return fmt.Errorf("failed to lock task: %w", err)
+	}
+
 	query := `
-		INSERT INTO transcripts (id, task_id, text, raw_response, created_at)
-		VALUES ($1, $2, $3, $4, $5)`
+		INSERT INTO transcripts (id, task_id, text, raw_response, version, created_at)
+		SELECT $1, $2, $3, $4, COALESCE(MAX(version), 0) + 1, $5
+		FROM transcripts
+		WHERE task_id = $2
+		RETURNING version`
 
-	_, err := s.pool.Exec(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		transcript.ID,
 		transcript.TaskID,
 		transcript.Text,
 		transcript.RawResponse,
 		transcript.CreatedAt,
-	)
+	).Scan(&transcript.Version)
 
 	if err != nil {
 		return fmt.Errorf("failed to create transcript: %w", err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transcript: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTranscriptsOlderThan deletes transcripts created before cutoff and
+// returns how many were deleted
+func (s *PostgresStorage) DeleteTranscriptsOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM transcripts WHERE created_at < $1`
+
+	tag, err := s.pool.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old transcripts: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteTasksOlderThan deletes finished tasks created before cutoff, along
+// with their transcripts, and returns how many tasks were deleted. Queued and
+// running tasks are kept however old they are.
+func (s *PostgresStorage) DeleteTasksOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM tasks
+		WHERE created_at < $1
+		  AND status NOT IN ($2, $3)`
+
+	tag, err := s.pool.Exec(ctx, query, cutoff, model.TaskStatusQueued, model.TaskStatusInProgress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old tasks: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RecordAudit inserts an audit log entry and fills in its ID
+func (s *PostgresStorage) RecordAudit(ctx context.Context, entry *model.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (user_id, chat_id, action, payload, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING id`
+
+	err := s.pool.QueryRow(ctx, query,
+		entry.UserID,
+		entry.ChatID,
+		entry.Action,
+		entry.Payload,
+		entry.CreatedAt,
+	).Scan(&entry.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// AddOutboxMessage saves a reply to deliver once Telegram is reachable again
+func (s *PostgresStorage) AddOutboxMessage(ctx context.Context, msg *model.OutboxMessage) error {
+	query := `
+		INSERT INTO telegram_outbox (task_id, task_reply, chat_id, thread_id, reply_to, parse_mode, text, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	err := s.pool.QueryRow(ctx, query,
+		msg.TaskID,
+		msg.TaskReply,
+		msg.ChatID,
+		msg.ThreadID,
+		msg.ReplyTo,
+		msg.ParseMode,
+		msg.Text,
+		msg.CreatedAt,
+	).Scan(&msg.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to add outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// LeaseOutboxMessages returns up to limit of the oldest saved replies and
+// hides them from other workers for lease. A reply stays in the outbox until
+// DeleteOutboxMessage removes it after delivery, so one whose worker stopped
+// before sending it is taken again once the lease runs out.
+func (s *PostgresStorage) LeaseOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	query := `
+		WITH leased AS (
+			UPDATE telegram_outbox
+			SET leased_until = NOW() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT id
+				FROM telegram_outbox
+				WHERE leased_until IS NULL OR leased_until < NOW()
+				ORDER BY created_at ASC, id ASC
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, task_id, task_reply, chat_id, thread_id, reply_to, parse_mode, text, created_at
+		)
+		SELECT * FROM leased ORDER BY created_at ASC, id ASC`
+
+	rows, err := s.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*model.OutboxMessage
+	for rows.Next() {
+		var msg model.OutboxMessage
+		err := rows.Scan(
+			&msg.ID,
+			&msg.TaskID,
+			&msg.TaskReply,
+			&msg.ChatID,
+			&msg.ThreadID,
+			&msg.ReplyTo,
+			&msg.ParseMode,
+			&msg.Text,
+			&msg.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate outbox messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// DeleteOutboxMessage removes a delivered reply. A reply already gone with
+// its task is not an error.
+func (s *PostgresStorage) DeleteOutboxMessage(ctx context.Context, id int64) error {
+	query := `DELETE FROM telegram_outbox WHERE id = $1`
+
+	if _, err := s.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseOutboxMessages ends the lease of replies that couldn't be sent, so
+// the next flush takes them right away
+func (s *PostgresStorage) ReleaseOutboxMessages(ctx context.Context, ids []int64) error {
+	query := `UPDATE telegram_outbox SET leased_until = NULL WHERE id = ANY($1)`
+
+	if _, err := s.pool.Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to release outbox messages: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteOutboxMessagesOlderThan deletes unsent replies saved before cutoff and
+// returns how many were deleted
+func (s *PostgresStorage) DeleteOutboxMessagesOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM telegram_outbox WHERE created_at < $1`
+
+	tag, err := s.pool.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old outbox messages: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// GetChatPreferences retrieves a chat's preferences. Chats that never saved any
+// get empty preferences, so every setting falls back to configuration.
+func (s *PostgresStorage) GetChatPreferences(ctx context.Context, chatID int64) (*model.ChatPreferences, error) {
+	query := `
+		SELECT preferences, updated_at
+		FROM chat_preferences
+		WHERE chat_id = $1`
+
+	var data []byte
+	prefs := model.ChatPreferences{ChatID: chatID}
+	err := s.pool.QueryRow(ctx, query, chatID).Scan(&data, &prefs.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return &prefs, nil
+		}
+		return nil, fmt.Errorf("failed to get chat preferences: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to decode chat preferences: %w", err)
+	}
+
+	return &prefs, nil
+}
+
+// SaveChatPreferences inserts or replaces a chat's preferences
+func (s *PostgresStorage) SaveChatPreferences(ctx context.Context, prefs *model.ChatPreferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to encode chat preferences: %w", err)
+	}
+
+	query := `
+		INSERT INTO chat_preferences (chat_id, preferences, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET preferences = EXCLUDED.preferences, updated_at = EXCLUDED.updated_at`
+
+	if _, err := s.pool.Exec(ctx, query, prefs.ChatID, data, prefs.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save chat preferences: %w", err)
+	}
+
 	return nil
 }
 
-// GetTranscriptByTaskID retrieves a transcript by task ID
+// GetTranscriptByTaskID retrieves the latest transcript version of a task
 func (s *PostgresStorage) GetTranscriptByTaskID(ctx context.Context, taskID string) (*model.Transcript, error) {
 	query := `
-		SELECT id, task_id, text, raw_response, created_at
+		SELECT id, task_id, text, raw_response, version, created_at
 		FROM transcripts
-		WHERE task_id = $1`
+		WHERE task_id = $1
+		ORDER BY version DESC
+		LIMIT 1`
 
 	var transcript model.Transcript
 	row := s.pool.QueryRow(ctx, query, taskID)
@@ -383,12 +1040,13 @@ func (s *PostgresStorage) GetTranscriptByTaskID(ctx context.Context, taskID stri
 		&transcript.TaskID,
 		&transcript.Text,
 		&transcript.RawResponse,
+		&transcript.Version,
 		&transcript.CreatedAt,
 	)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("transcript not found")
+			return nil, ErrTranscriptNotFound
 		}
 		return nil, fmt.Errorf("failed to get transcript: %w", err)
 	}
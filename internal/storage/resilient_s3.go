@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+)
+
+// ErrStorageUnavailable is returned while the S3 circuit breaker is open.
+// The failure is temporary, so tasks hitting it should be retried later.
+var ErrStorageUnavailable = errors.New("object storage temporarily unavailable")
+
+// Retry timing for a single S3 operation
+const (
+	s3RetryInitialInterval = 200 * time.Millisecond
+	s3RetryMaxInterval     = 2 * time.Second
+)
+
+// BreakerOptions configures retries and the circuit breaker around S3 calls
+type BreakerOptions struct {
+	MaxFailures   uint32        // failed operations in a row that open the breaker
+	Timeout       time.Duration // how long the breaker stays open
+	RetryAttempts int           // attempts per operation, including the first
+}
+
+func (o BreakerOptions) withDefaults() BreakerOptions {
+	if o.MaxFailures == 0 {
+		o.MaxFailures = 5
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.RetryAttempts <= 0 {
+		o.RetryAttempts = 3
+	}
+	return o
+}
+
+// CallBudget is the longest an operation can take when every attempt runs into
+// operationTimeout: all attempts plus the backoff between them
+func (o BreakerOptions) CallBudget(operationTimeout time.Duration) time.Duration {
+	o = o.withDefaults()
+	budget := time.Duration(o.RetryAttempts) * operationTimeout
+	interval := s3RetryInitialInterval
+	for attempt := 1; attempt < o.RetryAttempts; attempt++ {
+		budget += interval
+		interval = min(interval*2, s3RetryMaxInterval)
+	}
+	return budget
+}
+
+// objectOps are the S3 operations guarded by ResilientS3
+type objectOps interface {
+	UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error)
+	DownloadFile(ctx context.Context, key string) ([]byte, error)
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// ResilientS3 retries uploads, downloads and deletes and stops calling
+// Object Storage while it keeps failing. Other S3Storage methods pass through.
+type ResilientS3 struct {
+	*S3Storage
+
+	ops     objectOps
+	breaker *resilience.CircuitBreaker
+	retry   *resilience.RetryConfig
+}
+
+// NewResilientS3 wraps an S3 storage with retries and a circuit breaker
+func NewResilientS3(s3 *S3Storage, opts BreakerOptions) *ResilientS3 {
+	return newResilientS3(s3, s3, opts)
+}
+
+func newResilientS3(s3 *S3Storage, ops objectOps, opts BreakerOptions) *ResilientS3 {
+	opts = opts.withDefaults()
+	return &ResilientS3{
+		S3Storage: s3,
+		ops:       ops,
+		breaker:   resilience.NewCircuitBreaker(opts.MaxFailures, opts.Timeout),
+		retry: &resilience.RetryConfig{
+			MaxAttempts:     opts.RetryAttempts,
+			InitialInterval: s3RetryInitialInterval,
+			MaxInterval:     s3RetryMaxInterval,
+			Multiplier:      2.0,
+		},
+	}
+}
+
+// CircuitBreaker returns the breaker guarding uploads, downloads and deletes
+func (r *ResilientS3) CircuitBreaker() *resilience.CircuitBreaker {
+	return r.breaker
+}
+
+// execute runs fn with retries; an operation that fails every attempt
+// counts as one failure towards opening the breaker
+func (r *ResilientS3) execute(ctx context.Context, retry *resilience.RetryConfig, op, key string, fn func() error) error {
+	attempt := 0
+	err := r.breaker.Execute(func() error {
+		return resilience.RetryWithExponentialBackoff(ctx, retry, func() error {
+			attempt++
+			err := fn()
+			if err != nil {
+				logger.Warn("S3 operation failed",
+					zap.String("op", op),
+					zap.String("key", key),
+					zap.Int("attempt", attempt),
+					zap.Error(err))
+			}
+			return err
+		})
+	})
+
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		return fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	return err
+}
+
+// UploadFile uploads a file, retrying when the body can be rewound
+func (r *ResilientS3) UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	retry := r.retry
+	seeker, rewindable := body.(io.Seeker)
+	if !rewindable {
+		// A consumed body can't be sent again
+		single := *r.retry
+		single.MaxAttempts = 1
+		retry = &single
+	}
+
+	var url string
+	err := r.execute(ctx, retry, "upload", key, func() error {
+		if rewindable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind upload body: %w", err)
+			}
+		}
+
+		var err error
+		url, err = r.ops.UploadFile(ctx, key, body, contentType)
+		return err
+	})
+	return url, err
+}
+
+// DownloadFile downloads a file with retries
+func (r *ResilientS3) DownloadFile(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := r.execute(ctx, r.retry, "download", key, func() error {
+		var err error
+		data, err = r.ops.DownloadFile(ctx, key)
+		return err
+	})
+	return data, err
+}
+
+// DeleteFile deletes a file with retries
+func (r *ResilientS3) DeleteFile(ctx context.Context, key string) error {
+	return r.execute(ctx, r.retry, "delete", key, func() error {
+		return r.ops.DeleteFile(ctx, key)
+	})
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"voxly/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyOps fails the first failures calls, then succeeds
+type flakyOps struct {
+	failures int
+	calls    int
+	bodies   []string
+}
+
+func (f *flakyOps) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("503 service unavailable")
+	}
+	return nil
+}
+
+func (f *flakyOps) UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	data, _ := io.ReadAll(body)
+	f.bodies = append(f.bodies, string(data))
+	if err := f.fail(); err != nil {
+		return "", err
+	}
+	return "https://storage.yandexcloud.net/voxly/" + key, nil
+}
+
+func (f *flakyOps) DownloadFile(ctx context.Context, key string) ([]byte, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return []byte("data"), nil
+}
+
+func (f *flakyOps) DeleteFile(ctx context.Context, key string) error {
+	return f.fail()
+}
+
+func newTestResilientS3(ops objectOps, opts BreakerOptions) *ResilientS3 {
+	r := newResilientS3(nil, ops, opts)
+	r.retry.InitialInterval = time.Millisecond
+	r.retry.MaxInterval = time.Millisecond
+	return r
+}
+
+func TestResilientS3_RetriesTransientFailures(t *testing.T) {
+	ops := &flakyOps{failures: 2}
+	r := newTestResilientS3(ops, BreakerOptions{RetryAttempts: 3})
+
+	url, err := r.UploadFile(context.Background(), "voice/a.ogg", bytes.NewReader([]byte("ogg")), "audio/ogg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.yandexcloud.net/voxly/voice/a.ogg", url)
+	// Every attempt sent the whole body
+	assert.Equal(t, []string{"ogg", "ogg", "ogg"}, ops.bodies)
+	assert.Equal(t, resilience.StateClosed, r.breaker.GetState())
+}
+
+func TestResilientS3_NonRewindableBodyIsNotRetried(t *testing.T) {
+	ops := &flakyOps{failures: 1}
+	r := newTestResilientS3(ops, BreakerOptions{RetryAttempts: 3})
+
+	_, err := r.UploadFile(context.Background(), "voice/a.ogg", io.MultiReader(strings.NewReader("ogg")), "audio/ogg")
+	assert.Error(t, err)
+	assert.Equal(t, 1, ops.calls)
+}
+
+func TestResilientS3_RepeatedFailuresOpenBreaker(t *testing.T) {
+	ops := &flakyOps{failures: 100}
+	r := newTestResilientS3(ops, BreakerOptions{MaxFailures: 2, Timeout: time.Hour, RetryAttempts: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := r.DownloadFile(ctx, "voice/a.ogg")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrStorageUnavailable)
+	}
+	assert.Equal(t, 4, ops.calls)
+	assert.Equal(t, resilience.StateOpen, r.breaker.GetState())
+
+	// While open, calls fail fast with a retryable error
+	err := r.DeleteFile(ctx, "voice/a.ogg")
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, 4, ops.calls)
+}
+
+func TestResilientS3_BreakerRecoversAfterTimeout(t *testing.T) {
+	ops := &flakyOps{failures: 1}
+	r := newTestResilientS3(ops, BreakerOptions{MaxFailures: 1, Timeout: 10 * time.Millisecond, RetryAttempts: 1})
+	ctx := context.Background()
+
+	assert.Error(t, r.DeleteFile(ctx, "voice/a.ogg"))
+	assert.ErrorIs(t, r.DeleteFile(ctx, "voice/a.ogg"), ErrStorageUnavailable)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, r.DeleteFile(ctx, "voice/a.ogg"))
+	assert.Equal(t, resilience.StateClosed, r.breaker.GetState())
+}
+
+func TestResilientS3_RetriesStalledAttemptWithinCallBudget(t *testing.T) {
+	var requests atomic.Int32
+	stalled := make(chan struct{})
+	opts := ClientOptions{RetryMode: "standard", MaxAttempts: 1, OperationTimeout: 100 * time.Millisecond}
+	raw := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if requests.Add(1) == 1 {
+			// The first attempt stalls until the operation timeout gives up on it
+			select {
+			case <-r.Context().Done():
+			case <-stalled:
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}, opts)
+	t.Cleanup(func() { close(stalled) })
+	breaker := BreakerOptions{RetryAttempts: 2}
+	r := NewResilientS3(raw, breaker)
+
+	// A call bounded by the budget outlives the stalled attempt
+	ctx, cancel := context.WithTimeout(context.Background(), breaker.CallBudget(opts.OperationTimeout)+time.Second)
+	defer cancel()
+	_, err := r.UploadFile(ctx, "voice/a.ogg", bytes.NewReader([]byte("ogg")), "audio/ogg")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestBreakerOptions_CallBudget(t *testing.T) {
+	opts := BreakerOptions{RetryAttempts: 5}
+	// 200ms, 400ms, 800ms and 1.6s of backoff between five attempts
+	assert.Equal(t, 5*time.Second+3*time.Second, opts.CallBudget(time.Second))
+}
@@ -1,27 +1,93 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"path"
 	"path/filepath"
 	"time"
 	"voxly/pkg/logger"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"go.uber.org/zap"
 )
 
+// Key prefixes for stored objects. Objects under ContentKeyPrefix are shared
+// by every task with the same audio, so the orphan cleaner leaves them alone.
+const (
+	VoiceKeyPrefix      = "voice"
+	TranscriptKeyPrefix = "transcripts"
+	ContentKeyPrefix    = "content"
+)
+
 type S3Storage struct {
-	client *s3.Client
-	bucket string
+	client    *s3.Client
+	multipart multipartAPI
+	bucket    string
+	// partSize is the multipart threshold and part size; zero sends every file in one request
+	partSize int64
+	// operationTimeout bounds each call, SDK retries included; zero leaves it to the caller's context
+	operationTimeout time.Duration
+}
+
+// ClientOptions tune how the SDK retries and bounds Object Storage calls
+type ClientOptions struct {
+	// RetryMode is "standard" or "adaptive"; adaptive also slows down on throttling
+	RetryMode string
+	// MaxAttempts counts the first try; zero keeps the SDK default
+	MaxAttempts      int
+	OperationTimeout time.Duration
+	// PartSize splits larger uploads into parts of this size; zero disables multipart uploads
+	PartSize int64
+}
+
+// newRetryer builds the SDK retryer for the configured mode
+func newRetryer(opts ClientOptions) (func() aws.Retryer, error) {
+	mode, err := aws.ParseRetryMode(opts.RetryMode)
+	if err != nil {
+		return nil, err
+	}
+
+	standard := func(o *retry.StandardOptions) {
+		if opts.MaxAttempts > 0 {
+			o.MaxAttempts = opts.MaxAttempts
+		}
+	}
+
+	if mode == aws.RetryModeAdaptive {
+		return func() aws.Retryer {
+			return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+				o.StandardOptions = append(o.StandardOptions, standard)
+			})
+		}, nil
+	}
+	return func() aws.Retryer {
+		return retry.NewStandard(standard)
+	}, nil
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
 }
 
 // NewS3Storage creates a new S3 storage client
-func NewS3Storage(endpoint, accessKey, secretKey, bucket string) (*S3Storage, error) {
+func NewS3Storage(endpoint, accessKey, secretKey, bucket string, opts ClientOptions) (*S3Storage, error) {
+	retryer, err := newRetryer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 retry mode: %w", err)
+	}
+
 	customResolver := aws.EndpointResolverWithOptionsFunc(
 		func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 			return aws.Endpoint{
@@ -37,6 +103,7 @@ func NewS3Storage(endpoint, accessKey, secretKey, bucket string) (*S3Storage, er
 			credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
 		),
 		config.WithRegion("ru-central1"),
+		config.WithRetryer(retryer),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load S3 config: %w", err)
@@ -46,16 +113,51 @@ func NewS3Storage(endpoint, accessKey, secretKey, bucket string) (*S3Storage, er
 		o.UsePathStyle = true
 	})
 
-	logger.Info("S3 storage initialized", zap.String("bucket", bucket))
+	logger.Info("S3 storage initialized",
+		zap.String("bucket", bucket),
+		zap.String("retry_mode", opts.RetryMode),
+		zap.Duration("operation_timeout", opts.OperationTimeout),
+		zap.Int64("part_size", opts.PartSize))
 
 	return &S3Storage{
-		client: client,
-		bucket: bucket,
+		client:           client,
+		multipart:        client,
+		bucket:           bucket,
+		partSize:         opts.PartSize,
+		operationTimeout: opts.OperationTimeout,
 	}, nil
 }
 
-// UploadFile uploads a file to S3
+// withOperationTimeout bounds a single call with the configured timeout
+func (s *S3Storage) withOperationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.operationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.operationTimeout)
+}
+
+// UploadFile uploads a file to S3. Files of at least the part size are sent
+// as a multipart upload, which is aborted if any part fails.
 func (s *S3Storage) UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if s.partSize > 0 {
+		first, err := readPart(body, s.partSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if int64(len(first)) == s.partSize {
+			if err := s.uploadMultipart(ctx, key, contentType, first, body); err != nil {
+				return "", fmt.Errorf("failed to upload file: %w", err)
+			}
+			return s.uploaded(key), nil
+		}
+		// The whole file fit below the threshold
+		body = bytes.NewReader(first)
+	}
+
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(key),
@@ -67,24 +169,48 @@ func (s *S3Storage) UploadFile(ctx context.Context, key string, body io.Reader,
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 
-	// Generate public URL (Yandex Object Storage format)
-	url := fmt.Sprintf("https://storage.yandexcloud.net/%s/%s", s.bucket, key)
+	return s.uploaded(key), nil
+}
+
+// uploaded logs a finished upload and returns the object URL
+func (s *S3Storage) uploaded(key string) string {
+	url := s.ObjectURL(key)
 
 	logger.Info("File uploaded to S3",
 		zap.String("key", key),
 		zap.String("url", url))
 
-	return url, nil
+	return url
 }
 
 // GenerateKey generates a unique key for S3 object
 func (s *S3Storage) GenerateKey(taskID, extension string) string {
 	timestamp := time.Now().Format("2006/01/02")
-	return filepath.Join("voice", timestamp, fmt.Sprintf("%s%s", taskID, extension))
+	return filepath.Join(VoiceKeyPrefix, timestamp, fmt.Sprintf("%s%s", taskID, extension))
+}
+
+// GenerateContentKey generates a key derived from a hash of the file bytes,
+// so the same file always maps to the same object
+func (s *S3Storage) GenerateContentKey(fileHash, extension string) string {
+	return path.Join(ContentKeyPrefix, fileHash+extension)
+}
+
+// ObjectURL returns the public URL of an object (Yandex Object Storage format)
+func (s *S3Storage) ObjectURL(key string) string {
+	return fmt.Sprintf("https://storage.yandexcloud.net/%s/%s", s.bucket, key)
+}
+
+// GenerateTranscriptKey generates a key for a transcript backup object
+func (s *S3Storage) GenerateTranscriptKey(taskID, extension string) string {
+	timestamp := time.Now().Format("2006/01/02")
+	return filepath.Join(TranscriptKeyPrefix, timestamp, fmt.Sprintf("%s%s", taskID, extension))
 }
 
 // DownloadFile downloads a file from S3
 func (s *S3Storage) DownloadFile(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -108,6 +234,9 @@ func (s *S3Storage) DownloadFile(ctx context.Context, key string) ([]byte, error
 
 // DeleteFile deletes a file from S3
 func (s *S3Storage) DeleteFile(ctx context.Context, key string) error {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -121,3 +250,60 @@ func (s *S3Storage) DeleteFile(ctx context.Context, key string) error {
 
 	return nil
 }
+
+// ListObjects lists all objects under the given prefix
+func (s *S3Storage) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var objects []ObjectInfo
+	for paginator.HasMorePages() {
+		page, err := s.nextPage(ctx, paginator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	logger.Debug("Listed S3 objects",
+		zap.String("prefix", prefix),
+		zap.Int("count", len(objects)))
+
+	return objects, nil
+}
+
+// nextPage fetches one listing page, each bounded by the operation timeout
+func (s *S3Storage) nextPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+	return paginator.NextPage(ctx)
+}
+
+// ObjectExists checks whether an object with the given key exists
+func (s *S3Storage) ObjectExists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object: %w", err)
+	}
+
+	return true, nil
+}
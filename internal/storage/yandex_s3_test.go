@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestS3Storage points the real SDK client at a stub S3 endpoint
+func newTestS3Storage(t *testing.T, handler http.HandlerFunc) *S3Storage {
+	return newTestS3StorageWithOptions(t, handler, ClientOptions{RetryMode: "standard"})
+}
+
+func newTestS3StorageWithOptions(t *testing.T, handler http.HandlerFunc, opts ClientOptions) *S3Storage {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	s3Storage, err := NewS3Storage(server.URL, "access", "secret", "voxly", opts)
+	assert.NoError(t, err)
+	return s3Storage
+}
+
+const listPageTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>voxly</Name>
+  <Prefix>voice/</Prefix>
+  <KeyCount>1</KeyCount>
+  <IsTruncated>%t</IsTruncated>
+  <NextContinuationToken>%s</NextContinuationToken>
+  <Contents>
+    <Key>%s</Key>
+    <LastModified>2025-10-07T12:00:00.000Z</LastModified>
+    <Size>%d</Size>
+  </Contents>
+</ListBucketResult>`
+
+func TestS3Storage_ListObjectsPaginates(t *testing.T) {
+	var requests int
+	s3Storage := newTestS3Storage(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/voxly", r.URL.Path)
+		assert.Equal(t, "voice/", r.URL.Query().Get("prefix"))
+
+		w.Header().Set("Content-Type", "application/xml")
+		if r.URL.Query().Get("continuation-token") == "" {
+			fmt.Fprintf(w, listPageTemplate, true, "page-2", "voice/2025/10/07/task-1.ogg", 100)
+			return
+		}
+		assert.Equal(t, "page-2", r.URL.Query().Get("continuation-token"))
+		fmt.Fprintf(w, listPageTemplate, false, "", "voice/2025/10/07/task-2.ogg", 200)
+	})
+
+	objects, err := s3Storage.ListObjects(context.Background(), "voice/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Len(t, objects, 2)
+
+	assert.Equal(t, "voice/2025/10/07/task-1.ogg", objects[0].Key)
+	assert.Equal(t, int64(100), objects[0].Size)
+	assert.Equal(t, time.Date(2025, 10, 7, 12, 0, 0, 0, time.UTC), objects[0].LastModified)
+	assert.Equal(t, "voice/2025/10/07/task-2.ogg", objects[1].Key)
+	assert.Equal(t, int64(200), objects[1].Size)
+}
+
+func TestS3Storage_ObjectExists(t *testing.T) {
+	s3Storage := newTestS3Storage(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/present.ogg"):
+			w.Header().Set("Content-Length", "10")
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/missing.ogg"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+	ctx := context.Background()
+
+	exists, err := s3Storage.ObjectExists(ctx, "voice/present.ogg")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = s3Storage.ObjectExists(ctx, "voice/missing.ogg")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s3Storage.ObjectExists(ctx, "voice/forbidden.ogg")
+	assert.Error(t, err)
+}
+
+func TestS3Storage_GenerateContentKey(t *testing.T) {
+	s3Storage := newTestS3Storage(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	key := s3Storage.GenerateContentKey("3a7bd3e2", ".ogg")
+	assert.Equal(t, "content/3a7bd3e2.ogg", key)
+	// The same content always gets the same key, unlike task keys
+	assert.Equal(t, key, s3Storage.GenerateContentKey("3a7bd3e2", ".ogg"))
+	assert.NotEqual(t, key, s3Storage.GenerateContentKey("9f86d081", ".ogg"))
+
+	assert.Equal(t, "https://storage.yandexcloud.net/voxly/content/3a7bd3e2.ogg", s3Storage.ObjectURL(key))
+}
+
+func TestNewS3Storage_AppliesRetryOptions(t *testing.T) {
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {}, ClientOptions{
+		RetryMode:        "adaptive",
+		MaxAttempts:      5,
+		OperationTimeout: 10 * time.Second,
+	})
+
+	retryer := s3Storage.client.Options().Retryer
+	assert.IsType(t, &retry.AdaptiveMode{}, retryer)
+	assert.Equal(t, 5, retryer.MaxAttempts())
+	assert.Equal(t, 10*time.Second, s3Storage.operationTimeout)
+}
+
+func TestNewS3Storage_StandardRetryKeepsDefaultAttempts(t *testing.T) {
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {}, ClientOptions{RetryMode: "standard"})
+
+	retryer := s3Storage.client.Options().Retryer
+	assert.IsType(t, &retry.Standard{}, retryer)
+	assert.Equal(t, retry.DefaultMaxAttempts, retryer.MaxAttempts())
+}
+
+func TestNewS3Storage_RejectsUnknownRetryMode(t *testing.T) {
+	_, err := NewS3Storage("http://localhost", "access", "secret", "voxly", ClientOptions{RetryMode: "eager"})
+	assert.Error(t, err)
+}
+
+func TestS3Storage_RetriesTransientErrors(t *testing.T) {
+	var requests int
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, ClientOptions{RetryMode: "standard", MaxAttempts: 3})
+
+	err := s3Storage.DeleteFile(context.Background(), "voice/task-1.ogg")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestS3Storage_OperationTimeout(t *testing.T) {
+	s3Storage := newTestS3StorageWithOptions(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}, ClientOptions{RetryMode: "standard", MaxAttempts: 1, OperationTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := s3Storage.ObjectExists(context.Background(), "voice/task-1.ogg")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"voxly/internal/queue"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed with "sha256="
+const SignatureHeader = "X-Voxly-Signature"
+
+// DefaultRequestTimeout bounds a single delivery attempt
+const DefaultRequestTimeout = 10 * time.Second
+
+// DefaultQueueSize bounds how many transcripts wait for delivery
+const DefaultQueueSize = 100
+
+// Options configure webhook delivery
+type Options struct {
+	URL            string
+	Secret         string // signs the body when set
+	RetryAttempts  int
+	RequestTimeout time.Duration
+	QueueSize      int
+}
+
+// Sender POSTs transcription results to an external endpoint
+type Sender struct {
+	url            string
+	secret         []byte
+	client         *http.Client
+	retry          *resilience.RetryConfig
+	requestTimeout time.Duration
+	pending        chan *queue.TranscriptionResult
+}
+
+// NewSender creates a webhook sender. httpClient is shared with other
+// integrations; nil uses http.DefaultClient.
+func NewSender(opts Options, httpClient *http.Client) *Sender {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = DefaultRequestTimeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+
+	retry := resilience.DefaultRetryConfig()
+	if opts.RetryAttempts > 0 {
+		retry.MaxAttempts = opts.RetryAttempts
+	}
+
+	return &Sender{
+		url:            opts.URL,
+		secret:         []byte(opts.Secret),
+		client:         httpClient,
+		retry:          retry,
+		requestTimeout: opts.RequestTimeout,
+		pending:        make(chan *queue.TranscriptionResult, opts.QueueSize),
+	}
+}
+
+// Sign returns the signature header value for body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers result, retrying network errors and non-2xx responses
+func (s *Sender) Send(ctx context.Context, result *queue.TranscriptionResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	return resilience.RetryWithExponentialBackoff(ctx, s.retry, func() error {
+		return s.post(ctx, body)
+	})
+}
+
+// post makes a single delivery attempt
+func (s *Sender) post(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook rejected: status=%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Hook queues every completed transcript for Run to deliver, so a slow endpoint
+// doesn't hold up the task. When the queue is full the transcript is dropped.
+// Register it with Processor.OnComplete.
+func (s *Sender) Hook(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+	result := &queue.TranscriptionResult{
+		TaskID:      task.ID,
+		Text:        transcript.Text,
+		RawResponse: transcript.RawResponse,
+		Success:     true,
+	}
+
+	select {
+	case s.pending <- result:
+	default:
+		logger.Error("Webhook queue is full, dropping delivery",
+			zap.String("task_id", task.ID),
+			zap.Int("queue_size", cap(s.pending)))
+	}
+}
+
+// Run delivers queued transcripts one at a time until ctx is cancelled.
+// Transcripts still queued at that point are not delivered.
+func (s *Sender) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			if len(s.pending) > 0 {
+				logger.Warn("Webhook deliveries dropped at shutdown", zap.Int("pending", len(s.pending)))
+			}
+			return
+		case result := <-s.pending:
+			s.deliver(ctx, result)
+		}
+	}
+}
+
+// deliver sends a queued transcript, logging the outcome
+func (s *Sender) deliver(ctx context.Context, result *queue.TranscriptionResult) {
+	if err := s.Send(ctx, result); err != nil {
+		logger.Error("Failed to deliver webhook",
+			zap.String("task_id", result.TaskID),
+			zap.Error(err))
+		return
+	}
+
+	logger.Debug("Webhook delivered", zap.String("task_id", result.TaskID))
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"voxly/internal/queue"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSender creates a sender that retries without waiting
+func newTestSender(url, secret string, attempts int) *Sender {
+	s := NewSender(Options{URL: url, Secret: secret, RetryAttempts: attempts}, nil)
+	s.retry.InitialInterval = time.Millisecond
+	s.retry.MaxInterval = time.Millisecond
+	return s
+}
+
+func TestSender_SendSignsPayload(t *testing.T) {
+	var gotBody []byte
+	var gotSignature, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotContentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := newTestSender(server.URL, "top-secret", 1)
+	err := s.Send(context.Background(), &queue.TranscriptionResult{TaskID: "task-1", Text: "Привет", Success: true})
+	assert.NoError(t, err)
+
+	var result queue.TranscriptionResult
+	assert.NoError(t, json.Unmarshal(gotBody, &result))
+	assert.Equal(t, "task-1", result.TaskID)
+	assert.Equal(t, "Привет", result.Text)
+	assert.True(t, result.Success)
+
+	assert.Equal(t, "application/json", gotContentType)
+	assert.Equal(t, Sign([]byte("top-secret"), gotBody), gotSignature)
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", gotSignature)
+}
+
+func TestSender_SendWithoutSecret(t *testing.T) {
+	var signed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, signed = r.Header[SignatureHeader]
+	}))
+	defer server.Close()
+
+	assert.NoError(t, newTestSender(server.URL, "", 1).Send(context.Background(), &queue.TranscriptionResult{TaskID: "task-1"}))
+	assert.False(t, signed)
+}
+
+func TestSender_SendRetriesNon2xx(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := newTestSender(server.URL, "secret", 3).Send(context.Background(), &queue.TranscriptionResult{TaskID: "task-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestSender_SendGivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := newTestSender(server.URL, "secret", 2).Send(context.Background(), &queue.TranscriptionResult{TaskID: "task-1"})
+	assert.ErrorContains(t, err, "status=400")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestSender_Hook(t *testing.T) {
+	received := make(chan queue.TranscriptionResult, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result queue.TranscriptionResult
+		json.NewDecoder(r.Body).Decode(&result)
+		received <- result
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newTestSender(server.URL, "secret", 1)
+	go s.Run(ctx)
+
+	s.Hook(context.Background(), &model.Task{ID: "task-1"}, &model.Transcript{
+		TaskID:      "task-1",
+		Text:        "Привет",
+		RawResponse: json.RawMessage(`{"chunks":[]}`),
+	})
+
+	select {
+	case result := <-received:
+		assert.Equal(t, "task-1", result.TaskID)
+		assert.Equal(t, "Привет", result.Text)
+		assert.JSONEq(t, `{"chunks":[]}`, string(result.RawResponse))
+		assert.True(t, result.Success)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestSender_HookDoesNotWaitForEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewSender(Options{URL: server.URL, RetryAttempts: 1, QueueSize: 1}, nil)
+	go s.Run(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		// One delivery in flight, one queued, the rest dropped
+		for i := 0; i < 5; i++ {
+			s.Hook(context.Background(), &model.Task{ID: "task-1"}, &model.Transcript{Text: "Привет"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Hook blocked on a slow endpoint")
+	}
+}
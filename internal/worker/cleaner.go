@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+	"voxly/internal/storage"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// ObjectCleaner lists and deletes stored audio objects
+type ObjectCleaner interface {
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// StaleUploadAborter is implemented by storages that can abort multipart
+// uploads left unfinished, like storage.S3Storage
+type StaleUploadAborter interface {
+	AbortStaleUploads(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+// Cleaner periodically removes S3 audio left behind by failed, timed out or
+// deleted tasks, along with unfinished multipart uploads
+type Cleaner struct {
+	s3        ObjectCleaner
+	db        TaskStore
+	retention time.Duration
+	interval  time.Duration
+	// staleUploadAge is how long a multipart upload may stay unfinished; zero keeps them
+	staleUploadAge time.Duration
+	// callTimeout bounds each listing and delete; zero leaves them to the job's context
+	callTimeout time.Duration
+}
+
+// NewCleaner creates a new orphaned object cleaner
+func NewCleaner(s3 ObjectCleaner, db TaskStore, retention, interval, staleUploadAge time.Duration) *Cleaner {
+	return &Cleaner{
+		s3:             s3,
+		db:             db,
+		retention:      retention,
+		interval:       interval,
+		staleUploadAge: staleUploadAge,
+	}
+}
+
+// BoundCalls gives each S3 listing and delete its own timeout. Call it before Run.
+func (c *Cleaner) BoundCalls(timeout time.Duration) {
+	c.callTimeout = timeout
+}
+
+// Run cleans up orphaned objects on every tick until ctx is cancelled
+func (c *Cleaner) Run(ctx context.Context) {
+	if c.interval <= 0 {
+		logger.Info("S3 orphan cleanup disabled")
+		return
+	}
+
+	logger.Info("Starting S3 orphan cleanup",
+		zap.Duration("interval", c.interval),
+		zap.Duration("retention", c.retention),
+		zap.Duration("stale_upload_age", c.staleUploadAge))
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Cleanup(ctx); err != nil {
+				logger.Error("S3 orphan cleanup failed", zap.Error(err))
+			}
+			if _, err := c.AbortStaleUploads(ctx); err != nil {
+				logger.Error("Stale multipart upload cleanup failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Cleanup deletes expired objects whose task failed or no longer exists
+func (c *Cleaner) Cleanup(ctx context.Context) (int, error) {
+	objects, err := listObjects(ctx, c.s3, storage.VoiceKeyPrefix+"/", c.callTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	deleted := 0
+
+	for _, obj := range objects {
+		// Skip the DB lookup for objects that are still within retention
+		if now.Sub(obj.LastModified) < c.retention {
+			continue
+		}
+
+		task, err := c.db.GetTaskByID(ctx, taskIDFromKey(obj.Key))
+		if err != nil && !errors.Is(err, storage.ErrTaskNotFound) {
+			logger.Error("Failed to look up task for S3 object",
+				zap.String("key", obj.Key),
+				zap.Error(err))
+			continue
+		}
+
+		if !shouldDeleteObject(obj, task, now, c.retention) {
+			continue
+		}
+
+		if err := deleteObject(ctx, c.s3, obj.Key, c.callTimeout); err != nil {
+			logger.Error("Failed to delete orphaned S3 object",
+				zap.String("key", obj.Key),
+				zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+
+	logger.Info("S3 orphan cleanup completed",
+		zap.Int("scanned", len(objects)),
+		zap.Int("deleted", deleted))
+
+	return deleted, nil
+}
+
+// AbortStaleUploads aborts multipart uploads unfinished for longer than the
+// stale upload age, if the storage supports it
+func (c *Cleaner) AbortStaleUploads(ctx context.Context) (int, error) {
+	aborter, ok := c.s3.(StaleUploadAborter)
+	if !ok || c.staleUploadAge <= 0 {
+		return 0, nil
+	}
+	return aborter.AbortStaleUploads(ctx, c.staleUploadAge)
+}
+
+// withCallTimeout bounds a single S3 call of a background job; zero leaves it to ctx
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// listObjects lists objects under prefix within timeout
+func listObjects(ctx context.Context, s3 ObjectCleaner, prefix string, timeout time.Duration) ([]storage.ObjectInfo, error) {
+	ctx, cancel := withCallTimeout(ctx, timeout)
+	defer cancel()
+
+	return s3.ListObjects(ctx, prefix)
+}
+
+// deleteObject deletes an object within timeout
+func deleteObject(ctx context.Context, s3 ObjectCleaner, key string, timeout time.Duration) error {
+	ctx, cancel := withCallTimeout(ctx, timeout)
+	defer cancel()
+
+	return s3.DeleteFile(ctx, key)
+}
+
+// shouldDeleteObject reports whether an object is past retention and its task
+// is either missing (task == nil), failed or timed out
+func shouldDeleteObject(obj storage.ObjectInfo, task *model.Task, now time.Time, retention time.Duration) bool {
+	if now.Sub(obj.LastModified) < retention {
+		return false
+	}
+	return task == nil || task.Status == model.TaskStatusFailed || task.Status == model.TaskStatusTimeout
+}
+
+// taskIDFromKey extracts the task ID from a key like voice/2025/10/07/<task_id>.ogg
+// or, for a segment of long audio, voice/2025/10/07/<task_id>.part000.ogg
+func taskIDFromKey(key string) string {
+	id, _, _ := strings.Cut(path.Base(key), ".")
+	return id
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+	"voxly/internal/storage"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldDeleteObject(t *testing.T) {
+	now := time.Now()
+	retention := 24 * time.Hour
+	old := storage.ObjectInfo{Key: "voice/2025/10/01/task-1.ogg", LastModified: now.Add(-48 * time.Hour)}
+	fresh := storage.ObjectInfo{Key: "voice/2025/10/07/task-2.ogg", LastModified: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name     string
+		obj      storage.ObjectInfo
+		task     *model.Task
+		expected bool
+	}{
+		{"old object of missing task", old, nil, true},
+		{"old object of failed task", old, &model.Task{Status: model.TaskStatusFailed}, true},
+		{"old object of timed out task", old, &model.Task{Status: model.TaskStatusTimeout}, true},
+		{"old object of done task", old, &model.Task{Status: model.TaskStatusDone}, false},
+		{"old object of queued task", old, &model.Task{Status: model.TaskStatusQueued}, false},
+		{"fresh object of missing task", fresh, nil, false},
+		{"fresh object of failed task", fresh, &model.Task{Status: model.TaskStatusFailed}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, shouldDeleteObject(tt.obj, tt.task, now, retention))
+		})
+	}
+}
+
+func TestTaskIDFromKey(t *testing.T) {
+	assert.Equal(t, "task-123", taskIDFromKey("voice/2025/10/07/task-123.ogg"))
+	assert.Equal(t, "task-123", taskIDFromKey("task-123"))
+	assert.Equal(t, "task-123", taskIDFromKey("voice/2025/10/07/task-123.part002.ogg"))
+}
+
+func TestCleaner_Cleanup(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockDB := new(MockDB)
+	ctx := context.Background()
+	now := time.Now()
+
+	objects := []storage.ObjectInfo{
+		{Key: "voice/2025/10/01/failed.ogg", LastModified: now.Add(-10 * 24 * time.Hour)},
+		{Key: "voice/2025/10/01/missing.ogg", LastModified: now.Add(-10 * 24 * time.Hour)},
+		{Key: "voice/2025/10/01/done.ogg", LastModified: now.Add(-10 * 24 * time.Hour)},
+		// Segments of long audio belong to their task like the whole file
+		{Key: "voice/2025/10/01/done.part000.ogg", LastModified: now.Add(-10 * 24 * time.Hour)},
+		{Key: "voice/2025/10/01/failed.part001.ogg", LastModified: now.Add(-10 * 24 * time.Hour)},
+		{Key: "voice/2025/10/09/recent.ogg", LastModified: now.Add(-time.Hour)},
+	}
+
+	mockS3.On("ListObjects", ctx, "voice/").Return(objects, nil)
+	mockDB.On("GetTaskByID", ctx, "failed").Return(&model.Task{ID: "failed", Status: model.TaskStatusFailed}, nil)
+	mockDB.On("GetTaskByID", ctx, "missing").Return(nil, storage.ErrTaskNotFound)
+	mockDB.On("GetTaskByID", ctx, "done").Return(&model.Task{ID: "done", Status: model.TaskStatusDone}, nil)
+	mockS3.On("DeleteFile", ctx, "voice/2025/10/01/failed.ogg").Return(nil)
+	mockS3.On("DeleteFile", ctx, "voice/2025/10/01/missing.ogg").Return(nil)
+	mockS3.On("DeleteFile", ctx, "voice/2025/10/01/failed.part001.ogg").Return(nil)
+
+	cleaner := NewCleaner(mockS3, mockDB, 7*24*time.Hour, time.Hour, 24*time.Hour)
+	deleted, err := cleaner.Cleanup(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	mockS3.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "GetTaskByID", ctx, "recent")
+}
+
+// abortingS3 is an S3 mock that can also abort stale multipart uploads
+type abortingS3 struct {
+	*MockS3
+	olderThan time.Duration
+}
+
+func (s *abortingS3) AbortStaleUploads(ctx context.Context, olderThan time.Duration) (int, error) {
+	s.olderThan = olderThan
+	return 2, nil
+}
+
+func TestCleaner_AbortStaleUploads(t *testing.T) {
+	s3 := &abortingS3{MockS3: new(MockS3)}
+
+	aborted, err := NewCleaner(s3, new(MockDB), time.Hour, time.Hour, 24*time.Hour).AbortStaleUploads(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, aborted)
+	assert.Equal(t, 24*time.Hour, s3.olderThan)
+
+	// Disabled, or not supported by the storage
+	s3.olderThan = 0
+	aborted, err = NewCleaner(s3, new(MockDB), time.Hour, time.Hour, 0).AbortStaleUploads(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, aborted)
+	assert.Zero(t, s3.olderThan)
+
+	aborted, err = NewCleaner(new(MockS3), new(MockDB), time.Hour, time.Hour, 24*time.Hour).AbortStaleUploads(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, aborted)
+}
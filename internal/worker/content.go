@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// ContentStore keeps audio under keys derived from its bytes, so a file sent
+// again is found in storage instead of being uploaded twice
+type ContentStore interface {
+	GenerateContentKey(fileHash, extension string) string
+	ObjectExists(ctx context.Context, key string) (bool, error)
+	ObjectURL(key string) string
+}
+
+// storeAudio uploads a task's audio, records its key on the task and returns
+// its URL. With content keys enabled, audio already in storage is reused
+// without an upload.
+func (p *Processor) storeAudio(ctx context.Context, log *zap.Logger, task *model.Task, data []byte) (string, error) {
+	content, ok := p.s3.(ContentStore)
+	if !p.cfg.S3.ContentKeys || !ok {
+		key := p.s3.GenerateKey(task.ID, ".ogg")
+		task.AddAudioKeys(key)
+		return p.uploadFile(ctx, key, bytes.NewReader(data), "audio/ogg")
+	}
+
+	sum := sha256.Sum256(data)
+	key := content.GenerateContentKey(hex.EncodeToString(sum[:]), ".ogg")
+	task.AddAudioKeys(key)
+
+	exists, err := p.objectExists(ctx, content, key)
+	if err != nil {
+		// Uploading again is only wasteful, so don't fail the task over it
+		log.Warn("Failed to check for stored audio, uploading it", zap.String("key", key), zap.Error(err))
+	}
+	if exists {
+		log.Info("Audio already in S3, skipping upload", zap.String("key", key))
+		return content.ObjectURL(key), nil
+	}
+
+	return p.uploadFile(ctx, key, bytes.NewReader(data), "audio/ogg")
+}
+
+// objectExists checks for a stored object within the S3 call timeout
+func (p *Processor) objectExists(ctx context.Context, content ContentStore, key string) (bool, error) {
+	ctx, cancel := p.s3Context(ctx)
+	defer cancel()
+
+	return content.ObjectExists(ctx, key)
+}
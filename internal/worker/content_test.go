@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func contentKeysProcessor(mockS3 *MockS3) *Processor {
+	cfg := testConfig()
+	cfg.S3.ContentKeys = true
+	return NewProcessor(cfg, new(MockDB), mockS3, new(MockSpeechKit), nil, new(MockCache), nil)
+}
+
+func TestProcessor_StoreAudioSkipsStoredContent(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateContentKey", mock.AnythingOfType("string"), ".ogg").Return("content/abc.ogg")
+	mockS3.On("ObjectExists", mock.Anything, "content/abc.ogg").Return(true, nil)
+	mockS3.On("ObjectURL", "content/abc.ogg").Return("https://storage/content/abc.ogg")
+	p := contentKeysProcessor(mockS3)
+
+	task := &model.Task{ID: "task-123"}
+	url, err := p.storeAudio(context.Background(), logger.Logger, task, []byte("ogg-data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage/content/abc.ogg", url)
+	// The shared object is still the task's audio as far as /delete is concerned
+	assert.Equal(t, []string{"content/abc.ogg"}, task.AudioKeys())
+	mockS3.AssertNotCalled(t, "UploadFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessor_StoreAudioUploadsNewContent(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateContentKey", mock.AnythingOfType("string"), ".ogg").Return("content/abc.ogg")
+	mockS3.On("ObjectExists", mock.Anything, "content/abc.ogg").Return(false, nil)
+	mockS3.On("UploadFile", mock.Anything, "content/abc.ogg", mock.Anything, "audio/ogg").Return("https://storage/content/abc.ogg", nil)
+	p := contentKeysProcessor(mockS3)
+
+	url, err := p.storeAudio(context.Background(), logger.Logger, &model.Task{ID: "task-123"}, []byte("ogg-data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage/content/abc.ogg", url)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessor_StoreAudioUploadsWhenCheckFails(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateContentKey", mock.AnythingOfType("string"), ".ogg").Return("content/abc.ogg")
+	mockS3.On("ObjectExists", mock.Anything, "content/abc.ogg").Return(false, errors.New("access denied"))
+	mockS3.On("UploadFile", mock.Anything, "content/abc.ogg", mock.Anything, "audio/ogg").Return("https://storage/content/abc.ogg", nil)
+	p := contentKeysProcessor(mockS3)
+
+	_, err := p.storeAudio(context.Background(), logger.Logger, &model.Task{ID: "task-123"}, []byte("ogg-data"))
+	assert.NoError(t, err)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessor_StoreAudioUploadsWhenCheckStalls(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateContentKey", mock.AnythingOfType("string"), ".ogg").Return("content/abc.ogg")
+	mockS3.On("ObjectExists", mock.Anything, "content/abc.ogg").
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(false, context.DeadlineExceeded)
+	mockS3.On("UploadFile", mock.Anything, "content/abc.ogg", mock.Anything, "audio/ogg").Return("https://storage/content/abc.ogg", nil)
+	p := contentKeysProcessor(mockS3)
+	p.cfg.S3.CallTimeout = 20 * time.Millisecond
+
+	// The stalled lookup is abandoned at its own deadline
+	start := time.Now()
+	_, err := p.storeAudio(context.Background(), logger.Logger, &model.Task{ID: "task-123"}, []byte("ogg-data"))
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessor_StoreAudioKeysByContent(t *testing.T) {
+	var hashes []string
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateContentKey", mock.AnythingOfType("string"), ".ogg").
+		Run(func(args mock.Arguments) { hashes = append(hashes, args.String(0)) }).
+		Return("content/abc.ogg")
+	mockS3.On("ObjectExists", mock.Anything, "content/abc.ogg").Return(true, nil)
+	mockS3.On("ObjectURL", "content/abc.ogg").Return("https://storage/content/abc.ogg")
+	p := contentKeysProcessor(mockS3)
+
+	for _, data := range []string{"ogg-data", "ogg-data", "other-data"} {
+		_, err := p.storeAudio(context.Background(), logger.Logger, &model.Task{ID: "task-123"}, []byte(data))
+		assert.NoError(t, err)
+	}
+
+	// Equal bytes hash alike whatever the task
+	if assert.Len(t, hashes, 3) {
+		assert.Len(t, hashes[0], 64)
+		assert.Equal(t, hashes[0], hashes[1])
+		assert.NotEqual(t, hashes[0], hashes[2])
+	}
+}
+
+func TestProcessor_StoreAudioWithoutContentKeys(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return("https://storage/voice/task-123.ogg", nil)
+	p := NewProcessor(testConfig(), new(MockDB), mockS3, new(MockSpeechKit), nil, new(MockCache), nil)
+
+	url, err := p.storeAudio(context.Background(), logger.Logger, &model.Task{ID: "task-123"}, []byte("ogg-data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage/voice/task-123.ogg", url)
+	mockS3.AssertNotCalled(t, "ObjectExists", mock.Anything, mock.Anything)
+}
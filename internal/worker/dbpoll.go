@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+	"voxly/internal/config"
+	"voxly/internal/queue"
+	"voxly/internal/storage"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// QueuedTaskStore is the subset of the database used to take queued tasks
+type QueuedTaskStore interface {
+	ClaimNextTask(ctx context.Context, workerID string) (*model.Task, error)
+	UpdateTaskStatus(ctx context.Context, id string, status model.TaskStatus) error
+}
+
+// TaskHandler processes one queue message, like Processor.ProcessTask
+type TaskHandler func(taskData []byte) error
+
+// DBPoller takes queued tasks from the database, as a fallback to RabbitMQ or
+// instead of it. Tasks are claimed one at a time with ClaimNextTask, so workers
+// polling the same database don't process a task twice.
+type DBPoller struct {
+	db       QueuedTaskStore
+	handle   TaskHandler
+	workerID string
+	interval time.Duration
+	batch    int
+}
+
+// NewDBPoller creates a poller that claims tasks as workerID and passes them to
+// handle; batches below one mean one
+func NewDBPoller(db QueuedTaskStore, handle TaskHandler, workerID string, interval time.Duration, batch int) *DBPoller {
+	if batch < 1 {
+		batch = 1
+	}
+	return &DBPoller{
+		db:       db,
+		handle:   handle,
+		workerID: workerID,
+		interval: interval,
+		batch:    batch,
+	}
+}
+
+// Run polls for queued tasks on every tick until ctx is cancelled
+func (dp *DBPoller) Run(ctx context.Context) {
+	if dp.interval <= 0 {
+		logger.Info("Database task polling disabled")
+		return
+	}
+
+	logger.Info("Starting database task polling",
+		zap.String("worker_id", dp.workerID),
+		zap.Duration("interval", dp.interval),
+		zap.Int("batch", dp.batch))
+
+	ticker := time.NewTicker(dp.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := dp.Poll(ctx); err != nil {
+				logger.Error("Database task polling failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Poll claims and processes queued tasks until none are left or a batch is
+// done. It returns how many tasks this worker claimed.
+func (dp *DBPoller) Poll(ctx context.Context) (int, error) {
+	claimed := 0
+	for claimed < dp.batch && ctx.Err() == nil {
+		task, err := dp.db.ClaimNextTask(ctx, dp.workerID)
+		if errors.Is(err, storage.ErrNoQueuedTask) {
+			break
+		}
+		if err != nil {
+			return claimed, err
+		}
+
+		claimed++
+		// The next claim would likely take the same task again; it waits for the next tick
+		if requeued := dp.process(ctx, task); requeued {
+			break
+		}
+	}
+
+	return claimed, nil
+}
+
+// process runs the handler for a claimed task. Like a nacked queue message, a
+// task whose handler fails is put back for another attempt, and process reports
+// true. One whose handler panics is failed, so it can't crash the poller on
+// every claim.
+func (dp *DBPoller) process(ctx context.Context, task *model.Task) (requeued bool) {
+	log := logger.WithTask(task.ID)
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Task handler panicked",
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			if err := dp.db.UpdateTaskStatus(ctx, task.ID, model.TaskStatusFailed); err != nil {
+				log.Error("Failed to fail task after panic", zap.Error(err))
+			}
+		}
+	}()
+
+	// The processor takes the claim as its own instead of claiming the task again
+	voiceTask := queue.NewVoiceTask(task)
+	voiceTask.ClaimedBy = dp.workerID
+
+	data, err := json.Marshal(voiceTask)
+	if err != nil {
+		log.Error("Failed to marshal claimed task", zap.Error(err))
+		return false
+	}
+
+	if err := dp.handle(data); err != nil {
+		log.Warn("Task processing failed, putting it back in the queue", zap.Error(err))
+		if err := dp.db.UpdateTaskStatus(ctx, task.ID, model.TaskStatusQueued); err != nil {
+			log.Error("Failed to put task back in the queue", zap.Error(err))
+		}
+		return true
+	}
+	return false
+}
+
+// defaultStaleClaimAfter is how long a claim of a task without a deadline is
+// kept before another worker may take the task over
+const defaultStaleClaimAfter = time.Hour
+
+// claimTask loads the task of a delivery. A task polled from the database was
+// claimed by the poller already. With both task sources a queue delivery claims
+// it too, so a task both published and polled is processed once; with RabbitMQ
+// alone the queue hands each task to one worker and nothing is claimed. A claim
+// older than the task's deadline belongs to a worker that stopped and is taken
+// over; the Reclaimer publishes such tasks again.
+func (p *Processor) claimTask(ctx context.Context, voiceTask *queue.VoiceTask) (*model.Task, error) {
+	if voiceTask.ClaimedBy != "" || p.cfg.Worker.TaskSource != config.TaskSourceBoth {
+		return p.db.GetTaskByID(ctx, voiceTask.TaskID)
+	}
+
+	staleAfter := staleClaimAfter(voiceTask.Duration, p.cfg.Worker.TaskTimeoutMultiplier)
+	return p.db.ClaimTask(ctx, voiceTask.TaskID, p.workerID, p.maxAttempts(), staleAfter)
+}
+
+// staleClaimAfter is how long after its last update an in_progress task is
+// taken for abandoned: past its deadline, or defaultStaleClaimAfter without one
+func staleClaimAfter(durationSec int, multiplier float64) time.Duration {
+	staleAfter := taskTimeout(durationSec, multiplier)
+	if staleAfter == 0 {
+		staleAfter = defaultStaleClaimAfter
+	}
+	// Status updates after the deadline still touch the task
+	return staleAfter + time.Minute
+}
+
+// PublishTask puts the task back in the database queue, so background
+// recognition polls can retry tasks without RabbitMQ
+func (dp *DBPoller) PublishTask(task *queue.VoiceTask) error {
+	return dp.db.UpdateTaskStatus(context.Background(), task.TaskID, model.TaskStatusQueued)
+}
+
+// DefaultWorkerID identifies this process in task claims as host:pid
+func DefaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
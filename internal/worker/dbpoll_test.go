@@ -0,0 +1,351 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"voxly/internal/config"
+	"voxly/internal/queue"
+	"voxly/internal/speechkit"
+	"voxly/internal/storage"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// memoryTaskQueue is a QueuedTaskStore whose claims are atomic, like
+// PostgresStorage.ClaimNextTask
+type memoryTaskQueue struct {
+	mu    sync.Mutex
+	tasks []*model.Task
+}
+
+func (q *memoryTaskQueue) ClaimNextTask(ctx context.Context, workerID string) (*model.Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, task := range q.tasks {
+		if task.Status == model.TaskStatusQueued {
+			task.Status = model.TaskStatusInProgress
+			claimed := *task
+			return &claimed, nil
+		}
+	}
+	return nil, storage.ErrNoQueuedTask
+}
+
+func (q *memoryTaskQueue) UpdateTaskStatus(ctx context.Context, id string, status model.TaskStatus) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, task := range q.tasks {
+		if task.ID == id {
+			task.Status = status
+			return nil
+		}
+	}
+	return errors.New("task not found")
+}
+
+func (q *memoryTaskQueue) status(id string) model.TaskStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, task := range q.tasks {
+		if task.ID == id {
+			return task.Status
+		}
+	}
+	return ""
+}
+
+func newMemoryTaskQueue(n int) *memoryTaskQueue {
+	q := &memoryTaskQueue{}
+	for i := 0; i < n; i++ {
+		q.tasks = append(q.tasks, &model.Task{
+			ID:     fmt.Sprintf("task-%d", i),
+			ChatID: 42,
+			FileID: fmt.Sprintf("file-%d", i),
+			Status: model.TaskStatusQueued,
+			Meta:   model.JSONB{"voice_duration": 7, "mime_type": "audio/ogg"},
+		})
+	}
+	return q
+}
+
+func TestDBPoller_ConcurrentPollersProcessEachTaskOnce(t *testing.T) {
+	store := newMemoryTaskQueue(20)
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+	handle := func(data []byte) error {
+		var voiceTask queue.VoiceTask
+		assert.NoError(t, json.Unmarshal(data, &voiceTask))
+
+		mu.Lock()
+		processed[voiceTask.TaskID]++
+		mu.Unlock()
+
+		// Let the other pollers claim meanwhile
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			poller := NewDBPoller(store, handle, fmt.Sprintf("worker-%d", i), time.Second, 20)
+			_, err := poller.Poll(context.Background())
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, processed, 20)
+	for id, count := range processed {
+		assert.Equal(t, 1, count, id)
+	}
+}
+
+func TestDBPoller_PassesTaskMeta(t *testing.T) {
+	store := newMemoryTaskQueue(1)
+
+	var got queue.VoiceTask
+	poller := NewDBPoller(store, func(data []byte) error {
+		return json.Unmarshal(data, &got)
+	}, "worker-0", time.Second, 10)
+
+	claimed, err := poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	assert.Equal(t, "task-0", got.TaskID)
+	assert.Equal(t, "file-0", got.FileID)
+	assert.Equal(t, 7, got.Duration)
+	assert.Equal(t, "audio/ogg", got.MimeType)
+}
+
+func TestDBPoller_FailedTaskIsQueuedAgain(t *testing.T) {
+	store := newMemoryTaskQueue(1)
+
+	calls := 0
+	poller := NewDBPoller(store, func(data []byte) error {
+		calls++
+		if calls == 1 {
+			return errors.New("recognition failed")
+		}
+		return nil
+	}, "worker-0", time.Second, 10)
+
+	// The failed task waits for the next poll instead of being claimed again right away
+	claimed, err := poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	assert.Equal(t, model.TaskStatusQueued, store.status("task-0"))
+
+	claimed, err = poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDBPoller_PanickingHandlerFailsTask(t *testing.T) {
+	store := newMemoryTaskQueue(1)
+
+	poller := NewDBPoller(store, func(data []byte) error {
+		panic("boom")
+	}, "worker-0", time.Second, 10)
+
+	_, err := poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusFailed, store.status("task-0"))
+}
+
+func TestDBPoller_PublishTaskQueuesAgain(t *testing.T) {
+	store := newMemoryTaskQueue(1)
+	store.tasks[0].Status = model.TaskStatusFailed
+
+	poller := NewDBPoller(store, func(data []byte) error { return nil }, "worker-0", time.Second, 10)
+	assert.NoError(t, poller.PublishTask(&queue.VoiceTask{TaskID: "task-0"}))
+	assert.Equal(t, model.TaskStatusQueued, store.status("task-0"))
+}
+
+func TestDBPoller_StopsAtBatch(t *testing.T) {
+	store := newMemoryTaskQueue(5)
+
+	poller := NewDBPoller(store, func(data []byte) error { return nil }, "worker-0", time.Second, 3)
+	claimed, err := poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+	assert.Equal(t, model.TaskStatusQueued, store.status("task-3"))
+}
+
+// sharedTaskStore keeps one task row that both a DBPoller and queue deliveries
+// claim, with the claim rules of PostgresStorage
+type sharedTaskStore struct {
+	*MockDB
+	mu          sync.Mutex
+	task        model.Task
+	claimedBy   string
+	failUpdates bool
+}
+
+func (s *sharedTaskStore) ClaimNextTask(ctx context.Context, workerID string) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.task.Status != model.TaskStatusQueued {
+		return nil, storage.ErrNoQueuedTask
+	}
+	s.task.Status = model.TaskStatusInProgress
+	s.claimedBy = workerID
+	claimed := s.task
+	return &claimed, nil
+}
+
+func (s *sharedTaskStore) ClaimTask(ctx context.Context, id, workerID string, maxAttempts int, staleAfter time.Duration) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	retry := (s.task.Status == model.TaskStatusFailed || s.task.Status == model.TaskStatusTimeout) && s.task.Attempts < maxAttempts
+	own := s.task.Status == model.TaskStatusInProgress && s.claimedBy == workerID
+	if s.task.Status != model.TaskStatusQueued && !retry && !own {
+		return nil, storage.ErrTaskClaimed
+	}
+	s.task.Status = model.TaskStatusInProgress
+	s.claimedBy = workerID
+	claimed := s.task
+	return &claimed, nil
+}
+
+func (s *sharedTaskStore) GetTaskByID(ctx context.Context, id string) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task := s.task
+	return &task, nil
+}
+
+func (s *sharedTaskStore) UpdateTask(ctx context.Context, task *model.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failUpdates {
+		return errors.New("connection reset")
+	}
+	s.task = *task
+	return nil
+}
+
+func (s *sharedTaskStore) UpdateTaskStatus(ctx context.Context, id string, status model.TaskStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.task.Status = status
+	return nil
+}
+
+// newSharedStoreProcessor returns a processor recognizing the task in a
+// shared store as "Привет" and the Telegram stub it replies through
+func newSharedStoreProcessor(t *testing.T, store TaskStore, mockDB *MockDB) (*Processor, *telegramStub, *MockSpeechKit) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil).Once()
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+	cfg := testConfig()
+	cfg.Worker.TaskSource = config.TaskSourceBoth
+	return NewProcessor(cfg, store, mockS3, mockSK, bot, mockCache, nil), stub, mockSK
+}
+
+func sharedStoreTask() model.Task {
+	return model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{"voice_duration": 3, "mime_type": "audio/ogg"},
+	}
+}
+
+func recognizedHello() *speechkit.RecognitionResult {
+	return &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+		},
+	}
+}
+
+func TestProcessor_BothTaskSourcesProcessTaskOnce(t *testing.T) {
+	mockDB := new(MockDB)
+	store := &sharedTaskStore{MockDB: mockDB, task: sharedStoreTask()}
+	p, stub, mockSK := newSharedStoreProcessor(t, store, mockDB)
+	poller := NewDBPoller(store, p.ProcessTask, p.workerID+"/poller", time.Second, 10)
+
+	// The RabbitMQ copy of the task arrives while the polled one is being recognized
+	published := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123"}
+	mockSK.On("WaitForResult", "op-123").Run(func(mock.Arguments) {
+		assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, published)))
+	}).Return(recognizedHello(), nil)
+
+	claimed, err := poller.Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+
+	// A late redelivery of the finished task is skipped too
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, published)))
+
+	assert.Equal(t, model.TaskStatusDone, store.task.Status)
+	assert.Len(t, stub.sentMessages(), 1)
+	mockSK.AssertNumberOfCalls(t, "StartRecognition", 1)
+	mockDB.AssertNumberOfCalls(t, "CreateTranscript", 1)
+}
+
+func TestProcessor_RequeuedDeliveryProcessedOnRedelivery(t *testing.T) {
+	mockDB := new(MockDB)
+	store := &sharedTaskStore{MockDB: mockDB, task: sharedStoreTask()}
+	p, stub, mockSK := newSharedStoreProcessor(t, store, mockDB)
+	p.dbRetry.MaxAttempts = 1
+	mockSK.On("WaitForResult", "op-123").Return(recognizedHello(), nil)
+	task := sharedStoreTask()
+
+	// The claim is taken but the task can't be saved, so the delivery is requeued
+	store.failUpdates = true
+	assert.Error(t, p.ProcessTask(marshalVoiceTask(t, &task)))
+	assert.Equal(t, p.workerID, store.claimedBy)
+
+	// The redelivery finds the task still claimed by this worker and processes it
+	store.failUpdates = false
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, &task)))
+
+	assert.Equal(t, model.TaskStatusDone, store.task.Status)
+	assert.Len(t, stub.sentMessages(), 1)
+}
+
+func TestProcessor_QueueDeliveryNotClaimedWithRabbitMQOnly(t *testing.T) {
+	task := sharedStoreTask()
+	task.Status = model.TaskStatusInProgress
+
+	mockDB := new(MockDB)
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(&task, nil)
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	// A redelivery after a crash is processed, not skipped as claimed
+	claimed, err := p.claimTask(context.Background(), &queue.VoiceTask{TaskID: "task-123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "task-123", claimed.ID)
+	mockDB.AssertNotCalled(t, "ClaimTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+)
+
+// Task state writes are retried briefly; if they still fail, the task goes back
+// to the queue instead of going on with state the database doesn't have
+const (
+	dbWriteAttempts        = 3
+	dbWriteInitialInterval = 100 * time.Millisecond
+	dbWriteMaxInterval     = time.Second
+)
+
+func defaultDBRetry() *resilience.RetryConfig {
+	return &resilience.RetryConfig{
+		MaxAttempts:     dbWriteAttempts,
+		InitialInterval: dbWriteInitialInterval,
+		MaxInterval:     dbWriteMaxInterval,
+		Multiplier:      2.0,
+	}
+}
+
+// saveTask writes the task with retries. The error wraps errStateNotSaved.
+func (p *Processor) saveTask(ctx context.Context, task *model.Task) error {
+	return p.writeWithRetry(ctx, "update_task", task.ID, func() error {
+		return p.db.UpdateTask(ctx, task)
+	})
+}
+
+// saveTranscript stores the transcript with retries. The error wraps errStateNotSaved.
+func (p *Processor) saveTranscript(ctx context.Context, transcript *model.Transcript) error {
+	return p.writeWithRetry(ctx, "create_transcript", transcript.TaskID, func() error {
+		return p.db.CreateTranscript(ctx, transcript)
+	})
+}
+
+func (p *Processor) writeWithRetry(ctx context.Context, op, taskID string, write func() error) error {
+	attempt := 0
+	err := resilience.RetryWithExponentialBackoff(ctx, p.dbRetry, func() error {
+		attempt++
+		err := write()
+		if err != nil {
+			logger.WithTask(taskID).Warn("Database write failed",
+				zap.String("op", op),
+				zap.Int("attempt", attempt),
+				zap.Error(err))
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", errStateNotSaved, op, err)
+	}
+	return nil
+}
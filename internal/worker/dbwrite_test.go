@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/model"
+	"voxly/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func fastDBRetry() *resilience.RetryConfig {
+	return &resilience.RetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+}
+
+func TestProcessor_SaveTaskRetries(t *testing.T) {
+	task := &model.Task{ID: "task-1"}
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(errors.New("connection reset")).Once()
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil).Once()
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+	p.dbRetry = fastDBRetry()
+
+	assert.NoError(t, p.saveTask(context.Background(), task))
+	mockDB.AssertNumberOfCalls(t, "UpdateTask", 2)
+}
+
+func TestProcessor_SaveTaskGivesUp(t *testing.T) {
+	task := &model.Task{ID: "task-1"}
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(errors.New("connection reset"))
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+	p.dbRetry = fastDBRetry()
+
+	err := p.saveTask(context.Background(), task)
+	assert.ErrorIs(t, err, errStateNotSaved)
+	assert.ErrorContains(t, err, "connection reset")
+	mockDB.AssertNumberOfCalls(t, "UpdateTask", 3)
+}
+
+func TestProcessor_ProcessTaskRequeuesWhenStatusNotSaved(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued}
+
+	mockDB := new(MockDB)
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(errors.New("connection reset"))
+	mockCache := new(MockCache)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockS3 := new(MockS3)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, new(MockSpeechKit), bot, mockCache, nil)
+	p.dbRetry = fastDBRetry()
+
+	// The error makes the consumer Nack the message for another try
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+	assert.ErrorIs(t, err, errStateNotSaved)
+
+	mockS3.AssertNotCalled(t, "UploadFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, stub.sentMessages())
+}
+
+func TestProcessor_ProcessTaskRequeuesWhenTranscriptNotSaved(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+		},
+	}
+
+	mockDB := new(MockDB)
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(errors.New("connection reset"))
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK := new(MockSpeechKit)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+	mockCache := new(MockCache)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+	p.dbRetry = fastDBRetry()
+
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+	assert.ErrorIs(t, err, errStateNotSaved)
+
+	// Nothing is sent until the transcript is stored
+	mockDB.AssertNumberOfCalls(t, "CreateTranscript", 3)
+	assert.Empty(t, stub.sentMessages())
+	assert.NotEqual(t, model.TaskStatusDone, task.Status)
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// minTaskTimeout keeps short clips from timing out on ordinary queueing delays
+const minTaskTimeout = 2 * time.Minute
+
+// taskTimeout derives the processing deadline from the audio duration:
+// max(minTaskTimeout, duration*multiplier). Zero means no per-task deadline,
+// either because it is disabled or because the duration is unknown.
+func taskTimeout(durationSec int, multiplier float64) time.Duration {
+	if multiplier <= 0 || durationSec <= 0 {
+		return 0
+	}
+
+	timeout := time.Duration(float64(durationSec) * multiplier * float64(time.Second))
+	if timeout < minTaskTimeout {
+		return minTaskTimeout
+	}
+	return timeout
+}
+
+// taskContext returns a context bounded by the per-task deadline
+func (p *Processor) taskContext(ctx context.Context, durationSec int) (context.Context, context.CancelFunc) {
+	timeout := taskTimeout(durationSec, p.cfg.Worker.TaskTimeoutMultiplier)
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
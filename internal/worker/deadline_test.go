@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		duration   int
+		multiplier float64
+		want       time.Duration
+	}{
+		{"short clip gets the minimum", 5, 5, minTaskTimeout},
+		{"long audio scales with duration", 60, 5, 5 * time.Minute},
+		{"fractional multiplier", 100, 1.5, 150 * time.Second},
+		{"disabled", 60, 0, 0},
+		{"unknown duration", 0, 5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, taskTimeout(tt.duration, tt.multiplier))
+		})
+	}
+}
+
+func TestProcessor_TaskContext(t *testing.T) {
+	cfg := testConfig()
+	cfg.Worker.TaskTimeoutMultiplier = 5
+	p := &Processor{cfg: cfg}
+
+	ctx, cancel := p.taskContext(context.Background(), 60)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), deadline, time.Second)
+
+	cfg.Worker.TaskTimeoutMultiplier = 0
+	ctx, cancel = p.taskContext(context.Background(), 60)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"voxly/internal/i18n"
+	"voxly/internal/speechkit"
+	"voxly/internal/storage"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// errDownloadFailed marks failures to fetch the voice file from Telegram
+var errDownloadFailed = errors.New("failed to download file")
+
+// errTaskPanicked marks tasks whose processing panicked; the bug would likely repeat on retry
+var errTaskPanicked = errors.New("task processing panicked")
+
+// errStateNotSaved marks task state the database didn't take; the message is
+// requeued so the task runs again once the database is back
+var errStateNotSaved = errors.New("failed to save task state")
+
+// errFilePathExpired marks downloads Telegram refused because the file path
+// from getFile is no longer valid; a fresh getFile returns a new one
+var errFilePathExpired = errors.New("telegram file path expired")
+
+// errFileTooLarge marks files above the Bot API download limit
+var errFileTooLarge = errors.New("file exceeds the Telegram download limit")
+
+// errAudioURLRejected marks audio links the worker refuses to fetch: internal
+// addresses, redirect loops, oversized files and responses that aren't audio
+var errAudioURLRejected = errors.New("audio URL rejected")
+
+// errTaskDeadline marks failures caused by the task's own deadline running out,
+// as opposed to a single S3, SpeechKit or HTTP call hitting its timeout
+var errTaskDeadline = errors.New("task deadline exceeded")
+
+// deadlineError tags err with errTaskDeadline when the task deadline is what
+// ended it; per-call timeouts under a live task context are left as they are
+func deadlineError(taskCtx context.Context, err error) error {
+	if err == nil || !errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errTaskDeadline, err)
+}
+
+// failure describes how a failed task is reported to the user
+type failure struct {
+	message   i18n.Key
+	retryable bool
+}
+
+// classifyFailure picks the user message and retry policy for a processing error
+func classifyFailure(err error) failure {
+	switch {
+	case errors.Is(err, speechkit.ErrUnsupportedFormat):
+		return failure{message: i18n.UnsupportedFormat, retryable: false}
+	case errors.Is(err, speechkit.ErrRecognitionTimeout), errors.Is(err, errTaskDeadline):
+		return failure{message: i18n.RecognitionTimeout, retryable: true}
+	case errors.Is(err, errTaskPanicked):
+		return failure{message: i18n.InternalError, retryable: false}
+	case errors.Is(err, errFileTooLarge):
+		return failure{message: i18n.DownloadTooLarge, retryable: false}
+	case errors.Is(err, errAudioURLRejected):
+		return failure{message: i18n.AudioURLRejected, retryable: false}
+	case errors.Is(err, storage.ErrStorageUnavailable):
+		return failure{message: i18n.StorageUnavailable, retryable: true}
+	case errors.Is(err, errDownloadFailed):
+		return failure{message: i18n.DownloadFailed, retryable: true}
+	default:
+		return failure{message: i18n.RecognitionFailed, retryable: true}
+	}
+}
+
+// isFileTooBig reports whether Telegram refused to serve a file because of its size
+func isFileTooBig(err error) bool {
+	if errors.Is(err, tele.ErrTooLarge) {
+		return true
+	}
+
+	// telebot reports descriptions it doesn't know as plain errors
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "file is too big")
+}
+
+// terminalTelegramErrors are send failures that will not go away on retry
+var terminalTelegramErrors = []error{
+	tele.ErrBlockedByUser,
+	tele.ErrChatNotFound,
+	tele.ErrKickedFromGroup,
+	tele.ErrKickedFromSuperGroup,
+	tele.ErrKickedFromChannel,
+	tele.ErrNotStartedByUser,
+	tele.ErrUserIsDeactivated,
+	tele.ErrNoRightsToSend,
+}
+
+// isTerminalTelegramError reports whether the bot can no longer reach the chat
+func isTerminalTelegramError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, target := range terminalTelegramErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	// Any other "Forbidden" response means the bot lost access to the chat
+	var tgErr *tele.Error
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusForbidden
+}
+
+// unknownTelegramErrorCode matches the status telebot appends to API errors it
+// has no type for, e.g. "telegram: Bad Gateway (502)"
+var unknownTelegramErrorCode = regexp.MustCompile(`\((\d{3})\)$`)
+
+// isTransientTelegramError reports whether a send failed on Telegram's side,
+// e.g. a network error, a 5xx response or the open breaker, so the same
+// message may go through later. Flood control means Telegram is up; send
+// already waits it out.
+func isTransientTelegramError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var floodErr tele.FloodError
+	var groupErr tele.GroupError
+	if errors.As(err, &floodErr) || errors.As(err, &groupErr) {
+		return false
+	}
+	var tgErr *tele.Error
+	if errors.As(err, &tgErr) {
+		return tgErr.Code >= http.StatusInternalServerError
+	}
+
+	if m := unknownTelegramErrorCode.FindStringSubmatch(err.Error()); m != nil {
+		return m[1][0] == '5'
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// CompleteHook is called after a task has been transcribed and the user replied to
+type CompleteHook func(ctx context.Context, task *model.Task, transcript *model.Transcript)
+
+// OnComplete registers a hook that runs after every successful transcription.
+// Hooks run in registration order; register them before consuming tasks.
+func (p *Processor) OnComplete(hook CompleteHook) {
+	p.completeHooks = append(p.completeHooks, hook)
+}
+
+// runCompleteHooks calls every registered hook, isolating panics so one
+// broken integration can't take the worker down
+func (p *Processor) runCompleteHooks(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+	for i, hook := range p.completeHooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Complete hook panicked",
+						zap.Int("hook", i),
+						zap.String("task_id", task.ID),
+						zap.Any("panic", r))
+				}
+			}()
+			hook(ctx, task, transcript)
+		}()
+	}
+}
@@ -0,0 +1,170 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"voxly/internal/config"
+	"voxly/internal/speechkit"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessor_RunCompleteHooksRecoversPanics(t *testing.T) {
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	var calls []string
+	p.OnComplete(func(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+		calls = append(calls, "first")
+		panic("broken integration")
+	})
+	p.OnComplete(func(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+		calls = append(calls, "second")
+	})
+
+	assert.NotPanics(t, func() {
+		p.runCompleteHooks(context.Background(), &model.Task{ID: "task-1"}, &model.Transcript{TaskID: "task-1"})
+	})
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestProcessor_ProcessTaskRunsCompleteHooks(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+
+	var gotTask *model.Task
+	var gotTranscript *model.Transcript
+	p.OnComplete(func(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+		gotTask = task
+		gotTranscript = transcript
+	})
+
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+	assert.NoError(t, err)
+
+	assert.Same(t, task, gotTask)
+	assert.Equal(t, model.TaskStatusDone, gotTask.Status)
+	if assert.NotNil(t, gotTranscript) {
+		assert.Equal(t, "task-123", gotTranscript.TaskID)
+		assert.Equal(t, "Привет", gotTranscript.Text)
+	}
+}
+
+// newLogChatProcessor sets up a task that completes with the transcript "Привет"
+func newLogChatProcessor(t *testing.T, cfg *config.Config) (*Processor, *telegramStub, *model.Task) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+	return NewProcessor(cfg, mockDB, mockS3, mockSK, bot, mockCache, nil), stub, task
+}
+
+func TestProcessor_ProcessTaskCopiesToLogChat(t *testing.T) {
+	cfg := testConfig()
+	cfg.Telegram.LogChatID = -100123
+	p, stub, task := newLogChatProcessor(t, cfg)
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "42", sent[0]["chat_id"])
+		assert.Equal(t, "Привет", sent[0]["text"])
+
+		assert.Equal(t, "-100123", sent[1]["chat_id"])
+		assert.Equal(t, "Чат 42, сообщение 7 (задача task-123):\n\nПривет", sent[1]["text"])
+	}
+}
+
+func TestProcessor_LogChatFailureKeepsUserReply(t *testing.T) {
+	cfg := testConfig()
+	cfg.Telegram.LogChatID = -100123
+	p, stub, task := newLogChatProcessor(t, cfg)
+	stub.sendFailures = []string{"", `{"ok":false,"error_code":403,"description":"Forbidden: bot is not a member of the channel chat"}`}
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	assert.Equal(t, 1, task.ReplyMessageID())
+	assert.Len(t, stub.sentMessages(), 2)
+}
+
+func TestProcessor_LogChatRateSkipsCopies(t *testing.T) {
+	cfg := testConfig()
+	cfg.Telegram.LogChatID = -100123
+	cfg.Telegram.LogChatRate = 1
+	p, stub, _ := newLogChatProcessor(t, cfg)
+
+	task := &model.Task{ID: "task-1", ChatID: 42, TelegramMessageID: 7}
+	for i := 0; i < 3; i++ {
+		p.runCompleteHooks(context.Background(), task, &model.Transcript{TaskID: "task-1", Text: "Привет"})
+	}
+	assert.Len(t, stub.sentMessages(), 1)
+}
+
+func TestLogChatText(t *testing.T) {
+	task := &model.Task{ID: "task-1", ChatID: 42, TelegramMessageID: 7}
+	task.SetForwardFrom("Анна")
+
+	text := logChatText(nil, task, strings.Repeat("я", logChatMaxText+10))
+	assert.True(t, strings.HasPrefix(text, "Чат 42, сообщение 7 (задача task-1):\nПереслано от Анна:\n\n"))
+	assert.True(t, strings.HasSuffix(text, "я…"))
+	assert.Equal(t, logChatMaxText, strings.Count(text, "я"))
+}
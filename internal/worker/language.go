@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// languageMemorySize is how many recent detections are remembered per chat
+const languageMemorySize = 5
+
+// languageRedetectAfter is how many tasks in a row are requested in the
+// remembered language before one is detected again, so the history keeps
+// following a chat that switches languages
+const languageRedetectAfter = 3
+
+// knownUnsupportedLanguages are codes auto-detection is known to return for
+// audio without a single recognizable language
+var knownUnsupportedLanguages = map[string]string{
+	"und": "undetermined",
+	"mul": "multiple languages",
+	"zxx": "no linguistic content",
+}
+
+// supportedDetectedLanguage checks a language returned by auto-detection. A
+// code SpeechKit can't be asked for is logged and dropped: the reply goes out
+// without a language and the chat doesn't start requesting it.
+func supportedDetectedLanguage(log *zap.Logger, code string) string {
+	if code == "" || speechkit.SupportedLanguage(code) {
+		return code
+	}
+
+	reason, known := knownUnsupportedLanguages[code]
+	if !known {
+		reason = "unknown"
+	}
+	log.Warn("Unsupported language detected, replying without it",
+		zap.String("language", code),
+		zap.String("reason", reason))
+	return ""
+}
+
+// requestLanguage picks the language to request for a chat. The chat's own
+// language, or else the configured one, is always used; with auto-detection the
+// chat's remembered language skips detection, except for every few tasks that
+// detect it again.
+func (p *Processor) requestLanguage(ctx context.Context, prefs *model.ChatPreferences) string {
+	configured := prefs.Language
+	if configured == "" {
+		configured = p.cfg.SpeechKit.Language
+	}
+	if configured == "" {
+		return speechkit.DefaultLanguage
+	}
+	if configured != speechkit.LanguageAuto {
+		return configured
+	}
+
+	remembered := rememberedLanguage(p.languageHistory(ctx, prefs.ChatID))
+	if remembered == "" {
+		return speechkit.LanguageAuto
+	}
+
+	skipsKey := cache.ChatLanguageSkipsCacheKey(prefs.ChatID)
+	var skips int
+	if err := p.cache.Get(ctx, skipsKey, &skips); err == nil && skips >= languageRedetectAfter {
+		return speechkit.LanguageAuto
+	}
+	if err := p.cache.SetWithTTL(ctx, skipsKey, skips+1, cache.ChatLanguageTTL); err != nil {
+		logger.Warn("Failed to count skipped language detection",
+			zap.Int64("chat_id", prefs.ChatID),
+			zap.Error(err))
+	}
+	return remembered
+}
+
+// rememberLanguage adds a detected language to the chat's rolling history
+func (p *Processor) rememberLanguage(ctx context.Context, chatID int64, language string) {
+	if language == "" {
+		return
+	}
+
+	history := append(p.languageHistory(ctx, chatID), language)
+	if len(history) > languageMemorySize {
+		history = history[len(history)-languageMemorySize:]
+	}
+
+	if err := p.cache.SetWithTTL(ctx, cache.ChatLanguageCacheKey(chatID), history, cache.ChatLanguageTTL); err != nil {
+		logger.Error("Failed to remember chat language",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err))
+		return
+	}
+
+	// The remembered language is requested again until the next detection is due
+	if err := p.cache.Delete(ctx, cache.ChatLanguageSkipsCacheKey(chatID)); err != nil {
+		logger.Warn("Failed to reset skipped language detections",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err))
+	}
+}
+
+// languageHistory returns the chat's recent detections, oldest first
+func (p *Processor) languageHistory(ctx context.Context, chatID int64) []string {
+	var history []string
+	if err := p.cache.Get(ctx, cache.ChatLanguageCacheKey(chatID), &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// rememberedLanguage returns the most frequent language in history,
+// preferring the most recent one on a tie
+func rememberedLanguage(history []string) string {
+	counts := make(map[string]int)
+	var language string
+	for _, code := range history {
+		counts[code]++
+		if counts[code] >= counts[language] {
+			language = code
+		}
+	}
+	return language
+}
@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestRememberedLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []string
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"single", []string{"kk-KZ"}, "kk-KZ"},
+		{"majority", []string{"ru-RU", "en-US", "ru-RU"}, "ru-RU"},
+		{"tie prefers recent", []string{"ru-RU", "en-US"}, "en-US"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rememberedLanguage(tt.history))
+		})
+	}
+}
+
+func TestProcessor_LanguageMemory(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.SpeechKit.Language = speechkit.LanguageAuto
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, cache.NewMemoryCache(time.Hour), nil)
+
+	// Nothing detected yet
+	assert.Equal(t, speechkit.LanguageAuto, p.requestLanguage(ctx, &model.ChatPreferences{ChatID: 42}))
+
+	p.rememberLanguage(ctx, 42, "en-US")
+	assert.Equal(t, "en-US", p.requestLanguage(ctx, &model.ChatPreferences{ChatID: 42}))
+	assert.Equal(t, speechkit.LanguageAuto, p.requestLanguage(ctx, &model.ChatPreferences{ChatID: 43}), "memory is per chat")
+
+	// The history rolls over, keeping only the latest detections
+	for i := 0; i < languageMemorySize; i++ {
+		p.rememberLanguage(ctx, 42, "ru-RU")
+	}
+	assert.Equal(t, []string{"ru-RU", "ru-RU", "ru-RU", "ru-RU", "ru-RU"}, p.languageHistory(ctx, 42))
+	assert.Equal(t, "ru-RU", p.requestLanguage(ctx, &model.ChatPreferences{ChatID: 42}))
+
+	// Undetected results don't pollute the memory
+	p.rememberLanguage(ctx, 42, "")
+	assert.Len(t, p.languageHistory(ctx, 42), languageMemorySize)
+}
+
+func TestProcessor_LanguageRedetected(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.SpeechKit.Language = speechkit.LanguageAuto
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, cache.NewMemoryCache(time.Hour), nil)
+	prefs := &model.ChatPreferences{ChatID: 42}
+
+	p.rememberLanguage(ctx, 42, "ru-RU")
+	for i := 0; i < languageRedetectAfter; i++ {
+		assert.Equal(t, "ru-RU", p.requestLanguage(ctx, prefs))
+	}
+
+	// Every few tasks the language is detected again, until a detection comes back
+	assert.Equal(t, speechkit.LanguageAuto, p.requestLanguage(ctx, prefs))
+	assert.Equal(t, speechkit.LanguageAuto, p.requestLanguage(ctx, prefs))
+
+	// The chat switched: its new language takes over once it is the most frequent
+	for i := 0; i < 2; i++ {
+		p.rememberLanguage(ctx, 42, "en-US")
+		for j := 0; j < languageRedetectAfter; j++ {
+			p.requestLanguage(ctx, prefs)
+		}
+	}
+	assert.Equal(t, []string{"ru-RU", "en-US", "en-US"}, p.languageHistory(ctx, 42))
+	assert.Equal(t, speechkit.LanguageAuto, p.requestLanguage(ctx, prefs))
+	p.rememberLanguage(ctx, 42, "en-US")
+	assert.Equal(t, "en-US", p.requestLanguage(ctx, prefs))
+}
+
+func TestProcessor_RequestLanguageConfigured(t *testing.T) {
+	memory := cache.NewMemoryCache(time.Hour)
+	assert.NoError(t, memory.Set(context.Background(), cache.ChatLanguageCacheKey(42), []string{"en-US"}))
+
+	cfg := testConfig()
+	cfg.SpeechKit.Language = "kk-KZ"
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, memory, nil)
+
+	// An explicit language wins over the memory
+	assert.Equal(t, "kk-KZ", p.requestLanguage(context.Background(), &model.ChatPreferences{ChatID: 42}))
+
+	// The chat's own language wins over the configured one
+	assert.Equal(t, "en-US", p.requestLanguage(context.Background(), &model.ChatPreferences{ChatID: 42, Language: "en-US"}))
+
+	cfg.SpeechKit.Language = ""
+	assert.Equal(t, speechkit.DefaultLanguage, p.requestLanguage(context.Background(), &model.ChatPreferences{ChatID: 42}))
+}
+
+func TestProcessor_ProcessTaskRemembersDetectedLanguage(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	memory := cache.NewMemoryCache(time.Hour)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Hello", Confidence: 0.9, LanguageCode: "en-US"}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, speechkit.RecognitionOptions{Model: speechkit.ModelGeneralRC, Language: speechkit.LanguageAuto}).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+
+	cfg := testConfig()
+	cfg.SpeechKit.Language = speechkit.LanguageAuto
+	p := NewProcessor(cfg, mockDB, mockS3, mockSK, bot, memory, nil)
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	mockSK.AssertExpectations(t)
+
+	assert.Equal(t, "en-US", task.Language())
+	assert.Equal(t, "en-US", p.requestLanguage(context.Background(), &model.ChatPreferences{ChatID: 42}))
+}
+
+func TestSupportedDetectedLanguage(t *testing.T) {
+	log := zap.NewNop()
+	assert.Equal(t, "kk-KZ", supportedDetectedLanguage(log, "kk-KZ"))
+	assert.Equal(t, "", supportedDetectedLanguage(log, ""))
+	assert.Equal(t, "", supportedDetectedLanguage(log, "und"))
+	assert.Equal(t, "", supportedDetectedLanguage(log, "xx-XX"))
+}
+
+func TestProcessor_ProcessTaskUnsupportedDetectedLanguage(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	memory := cache.NewMemoryCache(time.Hour)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Сәлем", Confidence: 0.9, LanguageCode: "ky-KG"}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, speechkit.RecognitionOptions{Model: speechkit.ModelGeneralRC, Language: speechkit.LanguageAuto}).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+
+	cfg := testConfig()
+	cfg.SpeechKit.Language = speechkit.LanguageAuto
+	cfg.Reply.FooterTemplate = "{{if .Language}}Язык: {{.Language}}{{end}}"
+	p := NewProcessor(cfg, mockDB, mockS3, mockSK, bot, memory, nil)
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+
+	// The transcript goes out plain, and the chat keeps auto-detection
+	if sent := stub.sentMessages(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "Сәлем", sent[0]["text"])
+	}
+	assert.Equal(t, speechkit.LanguageAuto, task.Language())
+	assert.Empty(t, p.languageHistory(context.Background(), 42))
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+	"voxly/internal/i18n"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// defaultLogChatRate keeps within Telegram's limit of 20 messages a minute to a group
+const defaultLogChatRate = 20
+
+// logChatMaxText is how much of a transcript is copied to the log chat; the
+// rest is cut so the copy fits in one message with its attribution
+const logChatMaxText = 3500
+
+// newLogChatLimiter spreads copies evenly at rate messages per minute
+func newLogChatLimiter(rate int) *resilience.RateLimiter {
+	if rate <= 0 {
+		rate = defaultLogChatRate
+	}
+	return resilience.NewRateLimiter(rate, time.Minute/time.Duration(rate))
+}
+
+// copyToLogChat is a CompleteHook sending every transcript with its chat to
+// the configured log chat for moderation. The user has been replied to
+// already, so failures are only logged. Copies over the log chat's rate are
+// skipped instead of holding up the worker.
+func (p *Processor) copyToLogChat(ctx context.Context, task *model.Task, transcript *model.Transcript) {
+	log := logger.WithTask(task.ID).With(zap.Int64("log_chat_id", p.cfg.Telegram.LogChatID))
+
+	if !p.logChatLimiter.Allow() {
+		log.Warn("Log chat rate exceeded, transcript not copied")
+		return
+	}
+
+	text := logChatText(p.texts, task, transcript.Text)
+	if _, err := p.send(ctx, &tele.Chat{ID: p.cfg.Telegram.LogChatID}, text, &tele.SendOptions{}); err != nil {
+		log.Error("Failed to copy transcript to log chat", zap.Error(err))
+		return
+	}
+
+	log.Debug("Transcript copied to log chat")
+}
+
+// logChatText attributes the transcript to its chat and message, shortening long ones
+func logChatText(texts *i18n.Catalog, task *model.Task, text string) string {
+	if utf8.RuneCountInString(text) > logChatMaxText {
+		text = string([]rune(text)[:logChatMaxText]) + "…"
+	}
+
+	header := texts.Text(i18n.LogChatTranscript, task.ChatID, task.TelegramMessageID, task.ID)
+	if from := task.ForwardFrom(); from != "" {
+		header += "\n" + texts.Text(i18n.ForwardedFrom, from)
+	}
+	return header + "\n\n" + text
+}
@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// OutboxStore keeps replies that couldn't be sent while Telegram was unavailable
+type OutboxStore interface {
+	AddOutboxMessage(ctx context.Context, msg *model.OutboxMessage) error
+	LeaseOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error)
+	DeleteOutboxMessage(ctx context.Context, id int64) error
+	ReleaseOutboxMessages(ctx context.Context, ids []int64) error
+}
+
+// outboxBatch is how many saved replies are taken from the outbox at once
+const outboxBatch = 50
+
+// outboxLease is how long taken replies are hidden from other workers. Replies
+// of a worker that stopped before sending them are taken again after it.
+const outboxLease = 10 * time.Minute
+
+// outboxCleanupTimeout bounds removing and releasing replies after the
+// worker's context is cancelled
+const outboxCleanupTimeout = 5 * time.Second
+
+// UseOutbox saves transcripts and failure notices that can't be sent because
+// Telegram is unavailable to outbox, for RunOutbox to deliver later
+func (p *Processor) UseOutbox(outbox OutboxStore) {
+	p.outbox = outbox
+}
+
+// deferReply saves chunks, the unsent rest of a reply, to the outbox when
+// sendErr means Telegram is unavailable. first marks chunks as starting with the
+// transcript's first message, which becomes the task's reply once delivered.
+// It reports whether the reply was saved; otherwise sendErr is the caller's to handle.
+func (p *Processor) deferReply(ctx context.Context, task *model.Task, chunks []string, opts *tele.SendOptions, first bool, sendErr error) bool {
+	if p.outbox == nil || !isTransientTelegramError(sendErr) {
+		return false
+	}
+
+	log := logger.WithTask(task.ID)
+	// One timestamp keeps the chunks in order among other replies
+	createdAt := time.Now()
+	for i, chunk := range chunks {
+		msg := &model.OutboxMessage{
+			TaskID:    task.ID,
+			TaskReply: first && i == 0,
+			ChatID:    task.ChatID,
+			ThreadID:  opts.ThreadID,
+			ParseMode: string(opts.ParseMode),
+			Text:      chunk,
+			CreatedAt: createdAt,
+		}
+		if opts.ReplyTo != nil {
+			msg.ReplyTo = opts.ReplyTo.ID
+		}
+
+		if err := p.outbox.AddOutboxMessage(ctx, msg); err != nil {
+			log.Error("Failed to save reply to outbox",
+				zap.Int("saved", i),
+				zap.Int("chunks", len(chunks)),
+				zap.Error(err))
+			return false
+		}
+	}
+
+	log.Warn("Telegram unavailable, reply saved to outbox",
+		zap.Int("chunks", len(chunks)),
+		zap.Error(sendErr))
+	return true
+}
+
+// RunOutbox delivers saved replies every interval until ctx is cancelled
+func (p *Processor) RunOutbox(ctx context.Context, interval time.Duration) {
+	if p.outbox == nil || interval <= 0 {
+		logger.Info("Telegram outbox disabled")
+		return
+	}
+
+	logger.Info("Starting Telegram outbox delivery", zap.Duration("interval", interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			delivered, err := p.flushOutbox(ctx)
+			if delivered > 0 {
+				logger.Info("Delivered replies from outbox", zap.Int("delivered", delivered))
+			}
+			if err != nil {
+				logger.Error("Failed to flush Telegram outbox", zap.Error(err))
+			}
+		}
+	}
+}
+
+// flushOutbox sends saved replies, oldest first, until none are left or
+// Telegram fails again. Nothing is taken while the breaker is open; once its
+// timeout passes, the first reply is the trial send that closes it. A reply
+// leaves the outbox only once it is sent or can never be. It returns how many
+// replies were delivered.
+func (p *Processor) flushOutbox(ctx context.Context) (int, error) {
+	delivered := 0
+	for p.telegramBreaker.Ready() && ctx.Err() == nil {
+		messages, err := p.outbox.LeaseOutboxMessages(ctx, outboxBatch, outboxLease)
+		if err != nil {
+			return delivered, err
+		}
+		if len(messages) == 0 {
+			return delivered, nil
+		}
+
+		for i, msg := range messages {
+			err := p.sendOutboxMessage(ctx, msg)
+			if isTransientTelegramError(err) || (err != nil && ctx.Err() != nil) {
+				// Telegram is down again or the worker is stopping
+				return delivered, p.releaseOutbox(messages[i:])
+			}
+			if err != nil {
+				// The chat is gone or the reply is invalid, another try won't help
+				p.handleSendError(ctx, msg.ChatID, err)
+			} else {
+				delivered++
+			}
+			p.removeFromOutbox(msg)
+		}
+	}
+	return delivered, nil
+}
+
+// sendOutboxMessage sends a saved reply. The first message of a transcript is
+// stored as the task's reply, so edits and /delete find it.
+func (p *Processor) sendOutboxMessage(ctx context.Context, msg *model.OutboxMessage) error {
+	opts := &tele.SendOptions{
+		ThreadID:  msg.ThreadID,
+		ParseMode: tele.ParseMode(msg.ParseMode),
+		// The voice message may be gone by the time the reply goes out
+		AllowWithoutReply: true,
+	}
+	if msg.ReplyTo != 0 {
+		opts.ReplyTo = &tele.Message{ID: msg.ReplyTo}
+	}
+
+	sent, err := p.send(ctx, &tele.Chat{ID: msg.ChatID}, msg.Text, opts)
+	if err != nil {
+		return err
+	}
+
+	if msg.TaskReply {
+		p.rememberReply(ctx, msg.TaskID, sent.ID)
+	}
+	return nil
+}
+
+// rememberReply stores a transcript delivered from the outbox as the task's reply
+func (p *Processor) rememberReply(ctx context.Context, taskID string, messageID int) {
+	log := logger.WithTask(taskID)
+
+	task, err := p.db.GetTaskByID(ctx, taskID)
+	if err != nil {
+		log.Warn("Failed to get task of delivered reply", zap.Error(err))
+		return
+	}
+
+	task.SetReplyMessageID(messageID)
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Warn("Failed to save reply of delivered transcript", zap.Error(err))
+	}
+}
+
+// removeFromOutbox deletes a handled reply. It runs even when the worker is
+// stopping, since the reply has already gone out.
+func (p *Processor) removeFromOutbox(msg *model.OutboxMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxCleanupTimeout)
+	defer cancel()
+
+	if err := p.outbox.DeleteOutboxMessage(ctx, msg.ID); err != nil {
+		// The reply is sent again once its lease runs out
+		logger.WithTask(msg.TaskID).Error("Failed to remove delivered reply from outbox",
+			zap.Int64("outbox_id", msg.ID),
+			zap.Error(err))
+	}
+}
+
+// releaseOutbox returns unsent replies to the outbox for the next flush. They
+// keep their place, so they stay ahead of replies saved since. Replies that
+// can't be released are taken again once their lease runs out.
+func (p *Processor) releaseOutbox(messages []*model.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxCleanupTimeout)
+	defer cancel()
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	if err := p.outbox.ReleaseOutboxMessages(ctx, ids); err != nil {
+		return fmt.Errorf("failed to release %d replies: %w", len(ids), err)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"voxly/pkg/model"
+	"voxly/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	tele "gopkg.in/telebot.v4"
+)
+
+const badGatewayResponse = `{"ok":false,"error_code":502,"description":"Bad Gateway"}`
+
+// memoryOutbox is an OutboxStore leasing messages oldest first, like PostgresStorage
+type memoryOutbox struct {
+	mu       sync.Mutex
+	messages []*model.OutboxMessage
+	leased   map[int64]bool
+	nextID   int64
+	// onLease runs after messages are leased
+	onLease func()
+}
+
+func (o *memoryOutbox) AddOutboxMessage(ctx context.Context, msg *model.OutboxMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.nextID++
+	msg.ID = o.nextID
+	saved := *msg
+	o.messages = append(o.messages, &saved)
+	return nil
+}
+
+func (o *memoryOutbox) LeaseOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	o.mu.Lock()
+	if o.leased == nil {
+		o.leased = make(map[int64]bool)
+	}
+	var taken []*model.OutboxMessage
+	for _, msg := range o.messages {
+		if len(taken) == limit {
+			break
+		}
+		if !o.leased[msg.ID] {
+			o.leased[msg.ID] = true
+			taken = append(taken, msg)
+		}
+	}
+	o.mu.Unlock()
+
+	if o.onLease != nil {
+		o.onLease()
+	}
+	return taken, nil
+}
+
+func (o *memoryOutbox) DeleteOutboxMessage(ctx context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, msg := range o.messages {
+		if msg.ID == id {
+			o.messages = append(o.messages[:i], o.messages[i+1:]...)
+			break
+		}
+	}
+	delete(o.leased, id)
+	return nil
+}
+
+func (o *memoryOutbox) ReleaseOutboxMessages(ctx context.Context, ids []int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, id := range ids {
+		delete(o.leased, id)
+	}
+	return nil
+}
+
+// pending returns the messages left in the outbox, leased or not
+func (o *memoryOutbox) pending() []*model.OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*model.OutboxMessage(nil), o.messages...)
+}
+
+// unleased returns the messages the next flush takes
+func (o *memoryOutbox) unleased() []*model.OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var messages []*model.OutboxMessage
+	for _, msg := range o.messages {
+		if !o.leased[msg.ID] {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// newOutboxProcessor returns a processor that tries each send once and keeps
+// undelivered replies in a memory outbox
+func newOutboxProcessor(t *testing.T, db *MockDB) (*Processor, *telegramStub, *memoryOutbox) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), db, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.sendRetry = newSendRetry(1)
+
+	outbox := &memoryOutbox{}
+	p.UseOutbox(outbox)
+	return p, stub, outbox
+}
+
+func outboxTask() *model.Task {
+	task := &model.Task{ID: "task-1", ChatID: 42, TelegramMessageID: 7}
+	task.SetThreadID(3)
+	return task
+}
+
+func TestProcessor_SendRetriesTelegramFailures(t *testing.T) {
+	p, stub, _ := newOutboxProcessor(t, new(MockDB))
+	p.sendRetry = &resilience.RetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+	stub.sendFailures = []string{badGatewayResponse, badGatewayResponse}
+
+	msg, err := p.send(context.Background(), &tele.Chat{ID: 42}, "Привет", &tele.SendOptions{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, 3, msg.ID)
+	}
+	assert.Equal(t, resilience.StateClosed, p.TelegramBreaker().GetState())
+}
+
+func TestProcessor_SendResultSavesReplyWhenTelegramUnavailable(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	stub.sendError = badGatewayResponse
+
+	err := p.sendResultToUser(context.Background(), outboxTask(), "<b>Привет</b>", tele.ModeHTML, nil)
+	assert.NoError(t, err)
+
+	saved := outbox.pending()
+	if assert.Len(t, saved, 1) {
+		assert.Equal(t, "task-1", saved[0].TaskID)
+		assert.True(t, saved[0].TaskReply)
+		assert.Equal(t, int64(42), saved[0].ChatID)
+		assert.Equal(t, 3, saved[0].ThreadID)
+		assert.Equal(t, 7, saved[0].ReplyTo)
+		assert.Equal(t, "HTML", saved[0].ParseMode)
+		assert.Equal(t, "<b>Привет</b>", saved[0].Text)
+	}
+}
+
+func TestProcessor_SendResultSavesUnsentChunks(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	stub.sendFailures = []string{"", badGatewayResponse}
+
+	text := strings.Repeat("слово ", maxMessageLength/6+10)
+	err := p.sendResultToUser(context.Background(), outboxTask(), text, tele.ModeDefault, nil)
+	assert.NoError(t, err)
+
+	// The first chunk went out, only the second waits
+	saved := outbox.pending()
+	if assert.Len(t, saved, 1) {
+		assert.Equal(t, "task-1", saved[0].TaskID)
+		assert.False(t, saved[0].TaskReply)
+		assert.Equal(t, p.replyChunks(text, tele.ModeDefault)[1], saved[0].Text)
+	}
+}
+
+func TestProcessor_SendResultKeepsErrorsAboutTheChat(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	stub.sendError = `{"ok":false,"error_code":400,"description":"Bad Request: message is too weird"}`
+
+	err := p.sendResultToUser(context.Background(), outboxTask(), "Привет", tele.ModeDefault, nil)
+	assert.Error(t, err)
+	assert.Empty(t, outbox.pending())
+	assert.Equal(t, resilience.StateClosed, p.TelegramBreaker().GetState())
+}
+
+func TestProcessor_OpenBreakerSavesReplyWithoutSending(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	p.telegramBreaker = newTelegramBreaker(1, time.Hour)
+	p.telegramBreaker.Execute(func() error { return errors.New("telegram is down") })
+
+	err := p.sendResultToUser(context.Background(), outboxTask(), "Привет", tele.ModeDefault, nil)
+	assert.NoError(t, err)
+
+	assert.Empty(t, stub.sentMessages())
+	assert.Len(t, outbox.pending(), 1)
+}
+
+func TestProcessor_FlushOutboxDeliversInOrder(t *testing.T) {
+	mockDB := new(MockDB)
+	p, stub, outbox := newOutboxProcessor(t, mockDB)
+
+	task := outboxTask()
+	mockDB.On("GetTaskByID", mock.Anything, "task-1").Return(task, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+
+	created := time.Now()
+	outbox.AddOutboxMessage(context.Background(), &model.OutboxMessage{TaskID: "task-1", TaskReply: true, ChatID: 42, ThreadID: 3, ReplyTo: 7, Text: "(1/2) Привет", CreatedAt: created})
+	outbox.AddOutboxMessage(context.Background(), &model.OutboxMessage{TaskID: "task-1", ChatID: 42, ThreadID: 3, ReplyTo: 7, Text: "(2/2) мир", CreatedAt: created})
+
+	delivered, err := p.flushOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Empty(t, outbox.pending())
+
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "(1/2) Привет", sent[0]["text"])
+		assert.Equal(t, "(2/2) мир", sent[1]["text"])
+		assert.Equal(t, "7", sent[0]["reply_to_message_id"])
+		assert.Equal(t, "3", sent[0]["message_thread_id"])
+		assert.Equal(t, "true", sent[0]["allow_sending_without_reply"])
+	}
+
+	// The delivered transcript is the task's reply, like one sent right away
+	assert.Equal(t, 1, task.ReplyMessageID())
+	mockDB.AssertExpectations(t)
+}
+
+func TestProcessor_FlushOutboxPutsBackOnFailure(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	stub.sendFailures = []string{"", badGatewayResponse}
+
+	for i := 1; i <= 3; i++ {
+		outbox.AddOutboxMessage(context.Background(), &model.OutboxMessage{ChatID: 42, Text: fmt.Sprintf("reply %d", i), CreatedAt: time.Now()})
+	}
+
+	delivered, err := p.flushOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	pending := outbox.unleased()
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, "reply 2", pending[0].Text)
+		assert.Equal(t, "reply 3", pending[1].Text)
+	}
+	assert.Len(t, outbox.pending(), 2)
+}
+
+func TestProcessor_FlushOutboxKeepsRepliesOnShutdown(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	for i := 1; i <= 2; i++ {
+		outbox.AddOutboxMessage(context.Background(), &model.OutboxMessage{ChatID: 42, Text: fmt.Sprintf("reply %d", i), CreatedAt: time.Now()})
+	}
+
+	// The worker stops right after taking the replies
+	ctx, cancel := context.WithCancel(context.Background())
+	outbox.onLease = cancel
+
+	delivered, err := p.flushOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Empty(t, stub.sentMessages())
+
+	// Both replies are back for the next worker, and Telegram isn't blamed
+	assert.Len(t, outbox.unleased(), 2)
+	assert.Equal(t, resilience.StateClosed, p.TelegramBreaker().GetState())
+}
+
+func TestProcessor_SendCancelledDoesNotOpenBreaker(t *testing.T) {
+	p, _, _ := newOutboxProcessor(t, new(MockDB))
+	p.telegramBreaker = newTelegramBreaker(1, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.send(ctx, &tele.Chat{ID: 42}, "Привет", &tele.SendOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, resilience.StateClosed, p.TelegramBreaker().GetState())
+}
+
+func TestProcessor_FlushOutboxWaitsForBreaker(t *testing.T) {
+	p, stub, outbox := newOutboxProcessor(t, new(MockDB))
+	p.telegramBreaker = newTelegramBreaker(1, 50*time.Millisecond)
+	p.telegramBreaker.Execute(func() error { return errors.New("telegram is down") })
+
+	outbox.AddOutboxMessage(context.Background(), &model.OutboxMessage{ChatID: 42, Text: "Привет", CreatedAt: time.Now()})
+
+	// Nothing is taken while the breaker is open
+	delivered, err := p.flushOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Len(t, outbox.pending(), 1)
+
+	// After the timeout the saved reply is the trial send that closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	delivered, err = p.flushOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Empty(t, outbox.pending())
+	assert.Len(t, stub.sentMessages(), 1)
+	assert.Equal(t, resilience.StateClosed, p.TelegramBreaker().GetState())
+}
+
+func TestIsTransientTelegramError(t *testing.T) {
+	assert.False(t, isTransientTelegramError(nil))
+	assert.True(t, isTransientTelegramError(errors.New("telebot: connection refused")))
+	assert.True(t, isTransientTelegramError(resilience.ErrCircuitOpen))
+	assert.True(t, isTransientTelegramError(errors.New("telegram: Bad Gateway (502)")))
+	assert.True(t, isTransientTelegramError(tele.ErrInternal))
+	assert.False(t, isTransientTelegramError(errors.New("telegram: Bad Request: odd (400)")))
+	assert.False(t, isTransientTelegramError(tele.ErrBlockedByUser))
+	assert.False(t, isTransientTelegramError(context.Canceled))
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"voxly/pkg/resilience"
+)
+
+// defaultGroupSendRate is the Bot API limit for messages to one group per minute
+const defaultGroupSendRate = 20
+
+// groupSendBurst is how many replies a group gets at once before they're spaced out
+const groupSendBurst = 3
+
+// chatPacerIdle is how long a chat's limiter is kept after its last send
+const chatPacerIdle = 10 * time.Minute
+
+// chatPacer spaces out replies to the same group or channel, which Telegram
+// limits separately from the global rate. Private chats are only paced globally.
+type chatPacer struct {
+	interval time.Duration
+	burst    int
+
+	mu       sync.Mutex
+	limiters map[string]*chatLimiter
+	// lastPrune is when idle limiters were last dropped
+	lastPrune time.Time
+}
+
+type chatLimiter struct {
+	limiter  *resilience.RateLimiter
+	lastUsed time.Time
+}
+
+// newChatPacer paces each group at rate replies per minute
+func newChatPacer(rate int) *chatPacer {
+	if rate <= 0 {
+		rate = defaultGroupSendRate
+	}
+	return &chatPacer{
+		interval:  time.Minute / time.Duration(rate),
+		burst:     groupSendBurst,
+		limiters:  make(map[string]*chatLimiter),
+		lastPrune: time.Now(),
+	}
+}
+
+// Wait blocks until the recipient may get another message or ctx is done
+func (cp *chatPacer) Wait(ctx context.Context, recipient string) error {
+	if !isGroupRecipient(recipient) {
+		return nil
+	}
+	return cp.limiter(recipient).Wait(ctx)
+}
+
+// limiter returns the recipient's limiter, creating it on its first send
+func (cp *chatPacer) limiter(recipient string) *resilience.RateLimiter {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(cp.lastPrune) >= chatPacerIdle {
+		cp.prune(now)
+	}
+
+	entry, ok := cp.limiters[recipient]
+	if !ok {
+		entry = &chatLimiter{limiter: resilience.NewRateLimiter(cp.burst, cp.interval)}
+		cp.limiters[recipient] = entry
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+// prune drops limiters of chats that got nothing for chatPacerIdle; by then
+// their buckets are full again, so a new limiter behaves the same
+func (cp *chatPacer) prune(now time.Time) {
+	for recipient, entry := range cp.limiters {
+		if now.Sub(entry.lastUsed) >= chatPacerIdle {
+			delete(cp.limiters, recipient)
+		}
+	}
+	cp.lastPrune = now
+}
+
+// isGroupRecipient reports whether the recipient is a group or channel: their
+// IDs are negative, and channels may also be addressed by @username
+func isGroupRecipient(recipient string) bool {
+	return strings.HasPrefix(recipient, "-") || strings.HasPrefix(recipient, "@")
+}
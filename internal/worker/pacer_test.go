@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tele "gopkg.in/telebot.v4"
+)
+
+// testChatPacer paces groups at one reply per interval after the burst
+func testChatPacer(interval time.Duration) *chatPacer {
+	cp := newChatPacer(defaultGroupSendRate)
+	cp.interval = interval
+	return cp
+}
+
+// waitAll reports how long n replies to the recipient had to wait
+func waitAll(t *testing.T, cp *chatPacer, recipient string, n int) time.Duration {
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		assert.NoError(t, cp.Wait(context.Background(), recipient))
+	}
+	return time.Since(start)
+}
+
+func TestChatPacer_SpacesOutGroupReplies(t *testing.T) {
+	cp := testChatPacer(30 * time.Millisecond)
+
+	// The burst goes out at once, the next reply waits for a token
+	assert.Less(t, waitAll(t, cp, "-100", groupSendBurst), 30*time.Millisecond)
+	assert.GreaterOrEqual(t, waitAll(t, cp, "-100", 1), 30*time.Millisecond)
+
+	// Channels addressed by username are paced too
+	waitAll(t, cp, "@news", groupSendBurst)
+	assert.GreaterOrEqual(t, waitAll(t, cp, "@news", 1), 30*time.Millisecond)
+}
+
+func TestChatPacer_ChatsArePacedSeparately(t *testing.T) {
+	cp := testChatPacer(time.Hour)
+
+	waitAll(t, cp, "-100", groupSendBurst)
+
+	// Another group still has its whole burst
+	assert.Less(t, waitAll(t, cp, "-200", groupSendBurst), time.Second)
+
+	// Private chats are only paced by the global limiter
+	assert.Less(t, waitAll(t, cp, "42", 10*groupSendBurst), time.Second)
+
+	// A group out of tokens waits until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, cp.Wait(ctx, "-100"), context.DeadlineExceeded)
+}
+
+func TestChatPacer_PrunesIdleChats(t *testing.T) {
+	cp := testChatPacer(time.Millisecond)
+
+	waitAll(t, cp, "-100", 1)
+	waitAll(t, cp, "-200", 1)
+	cp.limiters["-100"].lastUsed = time.Now().Add(-chatPacerIdle)
+	cp.lastPrune = time.Now().Add(-chatPacerIdle)
+
+	waitAll(t, cp, "-200", 1)
+	assert.NotContains(t, cp.limiters, "-100")
+	assert.Contains(t, cp.limiters, "-200")
+}
+
+func TestNewChatPacer(t *testing.T) {
+	assert.Equal(t, 3*time.Second, newChatPacer(20).interval)
+	assert.Equal(t, time.Minute/defaultGroupSendRate, newChatPacer(0).interval)
+}
+
+func TestProcessor_SendPacesGroupReplies(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.chatPacer = testChatPacer(30 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < groupSendBurst+1; i++ {
+		_, err := p.send(context.Background(), &tele.Chat{ID: -100}, "Привет", &tele.SendOptions{})
+		assert.NoError(t, err)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Len(t, stub.sentMessages(), groupSendBurst+1)
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+	"voxly/internal/queue"
+
+	"go.uber.org/zap"
+)
+
+// PollerPool runs recognition polls in the background, at most size at once.
+// It lets the consumer take the next task while earlier ones are still being recognized.
+type PollerPool struct {
+	slots       chan struct{}
+	wg          sync.WaitGroup
+	outstanding atomic.Int64
+}
+
+// NewPollerPool creates a pool of size polls; sizes below one mean one
+func NewPollerPool(size int) *PollerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &PollerPool{slots: make(chan struct{}, size)}
+}
+
+// Go runs poll in the background. While all slots are busy it blocks, which
+// holds back consumption until a poll finishes.
+func (pp *PollerPool) Go(poll func()) {
+	pp.slots <- struct{}{}
+	pp.wg.Add(1)
+	pp.outstanding.Add(1)
+
+	go func() {
+		defer func() {
+			pp.outstanding.Add(-1)
+			pp.wg.Done()
+			<-pp.slots
+		}()
+		poll()
+	}()
+}
+
+// Outstanding returns how many polls are running
+func (pp *PollerPool) Outstanding() int {
+	return int(pp.outstanding.Load())
+}
+
+// Wait blocks until every started poll has finished or ctx is done
+func (pp *PollerPool) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pp.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TaskPublisher puts tasks back in the queue
+type TaskPublisher interface {
+	PublishTask(task *queue.VoiceTask) error
+}
+
+// PollInBackground makes the processor hand recognition polls to pool instead of
+// waiting for them while consuming. A poll's queue message is acknowledged at
+// hand-off, so tasks to retry are published to tasks again, and a Reclaimer
+// publishes tasks a stopped worker left in progress. Call it before consuming
+// tasks.
+func (p *Processor) PollInBackground(pool *PollerPool, tasks TaskPublisher) {
+	p.pollers = pool
+	p.tasks = tasks
+}
+
+// awaitInBackground waits for the recognition on a pool slot and finishes the task
+func (p *Processor) awaitInBackground(ctx, taskCtx context.Context, run *taskRun, operationID string) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			run.log.Error("Recognition poll panicked",
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			err = p.handleTaskError(ctx, run.task, fmt.Errorf("%w: %v", errTaskPanicked, r))
+		}
+		if err != nil {
+			p.retryTask(run)
+		}
+	}()
+
+	result, waitErr := p.waitFileRecognition(taskCtx, run, operationID)
+	err = p.finishTask(ctx, run, result, deadlineError(taskCtx, waitErr))
+}
+
+// retryTask publishes the task again for another attempt
+func (p *Processor) retryTask(run *taskRun) {
+	if p.tasks == nil {
+		run.log.Error("No task publisher, task is left failed")
+		return
+	}
+
+	run.voiceTask.RequeuedAt = time.Now()
+	if err := p.tasks.PublishTask(&run.voiceTask); err != nil {
+		run.log.Error("Failed to requeue task", zap.Error(err))
+		return
+	}
+	run.log.Info("Task requeued for another attempt")
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"voxly/internal/queue"
+	"voxly/internal/speechkit"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPollerPool_BoundsConcurrency(t *testing.T) {
+	pool := NewPollerPool(2)
+	release := make(chan struct{})
+
+	var running, maxRunning atomic.Int64
+	submitted := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			pool.Go(func() {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+			})
+		}
+		close(submitted)
+	}()
+
+	// The third poll waits for a free slot
+	assert.Eventually(t, func() bool { return pool.Outstanding() == 2 }, time.Second, time.Millisecond)
+	select {
+	case <-submitted:
+		t.Fatal("Go returned while the pool was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-submitted
+	assert.NoError(t, pool.Wait(context.Background()))
+	assert.Equal(t, int64(2), maxRunning.Load())
+	assert.Equal(t, 0, pool.Outstanding())
+}
+
+func TestPollerPool_WaitStopsOnContext(t *testing.T) {
+	pool := NewPollerPool(0)
+	release := make(chan struct{})
+	defer close(release)
+	pool.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Wait(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, pool.Outstanding())
+}
+
+// recordingPublisher keeps the tasks published to it
+type recordingPublisher struct {
+	mu    sync.Mutex
+	tasks []*queue.VoiceTask
+}
+
+func (r *recordingPublisher) PublishTask(task *queue.VoiceTask) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks = append(r.tasks, task)
+	return nil
+}
+
+func (r *recordingPublisher) published() []*queue.VoiceTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*queue.VoiceTask(nil), r.tasks...)
+}
+
+// backgroundPollSetup prepares a task whose recognition result is held until release is closed
+func backgroundPollSetup(t *testing.T, release chan struct{}, result *speechkit.RecognitionResult, waitErr error) (*Processor, *model.Task, *telegramStub, *recordingPublisher) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Run(func(mock.Arguments) { <-release }).Return(result, waitErr)
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+	publisher := &recordingPublisher{}
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+	p.PollInBackground(NewPollerPool(1), publisher)
+	return p, task, stub, publisher
+}
+
+func TestProcessor_ProcessTaskPollsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+		},
+	}
+	p, task, stub, publisher := backgroundPollSetup(t, release, result, nil)
+
+	// The message is settled while recognition is still running
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	assert.Equal(t, 1, p.pollers.Outstanding())
+	assert.Empty(t, stub.sentMessages())
+
+	close(release)
+	assert.NoError(t, p.pollers.Wait(context.Background()))
+
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	if sent := stub.sentMessages(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "Привет", sent[0]["text"])
+	}
+	assert.Empty(t, publisher.published())
+}
+
+func TestProcessor_BackgroundPollRequeuesFailedTask(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	p, task, stub, publisher := backgroundPollSetup(t, release, nil, speechkit.ErrRecognitionTimeout)
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	assert.NoError(t, p.pollers.Wait(context.Background()))
+
+	// The queue message is gone, so the retry is published anew
+	assert.Equal(t, model.TaskStatusTimeout, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+	if published := publisher.published(); assert.Len(t, published, 1) {
+		assert.Equal(t, "task-123", published[0].TaskID)
+	}
+	assert.Empty(t, stub.sentMessages())
+}
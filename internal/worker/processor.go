@@ -4,131 +4,411 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"text/template"
 	"time"
+	"unicode"
+	"voxly/internal/config"
+	"voxly/internal/i18n"
+	"voxly/internal/preferences"
 	"voxly/internal/queue"
 	"voxly/internal/speechkit"
 	"voxly/internal/storage"
 	"voxly/pkg/cache"
+	"voxly/pkg/httpclient"
 	"voxly/pkg/logger"
 	"voxly/pkg/model"
+	"voxly/pkg/resilience"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	tele "gopkg.in/telebot.v4"
 )
 
+// TaskStore is the subset of the database used by the processor
+type TaskStore interface {
+	GetTaskByID(ctx context.Context, id string) (*model.Task, error)
+	ClaimTask(ctx context.Context, id, workerID string, maxAttempts int, staleAfter time.Duration) (*model.Task, error)
+	UpdateTask(ctx context.Context, task *model.Task) error
+	CreateTranscript(ctx context.Context, transcript *model.Transcript) error
+	preferences.Storage
+}
+
+// FileStore is the subset of object storage used by the processor
+type FileStore interface {
+	UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error)
+	GenerateKey(taskID, extension string) string
+	GenerateTranscriptKey(taskID, extension string) string
+}
+
+// Recognizer runs speech recognition for uploaded audio
+type Recognizer interface {
+	StartRecognition(s3URI string, opts speechkit.RecognitionOptions) (string, error)
+	WaitForResult(ctx context.Context, operationID string, onProgress speechkit.ProgressFunc) (*speechkit.RecognitionResult, error)
+	CancelOperation(ctx context.Context, operationID string) error
+}
+
 type Processor struct {
-	db         *storage.PostgresStorage
-	s3         *storage.S3Storage
-	speechkit  *speechkit.Client
+	cfg        *config.Config
+	db         TaskStore
+	s3         FileStore
+	speechkit  Recognizer
 	bot        *tele.Bot
 	cache      cache.Cache
+	prefs      *preferences.Store
 	httpClient *http.Client
+	footer     *template.Template
+	replyTo    string
+	models     speechkit.ModelSelection
+	// reprocessModel is offered for low-confidence transcripts; empty disables the offer
+	reprocessModel speechkit.Model
+	texts          *i18n.Catalog
+	trimmer        AudioTrimmer
+	// workerID claims queue deliveries, as DBPoller claims polled tasks
+	workerID string
+
+	// maintenancePoll is how often a paused worker rechecks the maintenance flag
+	maintenancePoll time.Duration
+	// stopped is cancelled by Stop and ends the maintenance pause of held tasks
+	stopped context.Context
+	stop    context.CancelFunc
+
+	// sendLimiter paces replies across all chats; floodWaitUnit scales Telegram's retry_after
+	sendLimiter   *resilience.RateLimiter
+	floodWaitUnit time.Duration
+	// chatPacer spaces out replies to the same group
+	chatPacer *chatPacer
+	// telegramBreaker and sendRetry guard replies; outbox, when set, keeps the
+	// ones Telegram was unavailable for
+	telegramBreaker *resilience.CircuitBreaker
+	sendRetry       *resilience.RetryConfig
+	outbox          OutboxStore
+
+	completeHooks []CompleteHook
+	// logChatLimiter paces transcript copies to Telegram.LogChatID
+	logChatLimiter *resilience.RateLimiter
+	results        ResultPublisher
+
+	// dbRetry bounds retries of task state writes
+	dbRetry *resilience.RetryConfig
+	// urlFetcher downloads audio sent as a link
+	urlFetcher *httpclient.SafeFetcher
+	// warmupRetry bounds the dependency checks before consuming; nil skips them
+	warmupRetry *resilience.RetryConfig
+
+	// pollers, when set, wait for recognition results off the consuming goroutine;
+	// tasks republishes the ones to retry
+	pollers *PollerPool
+	tasks   TaskPublisher
+
+	// resultCounters counts hits and misses of the recognition result cache
+	resultCounters resultCounters
 }
 
+// defaultDownloadTimeout applies when no Telegram download timeout is configured
+const defaultDownloadTimeout = 60 * time.Second
+
 // NewProcessor creates a new worker processor
 func NewProcessor(
-	db *storage.PostgresStorage,
-	s3 *storage.S3Storage,
-	speechkitClient *speechkit.Client,
+	cfg *config.Config,
+	db TaskStore,
+	s3 FileStore,
+	speechkitClient Recognizer,
 	bot *tele.Bot,
 	redisCache cache.Cache,
+	httpClient *http.Client,
 ) *Processor {
-	return &Processor{
-		db:        db,
-		s3:        s3,
-		speechkit: speechkitClient,
-		bot:       bot,
-		cache:     redisCache,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	footer, err := parseFooterTemplate(cfg.Reply.FooterTemplate)
+	if err != nil {
+		logger.Error("Reply footer disabled", zap.Error(err))
+	}
+
+	replyTo, err := parseReplyTarget(cfg.Reply.To)
+	if err != nil {
+		logger.Error("Invalid reply target, replying to voice messages", zap.Error(err))
+	}
+
+	texts, err := i18n.New(cfg.Telegram.DefaultLocale)
+	if err != nil {
+		logger.Error("Unknown reply locale, using default",
+			zap.String("default", i18n.DefaultLocale),
+			zap.Error(err))
+	}
+
+	stopped, stop := context.WithCancel(context.Background())
+
+	p := &Processor{
+		cfg:             cfg,
+		db:              db,
+		s3:              s3,
+		speechkit:       speechkitClient,
+		bot:             bot,
+		cache:           redisCache,
+		prefs:           preferences.NewStore(db, redisCache),
+		httpClient:      httpClient,
+		footer:          footer,
+		replyTo:         replyTo,
+		models:          newModelSelection(cfg),
+		reprocessModel:  newReprocessModel(cfg),
+		texts:           texts,
+		trimmer:         newAudioTrimmer(cfg),
+		maintenancePoll: 10 * time.Second,
+		stopped:         stopped,
+		stop:            stop,
+		sendLimiter:     newSendLimiter(cfg.Telegram.SendRate),
+		chatPacer:       newChatPacer(cfg.Telegram.GroupSendRate),
+		floodWaitUnit:   time.Second,
+		telegramBreaker: newTelegramBreaker(cfg.Telegram.BreakerMaxFailures, cfg.Telegram.BreakerTimeout),
+		sendRetry:       newSendRetry(cfg.Telegram.SendRetryAttempts),
+		dbRetry:         defaultDBRetry(),
+		urlFetcher:      newAudioURLFetcher(),
+		warmupRetry:     newWarmupRetry(cfg),
+		workerID:        DefaultWorkerID(),
+	}
+
+	if cfg.Telegram.LogChatID != 0 {
+		p.logChatLimiter = newLogChatLimiter(cfg.Telegram.LogChatRate)
+		p.OnComplete(p.copyToLogChat)
+	}
+	return p
+}
+
+// newModelSelection reads model settings, falling back to the default model on
+// invalid names. Deferred models are refused: the worker would give up waiting
+// for their results.
+func newModelSelection(cfg *config.Config) speechkit.ModelSelection {
+	defaultModel, err := speechkit.ParseSelectableModel(cfg.SpeechKit.Model)
+	if err != nil {
+		logger.Error("Invalid SpeechKit model, using default",
+			zap.String("default", string(speechkit.DefaultModel)),
+			zap.Error(err))
+		defaultModel = speechkit.DefaultModel
 	}
+
+	selection := speechkit.ModelSelection{
+		Default:        defaultModel,
+		LongAudioAfter: cfg.SpeechKit.LongAudioAfter,
+	}
+	if cfg.SpeechKit.LongAudioModel != "" {
+		longModel, err := speechkit.ParseSelectableModel(cfg.SpeechKit.LongAudioModel)
+		if err != nil {
+			logger.Error("Invalid SpeechKit long audio model, auto-selection disabled", zap.Error(err))
+		} else {
+			selection.LongAudio = longModel
+		}
+	}
+	if cfg.SpeechKit.NarrowbandModel != "" {
+		narrowbandModel, err := speechkit.ParseSelectableModel(cfg.SpeechKit.NarrowbandModel)
+		if err != nil {
+			logger.Error("Invalid SpeechKit narrowband model, using the default for phone-quality audio", zap.Error(err))
+		} else {
+			selection.Narrowband = narrowbandModel
+		}
+	}
+	return selection
 }
 
-// ProcessTask processes a voice message task
-func (p *Processor) ProcessTask(taskData []byte) error {
+// ProcessTask processes a voice message task.
+// A panic after the task is loaded fails the task instead of crashing the worker.
+func (p *Processor) ProcessTask(taskData []byte) (err error) {
 	var voiceTask queue.VoiceTask
 	if err := json.Unmarshal(taskData, &voiceTask); err != nil {
 		return fmt.Errorf("failed to unmarshal task: %w", err)
 	}
 
-	logger.Info("Processing voice task",
-		zap.String("task_id", voiceTask.TaskID),
-		zap.Int64("chat_id", voiceTask.ChatID))
+	// Every entry of the task's lifecycle carries its IDs
+	log := logger.WithTask(voiceTask.TaskID).With(zap.Int64("chat_id", voiceTask.ChatID))
+	log.Info("Processing voice task")
+
+	// Holding the message pauses consumption, as the channel prefetches one message.
+	// A worker stopping meanwhile hands the task back instead of processing it.
+	if err := p.waitWhileMaintenance(p.stopped); err != nil {
+		return fmt.Errorf("worker stopped during maintenance: %w", err)
+	}
 
 	ctx := context.Background()
 
+	startedAt := time.Now()
+	var timings model.Timings
+
 	// Get task from database
-	task, err := p.db.GetTaskByID(ctx, voiceTask.TaskID)
+	task, err := p.claimTask(ctx, &voiceTask)
+	if errors.Is(err, storage.ErrTaskClaimed) {
+		log.Info("Task is processed by another worker or finished, skipping")
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get task from db: %w", err)
 	}
 
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Task processing panicked",
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			err = p.handleTaskError(ctx, task, fmt.Errorf("%w: %v", errTaskPanicked, r))
+		}
+	}()
+
+	prefs := p.chatPreferences(ctx, task.ChatID)
+	mode := p.parseMode(prefs)
+
+	// An earlier attempt may have started an operation that is still running
+	staleOperationID := task.CurrentOperationID()
+
 	// Update task status to in_progress
 	task.SetInProgress("")
-	if err := p.db.UpdateTask(ctx, task); err != nil {
-		logger.Error("Failed to update task status", zap.Error(err))
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Error("Failed to update task status", zap.Error(err))
+		return err
 	}
 
-	// Download file from Telegram
-	fileData, err := p.downloadTelegramFile(voiceTask.FileID)
+	// Bound the pipeline so a stalled stage can't hold the worker slot for long.
+	// Status updates keep using ctx so a failure can still be recorded after the deadline.
+	taskCtx, cancel := p.taskContext(ctx, voiceTask.Duration)
+	defer func() { cancel() }()
+
+	// Download the audio from Telegram or the link it was sent as
+	stageStart := time.Now()
+	if voiceTask.FileSize > queue.MaxFileSize {
+		return p.handleTaskError(ctx, task, fmt.Errorf("%w: %d bytes", errFileTooLarge, voiceTask.FileSize))
+	}
+	fileData, err := p.downloadAudio(taskCtx, voiceTask)
 	if err != nil {
-		p.handleTaskError(ctx, task, fmt.Sprintf("Failed to download file: %v", err))
-		return err
+		return p.handleTaskError(ctx, task, fmt.Errorf("%w: %w", errDownloadFailed, deadlineError(taskCtx, err)))
 	}
+	timings.DownloadMs = time.Since(stageStart).Milliseconds()
 
-	logger.Info("File downloaded from Telegram",
-		zap.String("task_id", task.ID),
+	log.Info("Audio file downloaded",
 		zap.Int("size", len(fileData)))
+	fileData, audioFormat, trimmedLead := p.prepareAudio(taskCtx, log, fileData)
+	recognitionModel := p.recognitionModel(task, voiceTask.Duration, audioFormat)
+	task.SetModel(string(recognitionModel))
+	language := p.requestLanguage(ctx, prefs)
+	opts := speechkit.RecognitionOptions{
+		Model:           recognitionModel,
+		Language:        language,
+		Audio:           audioFormat,
+		ProfanityFilter: prefs.ProfanityFilter,
+	}
 
-	// Upload to S3
-	s3Key := p.s3.GenerateKey(task.ID, ".ogg")
-	s3URL, err := p.s3.UploadFile(ctx, s3Key, bytes.NewReader(fileData), "audio/ogg")
-	if err != nil {
-		p.handleTaskError(ctx, task, fmt.Sprintf("Failed to upload to S3: %v", err))
-		return err
+	run := &taskRun{
+		log:       log,
+		task:      task,
+		voiceTask: voiceTask,
+		prefs:     prefs,
+		mode:      mode,
+		language:  language,
+		timings:   timings,
+		startedAt: startedAt,
+
+		trimmedLead: trimmedLead,
 	}
 
-	logger.Info("File uploaded to S3",
-		zap.String("task_id", task.ID),
-		zap.String("s3_url", s3URL))
+	// The same recording sent again reuses its result
+	resultKey := p.resultCacheKey(fileData, opts)
+	task.SetResultCacheKey(resultKey)
+	if result, ok := p.cachedResult(taskCtx, log, resultKey); ok {
+		run.cachedResult = true
+		return p.finishTask(ctx, run, result, nil)
+	}
+	run.resultKey = resultKey
 
-	// Start speech recognition
-	operationID, err := p.speechkit.StartRecognition(s3URL)
+	if segments := p.splitSegments(log, voiceTask.Duration, fileData); len(segments) > 1 {
+		stageStart = time.Now()
+		result, err := p.recognizeSegments(taskCtx, log, task, segments, opts)
+		run.timings.RecognitionMs = time.Since(stageStart).Milliseconds()
+		return p.finishTask(ctx, run, result, deadlineError(taskCtx, err))
+	}
+
+	operationID, err := p.startFileRecognition(ctx, taskCtx, run, fileData, opts, staleOperationID)
 	if err != nil {
-		p.handleTaskError(ctx, task, fmt.Sprintf("Failed to start recognition: %v", err))
-		return err
+		return p.finishTask(ctx, run, nil, deadlineError(taskCtx, err))
 	}
 
-	task.OperationID = &operationID
-	if err := p.db.UpdateTask(ctx, task); err != nil {
-		logger.Error("Failed to update operation_id", zap.Error(err))
+	if p.pollers != nil {
+		// The poll owns the task deadline from here on
+		release := cancel
+		cancel = func() {}
+		p.pollers.Go(func() {
+			defer release()
+			p.awaitInBackground(ctx, taskCtx, run, operationID)
+		})
+		log.Info("Recognition poll moved to background",
+			zap.Int("outstanding_polls", p.pollers.Outstanding()))
+		return nil
 	}
 
-	logger.Info("Recognition started",
-		zap.String("task_id", task.ID),
-		zap.String("operation_id", operationID))
+	result, err := p.waitFileRecognition(taskCtx, run, operationID)
+	return p.finishTask(ctx, run, result, deadlineError(taskCtx, err))
+}
+
+// taskRun is one processing attempt of a task, carried from recognition to the reply
+type taskRun struct {
+	log       *zap.Logger
+	task      *model.Task
+	voiceTask queue.VoiceTask
+	prefs     *model.ChatPreferences
+	mode      tele.ParseMode
+	language  string
+	timings   model.Timings
+	startedAt time.Time
+	// partial is set when recognition stopped early and result holds only the chunks done by then
+	partial bool
+
+	// recognitionStart is when the recognition operation was requested
+	recognitionStart time.Time
+	// resultKey caches the recognition result once it's complete; empty skips caching
+	resultKey string
+	// cachedResult is set when the result came from the result cache without a recognition
+	cachedResult bool
+	// trimmedLead is the silence cut from the start of the recording before upload
+	trimmedLead time.Duration
+}
+
+// finishTask stores and delivers the recognition result. It returns the error
+// of a failed recognition when the task should be retried.
+func (p *Processor) finishTask(ctx context.Context, run *taskRun, result *speechkit.RecognitionResult, err error) error {
+	log, task, voiceTask, prefs, mode := run.log, run.task, run.voiceTask, run.prefs, run.mode
+	language, timings, startedAt := run.language, run.timings, run.startedAt
 
-	// Wait for recognition result
-	result, err := p.speechkit.WaitForResult(operationID)
 	if err != nil {
-		p.handleTaskError(ctx, task, fmt.Sprintf("Recognition failed: %v", err))
-		return err
+		return p.handleTaskError(ctx, task, err)
+	}
+
+	// The cache keeps the result as recognized: it is keyed by the trimmed audio
+	recognized := result
+	result = untrimResult(result, run.trimmedLead)
+
+	// Without a supported detected language the task keeps auto and the reply omits it
+	if language == speechkit.LanguageAuto {
+		if detected := supportedDetectedLanguage(log, result.DetectedLanguage()); detected != "" {
+			language = detected
+			p.rememberLanguage(ctx, task.ChatID, language)
+		}
+	}
+	task.SetLanguage(language)
+	if result.Operation != nil {
+		task.SetRecognitionInfo(recognitionInfo(result.Operation))
 	}
 
 	// Extract text
-	recognizedText := result.GetFullText()
-	if recognizedText == "" {
-		p.handleTaskError(ctx, task, "No text recognized")
-		return fmt.Errorf("no text recognized")
+	recognizedText := strings.TrimSpace(result.BestText())
+	if !isMeaningfulText(recognizedText) {
+		// Silence won't recognize any better on retry, so finish the task right away
+		return p.finishNoSpeech(ctx, log, task, mode, &timings, startedAt)
 	}
 
-	logger.Info("Recognition completed",
-		zap.String("task_id", task.ID),
+	log.Info("Recognition completed",
 		zap.Int("text_length", len(recognizedText)))
 
 	// Save transcript to database
@@ -141,55 +421,365 @@ func (p *Processor) ProcessTask(taskData []byte) error {
 		CreatedAt:   time.Now(),
 	}
 
-	if err := p.db.CreateTranscript(ctx, transcript); err != nil {
-		logger.Error("Failed to save transcript", zap.Error(err))
+	if err := p.saveTranscript(ctx, transcript); err != nil {
+		log.Error("Failed to save transcript", zap.Error(err))
+		return err
+	}
+
+	if p.cfg.S3.BackupTranscripts {
+		p.backupTranscript(ctx, transcript)
+	}
+
+	if !run.partial {
+		p.cacheResult(ctx, log, run.resultKey, recognized)
 	}
 
-	// Cache transcript for fast retrieval (TTL: 7 days)
-	transcriptKey := cache.TranscriptCacheKey(task.ID)
-	if err := p.cache.SetWithTTL(ctx, transcriptKey, transcript, 7*24*time.Hour); err != nil {
-		logger.Error("Failed to cache transcript", zap.Error(err))
+	// Cache transcript for fast retrieval; with results published, their consumer does it
+	if p.results == nil {
+		transcriptKey := cache.TranscriptCacheKey(task.ID)
+		if err := p.cache.SetWithTTL(ctx, transcriptKey, transcript, p.cfg.TranscriptCacheTTL()); err != nil {
+			log.Error("Failed to cache transcript", zap.Error(err))
+		}
 	}
 
 	// Cache task status
 	taskKey := cache.TaskCacheKey(task.ID)
-	if err := p.cache.SetWithTTL(ctx, taskKey, task, 7*24*time.Hour); err != nil {
-		logger.Error("Failed to cache task", zap.Error(err))
+	if err := p.cache.SetWithTTL(ctx, taskKey, task, p.cfg.TranscriptCacheTTL()); err != nil {
+		log.Error("Failed to cache task", zap.Error(err))
+	}
+
+	// Send result back to user
+	timings.TotalMs = time.Since(startedAt).Milliseconds()
+	replyText := transcriptText(prefs, result, recognizedText)
+	if run.partial {
+		replyText += "\n\n" + p.texts.Text(i18n.PartialResult)
+	}
+	reply := p.buildReply(task, &voiceTask, prefs, result, replyText, time.Duration(timings.TotalMs)*time.Millisecond)
+	var markup *tele.ReplyMarkup
+	if p.offerReprocess(task, result) {
+		markup = reprocessMarkup(p.texts, task.ID)
 	}
 
-	// Update task status to done
+	// Update task status to done before replying: a task requeued after its
+	// reply went out would be recognized and sent again
+	task.SetTimings(timings)
 	task.SetCompleted()
-	if err := p.db.UpdateTask(ctx, task); err != nil {
-		logger.Error("Failed to update task status to done", zap.Error(err))
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Error("Failed to update task status to done", zap.Error(err))
+		return err
 	}
 
-	// Send result back to user
-	if err := p.sendResultToUser(voiceTask.ChatID, voiceTask.TelegramMessageID, recognizedText); err != nil {
-		logger.Error("Failed to send result to user", zap.Error(err))
+	previousReply := task.ReplyMessageID()
+	if err := p.sendResultToUser(ctx, task, reply, mode, markup); err != nil {
+		p.handleSendError(ctx, task.ChatID, err)
 		// Don't return error - task is completed anyway
 	}
+	p.saveSentReply(ctx, log, task, previousReply)
+
+	// Lets the bot estimate how long the next tasks will take. Cached and partial
+	// results skipped some of the recognition and would pull the average down.
+	if !run.cachedResult && !run.partial {
+		took := time.Duration(timings.TotalMs) * time.Millisecond
+		if err := cache.UpdateProcessingRate(ctx, p.cache, time.Duration(voiceTask.Duration)*time.Second, took); err != nil {
+			log.Warn("Failed to update processing rate", zap.Error(err))
+		}
+	}
+
+	p.runCompleteHooks(ctx, task, transcript)
+	p.publishResult(&queue.TranscriptionResult{
+		TaskID:       task.ID,
+		TranscriptID: transcript.ID,
+		Version:      transcript.Version,
+		CreatedAt:    transcript.CreatedAt,
+		Text:         transcript.Text,
+		RawResponse:  transcript.RawResponse,
+		Success:      true,
+	})
 
-	logger.Info("Task completed successfully",
-		zap.String("task_id", task.ID))
+	log.Info("Task completed successfully")
 
 	return nil
 }
 
+// recognitionInfo keeps the operation details worth storing with the task
+func recognitionInfo(op *speechkit.OperationInfo) model.RecognitionInfo {
+	info := model.RecognitionInfo{
+		OperationID:     op.ID,
+		MetadataType:    op.Metadata.Type,
+		ProgressPercent: op.Metadata.ProgressPercent,
+		AudioDurationMs: op.Metadata.AudioDuration.Milliseconds(),
+	}
+	if !op.CreatedAt.IsZero() && op.ModifiedAt.After(op.CreatedAt) {
+		info.OperationMs = op.ModifiedAt.Sub(op.CreatedAt).Milliseconds()
+	}
+	return info
+}
+
+// startFileRecognition uploads the whole recording and starts recognizing it as
+// one operation. Status updates use ctx; the pipeline stages are bounded by taskCtx.
+func (p *Processor) startFileRecognition(ctx, taskCtx context.Context, run *taskRun, fileData []byte, opts speechkit.RecognitionOptions, staleOperationID string) (string, error) {
+	log, task := run.log, run.task
+
+	// Upload to S3
+	stageStart := time.Now()
+	s3URL, err := p.storeAudio(taskCtx, log, task, fileData)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	run.timings.UploadMs = time.Since(stageStart).Milliseconds()
+
+	log.Info("File uploaded to S3",
+		zap.String("s3_url", s3URL))
+
+	// Start speech recognition
+	run.recognitionStart = time.Now()
+	operationID, err := p.speechkit.StartRecognition(s3URL, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to start recognition: %w", err)
+	}
+
+	task.OperationID = &operationID
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Error("Failed to update operation_id", zap.Error(err))
+	}
+	p.cancelStaleOperation(ctx, log, staleOperationID, operationID)
+
+	log.Info("Recognition started",
+		zap.String("operation_id", operationID),
+		zap.String("model", string(opts.Model)))
+
+	return operationID, nil
+}
+
+// waitFileRecognition polls the operation until its result is ready
+func (p *Processor) waitFileRecognition(taskCtx context.Context, run *taskRun, operationID string) (*speechkit.RecognitionResult, error) {
+	result, err := p.speechkit.WaitForResult(taskCtx, operationID, func(progress speechkit.Progress) {
+		run.log.Info("Recognition progress",
+			zap.Int("percent", progress.Percent),
+			zap.Duration("elapsed", progress.Elapsed))
+	})
+	var partial *speechkit.PartialResultError
+	if errors.As(err, &partial) && isMeaningfulText(partial.Result.BestText()) {
+		// Some text beats a failure, and a retry would likely time out again
+		run.log.Warn("Recognition stopped early, delivering partial result",
+			zap.Int("chunks", len(partial.Result.Chunks)),
+			zap.Error(partial.Err))
+		if cancelErr := p.speechkit.CancelOperation(context.Background(), operationID); cancelErr != nil {
+			run.log.Warn("Failed to cancel unfinished operation",
+				zap.String("operation_id", operationID),
+				zap.Error(cancelErr))
+		}
+		result, err, run.partial = partial.Result, nil, true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recognition result: %w", err)
+	}
+	run.timings.RecognitionMs = time.Since(run.recognitionStart).Milliseconds()
+
+	return result, nil
+}
+
+// cancelStaleOperation cancels the operation of an earlier attempt once a new
+// one is tracked, so the audio isn't billed twice and the old result is never used
+func (p *Processor) cancelStaleOperation(ctx context.Context, log *zap.Logger, staleID, currentID string) {
+	if staleID == "" || staleID == currentID {
+		return
+	}
+
+	log.Warn("Task already had a recognition operation, cancelling it",
+		zap.String("stale_operation_id", staleID),
+		zap.String("operation_id", currentID))
+
+	if err := p.speechkit.CancelOperation(ctx, staleID); err != nil {
+		// It may have finished already; its result is ignored either way
+		log.Warn("Failed to cancel stale operation",
+			zap.String("operation_id", staleID),
+			zap.Error(err))
+	}
+}
+
+// Stop ends the maintenance pause of tasks held by the worker, so they are
+// handed back to the queue at shutdown. Call it once the worker is stopping.
+func (p *Processor) Stop() {
+	p.stop()
+}
+
+// waitWhileMaintenance blocks until maintenance mode is switched off. It
+// returns ctx's error if ctx is cancelled first.
+func (p *Processor) waitWhileMaintenance(ctx context.Context) error {
+	// A cancelled ctx must not read as maintenance being off
+	checkCtx := context.WithoutCancel(ctx)
+	if !cache.MaintenanceEnabled(checkCtx, p.cache) {
+		return nil
+	}
+
+	logger.Warn("Maintenance mode is on, pausing task processing")
+
+	ticker := time.NewTicker(p.maintenancePoll)
+	defer ticker.Stop()
+
+	for cache.MaintenanceEnabled(checkCtx, p.cache) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	logger.Info("Maintenance mode is off, resuming task processing")
+	return nil
+}
+
+// finishNoSpeech completes a task whose audio contained no recognizable speech.
+// It returns an error only when the final state couldn't be saved.
+func (p *Processor) finishNoSpeech(ctx context.Context, log *zap.Logger, task *model.Task, mode tele.ParseMode, timings *model.Timings, startedAt time.Time) error {
+	log.Info("No speech recognized")
+
+	timings.TotalMs = time.Since(startedAt).Milliseconds()
+	task.SetTimings(*timings)
+	task.SetNoSpeech()
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Error("Failed to update task status to no_speech", zap.Error(err))
+		return err
+	}
+
+	previousReply := task.ReplyMessageID()
+	message := escapeText(p.texts.Text(i18n.NoSpeech), mode)
+	if err := p.sendResultToUser(ctx, task, message, mode, nil); err != nil {
+		p.handleSendError(ctx, task.ChatID, err)
+	}
+	p.saveSentReply(ctx, log, task, previousReply)
+
+	// Silence is a successful recognition with nothing to say
+	p.publishResult(&queue.TranscriptionResult{TaskID: task.ID, Success: true})
+	return nil
+}
+
+// saveSentReply stores the reply sent for a finished task, so edits and
+// /delete find it. A failure is only logged: requeueing the task would send
+// the transcript again.
+func (p *Processor) saveSentReply(ctx context.Context, log *zap.Logger, task *model.Task, previousReply int) {
+	if task.ReplyMessageID() == previousReply {
+		return
+	}
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Warn("Failed to save reply of finished task", zap.Error(err))
+	}
+}
+
+// isMeaningfulText reports whether a transcript has any letters or digits.
+// SpeechKit sometimes returns only punctuation for noise, which is no speech either.
+func isMeaningfulText(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// backupTranscript archives transcript text and raw response to S3
+func (p *Processor) backupTranscript(ctx context.Context, transcript *model.Transcript) {
+	textKey := p.s3.GenerateTranscriptKey(transcript.TaskID, ".txt")
+	if _, err := p.uploadFile(ctx, textKey, strings.NewReader(transcript.Text), "text/plain; charset=utf-8"); err != nil {
+		logger.Error("Failed to back up transcript text",
+			zap.String("task_id", transcript.TaskID),
+			zap.Error(err))
+	}
+
+	if len(transcript.RawResponse) == 0 {
+		return
+	}
+
+	rawKey := p.s3.GenerateTranscriptKey(transcript.TaskID, ".json")
+	if _, err := p.uploadFile(ctx, rawKey, bytes.NewReader(transcript.RawResponse), "application/json"); err != nil {
+		logger.Error("Failed to back up raw recognition response",
+			zap.String("task_id", transcript.TaskID),
+			zap.Error(err))
+	}
+}
+
+// downloadTimeout returns the configured Telegram download timeout
+func (p *Processor) downloadTimeout() time.Duration {
+	if p.cfg.Telegram.DownloadTimeout > 0 {
+		return p.cfg.Telegram.DownloadTimeout
+	}
+	return defaultDownloadTimeout
+}
+
+// s3Timeout returns the configured S3 call timeout
+func (p *Processor) s3Timeout() time.Duration {
+	return p.cfg.S3CallTimeout()
+}
+
+// s3Context bounds a single S3 call with its own deadline, so a stalled one
+// can't hold a worker until the whole task times out
+func (p *Processor) s3Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, p.s3Timeout())
+}
+
+// uploadFile stores an object in S3 within the S3 call timeout
+func (p *Processor) uploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	ctx, cancel := p.s3Context(ctx)
+	defer cancel()
+
+	return p.s3.UploadFile(ctx, key, body, contentType)
+}
+
 // downloadTelegramFile downloads file from Telegram
-func (p *Processor) downloadTelegramFile(fileID string) ([]byte, error) {
+func (p *Processor) downloadTelegramFile(ctx context.Context, fileID string) ([]byte, error) {
+	path, err := p.telegramFilePath(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.fetchTelegramFile(ctx, path)
+	if !errors.Is(err, errFilePathExpired) {
+		return data, err
+	}
+
+	// Paths are only valid for about an hour, which a task can spend in the queue
+	logger.Warn("Telegram file path expired, fetching a fresh one",
+		zap.String("file_id", fileID),
+		zap.String("file_path", path))
+
+	path, err = p.telegramFilePath(fileID)
+	if err != nil {
+		return nil, err
+	}
+	return p.fetchTelegramFile(ctx, path)
+}
+
+// telegramFilePath asks Telegram for the current download path of a file
+func (p *Processor) telegramFilePath(fileID string) (string, error) {
 	file, err := p.bot.FileByID(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file info: %w", err)
+		if isFileTooBig(err) {
+			return "", fmt.Errorf("%w: %w", errFileTooLarge, err)
+		}
+		return "", fmt.Errorf("failed to get file info: %w", err)
 	}
+	return file.FilePath, nil
+}
 
-	fileURL := p.bot.URL + "/file/bot" + p.bot.Token + "/" + file.FilePath
+// fetchTelegramFile downloads a file by the path getFile returned
+func (p *Processor) fetchTelegramFile(ctx context.Context, path string) ([]byte, error) {
+	fileURL := p.bot.URL + "/file/bot" + p.bot.Token + "/" + path
+
+	ctx, cancel := context.WithTimeout(ctx, p.downloadTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
 
-	resp, err := p.httpClient.Get(fileURL)
+	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to download file: %w", errFilePathExpired)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to download file: status=%d", resp.StatusCode)
 	}
@@ -202,36 +792,248 @@ func (p *Processor) downloadTelegramFile(fileID string) ([]byte, error) {
 	return data, nil
 }
 
-// sendResultToUser sends recognition result back to user
-func (p *Processor) sendResultToUser(chatID, replyToMessageID int64, text string) error {
-	chat := &tele.Chat{ID: chatID}
+// buildReply formats the transcript with the configured footer
+func (p *Processor) buildReply(
+	task *model.Task,
+	voiceTask *queue.VoiceTask,
+	prefs *model.ChatPreferences,
+	result *speechkit.RecognitionResult,
+	text string,
+	processing time.Duration,
+) string {
+	mode := p.parseMode(prefs)
+	confidence, hasConfidence := result.AverageConfidence()
+	language := task.Language()
+	switch language {
+	case "":
+		language = speechkit.DefaultLanguage
+	case speechkit.LanguageAuto:
+		// Nothing supported was detected
+		language = ""
+	}
+	data := newFooterData(voiceTask.Duration, language, confidence, hasConfidence, processing, mode)
 
-	_, err := p.bot.Send(chat, text, &tele.SendOptions{
-		ReplyTo: &tele.Message{ID: int(replyToMessageID)},
-	})
+	footer, err := renderFooter(p.footer, data)
+	if err != nil {
+		logger.Error("Failed to render reply footer",
+			zap.String("task_id", voiceTask.TaskID),
+			zap.Error(err))
+	}
+
+	if hasConfidence && confidence < prefs.ConfidenceThreshold(p.cfg.Reply.ConfidenceThreshold) {
+		text += "\n\n" + lowConfidenceWarning(p.texts, confidence)
+	}
 
-	return err
+	if from := task.ForwardFrom(); from != "" && p.cfg.Reply.ForwardAttribution {
+		text = forwardAttribution(p.texts, from) + "\n" + text
+	}
+
+	return formatReply(text, footer, mode)
+}
+
+// transcriptText returns the text to reply with: the recognized text, or every
+// phrase on its own line with its start time when the chat turned timestamps on
+func transcriptText(prefs *model.ChatPreferences, result *speechkit.RecognitionResult, recognized string) string {
+	if !prefs.Timestamps {
+		return recognized
+	}
+	if text := result.GetTextWithTimestamps(); text != "" {
+		return text
+	}
+	return recognized
+}
+
+// chatPreferences returns the chat's preferences, or empty ones falling back
+// to configuration when they can't be read
+func (p *Processor) chatPreferences(ctx context.Context, chatID int64) *model.ChatPreferences {
+	prefs, err := p.prefs.Get(ctx, chatID)
+	if err != nil {
+		logger.WithChat(chatID).Error("Failed to get chat preferences", zap.Error(err))
+		return &model.ChatPreferences{ChatID: chatID}
+	}
+	return prefs
+}
+
+// parseMode returns the chat's reply formatting, falling back to the configured one
+func (p *Processor) parseMode(prefs *model.ChatPreferences) tele.ParseMode {
+	if prefs.ParseMode != "" {
+		return tele.ParseMode(prefs.ParseMode)
+	}
+	return tele.ParseMode(p.cfg.Reply.ParseMode)
+}
+
+// sendResultToUser sends recognition result back to user, split into several
+// messages when it exceeds Telegram's limit. markup, if any, goes under the
+// first message, the one a reprocessed task edits.
+func (p *Processor) sendResultToUser(ctx context.Context, task *model.Task, text string, mode tele.ParseMode, markup *tele.ReplyMarkup) error {
+	opts := p.replyOptions(task)
+	opts.ParseMode = mode
+
+	chunks := p.replyChunks(text, mode)
+	for i, chunk := range chunks {
+		chunkOpts := *opts
+		if i == 0 {
+			chunkOpts.ReplyMarkup = markup
+		}
+
+		// A reprocessed task updates its earlier transcript instead of replying again
+		if i == 0 && p.editReply(ctx, task, chunk, mode, markup) {
+			continue
+		}
+
+		msg, err := p.send(ctx, &tele.Chat{ID: task.ChatID}, chunk, &chunkOpts)
+		if err != nil {
+			// The rest of the transcript waits for Telegram instead of being lost
+			if p.deferReply(ctx, task, chunks[i:], opts, i == 0, err) {
+				return nil
+			}
+			return err
+		}
+		// Kept on the task so an edit of the voice message can update this reply
+		if i == 0 {
+			task.SetReplyMessageID(msg.ID)
+		}
+	}
+	return nil
+}
+
+// editReply puts text into the task's earlier transcript reply. It reports
+// false when there is no such reply or it can't be edited, e.g. was deleted.
+func (p *Processor) editReply(ctx context.Context, task *model.Task, text string, mode tele.ParseMode, markup *tele.ReplyMarkup) bool {
+	replyID := task.ReplyMessageID()
+	if replyID == 0 {
+		return false
+	}
+
+	// Without markup the edit also removes an earlier reprocess button
+	_, err := p.edit(ctx, task.ChatID, replyID, text, &tele.SendOptions{ParseMode: mode, ReplyMarkup: markup})
+	if err == nil || errors.Is(err, tele.ErrMessageNotModified) || errors.Is(err, tele.ErrSameMessageContent) {
+		return true
+	}
+
+	logger.WithTask(task.ID).Warn("Failed to edit transcript reply, sending a new one",
+		zap.Int("reply_message_id", replyID),
+		zap.Error(err))
+	return false
+}
+
+// replyChunks splits a reply into messages, numbering them when configured
+func (p *Processor) replyChunks(text string, mode tele.ParseMode) []string {
+	if !p.cfg.Reply.ChunkNumbering {
+		return splitReply(text, maxMessageLength)
+	}
+
+	separator := p.cfg.Reply.ChunkSeparator
+	if separator == "" {
+		separator = " "
+	}
+	return numberChunks(text, maxMessageLength, separator, mode)
 }
 
-// handleTaskError handles task error
-func (p *Processor) handleTaskError(ctx context.Context, task *model.Task, errorMsg string) {
-	logger.Error("Task processing error",
-		zap.String("task_id", task.ID),
-		zap.String("error", errorMsg))
+// replyOptions sends into the task's forum topic, if any, replying to the
+// message picked by the configured reply target. Replies to the "processing"
+// message go to the voice message when there is none.
+func (p *Processor) replyOptions(task *model.Task) *tele.SendOptions {
+	opts := &tele.SendOptions{ThreadID: task.ThreadID()}
 
-	task.SetError(errorMsg)
+	// Only groups need the reply to tell whose audio it was; private chats and
+	// channels get standalone messages whatever the reply target
+	if standaloneChat(task.ChatType()) {
+		return opts
+	}
+
+	switch p.replyTo {
+	case NoReply:
+		return opts
+	case ReplyToProcessing:
+		if id := task.ProcessingMessageID(); id != 0 {
+			opts.ReplyTo = &tele.Message{ID: id}
+			return opts
+		}
+	}
+
+	opts.ReplyTo = &tele.Message{ID: int(task.TelegramMessageID)}
+	return opts
+}
+
+// maxAttempts returns the configured retry budget
+func (p *Processor) maxAttempts() int {
+	if p.cfg.Worker.MaxAttempts <= 0 {
+		return model.DefaultMaxAttempts
+	}
+	return p.cfg.Worker.MaxAttempts
+}
+
+// handleSendError logs a failed reply and deactivates chats the bot can no longer reach
+func (p *Processor) handleSendError(ctx context.Context, chatID int64, err error) {
+	if !isTerminalTelegramError(err) {
+		logger.Error("Failed to send result to user", zap.Error(err))
+		return
+	}
+
+	logger.Warn("Bot lost access to chat, deactivating it",
+		zap.Int64("chat_id", chatID),
+		zap.Error(err))
+
+	_, err = p.prefs.Update(ctx, chatID, func(prefs *model.ChatPreferences) {
+		prefs.SetActive(false)
+	})
+	if err != nil {
+		logger.Error("Failed to deactivate chat", zap.Error(err))
+	}
+}
+
+// handleTaskError records a failed attempt. It returns taskErr when the task
+// should be requeued, or nil once the task is given up on and the user notified.
+// If the attempt can't be recorded, the save error is returned to requeue the task.
+func (p *Processor) handleTaskError(ctx context.Context, task *model.Task, taskErr error) error {
+	log := logger.WithTask(task.ID)
+	log.Error("Task processing error", zap.Error(taskErr))
+
+	// The deadline is reported apart from errors, so slow recognition stands out.
+	// A single call timing out under a live task is an ordinary failure.
+	timedOut := errors.Is(taskErr, errTaskDeadline) || errors.Is(taskErr, speechkit.ErrRecognitionTimeout)
+	status := model.TaskStatusFailed
+	if timedOut {
+		status = model.TaskStatusTimeout
+	}
+	// A late error must not overwrite a task another attempt already finished
+	if !task.CanTransitionTo(status) {
+		log.Warn("Task already finished, dropping the error",
+			zap.String("status", string(task.Status)))
+		return nil
+	}
+	if timedOut {
+		task.SetTimedOut()
+	} else {
+		task.SetError(taskErr.Error())
+	}
 	task.IncrementAttempts()
 
-	if err := p.db.UpdateTask(ctx, task); err != nil {
-		logger.Error("Failed to update task error", zap.Error(err))
+	// Without the recorded attempt the retry budget would be off, so try again later
+	if err := p.saveTask(ctx, task); err != nil {
+		log.Error("Failed to update task error", zap.Error(err))
+		return err
 	}
 
-	// Optionally notify user about error
-	if task.Attempts >= 3 {
-		chat := &tele.Chat{ID: task.ChatID}
-		message := "Не удалось распознать голосовое сообщение после нескольких попыток."
-		p.bot.Send(chat, message, &tele.SendOptions{
-			ReplyTo: &tele.Message{ID: int(task.TelegramMessageID)},
-		})
+	f := classifyFailure(taskErr)
+	if f.retryable && task.CanRetry(p.maxAttempts()) {
+		return taskErr
+	}
+
+	// Non-retryable failures are reported right away, others once the retry budget is exhausted
+	text := p.texts.Text(f.message)
+	opts := p.replyOptions(task)
+	_, err := p.send(ctx, &tele.Chat{ID: task.ChatID}, text, opts)
+	if err != nil && !p.deferReply(ctx, task, []string{text}, opts, false, err) {
+		p.handleSendError(ctx, task.ChatID, err)
 	}
+
+	p.publishResult(&queue.TranscriptionResult{
+		TaskID:       task.ID,
+		Success:      false,
+		ErrorMessage: taskErr.Error(),
+	})
+
+	return nil
 }
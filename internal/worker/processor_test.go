@@ -2,14 +2,27 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"voxly/internal/config"
+	"voxly/internal/i18n"
+	"voxly/internal/queue"
 	"voxly/internal/speechkit"
+	"voxly/internal/storage"
+	"voxly/pkg/cache"
 	"voxly/pkg/model"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	tele "gopkg.in/telebot.v4"
 )
 
 type MockDB struct {
@@ -29,6 +42,28 @@ func (m *MockDB) GetTaskByID(ctx context.Context, id string) (*model.Task, error
 	return args.Get(0).(*model.Task), args.Error(1)
 }
 
+// ClaimTask returns the task from GetTaskByID, unless a test mocks the claim
+func (m *MockDB) ClaimTask(ctx context.Context, id, workerID string, maxAttempts int, staleAfter time.Duration) (*model.Task, error) {
+	if !m.mocksMethod("ClaimTask") {
+		return m.GetTaskByID(ctx, id)
+	}
+	args := m.Called(ctx, id, workerID, maxAttempts, staleAfter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Task), args.Error(1)
+}
+
+// mocksMethod reports whether the test set up an expectation for method
+func (m *MockDB) mocksMethod(method string) bool {
+	for _, call := range m.ExpectedCalls {
+		if call.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *MockDB) UpdateTask(ctx context.Context, task *model.Task) error {
 	args := m.Called(ctx, task)
 	return args.Error(0)
@@ -47,6 +82,19 @@ func (m *MockDB) GetTranscriptByTaskID(ctx context.Context, taskID string) (*mod
 	return args.Get(0).(*model.Transcript), args.Error(1)
 }
 
+func (m *MockDB) GetChatPreferences(ctx context.Context, chatID int64) (*model.ChatPreferences, error) {
+	args := m.Called(ctx, chatID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ChatPreferences), args.Error(1)
+}
+
+func (m *MockDB) SaveChatPreferences(ctx context.Context, prefs *model.ChatPreferences) error {
+	args := m.Called(ctx, prefs)
+	return args.Error(0)
+}
+
 func (m *MockDB) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -56,8 +104,8 @@ type MockS3 struct {
 	mock.Mock
 }
 
-func (m *MockS3) UploadFile(ctx context.Context, key string, data interface{}, contentType string) (string, error) {
-	args := m.Called(ctx, key, data, contentType)
+func (m *MockS3) UploadFile(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	args := m.Called(ctx, key, body, contentType)
 	return args.String(0), args.Error(1)
 }
 
@@ -66,16 +114,49 @@ func (m *MockS3) GenerateKey(taskID, extension string) string {
 	return args.String(0)
 }
 
+func (m *MockS3) GenerateContentKey(fileHash, extension string) string {
+	args := m.Called(fileHash, extension)
+	return args.String(0)
+}
+
+func (m *MockS3) ObjectExists(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockS3) ObjectURL(key string) string {
+	args := m.Called(key)
+	return args.String(0)
+}
+
+func (m *MockS3) GenerateTranscriptKey(taskID, extension string) string {
+	args := m.Called(taskID, extension)
+	return args.String(0)
+}
+
+func (m *MockS3) ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.ObjectInfo), args.Error(1)
+}
+
+func (m *MockS3) DeleteFile(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
 type MockSpeechKit struct {
 	mock.Mock
 }
 
-func (m *MockSpeechKit) StartRecognition(s3URI string) (string, error) {
-	args := m.Called(s3URI)
+func (m *MockSpeechKit) StartRecognition(s3URI string, opts speechkit.RecognitionOptions) (string, error) {
+	args := m.Called(s3URI, opts)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockSpeechKit) WaitForResult(operationID string) (*speechkit.RecognitionResult, error) {
+func (m *MockSpeechKit) WaitForResult(ctx context.Context, operationID string, onProgress speechkit.ProgressFunc) (*speechkit.RecognitionResult, error) {
 	args := m.Called(operationID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -83,6 +164,16 @@ func (m *MockSpeechKit) WaitForResult(operationID string) (*speechkit.Recognitio
 	return args.Get(0).(*speechkit.RecognitionResult), args.Error(1)
 }
 
+func (m *MockSpeechKit) CancelOperation(ctx context.Context, operationID string) error {
+	args := m.Called(operationID)
+	return args.Error(0)
+}
+
+func (m *MockSpeechKit) CheckCredentials(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 type MockCache struct {
 	mock.Mock
 }
@@ -117,6 +208,919 @@ func (m *MockCache) Close() error {
 	return args.Error(0)
 }
 
+// telegramStub emulates the Bot API endpoints used by the processor
+type telegramStub struct {
+	mu           sync.Mutex
+	fileData     []byte
+	sent         []map[string]string
+	sendError    string
+	getFileError string
+	// sendFailures answer the next sendMessage calls, one each, before sendError applies
+	sendFailures []string
+	edited       []map[string]string
+	editError    string
+	// filePaths are returned by the next getFile calls, one each; expiredPaths answer 404
+	filePaths    []string
+	expiredPaths map[string]bool
+}
+
+func newTelegramStub(t *testing.T, fileData []byte) (*tele.Bot, *telegramStub) {
+	stub := &telegramStub{fileData: fileData}
+	server := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(server.Close)
+
+	bot, err := tele.NewBot(tele.Settings{
+		URL:     server.URL,
+		Token:   "test-token",
+		Offline: true,
+	})
+	assert.NoError(t, err)
+
+	return bot, stub
+}
+
+func (s *telegramStub) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getFile"):
+		if s.getFileError != "" {
+			fmt.Fprint(w, s.getFileError)
+			return
+		}
+		path := "voice/file-123.oga"
+		s.mu.Lock()
+		if len(s.filePaths) > 0 {
+			path, s.filePaths = s.filePaths[0], s.filePaths[1:]
+		}
+		s.mu.Unlock()
+		fmt.Fprintf(w, `{"ok":true,"result":{"file_id":"file-123","file_path":%q}}`, path)
+	case strings.HasPrefix(r.URL.Path, "/file/"):
+		if s.expiredPaths[strings.TrimPrefix(r.URL.Path, "/file/bottest-token/")] {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(s.fileData)
+	case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+		var params map[string]string
+		json.NewDecoder(r.Body).Decode(&params)
+
+		s.mu.Lock()
+		s.sent = append(s.sent, params)
+		messageID := len(s.sent)
+		sendError := s.sendError
+		if len(s.sendFailures) > 0 {
+			sendError, s.sendFailures = s.sendFailures[0], s.sendFailures[1:]
+		}
+		s.mu.Unlock()
+
+		if sendError != "" {
+			fmt.Fprint(w, sendError)
+			return
+		}
+
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"chat":{"id":%s}}}`, messageID, params["chat_id"])
+	case strings.HasSuffix(r.URL.Path, "/editMessageText"):
+		var params map[string]string
+		json.NewDecoder(r.Body).Decode(&params)
+
+		s.mu.Lock()
+		s.edited = append(s.edited, params)
+		editError := s.editError
+		s.mu.Unlock()
+
+		if editError != "" {
+			fmt.Fprint(w, editError)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%s,"chat":{"id":%s}}}`, params["message_id"], params["chat_id"])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *telegramStub) sentMessages() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]string(nil), s.sent...)
+}
+
+func (s *telegramStub) editedMessages() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]string(nil), s.edited...)
+}
+
+func TestNewProcessorWithOptionalUnavailableRedis(t *testing.T) {
+	cfg := testConfig()
+	cfg.Redis.Addr = "127.0.0.1:1"
+	cfg.Redis.ConnectAttempts = 1
+	cfg.Redis.Optional = true
+
+	redisCache, err := cfg.NewCache()
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, redisCache, nil)
+	assert.NotNil(t, p)
+	// The in-memory cache still claims messages for deduplication
+	assert.Implements(t, (*cache.Claimer)(nil), redisCache)
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Worker.MaxAttempts = 3
+	return cfg
+}
+
+func marshalVoiceTask(t *testing.T, task *model.Task) []byte {
+	data, err := json.Marshal(queue.VoiceTask{
+		TaskID:            task.ID,
+		ChatID:            task.ChatID,
+		TelegramMessageID: task.TelegramMessageID,
+		FileID:            task.FileID,
+		Duration:          3,
+		MimeType:          "audio/ogg",
+	})
+	assert.NoError(t, err)
+	return data
+}
+
+func TestProcessor_ProcessTaskRecordsTimings(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+		},
+	}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, speechkit.RecognitionOptions{Model: speechkit.ModelGeneralRC, Language: speechkit.DefaultLanguage}).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").After(10*time.Millisecond).Return(result, nil)
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, "chat:preferences:42", mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("Get", mock.Anything, "chat:active:42", mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("Get", mock.Anything, "chat:threshold:42", mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("Get", mock.Anything, cache.MaintenanceCacheKey, mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("Get", mock.Anything, cache.ProcessingRateCacheKey, mock.Anything).Return(errors.New("cache miss"))
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+	assert.NoError(t, err)
+
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	mockCache.AssertCalled(t, "SetWithTTL", mock.Anything, cache.ProcessingRateCacheKey, mock.AnythingOfType("float64"), mock.Anything)
+
+	timings, ok := task.Timings()
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, timings.RecognitionMs, int64(10))
+	assert.GreaterOrEqual(t, timings.TotalMs, timings.RecognitionMs)
+
+	sent := stub.sentMessages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Привет", sent[0]["text"])
+	assert.Equal(t, 1, task.ReplyMessageID())
+
+	mockS3.AssertExpectations(t)
+	mockSK.AssertExpectations(t)
+}
+
+func TestProcessor_ProcessTaskTimestampsPreference(t *testing.T) {
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{StartTimeMs: 1200, Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+			{StartTimeMs: 65000, Alternatives: []speechkit.Alternative{{Text: "как дела", Confidence: 0.9}}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		timestamps bool
+		// trimmedLead is the silence trimmed from the start before upload
+		trimmedLead time.Duration
+		want        string
+	}{
+		{name: "off by default", timestamps: false, want: "Привет как дела"},
+		{name: "on", timestamps: true, want: "[0:01] Привет\n[1:05] как дела"},
+		{name: "after trimmed silence", timestamps: true, trimmedLead: 4 * time.Second, want: "[0:05] Привет\n[1:09] как дела"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, stub := newTelegramStub(t, []byte("ogg-data"))
+			mockDB := new(MockDB)
+			mockS3 := new(MockS3)
+			mockSK := new(MockSpeechKit)
+			mockCache := new(MockCache)
+
+			task := &model.Task{
+				ID:                "task-123",
+				TelegramMessageID: 7,
+				ChatID:            42,
+				FileID:            "file-123",
+				Status:            model.TaskStatusQueued,
+				Meta:              model.JSONB{},
+			}
+			s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+
+			mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+			mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42, Timestamps: tt.timestamps}, nil)
+			mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+			// The stored transcript stays plain text either way
+			mockDB.On("CreateTranscript", mock.Anything, mock.MatchedBy(func(transcript *model.Transcript) bool {
+				return transcript.Text == "Привет как дела"
+			})).Return(nil)
+			mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+			mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+			mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+			mockSK.On("WaitForResult", "op-123").Return(result, nil)
+			mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+			p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+			if tt.trimmedLead > 0 {
+				p.trimmer = &fakeTrimmer{trimmed: []byte("trimmed-ogg"), lead: tt.trimmedLead}
+			}
+			assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+
+			sent := stub.sentMessages()
+			if assert.Len(t, sent, 1) {
+				assert.Equal(t, tt.want, sent[0]["text"])
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessor_ProcessTaskEditsEarlierTranscript(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockCache := new(MockCache)
+
+	// The voice message was edited after its first transcript was sent
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-456",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{model.MetaKeyReplyMessageID: float64(3)},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Новый текст", Confidence: 0.9}}},
+		},
+	}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+	mockCache.On("SetWithTTL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, mockCache, nil)
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	assert.Empty(t, stub.sentMessages())
+	if edited := stub.editedMessages(); assert.Len(t, edited, 1) {
+		assert.Equal(t, "3", edited[0]["message_id"])
+		assert.Equal(t, "Новый текст", edited[0]["text"])
+	}
+}
+
+func TestProcessor_ProcessTaskNoSpeech(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	silence := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "  "}}},
+		{Alternatives: []speechkit.Alternative{{Text: "…?"}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(silence, nil)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+
+	// A nil error acks the message, so the clip is not requeued
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusNoSpeech, task.Status)
+	assert.Equal(t, 0, task.Attempts)
+	assert.Nil(t, task.ErrorText)
+	mockDB.AssertNotCalled(t, "CreateTranscript", mock.Anything, mock.Anything)
+
+	sent := stub.sentMessages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Речь не распознана.", sent[0]["text"])
+}
+
+func TestIsMeaningfulText(t *testing.T) {
+	assert.True(t, isMeaningfulText("Привет"))
+	assert.True(t, isMeaningfulText("... 42 ..."))
+	assert.False(t, isMeaningfulText(""))
+	assert.False(t, isMeaningfulText(" \n\t "))
+	assert.False(t, isMeaningfulText("... - ?!"))
+	assert.False(t, isMeaningfulText("«…»"))
+}
+
+func TestProcessor_BackupTranscript(t *testing.T) {
+	mockS3 := new(MockS3)
+	ctx := context.Background()
+
+	cfg := testConfig()
+	cfg.S3.BackupTranscripts = true
+	p := NewProcessor(cfg, new(MockDB), mockS3, new(MockSpeechKit), nil, new(MockCache), nil)
+
+	transcript := &model.Transcript{
+		TaskID:      "task-123",
+		Text:        "Привет, мир",
+		RawResponse: []byte(`{"chunks":[]}`),
+	}
+
+	textKey := "transcripts/2025/10/07/task-123.txt"
+	rawKey := "transcripts/2025/10/07/task-123.json"
+	uploaded := make(map[string]string)
+	captureBody := func(args mock.Arguments) {
+		data, _ := io.ReadAll(args.Get(2).(io.Reader))
+		uploaded[args.String(1)] = string(data)
+	}
+
+	mockS3.On("GenerateTranscriptKey", "task-123", ".txt").Return(textKey)
+	mockS3.On("GenerateTranscriptKey", "task-123", ".json").Return(rawKey)
+	mockS3.On("UploadFile", mock.Anything, textKey, mock.Anything, "text/plain; charset=utf-8").
+		Run(captureBody).Return("https://storage/"+textKey, nil)
+	mockS3.On("UploadFile", mock.Anything, rawKey, mock.Anything, "application/json").
+		Run(captureBody).Return("https://storage/"+rawKey, nil)
+
+	p.backupTranscript(ctx, transcript)
+
+	mockS3.AssertExpectations(t)
+	assert.Equal(t, "Привет, мир", uploaded[textKey])
+	assert.Equal(t, `{"chunks":[]}`, uploaded[rawKey])
+}
+
+func TestIsTerminalTelegramError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"blocked by user", tele.ErrBlockedByUser, true},
+		{"chat not found", tele.ErrChatNotFound, true},
+		{"kicked from supergroup", tele.ErrKickedFromSuperGroup, true},
+		{"no rights to send", tele.ErrNoRightsToSend, true},
+		{"wrapped blocked", fmt.Errorf("send failed: %w", tele.ErrBlockedByUser), true},
+		{"unknown forbidden", tele.NewError(403, "Forbidden: something new"), true},
+		{"flood", tele.FloodError{RetryAfter: 5}, false},
+		{"message too long", tele.ErrTooLongMessage, false},
+		{"network", errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isTerminalTelegramError(tt.err))
+		})
+	}
+}
+
+func TestProcessor_HandleSendErrorDeactivatesBlockedChat(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	stub.sendError = `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+	mockDB := new(MockDB)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("SaveChatPreferences", mock.Anything, mock.MatchedBy(func(prefs *model.ChatPreferences) bool {
+		return prefs.ChatID == 42 && !prefs.IsActive(true)
+	})).Return(nil)
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), bot, cache.NewMemoryCache(time.Hour), nil)
+
+	err := p.sendResultToUser(context.Background(), &model.Task{ChatID: 42, TelegramMessageID: 7}, "Привет", tele.ModeDefault, nil)
+	assert.ErrorIs(t, err, tele.ErrBlockedByUser)
+
+	p.handleSendError(context.Background(), 42, err)
+	mockDB.AssertExpectations(t)
+	assert.False(t, p.chatPreferences(context.Background(), 42).IsActive(true))
+}
+
+func TestNewModelSelection(t *testing.T) {
+	cfg := testConfig()
+	cfg.SpeechKit.Model = "general:rc"
+	cfg.SpeechKit.LongAudioModel = "general"
+	cfg.SpeechKit.LongAudioAfter = 5 * time.Minute
+
+	selection := newModelSelection(cfg)
+	assert.Equal(t, speechkit.ModelGeneralRC, selection.Select(time.Minute))
+	assert.Equal(t, speechkit.ModelGeneral, selection.Select(10*time.Minute))
+	assert.Empty(t, selection.Narrowband)
+
+	cfg.SpeechKit.NarrowbandModel = "general:rc"
+	assert.Equal(t, speechkit.ModelGeneralRC, newModelSelection(cfg).Narrowband)
+
+	// Invalid names fall back to the default model without auto-selection
+	cfg.SpeechKit.Model = "turbo"
+	cfg.SpeechKit.LongAudioModel = "slow"
+	cfg.SpeechKit.NarrowbandModel = "phone"
+	selection = newModelSelection(cfg)
+	assert.Equal(t, speechkit.DefaultModel, selection.Select(10*time.Minute))
+	assert.Empty(t, selection.Narrowband)
+
+	// The worker can't wait hours for deferred results
+	cfg.SpeechKit.Model = "deferred-general"
+	cfg.SpeechKit.LongAudioModel = "deferred-general"
+	cfg.SpeechKit.NarrowbandModel = "deferred-general"
+	selection = newModelSelection(cfg)
+	assert.Equal(t, speechkit.DefaultModel, selection.Select(10*time.Minute))
+	assert.Empty(t, selection.Narrowband)
+}
+
+func TestProcessor_ProcessTaskPausedDuringMaintenance(t *testing.T) {
+	ctx := context.Background()
+	memory := cache.NewMemoryCache(time.Hour)
+	assert.NoError(t, cache.SetMaintenance(ctx, memory, true))
+
+	mockDB := new(MockDB)
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(nil, storage.ErrTaskNotFound)
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, memory, nil)
+	p.maintenancePoll = time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ProcessTask(marshalVoiceTask(t, &model.Task{ID: "task-123", ChatID: 42}))
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("task processed during maintenance")
+	case <-time.After(30 * time.Millisecond):
+	}
+	mockDB.AssertNotCalled(t, "GetTaskByID", mock.Anything, mock.Anything)
+
+	assert.NoError(t, cache.SetMaintenance(ctx, memory, false))
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, storage.ErrTaskNotFound)
+	case <-time.After(time.Second):
+		t.Fatal("task not resumed after maintenance")
+	}
+}
+
+func TestProcessor_StopEndsMaintenancePause(t *testing.T) {
+	memory := cache.NewMemoryCache(time.Hour)
+	assert.NoError(t, cache.SetMaintenance(context.Background(), memory, true))
+
+	mockDB := new(MockDB)
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, memory, nil)
+	p.maintenancePoll = time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ProcessTask(marshalVoiceTask(t, &model.Task{ID: "task-123", ChatID: 42}))
+	}()
+
+	p.Stop()
+
+	// The held task goes back to the queue unprocessed
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("maintenance pause not ended by Stop")
+	}
+	mockDB.AssertNotCalled(t, "GetTaskByID", mock.Anything, mock.Anything)
+	mockDB.AssertNotCalled(t, "ClaimTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessor_BuildReplyLowConfidenceWarning(t *testing.T) {
+	cfg := testConfig()
+	cfg.Reply.ConfidenceThreshold = 0.5
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, cache.NewNoopCache(), nil)
+
+	task := &model.Task{ID: "task-1", ChatID: 42}
+	voiceTask := &queue.VoiceTask{TaskID: "task-1", ChatID: 42}
+	prefs := &model.ChatPreferences{ChatID: 42}
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.6}}},
+	}}
+
+	// Above the configured default
+	assert.Equal(t, "Привет", p.buildReply(task, voiceTask, prefs, result, "Привет", time.Second))
+
+	// The chat raised its threshold
+	prefs.SetThreshold(0.8)
+	reply := p.buildReply(task, voiceTask, prefs, result, "Привет", time.Second)
+	assert.Equal(t, "Привет\n\n"+lowConfidenceWarning(nil, 0.6), reply)
+
+	// Results without confidence never trigger the warning
+	noConfidence := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Привет"}}},
+	}}
+	assert.Equal(t, "Привет", p.buildReply(task, voiceTask, prefs, noConfidence, "Привет", time.Second))
+}
+
+func TestProcessor_BuildReplyForwardAttribution(t *testing.T) {
+	cfg := testConfig()
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, cache.NewNoopCache(), nil)
+
+	task := &model.Task{ID: "task-1", ChatID: 42}
+	task.SetForwardFrom("Анна <3")
+	voiceTask := &queue.VoiceTask{TaskID: "task-1", ChatID: 42}
+	prefs := &model.ChatPreferences{ChatID: 42}
+	result := &speechkit.RecognitionResult{}
+
+	// Attribution is opt-in
+	assert.Equal(t, "Привет", p.buildReply(task, voiceTask, prefs, result, "Привет", time.Second))
+
+	cfg.Reply.ForwardAttribution = true
+	assert.Equal(t, "Переслано от Анна <3:\nПривет", p.buildReply(task, voiceTask, prefs, result, "Привет", time.Second))
+
+	// The sender name is escaped together with the transcript
+	cfg.Reply.ParseMode = tele.ModeHTML
+	assert.Equal(t, "Переслано от Анна &lt;3:\nПривет", p.buildReply(task, voiceTask, prefs, result, "Привет", time.Second))
+
+	// Messages that weren't forwarded are unchanged
+	assert.Equal(t, "Привет", p.buildReply(&model.Task{ID: "task-2"}, voiceTask, prefs, result, "Привет", time.Second))
+}
+
+func TestProcessor_SendResultUsesThreadID(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	topicTask := &model.Task{ChatID: -100, TelegramMessageID: 7}
+	topicTask.SetThreadID(15)
+	assert.NoError(t, p.sendResultToUser(context.Background(), topicTask, "в топике", tele.ModeDefault, nil))
+
+	plainTask := &model.Task{ChatID: 42, TelegramMessageID: 8}
+	plainTask.SetThreadID(0)
+	assert.NoError(t, p.sendResultToUser(context.Background(), plainTask, "без топика", tele.ModeDefault, nil))
+
+	sent := stub.sentMessages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "15", sent[0]["message_thread_id"])
+	assert.NotContains(t, sent[1], "message_thread_id")
+}
+
+func TestProcessor_HandleTaskErrorNotifiesAtConfiguredLimit(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+
+	cfg := testConfig()
+	cfg.Worker.MaxAttempts = 2
+	p := NewProcessor(cfg, mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+
+	err := p.handleTaskError(context.Background(), task, errors.New("first failure"))
+	assert.EqualError(t, err, "first failure")
+	assert.Equal(t, 1, task.Attempts)
+	assert.Empty(t, stub.sentMessages())
+
+	err = p.handleTaskError(context.Background(), task, errors.New("second failure"))
+	assert.NoError(t, err, "exhausted tasks are not requeued")
+	assert.Equal(t, 2, task.Attempts)
+	assert.Len(t, stub.sentMessages(), 1)
+}
+
+func TestProcessor_HandleTaskErrorNonRetryableNotifiesImmediately(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+
+	opErr := &speechkit.OperationError{Code: 3, Message: "invalid audio"}
+	err := p.handleTaskError(context.Background(), task, fmt.Errorf("failed to get recognition result: %w", opErr))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, task.Attempts)
+	sent := stub.sentMessages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Формат аудио не поддерживается.", sent[0]["text"])
+}
+
+func TestProcessor_HandleTaskErrorDeadlineSetsTimeout(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+
+	cfg := testConfig()
+	cfg.Worker.MaxAttempts = 2
+	p := NewProcessor(cfg, mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+	taskCtx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	deadlineErr := deadlineError(taskCtx, fmt.Errorf("failed to get recognition result: %w", context.DeadlineExceeded))
+
+	// Timed out tasks are retried like failed ones
+	err := p.handleTaskError(context.Background(), task, deadlineErr)
+	assert.ErrorIs(t, err, errTaskDeadline)
+	assert.Equal(t, model.TaskStatusTimeout, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+	assert.Empty(t, stub.sentMessages())
+
+	err = p.handleTaskError(context.Background(), task, deadlineErr)
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusTimeout, task.Status)
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Распознавание заняло слишком много времени. Попробуйте отправить сообщение покороче.", sent[0]["text"])
+	}
+}
+
+func TestProcessor_HandleTaskErrorCallTimeoutSetsFailed(t *testing.T) {
+	bot, _ := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+	taskCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	callErr := deadlineError(taskCtx, fmt.Errorf("failed to upload to S3: %w", context.DeadlineExceeded))
+
+	// One call running out of time while the task still has time is a plain failure
+	err := p.handleTaskError(context.Background(), task, callErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, errTaskDeadline)
+	assert.Equal(t, model.TaskStatusFailed, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+}
+
+func TestProcessor_HandleTaskErrorKeepsFinishedTask(t *testing.T) {
+	mockDB := new(MockDB)
+	p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+	task := &model.Task{ID: "task-123", ChatID: 42, Status: model.TaskStatusDone}
+
+	err := p.handleTaskError(context.Background(), task, speechkit.ErrRecognitionTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	assert.Equal(t, 0, task.Attempts)
+	mockDB.AssertNotCalled(t, "UpdateTask", mock.Anything, mock.Anything)
+}
+
+func TestProcessor_ProcessTaskDeadlineExceeded(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	task := &model.Task{
+		ID:                "task-123",
+		TelegramMessageID: 7,
+		ChatID:            42,
+		FileID:            "file-123",
+		Status:            model.TaskStatusQueued,
+		Meta:              model.JSONB{},
+	}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(nil, speechkit.ErrRecognitionTimeout)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+
+	assert.ErrorIs(t, err, speechkit.ErrRecognitionTimeout)
+	assert.Equal(t, model.TaskStatusTimeout, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+}
+
+func TestProcessor_ProcessTaskPollTimeoutIsFailure(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	// A single status request timing out, the task deadline is far away
+	mockSK.On("WaitForResult", "op-123").Return(nil, context.DeadlineExceeded)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, model.TaskStatusFailed, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+}
+
+func TestProcessor_ProcessTaskDeliversPartialResultOnTimeout(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	partial := &speechkit.PartialResultError{
+		Result: &speechkit.RecognitionResult{
+			Chunks: []speechkit.Chunk{{Alternatives: []speechkit.Alternative{{Text: "Начало записи"}}}},
+		},
+		Err: speechkit.ErrRecognitionTimeout,
+	}
+
+	var transcript *model.Transcript
+	// Long enough for the run to register as processing time
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").After(10*time.Millisecond).Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).
+		Run(func(args mock.Arguments) { transcript = args.Get(1).(*model.Transcript) }).
+		Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(nil, partial)
+	// Nobody waits for the rest anymore
+	mockSK.On("CancelOperation", "op-123").Return(nil)
+
+	memory := cache.NewMemoryCache(time.Hour)
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, memory, nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+	if assert.NotNil(t, transcript) {
+		assert.Equal(t, "Начало записи", transcript.Text)
+	}
+	// The cut-short run isn't added to the processing rate
+	_, ok := cache.ProcessingRate(context.Background(), memory)
+	assert.False(t, ok)
+	if sent := stub.sentMessages(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "Начало записи\n\n(частично распознано)", sent[0]["text"])
+	}
+	mockSK.AssertExpectations(t)
+}
+
+func TestProcessor_ProcessTaskTimeoutWithoutPartialText(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	partial := &speechkit.PartialResultError{
+		Result: &speechkit.RecognitionResult{
+			Chunks: []speechkit.Chunk{{Alternatives: []speechkit.Alternative{{Text: " "}}}},
+		},
+		Err: speechkit.ErrRecognitionTimeout,
+	}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(nil, partial)
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+
+	// Nothing worth sending: the attempt times out as before
+	assert.ErrorIs(t, err, speechkit.ErrRecognitionTimeout)
+	assert.Equal(t, model.TaskStatusTimeout, task.Status)
+	assert.Empty(t, stub.sentMessages())
+}
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		message   i18n.Key
+		retryable bool
+	}{
+		{
+			name:      "download",
+			err:       fmt.Errorf("%w: %w", errDownloadFailed, errors.New("status=404")),
+			message:   i18n.DownloadFailed,
+			retryable: true,
+		},
+		{
+			name:      "unsupported format",
+			err:       fmt.Errorf("failed to get recognition result: %w", &speechkit.OperationError{Code: 3}),
+			message:   i18n.UnsupportedFormat,
+			retryable: false,
+		},
+		{
+			name:      "timeout",
+			err:       fmt.Errorf("failed to get recognition result: %w", speechkit.ErrRecognitionTimeout),
+			message:   i18n.RecognitionTimeout,
+			retryable: true,
+		},
+		{
+			name:      "file too large",
+			err:       fmt.Errorf("%w: %w", errDownloadFailed, fmt.Errorf("%w: 25000000 bytes", errFileTooLarge)),
+			message:   i18n.DownloadTooLarge,
+			retryable: false,
+		},
+		{
+			name:      "storage unavailable",
+			err:       fmt.Errorf("failed to upload to S3: %w", storage.ErrStorageUnavailable),
+			message:   i18n.StorageUnavailable,
+			retryable: true,
+		},
+		{
+			name:      "task deadline",
+			err:       fmt.Errorf("%w: %w", errTaskDeadline, fmt.Errorf("failed to get recognition result: %w", context.DeadlineExceeded)),
+			message:   i18n.RecognitionTimeout,
+			retryable: true,
+		},
+		{
+			name:      "call timeout",
+			err:       fmt.Errorf("failed to get recognition result: %w", context.DeadlineExceeded),
+			message:   i18n.RecognitionFailed,
+			retryable: true,
+		},
+		{
+			name:      "panic",
+			err:       fmt.Errorf("%w: nil map", errTaskPanicked),
+			message:   i18n.InternalError,
+			retryable: false,
+		},
+		{
+			name:      "rejected audio link",
+			err:       fmt.Errorf("%w: %w", errDownloadFailed, errAudioURLRejected),
+			message:   i18n.AudioURLRejected,
+			retryable: false,
+		},
+		{
+			name:      "other",
+			err:       errors.New("failed to upload to S3: boom"),
+			message:   i18n.RecognitionFailed,
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := classifyFailure(tt.err)
+			assert.Equal(t, tt.message, f.message)
+			assert.Equal(t, tt.retryable, f.retryable)
+		})
+	}
+}
+
 func TestProcessor_HandleTaskError(t *testing.T) {
 	mockDB := new(MockDB)
 	ctx := context.Background()
@@ -164,14 +1168,14 @@ func TestSpeechKit_RecognitionFlow(t *testing.T) {
 		},
 	}
 
-	mockSK.On("StartRecognition", s3URL).Return(operationID, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return(operationID, nil)
 	mockSK.On("WaitForResult", operationID).Return(result, nil)
 
-	opID, err := mockSK.StartRecognition(s3URL)
+	opID, err := mockSK.StartRecognition(s3URL, speechkit.RecognitionOptions{})
 	assert.NoError(t, err)
 	assert.Equal(t, operationID, opID)
 
-	res, err := mockSK.WaitForResult(operationID)
+	res, err := mockSK.WaitForResult(context.Background(), operationID, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 	assert.Len(t, res.Chunks, 1)
@@ -179,6 +1183,51 @@ func TestSpeechKit_RecognitionFlow(t *testing.T) {
 	mockSK.AssertExpectations(t)
 }
 
+func TestProcessor_SlowUploadTimesOut(t *testing.T) {
+	cfg := testConfig()
+	cfg.S3.CallTimeout = 20 * time.Millisecond
+	mockS3 := new(MockS3)
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return("", context.DeadlineExceeded)
+
+	p := NewProcessor(cfg, new(MockDB), mockS3, new(MockSpeechKit), nil, new(MockCache), nil)
+
+	start := time.Now()
+	_, err := p.uploadFile(context.Background(), "voice/task-123.ogg", strings.NewReader("ogg-data"), "audio/ogg")
+
+	// The stalled upload is abandoned at its own deadline, not the task's
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestProcessor_ProcessTaskRetriesStalledUpload(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+
+	mockDB := new(MockDB)
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3 := new(MockS3)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return("", context.DeadlineExceeded)
+	mockSK := new(MockSpeechKit)
+
+	cfg := testConfig()
+	cfg.S3.CallTimeout = 20 * time.Millisecond
+	p := NewProcessor(cfg, mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+
+	// The attempt fails and the message is requeued for another one
+	err := p.ProcessTask(marshalVoiceTask(t, task))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, model.TaskStatusFailed, task.Status)
+	assert.Equal(t, 1, task.Attempts)
+	mockSK.AssertNotCalled(t, "StartRecognition", mock.Anything, mock.Anything)
+}
+
 func TestS3_UploadFile(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test")
@@ -219,3 +1268,256 @@ func TestS3_UploadFileError(t *testing.T) {
 
 	mockS3.AssertExpectations(t)
 }
+
+type recordingTransport struct {
+	mu        sync.Mutex
+	paths     []string
+	remaining []time.Duration
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, req.URL.Path)
+	if deadline, ok := req.Context().Deadline(); ok {
+		t.remaining = append(t.remaining, time.Until(deadline))
+	}
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestProcessor_DownloadUsesInjectedHTTPClient(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	transport := &recordingTransport{}
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), &http.Client{Transport: transport})
+
+	data, err := p.downloadTelegramFile(context.Background(), "file-123")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ogg-data"), data)
+	assert.Equal(t, []string{"/file/bottest-token/voice/file-123.oga"}, transport.paths)
+}
+
+func TestProcessor_DownloadUsesConfiguredTimeout(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	cfg := testConfig()
+	cfg.Telegram.DownloadTimeout = 15 * time.Second
+	transport := &recordingTransport{}
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), &http.Client{Transport: transport})
+
+	_, err := p.downloadTelegramFile(context.Background(), "file-123")
+	assert.NoError(t, err)
+	if assert.Len(t, transport.remaining, 1) {
+		assert.InDelta(t, 15*time.Second, transport.remaining[0], float64(time.Second))
+	}
+}
+
+func TestProcessor_DownloadRefreshesExpiredPath(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	stub.filePaths = []string{"voice/old.oga", "voice/new.oga"}
+	stub.expiredPaths = map[string]bool{"voice/old.oga": true}
+	transport := &recordingTransport{}
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), &http.Client{Transport: transport})
+
+	data, err := p.downloadTelegramFile(context.Background(), "file-123")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ogg-data"), data)
+	assert.Equal(t, []string{"/file/bottest-token/voice/old.oga", "/file/bottest-token/voice/new.oga"}, transport.paths)
+}
+
+func TestProcessor_DownloadRefreshesExpiredPathOnce(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	stub.filePaths = []string{"voice/old.oga", "voice/old.oga", "voice/new.oga"}
+	stub.expiredPaths = map[string]bool{"voice/old.oga": true}
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	_, err := p.downloadTelegramFile(context.Background(), "file-123")
+	assert.ErrorIs(t, err, errFilePathExpired)
+	// The third path is never asked for
+	assert.Equal(t, []string{"voice/new.oga"}, stub.filePaths)
+}
+
+func TestIsFileTooBig(t *testing.T) {
+	assert.True(t, isFileTooBig(tele.ErrTooLarge))
+	assert.True(t, isFileTooBig(fmt.Errorf("failed to get file info: %w", errors.New("telegram: Bad Request: file is too big (400)"))))
+	assert.False(t, isFileTooBig(tele.NewError(400, "Bad Request: wrong file_id")))
+	assert.False(t, isFileTooBig(errors.New("connection refused")))
+	assert.False(t, isFileTooBig(nil))
+}
+
+func TestProcessor_ProcessTaskFileTooLarge(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileSize     int64
+		getFileError string
+	}{
+		{"known size", queue.MaxFileSize + 1, ""},
+		{"rejected by telegram", 0, `{"ok":false,"error_code":400,"description":"Bad Request: file is too big"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, stub := newTelegramStub(t, nil)
+			stub.getFileError = tt.getFileError
+			mockDB := new(MockDB)
+			task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+			mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+			mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+			mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+
+			// No S3 or SpeechKit expectations: the task must stop before upload
+			p := NewProcessor(testConfig(), mockDB, new(MockS3), new(MockSpeechKit), bot, cache.NewNoopCache(), nil)
+
+			data, err := json.Marshal(queue.VoiceTask{TaskID: "task-123", ChatID: 42, FileID: "file-123", FileSize: tt.fileSize})
+			assert.NoError(t, err)
+
+			// Terminal: reported right away and not requeued
+			assert.NoError(t, p.ProcessTask(data))
+			assert.Equal(t, 1, task.Attempts)
+			if sent := stub.sentMessages(); assert.Len(t, sent, 1) {
+				assert.Contains(t, sent[0]["text"], "20 МБ")
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessTaskRecoversPanic(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Run(func(args mock.Arguments) {
+		panic("nil map")
+	}).Return("")
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, new(MockSpeechKit), bot, cache.NewNoopCache(), nil)
+
+	var err error
+	assert.NotPanics(t, func() { err = p.ProcessTask(marshalVoiceTask(t, task)) })
+
+	// The task fails for good instead of being redelivered into the same panic
+	assert.NoError(t, err)
+	assert.Equal(t, model.TaskStatusFailed, task.Status)
+	if assert.NotNil(t, task.ErrorText) {
+		assert.Contains(t, *task.ErrorText, "nil map")
+	}
+	if sent := stub.sentMessages(); assert.NotEmpty(t, sent) {
+		assert.Equal(t, "Произошла внутренняя ошибка при обработке голосового сообщения.", sent[len(sent)-1]["text"])
+	}
+}
+
+func TestProcessor_ProcessTaskCancelsStaleOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelErr error
+	}{
+		{"cancelled", nil},
+		{"already finished", errors.New("operation cancel failed: status=400")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _ := newTelegramStub(t, []byte("ogg-data"))
+			mockDB := new(MockDB)
+			mockS3 := new(MockS3)
+			mockSK := new(MockSpeechKit)
+
+			// The previous attempt started op-old, then the worker died before it finished
+			staleID := "op-old"
+			task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusInProgress, OperationID: &staleID, Attempts: 1, Meta: model.JSONB{}}
+			s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+			result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+				{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+			}}
+
+			mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+			mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+			mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+			mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+			mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+			mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+			mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-new", nil)
+			mockSK.On("CancelOperation", "op-old").Return(tt.cancelErr).Once()
+			mockSK.On("WaitForResult", "op-new").Return(result, nil)
+
+			p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+
+			assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+			assert.Equal(t, "op-new", task.CurrentOperationID())
+			assert.Equal(t, model.TaskStatusDone, task.Status)
+			mockSK.AssertExpectations(t)
+			mockSK.AssertNotCalled(t, "WaitForResult", "op-old")
+		})
+	}
+}
+
+func TestProcessor_FailureMessageUsesConfiguredLocale(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+
+	cfg := testConfig()
+	cfg.Telegram.DefaultLocale = i18n.English
+	p := NewProcessor(cfg, mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+
+	assert.NoError(t, p.handleTaskError(context.Background(), task, fmt.Errorf("%w: nil map", errTaskPanicked)))
+
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "An internal error occurred while processing the voice message.", sent[0]["text"])
+	}
+}
+
+func TestRecognitionInfo(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	info := recognitionInfo(&speechkit.OperationInfo{
+		ID:         "op-1",
+		CreatedAt:  createdAt,
+		ModifiedAt: createdAt.Add(2500 * time.Millisecond),
+		Metadata: speechkit.OperationMetadata{
+			Type:            "LongRunningRecognitionMetadata",
+			ProgressPercent: 100,
+			HasProgress:     true,
+			AudioDuration:   3 * time.Second,
+		},
+	})
+
+	assert.Equal(t, model.RecognitionInfo{
+		OperationID:     "op-1",
+		MetadataType:    "LongRunningRecognitionMetadata",
+		ProgressPercent: 100,
+		AudioDurationMs: 3000,
+		OperationMs:     2500,
+	}, info)
+
+	// Without timestamps the operation's run time is unknown
+	assert.Zero(t, recognitionInfo(&speechkit.OperationInfo{ID: "op-2"}).OperationMs)
+}
+
+// replySaveFailingStore fails to save a task once it has a reply
+type replySaveFailingStore struct {
+	*sharedTaskStore
+}
+
+func (s *replySaveFailingStore) UpdateTask(ctx context.Context, task *model.Task) error {
+	if task.ReplyMessageID() != 0 {
+		return errors.New("connection reset")
+	}
+	return s.sharedTaskStore.UpdateTask(ctx, task)
+}
+
+func TestProcessor_FinishedTaskNotRequeuedAfterReply(t *testing.T) {
+	mockDB := new(MockDB)
+	store := &replySaveFailingStore{&sharedTaskStore{MockDB: mockDB, task: sharedStoreTask()}}
+	p, stub, mockSK := newSharedStoreProcessor(t, store, mockDB)
+	mockSK.On("WaitForResult", "op-123").Return(recognizedHello(), nil)
+
+	task := sharedStoreTask()
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, &task)))
+
+	// The task was done before the reply went out, so losing the reply ID costs no second transcript
+	assert.Equal(t, model.TaskStatusDone, store.task.Status)
+	assert.Len(t, stub.sentMessages(), 1)
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"time"
+	"voxly/internal/queue"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+)
+
+// StaleTaskStore finds tasks left in progress
+type StaleTaskStore interface {
+	GetStaleTasks(ctx context.Context, olderThan time.Duration, limit int) ([]*model.Task, error)
+}
+
+// reclaimBatch is how many stale tasks are looked at per run
+const reclaimBatch = 100
+
+// Reclaimer periodically publishes again tasks left in progress by a worker
+// that stopped after acknowledging their queue message, as background polls
+// do, or while holding their claim, which makes other deliveries skip them.
+// The delivery takes over the stale claim, so a task published by several
+// workers is still processed once.
+type Reclaimer struct {
+	db         StaleTaskStore
+	tasks      TaskPublisher
+	multiplier float64
+	interval   time.Duration
+}
+
+// NewReclaimer creates a job publishing stale tasks to tasks every interval.
+// multiplier is the task timeout multiplier the deadlines are derived from.
+func NewReclaimer(db StaleTaskStore, tasks TaskPublisher, multiplier float64, interval time.Duration) *Reclaimer {
+	return &Reclaimer{
+		db:         db,
+		tasks:      tasks,
+		multiplier: multiplier,
+		interval:   interval,
+	}
+}
+
+// Run reclaims stale tasks on every tick until ctx is cancelled
+func (r *Reclaimer) Run(ctx context.Context) {
+	if r.interval <= 0 || r.tasks == nil {
+		logger.Info("Stale task reclaim disabled")
+		return
+	}
+
+	logger.Info("Starting stale task reclaim", zap.Duration("interval", r.interval))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reclaimed, err := r.Reclaim(ctx)
+			if reclaimed > 0 {
+				logger.Info("Reclaimed stale tasks", zap.Int("reclaimed", reclaimed))
+			}
+			if err != nil {
+				logger.Error("Stale task reclaim failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Reclaim publishes tasks not updated since their deadline passed and returns
+// how many it published
+func (r *Reclaimer) Reclaim(ctx context.Context) (int, error) {
+	// No task goes stale sooner than the shortest deadline
+	tasks, err := r.db.GetStaleTasks(ctx, minTaskTimeout, reclaimBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := 0
+	for _, task := range tasks {
+		voiceTask := queue.NewVoiceTask(task)
+		if time.Since(task.UpdatedAt) < staleClaimAfter(voiceTask.Duration, r.multiplier) {
+			continue
+		}
+
+		log := logger.WithTask(task.ID)
+		// A new publication, so deduplication doesn't drop it
+		voiceTask.RequeuedAt = time.Now()
+		if err := r.tasks.PublishTask(voiceTask); err != nil {
+			log.Error("Failed to publish stale task", zap.Error(err))
+			continue
+		}
+		log.Warn("Task left in progress by a stopped worker, published again",
+			zap.Time("updated_at", task.UpdatedAt))
+		reclaimed++
+	}
+
+	return reclaimed, nil
+}
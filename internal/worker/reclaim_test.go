@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStaleTaskStore struct {
+	mock.Mock
+}
+
+func (m *MockStaleTaskStore) GetStaleTasks(ctx context.Context, olderThan time.Duration, limit int) ([]*model.Task, error) {
+	args := m.Called(ctx, olderThan, limit)
+	return args.Get(0).([]*model.Task), args.Error(1)
+}
+
+func TestReclaimer_Reclaim(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// A 60 second recording has a 5 minute deadline with multiplier 5
+	abandoned := &model.Task{ID: "abandoned", Status: model.TaskStatusInProgress, Meta: model.JSONB{"voice_duration": 60}, UpdatedAt: now.Add(-10 * time.Minute)}
+	running := &model.Task{ID: "running", Status: model.TaskStatusInProgress, Meta: model.JSONB{"voice_duration": 600}, UpdatedAt: now.Add(-10 * time.Minute)}
+
+	db := new(MockStaleTaskStore)
+	db.On("GetStaleTasks", ctx, minTaskTimeout, reclaimBatch).Return([]*model.Task{abandoned, running}, nil)
+	tasks := &recordingPublisher{}
+
+	reclaimed, err := NewReclaimer(db, tasks, 5, time.Minute).Reclaim(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reclaimed)
+
+	published := tasks.published()
+	if assert.Len(t, published, 1) {
+		assert.Equal(t, "abandoned", published[0].TaskID)
+		assert.Equal(t, 60, published[0].Duration)
+		// Deduplication must not take it for the first publication
+		assert.NotEqual(t, "abandoned", published[0].MessageID())
+	}
+}
+
+func TestReclaimer_RunDisabled(t *testing.T) {
+	db := new(MockStaleTaskStore)
+
+	// Returns right away without touching the database
+	NewReclaimer(db, &recordingPublisher{}, 5, 0).Run(context.Background())
+	NewReclaimer(db, nil, 5, time.Minute).Run(context.Background())
+	db.AssertNotCalled(t, "GetStaleTasks", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,221 @@
+package worker
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"voxly/internal/i18n"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// FooterData holds the values exposed to the reply footer template.
+// Values are preformatted and escaped for the reply parse mode;
+// unknown values are empty so templates can skip them with {{if}}.
+type FooterData struct {
+	Duration       string // audio duration, e.g. "1:05"
+	Language       string // recognition language code
+	Confidence     string // average confidence, e.g. "93%"
+	ProcessingTime string // time from dequeue to result, e.g. "4.2s"
+}
+
+// Reply targets: what a transcript or failure message replies to
+const (
+	ReplyToOriginal   = "reply-to-original"   // the voice message
+	ReplyToProcessing = "reply-to-processing" // the bot's "processing" message
+	NoReply           = "no-reply"            // nothing, a standalone message
+)
+
+// parseReplyTarget checks the configured reply target, empty means ReplyToOriginal
+func parseReplyTarget(target string) (string, error) {
+	switch target {
+	case "":
+		return ReplyToOriginal, nil
+	case ReplyToOriginal, ReplyToProcessing, NoReply:
+		return target, nil
+	default:
+		return ReplyToOriginal, fmt.Errorf("unknown reply target %q, expected %s, %s or %s",
+			target, ReplyToOriginal, ReplyToProcessing, NoReply)
+	}
+}
+
+// standaloneChat reports whether replies to a chat of the type are sent without
+// quoting the audio: private chats hold a single conversation and channel posts
+// are read by subscribers, not by whoever posted them. Tasks created before the
+// chat type was stored follow the reply target.
+func standaloneChat(chatType string) bool {
+	switch tele.ChatType(chatType) {
+	case tele.ChatPrivate, tele.ChatChannel, tele.ChatChannelPrivate:
+		return true
+	default:
+		return false
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[",
+)
+
+var markdownV2Escaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+	"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// parseFooterTemplate parses the configured footer, a blank template disables it
+func parseFooterTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("footer").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reply footer template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// newFooterData formats footer values for the given parse mode
+func newFooterData(
+	durationSec int,
+	language string,
+	confidence float64,
+	hasConfidence bool,
+	processing time.Duration,
+	mode tele.ParseMode,
+) FooterData {
+	var data FooterData
+	if durationSec > 0 {
+		data.Duration = escapeText(fmt.Sprintf("%d:%02d", durationSec/60, durationSec%60), mode)
+	}
+	data.Language = escapeText(language, mode)
+	if hasConfidence {
+		data.Confidence = escapeText(fmt.Sprintf("%.0f%%", confidence*100), mode)
+	}
+	if processing > 0 {
+		data.ProcessingTime = escapeText(processing.Round(100*time.Millisecond).String(), mode)
+	}
+	return data
+}
+
+// renderFooter executes the footer template, returning an empty string when disabled
+func renderFooter(tmpl *template.Template, data FooterData) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render reply footer: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// lowConfidenceWarning tells the user the transcript may be inaccurate
+func lowConfidenceWarning(texts *i18n.Catalog, confidence float64) string {
+	return texts.Text(i18n.LowConfidence, confidence*100)
+}
+
+// forwardAttribution introduces the transcript of a forwarded voice message
+func forwardAttribution(texts *i18n.Catalog, name string) string {
+	return texts.Text(i18n.ForwardedFrom, name)
+}
+
+// formatReply escapes the transcript for the parse mode and appends the footer
+func formatReply(text, footer string, mode tele.ParseMode) string {
+	text = escapeText(text, mode)
+	if footer == "" {
+		return text
+	}
+	return text + "\n\n" + footer
+}
+
+// escapeText escapes Telegram formatting characters for the parse mode
+func escapeText(text string, mode tele.ParseMode) string {
+	switch mode {
+	case tele.ModeMarkdown:
+		return markdownEscaper.Replace(text)
+	case tele.ModeMarkdownV2:
+		return markdownV2Escaper.Replace(text)
+	case tele.ModeHTML:
+		return html.EscapeString(text)
+	default:
+		return text
+	}
+}
+
+// maxMessageLength is Telegram's limit on message text, in characters
+const maxMessageLength = 4096
+
+// splitReply cuts text into messages of at most limit characters, preferring
+// paragraph, line and word boundaries so escapes and words stay whole
+func splitReply(text string, limit int) []string {
+	var chunks []string
+	rest := []rune(text)
+	for len(rest) > limit {
+		cut := splitPoint(rest[:limit+1])
+		chunks = append(chunks, strings.TrimRightFunc(string(rest[:cut]), unicode.IsSpace))
+		rest = []rune(strings.TrimLeftFunc(string(rest[cut:]), unicode.IsSpace))
+	}
+	if len(rest) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, string(rest))
+	}
+	return chunks
+}
+
+// splitPoint picks where to cut a window one rune longer than the limit: at the
+// last paragraph or line break in its second half, else the last space, else the limit
+func splitPoint(window []rune) int {
+	s := string(window)
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(s, sep); i >= len(s)/2 {
+			return utf8.RuneCountInString(s[:i])
+		}
+	}
+	if i := strings.LastIndex(s, " "); i > 0 {
+		return utf8.RuneCountInString(s[:i])
+	}
+
+	cut := len(window) - 1
+	// Keep an escaped character together with its backslash
+	if cut > 1 && window[cut-1] == '\\' {
+		cut--
+	}
+	return cut
+}
+
+// numberChunks splits text like splitReply and prefixes each chunk with its
+// position, e.g. "(1/3)". Labels count against the limit; their width depends
+// on the chunk count, so the split repeats until the count stops growing.
+func numberChunks(text string, limit int, separator string, mode tele.ParseMode) []string {
+	chunks := splitReply(text, limit)
+	if len(chunks) < 2 {
+		return chunks
+	}
+
+	for {
+		total := len(chunks)
+		widest := utf8.RuneCountInString(chunkLabel(total, total, separator, mode))
+		if widest >= limit {
+			return chunks
+		}
+		chunks = splitReply(text, limit-widest)
+		if len(chunks) <= total {
+			break
+		}
+	}
+
+	for i, chunk := range chunks {
+		chunks[i] = chunkLabel(i+1, len(chunks), separator, mode) + chunk
+	}
+	return chunks
+}
+
+// chunkLabel formats a chunk's position followed by the separator
+func chunkLabel(n, total int, separator string, mode tele.ParseMode) string {
+	return escapeText(fmt.Sprintf("(%d/%d)", n, total), mode) + separator
+}
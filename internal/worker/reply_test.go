@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	tele "gopkg.in/telebot.v4"
+)
+
+const testFooter = "⏱ {{.Duration}}{{if .Language}} · {{.Language}}{{end}}{{if .Confidence}} · {{.Confidence}}{{end}} · {{.ProcessingTime}}"
+
+func TestRenderFooter_AllFields(t *testing.T) {
+	tmpl, err := parseFooterTemplate(testFooter)
+	assert.NoError(t, err)
+
+	data := newFooterData(65, "ru-RU", 0.934, true, 4230*time.Millisecond, tele.ModeDefault)
+	footer, err := renderFooter(tmpl, data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "⏱ 1:05 · ru-RU · 93% · 4.2s", footer)
+}
+
+func TestRenderFooter_OptionalFieldsOmitted(t *testing.T) {
+	tmpl, err := parseFooterTemplate(testFooter)
+	assert.NoError(t, err)
+
+	data := newFooterData(7, "", 0, false, time.Second, tele.ModeDefault)
+	footer, err := renderFooter(tmpl, data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "⏱ 0:07 · 1s", footer)
+}
+
+func TestRenderFooter_Disabled(t *testing.T) {
+	tmpl, err := parseFooterTemplate("  ")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	footer, err := renderFooter(tmpl, FooterData{Duration: "0:01"})
+	assert.NoError(t, err)
+	assert.Empty(t, footer)
+	assert.Equal(t, "текст", formatReply("текст", footer, tele.ModeDefault))
+}
+
+func TestParseFooterTemplate_Invalid(t *testing.T) {
+	_, err := parseFooterTemplate("{{.Duration")
+	assert.Error(t, err)
+}
+
+func TestFormatReply_EscapesMarkdownV2(t *testing.T) {
+	tmpl, err := parseFooterTemplate("_{{.Language}} {{.ProcessingTime}}_")
+	assert.NoError(t, err)
+
+	data := newFooterData(0, "ru-RU", 0, false, 1500*time.Millisecond, tele.ModeMarkdownV2)
+	footer, err := renderFooter(tmpl, data)
+	assert.NoError(t, err)
+
+	reply := formatReply("Привет. Как дела?", footer, tele.ModeMarkdownV2)
+	assert.Equal(t, "Привет\\. Как дела?\n\n_ru\\-RU 1\\.5s_", reply)
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		mode tele.ParseMode
+		in   string
+		want string
+	}{
+		{tele.ModeDefault, "a_b <c>", "a_b <c>"},
+		{tele.ModeMarkdown, "a_b *c*", "a\\_b \\*c\\*"},
+		{tele.ModeHTML, "a & <b>", "a &amp; &lt;b&gt;"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, escapeText(tt.in, tt.mode), string(tt.mode))
+	}
+}
+
+func TestSplitReply(t *testing.T) {
+	assert.Equal(t, []string{"короткий текст"}, splitReply("короткий текст", 20))
+	assert.Equal(t, []string{""}, splitReply("", 20))
+
+	// Words stay whole and the spaces at the cut are dropped
+	assert.Equal(t, []string{"один два", "три четыре", "пять"}, splitReply("один два три четыре пять", 10))
+
+	// Paragraph breaks in the second half of the window win over spaces
+	assert.Equal(t, []string{"первый абзац", "второй"}, splitReply("первый абзац\n\nвторой", 15))
+
+	// A word longer than the limit is cut at the limit
+	assert.Equal(t, []string{"абвгд", "еёжзи", "й"}, splitReply("абвгдеёжзий", 5))
+
+	// An escape is never separated from its backslash
+	assert.Equal(t, []string{"abcd", "\\.ef"}, splitReply("abcd\\.ef", 5))
+}
+
+func TestSplitReply_FitsLimit(t *testing.T) {
+	text := strings.Repeat("слово ", 2000)
+	for _, chunk := range splitReply(text, maxMessageLength) {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), maxMessageLength)
+	}
+}
+
+func TestNumberChunks(t *testing.T) {
+	assert.Equal(t, []string{"один"}, numberChunks("один", 10, " ", tele.ModeDefault))
+
+	// Unlabeled the text takes two messages, but "(1/2) " leaves five characters
+	assert.Equal(t, []string{"(1/3) один", "(2/3) два", "(3/3) три"}, numberChunks("один два три", 11, " ", tele.ModeDefault))
+	assert.Equal(t, []string{"(1/3)\nabc", "(2/3)\ndef", "(3/3)\nghi"}, numberChunks("abc def ghi", 9, "\n", tele.ModeDefault))
+
+	// Without room for the text next to the labels the chunks go unnumbered
+	assert.Equal(t, []string{"abc", "def"}, numberChunks("abc def", 5, " ", tele.ModeDefault))
+
+	// Labels are escaped for MarkdownV2 and their escapes count against the limit
+	assert.Equal(t,
+		[]string{"\\(1/4\\) abc", "\\(2/4\\) def", "\\(3/4\\) ghi", "\\(4/4\\) jkl"},
+		numberChunks("abc def ghi jkl", 12, " ", tele.ModeMarkdownV2))
+}
+
+func TestNumberChunks_LabelWidthGrowsWithCount(t *testing.T) {
+	// Nine chunks of two words fit unlabeled; "(9/9) " leaves room for one word,
+	// and "(18/18) " is wider still
+	text := strings.TrimSpace(strings.Repeat("abcd ", 18))
+	chunks := numberChunks(text, 10, " ", tele.ModeDefault)
+
+	assert.Greater(t, len(chunks), 18)
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), 10, "chunk %d", i)
+		assert.True(t, strings.HasPrefix(chunk, fmt.Sprintf("(%d/%d) ", i+1, len(chunks))))
+	}
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"time"
+	"voxly/internal/config"
+	"voxly/internal/i18n"
+	"voxly/internal/speechkit"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// newReprocessModel reads the model offered for low-confidence transcripts. It
+// returns an empty model, disabling the offer, when none is configured or the
+// name is invalid.
+func newReprocessModel(cfg *config.Config) speechkit.Model {
+	if cfg.Reply.ReprocessBelow <= 0 || cfg.SpeechKit.ReprocessModel == "" {
+		return ""
+	}
+
+	reprocessModel, err := speechkit.ParseModel(cfg.SpeechKit.ReprocessModel)
+	if err != nil {
+		logger.Error("Invalid SpeechKit reprocess model, low confidence reprocessing disabled", zap.Error(err))
+		return ""
+	}
+	return reprocessModel
+}
+
+// recognitionModel picks the model for a task: the one a user asked for with the
+// reprocess button, or else the one selected by audio duration and quality
+func (p *Processor) recognitionModel(task *model.Task, durationSec int, audio speechkit.AudioFormat) speechkit.Model {
+	if override := task.ModelOverride(); override != "" {
+		if overrideModel, err := speechkit.ParseModel(override); err == nil {
+			return overrideModel
+		}
+		logger.WithTask(task.ID).Warn("Ignoring invalid model override", zap.String("model", override))
+	}
+	return p.models.SelectAudio(time.Duration(durationSec)*time.Second, audio)
+}
+
+// offerReprocess reports whether a transcript's average confidence is low enough
+// to offer recognizing it again with the reprocess model. Tasks already
+// recognized with that model, or reprocessed once, aren't offered it again.
+func (p *Processor) offerReprocess(task *model.Task, result *speechkit.RecognitionResult) bool {
+	if p.reprocessModel == "" || task.ModelOverride() != "" || task.Model() == string(p.reprocessModel) {
+		return false
+	}
+
+	confidence, ok := result.AverageConfidence()
+	return ok && confidence < p.cfg.Reply.ReprocessBelow
+}
+
+// reprocessMarkup builds the button that recognizes the task again with another model
+func reprocessMarkup(texts *i18n.Catalog, taskID string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data(texts.Text(i18n.ButtonReprocessModel), model.ButtonReprocessModel, taskID)))
+	return markup
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"voxly/internal/speechkit"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	tele "gopkg.in/telebot.v4"
+)
+
+func resultWithConfidence(confidences ...float64) *speechkit.RecognitionResult {
+	result := &speechkit.RecognitionResult{}
+	for _, confidence := range confidences {
+		result.Chunks = append(result.Chunks, speechkit.Chunk{
+			Alternatives: []speechkit.Alternative{{Text: "текст", Confidence: confidence}},
+		})
+	}
+	return result
+}
+
+func TestProcessor_OfferReprocess(t *testing.T) {
+	tests := []struct {
+		name   string
+		below  float64
+		model  string
+		task   func(*model.Task)
+		result *speechkit.RecognitionResult
+		offer  bool
+	}{
+		{"average below floor", 0.6, "general", nil, resultWithConfidence(0.7, 0.3), true},
+		{"average above floor", 0.6, "general", nil, resultWithConfidence(0.9, 0.5), false},
+		{"disabled", 0, "general", nil, resultWithConfidence(0.1), false},
+		{"invalid model", 0.6, "best", nil, resultWithConfidence(0.1), false},
+		{"no confidence reported", 0.6, "general", nil, resultWithConfidence(0), false},
+		{"already recognized with the model", 0.6, "general", func(task *model.Task) { task.SetModel("general") }, resultWithConfidence(0.1), false},
+		{"reprocessed once", 0.6, "general", func(task *model.Task) { task.SetModelOverride("general:rc") }, resultWithConfidence(0.1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Reply.ReprocessBelow = tt.below
+			cfg.SpeechKit.ReprocessModel = tt.model
+			p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+			task := &model.Task{ID: "task-1", Meta: model.JSONB{}}
+			task.SetModel("general:rc")
+			if tt.task != nil {
+				tt.task(task)
+			}
+
+			assert.Equal(t, tt.offer, p.offerReprocess(task, tt.result))
+		})
+	}
+}
+
+func TestProcessor_RecognitionModelOverride(t *testing.T) {
+	cfg := testConfig()
+	cfg.SpeechKit.Model = "general:rc"
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	task := &model.Task{ID: "task-1", Meta: model.JSONB{}}
+	assert.Equal(t, speechkit.ModelGeneralRC, p.recognitionModel(task, 10, speechkit.AudioFormat{}))
+
+	task.SetModelOverride("general")
+	assert.Equal(t, speechkit.ModelGeneral, p.recognitionModel(task, 10, speechkit.AudioFormat{}))
+
+	// An override that is no longer valid falls back to the selection
+	task.SetModelOverride("retired")
+	assert.Equal(t, speechkit.ModelGeneralRC, p.recognitionModel(task, 10, speechkit.AudioFormat{}))
+}
+
+func TestProcessor_RecognitionModelNarrowband(t *testing.T) {
+	cfg := testConfig()
+	cfg.SpeechKit.Model = "general:rc"
+	cfg.SpeechKit.NarrowbandModel = "general"
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	task := &model.Task{ID: "task-1", Meta: model.JSONB{}}
+	phone := speechkit.AudioFormat{Encoding: speechkit.EncodingOggOpus, SampleRate: 48000, ChannelCount: 1, InputSampleRate: 8000}
+	assert.Equal(t, speechkit.ModelGeneral, p.recognitionModel(task, 10, phone))
+
+	// Voice messages and unprobed audio keep the default model
+	voice := speechkit.AudioFormat{Encoding: speechkit.EncodingOggOpus, SampleRate: 48000, ChannelCount: 1, InputSampleRate: 48000}
+	assert.Equal(t, speechkit.ModelGeneralRC, p.recognitionModel(task, 10, voice))
+	assert.Equal(t, speechkit.ModelGeneralRC, p.recognitionModel(task, 10, speechkit.AudioFormat{}))
+
+	// The reprocess button still wins
+	task.SetModelOverride("deferred-general")
+	assert.Equal(t, speechkit.ModelDeferredGeneral, p.recognitionModel(task, 10, phone))
+}
+
+func TestProcessor_SendAttachesMarkupToFirstChunk(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	task := &model.Task{ID: "task-1", ChatID: 42, TelegramMessageID: 7}
+	text := strings.Repeat("слово ", maxMessageLength/6+100)
+	assert.NoError(t, p.sendResultToUser(context.Background(), task, text, tele.ModeDefault, reprocessMarkup(p.texts, task.ID)))
+
+	if sent := stub.sentMessages(); assert.Len(t, sent, 2) {
+		assert.Contains(t, sent[0]["reply_markup"], model.ButtonReprocessModel)
+		assert.Contains(t, sent[0]["reply_markup"], "task-1")
+		assert.Empty(t, sent[1]["reply_markup"])
+	}
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+
+	"go.uber.org/zap"
+)
+
+// ResultCacheStats counts lookups of recognition results cached by audio content
+type ResultCacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// resultCounters backs ResultCacheStats
+type resultCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// ResultCacheStats reports how often recognition was skipped thanks to a cached result
+func (p *Processor) ResultCacheStats() ResultCacheStats {
+	return ResultCacheStats{
+		Hits:   p.resultCounters.hits.Load(),
+		Misses: p.resultCounters.misses.Load(),
+	}
+}
+
+// resultCacheKey returns the key of the audio's recognition result with the
+// given options, or an empty string when the result cache is disabled
+func (p *Processor) resultCacheKey(data []byte, opts speechkit.RecognitionOptions) string {
+	if p.cfg.Worker.ResultCacheTTL <= 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	variant := fmt.Sprintf("%s:%s:%t", opts.Model, opts.Language, opts.ProfanityFilter)
+	return cache.RecognitionResultCacheKey(hex.EncodeToString(sum[:]), variant)
+}
+
+// cachedResult returns the recognition result cached under key, if any
+func (p *Processor) cachedResult(ctx context.Context, log *zap.Logger, key string) (*speechkit.RecognitionResult, bool) {
+	if key == "" {
+		return nil, false
+	}
+
+	var result speechkit.RecognitionResult
+	if err := p.cache.Get(ctx, key, &result); err != nil || len(result.Chunks) == 0 {
+		p.resultCounters.misses.Add(1)
+		return nil, false
+	}
+
+	p.resultCounters.hits.Add(1)
+	log.Info("Recognition result found in cache, skipping recognition",
+		zap.Int("chunks", len(result.Chunks)))
+	return &result, true
+}
+
+// cacheResult keeps a complete recognition result for the same audio sent again.
+// A lost entry only costs another recognition, so failures are logged.
+func (p *Processor) cacheResult(ctx context.Context, log *zap.Logger, key string, result *speechkit.RecognitionResult) {
+	if key == "" {
+		return
+	}
+	if err := p.cache.SetWithTTL(ctx, key, result, p.cfg.ResultCacheTTL()); err != nil {
+		log.Warn("Failed to cache recognition result", zap.Error(err))
+	}
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessor_ResultCacheStoresFullResult(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.Worker.ResultCacheTTL = time.Hour
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, cache.NewMemoryCache(time.Hour), nil)
+	log := logger.WithTask("task-1")
+
+	opts := speechkit.RecognitionOptions{Model: speechkit.ModelGeneral, Language: speechkit.LanguageAuto}
+	key := p.resultCacheKey([]byte("ogg-data"), opts)
+	assert.NotEmpty(t, key)
+
+	_, ok := p.cachedResult(ctx, log, key)
+	assert.False(t, ok)
+
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{{
+		StartTimeMs: 1200,
+		EndTimeMs:   2400,
+		ChannelTag:  "1",
+		Alternatives: []speechkit.Alternative{{
+			Text:         "Привет",
+			Confidence:   0.9,
+			LanguageCode: "ru-RU",
+			Words:        []speechkit.Word{{Word: "привет", StartTimeMs: 1200, EndTimeMs: 2400, Confidence: 0.9}},
+		}},
+	}}}
+	p.cacheResult(ctx, log, key, result)
+
+	// Timings and words survive, so timestamps can be rendered from the cache
+	cached, ok := p.cachedResult(ctx, log, key)
+	assert.True(t, ok)
+	assert.Equal(t, result.Chunks, cached.Chunks)
+	assert.Equal(t, "[0:01] Привет", cached.GetTextWithTimestamps())
+	assert.Equal(t, ResultCacheStats{Hits: 1, Misses: 1}, p.ResultCacheStats())
+
+	// Options that change the result get their own entry
+	assert.NotEqual(t, key, p.resultCacheKey([]byte("ogg-data"), speechkit.RecognitionOptions{Model: speechkit.ModelGeneral, Language: "ru-RU"}))
+	assert.NotEqual(t, key, p.resultCacheKey([]byte("other"), opts))
+
+	// Disabled by default
+	p.cfg.Worker.ResultCacheTTL = 0
+	assert.Empty(t, p.resultCacheKey([]byte("ogg-data"), opts))
+}
+
+func TestProcessor_ProcessTaskReusesCachedResult(t *testing.T) {
+	bot, stub := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	newTask := func(id string, messageID int64) *model.Task {
+		return &model.Task{
+			ID:                id,
+			TelegramMessageID: messageID,
+			ChatID:            42,
+			FileID:            "file-" + id,
+			Status:            model.TaskStatusQueued,
+			Meta:              model.JSONB{},
+		}
+	}
+	first, second := newTask("task-1", 7), newTask("task-2", 8)
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-1.ogg"
+	result := &speechkit.RecognitionResult{
+		Chunks: []speechkit.Chunk{
+			{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+		},
+	}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-1").Return(first, nil)
+	// Long enough for the reused result to register as processing time
+	mockDB.On("GetTaskByID", mock.Anything, "task-2").After(10*time.Millisecond).Return(second, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-1", ".ogg").Return("voice/task-1.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-1.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-1", nil)
+	mockSK.On("WaitForResult", "op-1").Return(result, nil)
+
+	cfg := testConfig()
+	cfg.Worker.ResultCacheTTL = time.Hour
+	memory := cache.NewMemoryCache(time.Hour)
+	p := NewProcessor(cfg, mockDB, mockS3, mockSK, bot, memory, nil)
+
+	ctx := context.Background()
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, first)))
+	assert.NoError(t, memory.Delete(ctx, cache.ProcessingRateCacheKey))
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, second)))
+
+	// A cached result says nothing about how long recognition takes
+	_, ok := cache.ProcessingRate(ctx, memory)
+	assert.False(t, ok)
+
+	// The second message with the same audio is answered without a recognition
+	assert.Equal(t, model.TaskStatusDone, second.Status)
+	mockSK.AssertNumberOfCalls(t, "StartRecognition", 1)
+	mockS3.AssertNumberOfCalls(t, "UploadFile", 1)
+	assert.Equal(t, ResultCacheStats{Hits: 1, Misses: 1}, p.ResultCacheStats())
+
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "Привет", sent[0]["text"])
+		assert.Equal(t, "Привет", sent[1]["text"])
+	}
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"voxly/internal/queue"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ResultPublisher announces finished tasks to other consumers
+type ResultPublisher interface {
+	PublishResult(result *queue.TranscriptionResult) error
+}
+
+// PublishResults makes the processor publish a TranscriptionResult for every
+// task that completes or fails for good. Call it before consuming tasks.
+func (p *Processor) PublishResults(publisher ResultPublisher) {
+	p.results = publisher
+}
+
+// publishResult sends result if a publisher is set. A lost result only
+// affects downstream consumers, so failures are logged and otherwise ignored.
+func (p *Processor) publishResult(result *queue.TranscriptionResult) {
+	if p.results == nil {
+		return
+	}
+
+	if err := p.results.PublishResult(result); err != nil {
+		logger.Error("Failed to publish transcription result",
+			zap.String("task_id", result.TaskID),
+			zap.Bool("success", result.Success),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"voxly/internal/queue"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockResults is a mock implementation of ResultPublisher
+type MockResults struct {
+	mock.Mock
+}
+
+func (m *MockResults) PublishResult(result *queue.TranscriptionResult) error {
+	args := m.Called(result)
+	return args.Error(0)
+}
+
+func TestProcessor_PublishesSuccessResult(t *testing.T) {
+	bot, _ := newTelegramStub(t, []byte("ogg-data"))
+	mockDB := new(MockDB)
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	results := new(MockResults)
+
+	task := &model.Task{ID: "task-123", TelegramMessageID: 7, ChatID: 42, FileID: "file-123", Status: model.TaskStatusQueued, Meta: model.JSONB{}}
+	s3URL := "https://storage.yandexcloud.net/bucket/voice/task-123.ogg"
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{
+		{Alternatives: []speechkit.Alternative{{Text: "Привет", Confidence: 0.9}}},
+	}}
+
+	mockDB.On("GetTaskByID", mock.Anything, "task-123").Return(task, nil)
+	mockDB.On("GetChatPreferences", mock.Anything, int64(42)).Return(&model.ChatPreferences{ChatID: 42}, nil)
+	mockDB.On("UpdateTask", mock.Anything, task).Return(nil)
+	mockDB.On("CreateTranscript", mock.Anything, mock.AnythingOfType("*model.Transcript")).Return(nil)
+	mockS3.On("GenerateKey", "task-123", ".ogg").Return("voice/task-123.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/task-123.ogg", mock.Anything, "audio/ogg").Return(s3URL, nil)
+	mockSK.On("StartRecognition", s3URL, mock.Anything).Return("op-123", nil)
+	mockSK.On("WaitForResult", "op-123").Return(result, nil)
+
+	var published *queue.TranscriptionResult
+	results.On("PublishResult", mock.AnythingOfType("*queue.TranscriptionResult")).
+		Run(func(args mock.Arguments) { published = args.Get(0).(*queue.TranscriptionResult) }).
+		Return(errors.New("channel closed")) // must not fail the task
+
+	p := NewProcessor(testConfig(), mockDB, mockS3, mockSK, bot, cache.NewNoopCache(), nil)
+	p.PublishResults(results)
+
+	assert.NoError(t, p.ProcessTask(marshalVoiceTask(t, task)))
+	assert.Equal(t, model.TaskStatusDone, task.Status)
+
+	if assert.NotNil(t, published) {
+		assert.Equal(t, "task-123", published.TaskID)
+		assert.Equal(t, "Привет", published.Text)
+		assert.True(t, published.Success)
+		assert.Empty(t, published.ErrorMessage)
+		assert.NotEmpty(t, published.RawResponse)
+		// The bot caches the transcript as stored
+		assert.NotEmpty(t, published.TranscriptID)
+		assert.False(t, published.CreatedAt.IsZero())
+	}
+}
+
+func TestProcessor_PublishesFailureResult(t *testing.T) {
+	bot, _ := newTelegramStub(t, nil)
+	mockDB := new(MockDB)
+	mockDB.On("UpdateTask", mock.Anything, mock.AnythingOfType("*model.Task")).Return(nil)
+	results := new(MockResults)
+	results.On("PublishResult", &queue.TranscriptionResult{
+		TaskID:       "task-123",
+		Success:      false,
+		ErrorMessage: "failed to get recognition result: recognition failed: invalid audio (code: 3)",
+	}).Return(nil)
+
+	cfg := testConfig()
+	cfg.Worker.MaxAttempts = 2
+	p := NewProcessor(cfg, mockDB, new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.PublishResults(results)
+	task := &model.Task{ID: "task-123", ChatID: 42, TelegramMessageID: 7, Status: model.TaskStatusInProgress}
+
+	// A retried failure isn't final yet
+	assert.Error(t, p.handleTaskError(context.Background(), task, errors.New("boom")))
+	results.AssertNotCalled(t, "PublishResult", mock.Anything)
+
+	opErr := &speechkit.OperationError{Code: 3, Message: "invalid audio"}
+	assert.NoError(t, p.handleTaskError(context.Background(), task, fmt.Errorf("failed to get recognition result: %w", opErr)))
+	results.AssertExpectations(t)
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voxly/internal/storage"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// RetentionStore deletes stored data by age
+type RetentionStore interface {
+	DeleteTranscriptsOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteTasksOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteOutboxMessagesOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Retention periodically deletes transcripts, unsent replies carrying them, their
+// S3 backups and optionally finished tasks older than the retention period, so
+// recognized text isn't kept forever. Cached copies in Redis expire on their own
+// within the period, see config.Config.TranscriptCacheTTL.
+type Retention struct {
+	db          RetentionStore
+	backups     ObjectCleaner
+	callTimeout time.Duration
+	retention   time.Duration
+	deleteTasks bool
+	interval    time.Duration
+}
+
+// NewRetention creates a job deleting data older than retention every interval
+func NewRetention(db RetentionStore, retention time.Duration, deleteTasks bool, interval time.Duration) *Retention {
+	return &Retention{
+		db:          db,
+		retention:   retention,
+		deleteTasks: deleteTasks,
+		interval:    interval,
+	}
+}
+
+// UseBackups also deletes transcript backups older than the retention period
+// from s3, giving each S3 call callTimeout. Call it before Run.
+func (r *Retention) UseBackups(s3 ObjectCleaner, callTimeout time.Duration) {
+	r.backups = s3
+	r.callTimeout = callTimeout
+}
+
+// Run deletes expired data on every tick until ctx is cancelled
+func (r *Retention) Run(ctx context.Context) {
+	if r.interval <= 0 || r.retention <= 0 {
+		logger.Info("Transcript retention disabled")
+		return
+	}
+
+	logger.Info("Starting transcript retention",
+		zap.Duration("interval", r.interval),
+		zap.Duration("retention", r.retention),
+		zap.Bool("delete_tasks", r.deleteTasks))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Purge(ctx); err != nil {
+				logger.Error("Transcript retention failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Purge deletes transcripts, outbox replies, transcript backups and, if
+// enabled, finished tasks created before the retention period
+func (r *Retention) Purge(ctx context.Context) error {
+	cutoff := time.Now().Add(-r.retention)
+
+	transcripts, err := r.db.DeleteTranscriptsOlderThan(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	replies, err := r.db.DeleteOutboxMessagesOlderThan(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	backups, err := r.purgeBackups(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	var tasks int64
+	if r.deleteTasks {
+		if tasks, err = r.db.DeleteTasksOlderThan(ctx, cutoff); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Transcript retention completed",
+		zap.Time("cutoff", cutoff),
+		zap.Int64("transcripts_deleted", transcripts),
+		zap.Int64("outbox_deleted", replies),
+		zap.Int("backups_deleted", backups),
+		zap.Int64("tasks_deleted", tasks))
+
+	return nil
+}
+
+// purgeBackups deletes transcript backups stored before cutoff and returns how
+// many were deleted. A backup that can't be deleted is tried again next run.
+func (r *Retention) purgeBackups(ctx context.Context, cutoff time.Time) (int, error) {
+	if r.backups == nil {
+		return 0, nil
+	}
+
+	objects, err := listObjects(ctx, r.backups, storage.TranscriptKeyPrefix+"/", r.callTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list transcript backups: %w", err)
+	}
+
+	deleted := 0
+	for _, obj := range objects {
+		if !obj.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := deleteObject(ctx, r.backups, obj.Key, r.callTimeout); err != nil {
+			logger.Error("Failed to delete transcript backup",
+				zap.String("key", obj.Key),
+				zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRetentionStore struct {
+	mock.Mock
+}
+
+func (m *MockRetentionStore) DeleteTranscriptsOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRetentionStore) DeleteTasksOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRetentionStore) DeleteOutboxMessagesOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// aroundCutoff matches a cutoff retention before now
+func aroundCutoff(retention time.Duration) interface{} {
+	return mock.MatchedBy(func(cutoff time.Time) bool {
+		expected := time.Now().Add(-retention)
+		return cutoff.After(expected.Add(-time.Minute)) && !cutoff.After(expected)
+	})
+}
+
+func TestRetention_Purge(t *testing.T) {
+	ctx := context.Background()
+	retention := 30 * 24 * time.Hour
+
+	db := new(MockRetentionStore)
+	db.On("DeleteTranscriptsOlderThan", ctx, aroundCutoff(retention)).Return(int64(3), nil)
+	db.On("DeleteOutboxMessagesOlderThan", ctx, aroundCutoff(retention)).Return(int64(1), nil)
+	db.On("DeleteTasksOlderThan", ctx, aroundCutoff(retention)).Return(int64(2), nil)
+
+	assert.NoError(t, NewRetention(db, retention, true, time.Hour).Purge(ctx))
+	db.AssertExpectations(t)
+}
+
+func TestRetention_PurgeKeepsTasks(t *testing.T) {
+	ctx := context.Background()
+
+	db := new(MockRetentionStore)
+	db.On("DeleteTranscriptsOlderThan", ctx, mock.Anything).Return(int64(3), nil)
+	db.On("DeleteOutboxMessagesOlderThan", ctx, mock.Anything).Return(int64(0), nil)
+
+	assert.NoError(t, NewRetention(db, time.Hour, false, time.Hour).Purge(ctx))
+	db.AssertNotCalled(t, "DeleteTasksOlderThan", mock.Anything, mock.Anything)
+}
+
+func TestRetention_PurgeError(t *testing.T) {
+	ctx := context.Background()
+
+	db := new(MockRetentionStore)
+	db.On("DeleteTranscriptsOlderThan", ctx, mock.Anything).Return(int64(0), errors.New("connection refused"))
+
+	assert.Error(t, NewRetention(db, time.Hour, true, time.Hour).Purge(ctx))
+	db.AssertNotCalled(t, "DeleteTasksOlderThan", mock.Anything, mock.Anything)
+}
+
+func TestRetention_PurgeBackups(t *testing.T) {
+	ctx := context.Background()
+	retention := 30 * 24 * time.Hour
+	now := time.Now()
+
+	db := new(MockRetentionStore)
+	db.On("DeleteTranscriptsOlderThan", ctx, mock.Anything).Return(int64(1), nil)
+	db.On("DeleteOutboxMessagesOlderThan", ctx, mock.Anything).Return(int64(0), nil)
+
+	s3 := new(MockS3)
+	s3.On("ListObjects", mock.Anything, "transcripts/").Return([]storage.ObjectInfo{
+		{Key: "transcripts/2024/01/01/task-old.txt", LastModified: now.Add(-retention - time.Hour)},
+		{Key: "transcripts/2024/01/01/task-old.json", LastModified: now.Add(-retention - time.Hour)},
+		{Key: "transcripts/2024/03/01/task-new.txt", LastModified: now.Add(-time.Hour)},
+	}, nil)
+	s3.On("DeleteFile", mock.Anything, "transcripts/2024/01/01/task-old.txt").Return(nil)
+	s3.On("DeleteFile", mock.Anything, "transcripts/2024/01/01/task-old.json").Return(nil)
+
+	r := NewRetention(db, retention, false, time.Hour)
+	r.UseBackups(s3, time.Minute)
+
+	assert.NoError(t, r.Purge(ctx))
+	s3.AssertExpectations(t)
+	s3.AssertNotCalled(t, "DeleteFile", mock.Anything, "transcripts/2024/03/01/task-new.txt")
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/model"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultSegmentConcurrency bounds parallel segment recognitions when none is configured
+const defaultSegmentConcurrency = 4
+
+// splitSegments cuts long recordings into segments when segmentation is on.
+// It returns nil when the audio should be recognized as a whole.
+func (p *Processor) splitSegments(log *zap.Logger, durationSec int, data []byte) []speechkit.AudioSegment {
+	length := p.cfg.Worker.SegmentDuration
+	if length <= 0 || time.Duration(durationSec)*time.Second <= length {
+		return nil
+	}
+
+	segments, err := speechkit.SplitOggOpus(data, length)
+	if err != nil {
+		log.Warn("Failed to split audio, recognizing it whole", zap.Error(err))
+		return nil
+	}
+	return segments
+}
+
+// segmentConcurrency returns how many segments of a task are recognized at once
+func (p *Processor) segmentConcurrency() int {
+	if p.cfg.Worker.SegmentConcurrency <= 0 {
+		return defaultSegmentConcurrency
+	}
+	return p.cfg.Worker.SegmentConcurrency
+}
+
+// recognizeSegments uploads and recognizes segments in parallel and merges the
+// results in order. The first failure cancels the remaining segments.
+func (p *Processor) recognizeSegments(ctx context.Context, log *zap.Logger, task *model.Task, segments []speechkit.AudioSegment, opts speechkit.RecognitionOptions) (*speechkit.RecognitionResult, error) {
+	log.Info("Recognizing audio in segments", zap.Int("segments", len(segments)))
+
+	results := make([]*speechkit.RecognitionResult, len(segments))
+	keys := make([]string, len(segments))
+	for i := range segments {
+		keys[i] = p.s3.GenerateKey(task.ID, fmt.Sprintf(".part%03d.ogg", i))
+	}
+	task.AddAudioKeys(keys...)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(p.segmentConcurrency())
+	for i, segment := range segments {
+		g.Go(func() error {
+			result, err := p.recognizeSegment(gctx, log, keys[i], segment, opts)
+			if err != nil {
+				return fmt.Errorf("segment %d: %w", i, err)
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	merged := &speechkit.RecognitionResult{}
+	for i, result := range results {
+		merged.Chunks = append(merged.Chunks, shiftChunks(result.Chunks, segments[i].Offset)...)
+	}
+	return merged, nil
+}
+
+// recognizeSegment runs one segment through upload and recognition
+func (p *Processor) recognizeSegment(ctx context.Context, log *zap.Logger, key string, segment speechkit.AudioSegment, opts speechkit.RecognitionOptions) (*speechkit.RecognitionResult, error) {
+	// Don't start billable work once a sibling has failed
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	url, err := p.uploadFile(ctx, key, bytes.NewReader(segment.Data), "audio/ogg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload to S3: %w", err)
+	}
+
+	operationID, err := p.speechkit.StartRecognition(url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start recognition: %w", err)
+	}
+
+	result, err := p.speechkit.WaitForResult(ctx, operationID, nil)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			// Cancelled because another segment failed; stop paying for this one
+			if cancelErr := p.speechkit.CancelOperation(context.Background(), operationID); cancelErr != nil {
+				log.Warn("Failed to cancel segment operation",
+					zap.String("operation_id", operationID),
+					zap.Error(cancelErr))
+			}
+		}
+		return nil, fmt.Errorf("failed to get recognition result: %w", err)
+	}
+	return result, nil
+}
+
+// shiftChunks moves chunk and word timings by the segment's offset in the recording
+func shiftChunks(chunks []speechkit.Chunk, offset time.Duration) []speechkit.Chunk {
+	shift := offset.Milliseconds()
+	if shift == 0 {
+		return chunks
+	}
+
+	shifted := make([]speechkit.Chunk, len(chunks))
+	for i, chunk := range chunks {
+		chunk.StartTimeMs += shift
+		chunk.EndTimeMs += shift
+
+		alternatives := make([]speechkit.Alternative, len(chunk.Alternatives))
+		for j, alt := range chunk.Alternatives {
+			words := make([]speechkit.Word, len(alt.Words))
+			for k, word := range alt.Words {
+				word.StartTimeMs += shift
+				word.EndTimeMs += shift
+				words[k] = word
+			}
+			if alt.Words == nil {
+				words = nil
+			}
+			alt.Words = words
+			alternatives[j] = alt
+		}
+		chunk.Alternatives = alternatives
+		shifted[i] = chunk
+	}
+	return shifted
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// segmentRecognizer fakes SpeechKit for segment tests, tracking how many
+// operations are awaited at once. The failing operation fails once another
+// one is in flight; the rest block until cancelled when block is set.
+type segmentRecognizer struct {
+	mu        sync.Mutex
+	inFlight  int
+	maxFlight int
+	started   []string
+	cancelled []string
+
+	failing string
+	block   bool
+	waiting chan struct{}
+	once    sync.Once
+}
+
+func newSegmentRecognizer() *segmentRecognizer {
+	return &segmentRecognizer{waiting: make(chan struct{})}
+}
+
+func (r *segmentRecognizer) StartRecognition(s3URI string, opts speechkit.RecognitionOptions) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, s3URI)
+	return "op-" + s3URI, nil
+}
+
+func (r *segmentRecognizer) WaitForResult(ctx context.Context, operationID string, onProgress speechkit.ProgressFunc) (*speechkit.RecognitionResult, error) {
+	r.mu.Lock()
+	r.inFlight++
+	r.maxFlight = max(r.maxFlight, r.inFlight)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight--
+		r.mu.Unlock()
+	}()
+
+	if operationID == r.failing {
+		<-r.waiting
+		return nil, errors.New("recognition failed")
+	}
+	if r.block {
+		r.once.Do(func() { close(r.waiting) })
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	return &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{{
+		StartTimeMs:  100,
+		EndTimeMs:    900,
+		Alternatives: []speechkit.Alternative{{Text: operationID, Words: []speechkit.Word{{Word: operationID, StartTimeMs: 100, EndTimeMs: 900}}}},
+	}}}, nil
+}
+
+func (r *segmentRecognizer) CancelOperation(ctx context.Context, operationID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancelled = append(r.cancelled, operationID)
+	return nil
+}
+
+// segmentStorage expects an upload for each of count segments of task-1
+func segmentStorage(count int) *MockS3 {
+	mockS3 := new(MockS3)
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("voice/task-1.part%03d.ogg", i)
+		mockS3.On("GenerateKey", "task-1", fmt.Sprintf(".part%03d.ogg", i)).Return(key)
+		mockS3.On("UploadFile", mock.Anything, key, mock.Anything, "audio/ogg").Return(key, nil)
+	}
+	return mockS3
+}
+
+func testSegments(count int) []speechkit.AudioSegment {
+	segments := make([]speechkit.AudioSegment, count)
+	for i := range segments {
+		segments[i] = speechkit.AudioSegment{Data: []byte{byte(i)}, Offset: time.Duration(i) * time.Minute}
+	}
+	return segments
+}
+
+func TestProcessor_RecognizeSegmentsBoundsConcurrency(t *testing.T) {
+	cfg := testConfig()
+	cfg.Worker.SegmentConcurrency = 2
+	sk := newSegmentRecognizer()
+	p := NewProcessor(cfg, new(MockDB), segmentStorage(6), sk, nil, new(MockCache), nil)
+
+	result, err := p.recognizeSegments(context.Background(), logger.Logger, &model.Task{ID: "task-1"}, testSegments(6), speechkit.RecognitionOptions{})
+	assert.NoError(t, err)
+
+	assert.LessOrEqual(t, sk.maxFlight, 2)
+	assert.Len(t, sk.started, 6)
+
+	// Chunks keep the recording order, shifted to where their segment starts
+	if assert.Len(t, result.Chunks, 6) {
+		for i, chunk := range result.Chunks {
+			shift := int64(i) * time.Minute.Milliseconds()
+			assert.Equal(t, fmt.Sprintf("op-voice/task-1.part%03d.ogg", i), chunk.Alternatives[0].Text)
+			assert.Equal(t, 100+shift, chunk.StartTimeMs)
+			assert.Equal(t, 900+shift, chunk.EndTimeMs)
+			assert.Equal(t, 100+shift, chunk.Alternatives[0].Words[0].StartTimeMs)
+		}
+	}
+}
+
+func TestProcessor_RecognizeSegmentsFailsFast(t *testing.T) {
+	cfg := testConfig()
+	cfg.Worker.SegmentConcurrency = 2
+	sk := newSegmentRecognizer()
+	sk.failing = "op-voice/task-1.part000.ogg"
+	sk.block = true
+	p := NewProcessor(cfg, new(MockDB), segmentStorage(5), sk, nil, new(MockCache), nil)
+
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		_, err = p.recognizeSegments(context.Background(), logger.Logger, &model.Task{ID: "task-1"}, testSegments(5), speechkit.RecognitionOptions{})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recognizeSegments didn't return after a segment failed")
+	}
+
+	assert.EqualError(t, err, "segment 0: failed to get recognition result: recognition failed")
+	// The sibling in flight is cancelled and no further segments are started
+	assert.Equal(t, []string{"op-voice/task-1.part001.ogg"}, sk.cancelled)
+	assert.Len(t, sk.started, 2)
+}
+
+func TestProcessor_SplitSegments(t *testing.T) {
+	cfg := testConfig()
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	// Disabled by default
+	assert.Nil(t, p.splitSegments(logger.Logger, 600, []byte("ogg")))
+
+	cfg.Worker.SegmentDuration = time.Minute
+	// Short recordings are recognized whole
+	assert.Nil(t, p.splitSegments(logger.Logger, 60, []byte("ogg")))
+	// Unsplittable audio falls back to a single recognition
+	assert.Nil(t, p.splitSegments(logger.Logger, 600, []byte("not ogg")))
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// selfTestAudio is one second of silent mono OGG Opus, small enough to recognize for free
+//
+//go:embed selftest.ogg
+var selfTestAudio []byte
+
+// Self-test stage names, in pipeline order
+const (
+	SelfTestStageUpload    = "upload"
+	SelfTestStageStart     = "start_recognition"
+	SelfTestStageRecognize = "recognize"
+)
+
+// SelfTestStage reports how one pipeline stage did
+type SelfTestStage struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SelfTestReport is the outcome of a synthetic run through the pipeline
+type SelfTestReport struct {
+	OK     bool            `json:"ok"`
+	Stages []SelfTestStage `json:"stages"`
+}
+
+// SelfTest runs the bundled clip through upload and recognition without touching
+// Telegram or the database. Stages after the first failure are not run.
+func (p *Processor) SelfTest(ctx context.Context) *SelfTestReport {
+	log := logger.WithTask("selftest")
+	report := &SelfTestReport{OK: true}
+
+	run := func(name string, stage func() error) bool {
+		start := time.Now()
+		err := stage()
+
+		result := SelfTestStage{Name: name, OK: err == nil, LatencyMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+			log.Warn("Self-test stage failed", zap.String("stage", name), zap.Error(err))
+		}
+		report.Stages = append(report.Stages, result)
+		return err == nil
+	}
+
+	// A task-like key lets the orphan cleaner remove the clip if the delete fails
+	key := p.s3.GenerateKey(uuid.New().String(), ".ogg")
+	var url, operationID string
+
+	if !run(SelfTestStageUpload, func() (err error) {
+		url, err = p.uploadFile(ctx, key, bytes.NewReader(selfTestAudio), "audio/ogg")
+		return err
+	}) {
+		return report
+	}
+	defer p.deleteSelfTestAudio(key)
+
+	if !run(SelfTestStageStart, func() (err error) {
+		audio, _ := speechkit.ProbeAudioFormat(selfTestAudio)
+		operationID, err = p.speechkit.StartRecognition(url, speechkit.RecognitionOptions{
+			Model:    p.models.Default,
+			Language: speechkit.DefaultLanguage,
+			Audio:    audio,
+		})
+		return err
+	}) {
+		return report
+	}
+
+	run(SelfTestStageRecognize, func() error {
+		if _, err := p.speechkit.WaitForResult(ctx, operationID, nil); err != nil {
+			return fmt.Errorf("failed to get recognition result: %w", err)
+		}
+		return nil
+	})
+
+	log.Info("Self-test finished", zap.Bool("ok", report.OK))
+	return report
+}
+
+// deleteSelfTestAudio removes the uploaded clip when the store supports deletes
+func (p *Processor) deleteSelfTestAudio(key string) {
+	cleaner, ok := p.s3.(ObjectCleaner)
+	if !ok {
+		return
+	}
+	ctx, cancel := p.s3Context(context.Background())
+	defer cancel()
+
+	if err := cleaner.DeleteFile(ctx, key); err != nil {
+		logger.Warn("Failed to delete self-test audio", zap.String("key", key), zap.Error(err))
+	}
+}
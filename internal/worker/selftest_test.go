@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSelfTestAudioIsOggOpus(t *testing.T) {
+	format, ok := speechkit.ProbeAudioFormat(selfTestAudio)
+	assert.True(t, ok)
+	assert.Equal(t, speechkit.AudioFormat{Encoding: speechkit.EncodingOggOpus, SampleRate: 48000, ChannelCount: 1, InputSampleRate: 48000}, format)
+
+	// A single second keeps each self-test run cheap
+	segments, err := speechkit.SplitOggOpus(selfTestAudio, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
+
+func TestProcessor_SelfTest(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockSpeechKit := new(MockSpeechKit)
+	mockS3.On("GenerateKey", mock.Anything, ".ogg").Return("voice/selftest.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/selftest.ogg", mock.Anything, "audio/ogg").Return("s3://bucket/voice/selftest.ogg", nil)
+	mockS3.On("DeleteFile", mock.Anything, "voice/selftest.ogg").Return(nil)
+	mockSpeechKit.On("StartRecognition", "s3://bucket/voice/selftest.ogg", mock.Anything).Return("op-1", nil)
+	mockSpeechKit.On("WaitForResult", "op-1").Return(&speechkit.RecognitionResult{}, nil)
+
+	p := NewProcessor(testConfig(), new(MockDB), mockS3, mockSpeechKit, nil, new(MockCache), nil)
+	report := p.SelfTest(context.Background())
+
+	assert.True(t, report.OK)
+	if assert.Len(t, report.Stages, 3) {
+		assert.Equal(t, SelfTestStageUpload, report.Stages[0].Name)
+		assert.Equal(t, SelfTestStageStart, report.Stages[1].Name)
+		assert.Equal(t, SelfTestStageRecognize, report.Stages[2].Name)
+		for _, stage := range report.Stages {
+			assert.True(t, stage.OK)
+			assert.Empty(t, stage.Error)
+		}
+	}
+	mockS3.AssertExpectations(t)
+	mockSpeechKit.AssertExpectations(t)
+}
+
+func TestProcessor_SelfTestStopsAtFailedStage(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockSpeechKit := new(MockSpeechKit)
+	mockS3.On("GenerateKey", mock.Anything, ".ogg").Return("voice/selftest.ogg")
+	mockS3.On("UploadFile", mock.Anything, "voice/selftest.ogg", mock.Anything, "audio/ogg").Return("s3://bucket/voice/selftest.ogg", nil)
+	mockS3.On("DeleteFile", mock.Anything, "voice/selftest.ogg").Return(nil)
+	mockSpeechKit.On("StartRecognition", mock.Anything, mock.Anything).Return("", errors.New("permission denied"))
+
+	p := NewProcessor(testConfig(), new(MockDB), mockS3, mockSpeechKit, nil, new(MockCache), nil)
+	report := p.SelfTest(context.Background())
+
+	assert.False(t, report.OK)
+	if assert.Len(t, report.Stages, 2) {
+		assert.True(t, report.Stages[0].OK)
+		assert.False(t, report.Stages[1].OK)
+		assert.Equal(t, "permission denied", report.Stages[1].Error)
+	}
+	mockSpeechKit.AssertNotCalled(t, "WaitForResult", mock.Anything)
+	// The uploaded clip is still removed
+	mockS3.AssertExpectations(t)
+}
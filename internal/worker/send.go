@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// maxFloodRetries is how many times a send is repeated after Telegram asks to slow down
+const maxFloodRetries = 3
+
+// defaultSendRate is the Bot API limit for messages to different chats
+const defaultSendRate = 30
+
+// newSendLimiter spreads sends evenly at rate messages per second
+func newSendLimiter(rate int) *resilience.RateLimiter {
+	if rate <= 0 {
+		rate = defaultSendRate
+	}
+	return resilience.NewRateLimiter(rate, time.Second/time.Duration(rate))
+}
+
+// Retry timing for a single reply
+const (
+	sendRetryInitialInterval = 500 * time.Millisecond
+	sendRetryMaxInterval     = 5 * time.Second
+)
+
+// newTelegramBreaker stops replies for timeout after maxFailures failed in a
+// row; non-positive values fall back to 5 failures and 30 seconds
+func newTelegramBreaker(maxFailures int, timeout time.Duration) *resilience.CircuitBreaker {
+	if maxFailures <= 0 {
+		maxFailures = 5
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return resilience.NewCircuitBreaker(uint32(maxFailures), timeout)
+}
+
+// newSendRetry makes attempts tries per reply, including the first; non-positive means 3
+func newSendRetry(attempts int) *resilience.RetryConfig {
+	if attempts <= 0 {
+		attempts = 3
+	}
+	return &resilience.RetryConfig{
+		MaxAttempts:     attempts,
+		InitialInterval: sendRetryInitialInterval,
+		MaxInterval:     sendRetryMaxInterval,
+		Multiplier:      2.0,
+	}
+}
+
+// TelegramBreaker returns the breaker guarding replies
+func (p *Processor) TelegramBreaker() *resilience.CircuitBreaker {
+	return p.telegramBreaker
+}
+
+// send delivers a message within the global and the chat's send rate. On flood control it
+// waits as long as Telegram asks and tries again. Failures on Telegram's side are
+// retried, and a send failing every attempt counts towards opening the breaker;
+// while it is open, sends fail with resilience.ErrCircuitOpen right away.
+func (p *Processor) send(ctx context.Context, to tele.Recipient, what interface{}, opts *tele.SendOptions) (*tele.Message, error) {
+	var msg *tele.Message
+	var sendErr error
+	err := p.telegramBreaker.Execute(func() error {
+		err := resilience.RetryWithExponentialBackoff(ctx, p.sendRetry, func() error {
+			msg, sendErr = p.paced(ctx, to, func() (*tele.Message, error) {
+				return p.bot.Send(to, what, opts)
+			})
+			// Errors about the message or the chat are the caller's to handle
+			// and don't mean Telegram is down
+			if isTransientTelegramError(sendErr) {
+				return sendErr
+			}
+			return nil
+		})
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			// The worker stopping says nothing about Telegram
+			sendErr = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, sendErr
+}
+
+// edit replaces the text of a message sent earlier, paced like send
+func (p *Processor) edit(ctx context.Context, chatID int64, messageID int, what interface{}, opts *tele.SendOptions) (*tele.Message, error) {
+	to := &tele.Chat{ID: chatID}
+	msg := &tele.StoredMessage{MessageID: strconv.Itoa(messageID), ChatID: chatID}
+	return p.paced(ctx, to, func() (*tele.Message, error) {
+		return p.bot.Edit(msg, what, opts)
+	})
+}
+
+// paced runs a Bot API call within the global and the chat's send rate,
+// repeating it on flood control
+func (p *Processor) paced(ctx context.Context, to tele.Recipient, call func() (*tele.Message, error)) (*tele.Message, error) {
+	for attempt := 0; ; attempt++ {
+		if err := p.chatPacer.Wait(ctx, to.Recipient()); err != nil {
+			return nil, err
+		}
+		if err := p.sendLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		msg, err := call()
+
+		var floodErr tele.FloodError
+		if !errors.As(err, &floodErr) || attempt == maxFloodRetries {
+			return msg, err
+		}
+
+		wait := time.Duration(floodErr.RetryAfter) * p.floodWaitUnit
+		logger.Warn("Telegram flood control, delaying send",
+			zap.String("recipient", to.Recipient()),
+			zap.Duration("retry_after", wait))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
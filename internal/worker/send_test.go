@@ -0,0 +1,239 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	tele "gopkg.in/telebot.v4"
+)
+
+const floodResponse = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 2","parameters":{"retry_after":2}}`
+
+func TestProcessor_SendRetriesAfterFloodError(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	stub.sendFailures = []string{floodResponse}
+
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.floodWaitUnit = 10 * time.Millisecond
+
+	start := time.Now()
+	err := p.sendResultToUser(context.Background(), &model.Task{ChatID: 42, TelegramMessageID: 7}, "Привет", tele.ModeDefault, nil)
+	assert.NoError(t, err)
+
+	// retry_after is honoured before the second attempt
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "Привет", sent[1]["text"])
+	}
+}
+
+func TestProcessor_SendGivesUpOnPersistentFlood(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	stub.sendError = floodResponse
+
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.floodWaitUnit = time.Millisecond
+
+	_, err := p.send(context.Background(), &tele.Chat{ID: 42}, "Привет", &tele.SendOptions{})
+
+	var floodErr tele.FloodError
+	assert.ErrorAs(t, err, &floodErr)
+	assert.Equal(t, 2, floodErr.RetryAfter)
+	assert.Len(t, stub.sentMessages(), maxFloodRetries+1)
+}
+
+func TestProcessor_SendStopsWaitingOnCancel(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	stub.sendError = floodResponse
+
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+	p.floodWaitUnit = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := p.send(ctx, &tele.Chat{ID: 42}, "Привет", &tele.SendOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, stub.sentMessages(), 1)
+}
+
+func TestNewSendLimiter(t *testing.T) {
+	limiter := newSendLimiter(2)
+	assert.True(t, limiter.Allow())
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow(), "burst is capped at the rate")
+
+	// A non-positive rate falls back to the Bot API limit
+	limiter = newSendLimiter(0)
+	for i := 0; i < defaultSendRate; i++ {
+		assert.True(t, limiter.Allow())
+	}
+	assert.False(t, limiter.Allow())
+}
+
+func TestProcessor_SendSplitsLongReply(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+
+	cfg := testConfig()
+	cfg.Reply.ChunkNumbering = true
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	text := strings.Repeat("слово ", 1000)
+	err := p.sendResultToUser(context.Background(), &model.Task{ChatID: 42, TelegramMessageID: 7}, text, tele.ModeDefault, nil)
+	assert.NoError(t, err)
+
+	sent := stub.sentMessages()
+	if assert.Len(t, sent, 2) {
+		assert.True(t, strings.HasPrefix(sent[0]["text"], "(1/2) слово"))
+		assert.True(t, strings.HasPrefix(sent[1]["text"], "(2/2) слово"))
+		// Every part replies to the voice message
+		assert.Equal(t, "7", sent[0]["reply_to_message_id"])
+		assert.Equal(t, "7", sent[1]["reply_to_message_id"])
+	}
+}
+
+func TestProcessor_SendRemembersReply(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	task := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	assert.NoError(t, p.sendResultToUser(context.Background(), task, "Привет", tele.ModeDefault, nil))
+
+	assert.Len(t, stub.sentMessages(), 1)
+	assert.Empty(t, stub.editedMessages())
+	// The stub numbers sent messages from 1
+	assert.Equal(t, 1, task.ReplyMessageID())
+}
+
+func TestProcessor_SendEditsEarlierReply(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	task := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	task.SetReplyMessageID(5)
+	assert.NoError(t, p.sendResultToUser(context.Background(), task, "Исправлено", tele.ModeDefault, nil))
+
+	assert.Empty(t, stub.sentMessages())
+	if edited := stub.editedMessages(); assert.Len(t, edited, 1) {
+		assert.Equal(t, "5", edited[0]["message_id"])
+		assert.Equal(t, "42", edited[0]["chat_id"])
+		assert.Equal(t, "Исправлено", edited[0]["text"])
+	}
+	assert.Equal(t, 5, task.ReplyMessageID())
+}
+
+func TestProcessor_SendEditsOnlyFirstChunk(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	task := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	task.SetReplyMessageID(5)
+	text := strings.Repeat("слово ", 1000)
+	assert.NoError(t, p.sendResultToUser(context.Background(), task, text, tele.ModeDefault, nil))
+
+	assert.Len(t, stub.editedMessages(), 1)
+	assert.Len(t, stub.sentMessages(), 1)
+	assert.Equal(t, 5, task.ReplyMessageID())
+}
+
+func TestProcessor_SendFallsBackWhenReplyCannotBeEdited(t *testing.T) {
+	tests := []struct {
+		name      string
+		editError string
+		wantSent  int
+		wantReply int
+	}{
+		{
+			name:      "reply deleted",
+			editError: `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`,
+			wantSent:  1,
+			wantReply: 1,
+		},
+		{
+			name:      "text unchanged",
+			editError: `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified"}`,
+			wantSent:  0,
+			wantReply: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, stub := newTelegramStub(t, nil)
+			stub.editError = tt.editError
+			p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+			task := &model.Task{ChatID: 42, TelegramMessageID: 7}
+			task.SetReplyMessageID(5)
+			assert.NoError(t, p.sendResultToUser(context.Background(), task, "Привет", tele.ModeDefault, nil))
+
+			assert.Len(t, stub.editedMessages(), 1)
+			assert.Len(t, stub.sentMessages(), tt.wantSent)
+			assert.Equal(t, tt.wantReply, task.ReplyMessageID())
+		})
+	}
+}
+
+func TestProcessor_ReplyOptions(t *testing.T) {
+	task := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	task.SetProcessingMessageID(8)
+	withoutProcessing := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	group := &model.Task{ChatID: -42, TelegramMessageID: 7}
+	group.SetChatType("supergroup")
+	private := &model.Task{ChatID: 42, TelegramMessageID: 7}
+	private.SetChatType("private")
+	channel := &model.Task{ChatID: -1001, TelegramMessageID: 7}
+	channel.SetChatType("channel")
+
+	tests := []struct {
+		name    string
+		replyTo string
+		task    *model.Task
+		want    int
+	}{
+		{"default", "", task, 7},
+		{"original", ReplyToOriginal, task, 7},
+		{"processing", ReplyToProcessing, task, 8},
+		{"processing message missing", ReplyToProcessing, withoutProcessing, 7},
+		{"unknown falls back to original", "reply-to-admin", task, 7},
+		{"no reply", NoReply, task, 0},
+		{"group replies to original", "", group, 7},
+		{"private chat is standalone", ReplyToOriginal, private, 0},
+		{"channel is standalone", ReplyToOriginal, channel, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Reply.To = tt.replyTo
+			p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+			opts := p.replyOptions(tt.task)
+			if tt.want == 0 {
+				assert.Nil(t, opts.ReplyTo)
+			} else if assert.NotNil(t, opts.ReplyTo) {
+				assert.Equal(t, tt.want, opts.ReplyTo.ID)
+			}
+		})
+	}
+}
+
+func TestProcessor_SendWithoutReply(t *testing.T) {
+	bot, stub := newTelegramStub(t, nil)
+
+	cfg := testConfig()
+	cfg.Reply.To = NoReply
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), bot, new(MockCache), nil)
+
+	err := p.sendResultToUser(context.Background(), &model.Task{ChatID: 42, TelegramMessageID: 7}, "Привет", tele.ModeDefault, nil)
+	assert.NoError(t, err)
+
+	if sent := stub.sentMessages(); assert.Len(t, sent, 1) {
+		assert.NotContains(t, sent[0], "reply_to_message_id")
+	}
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"time"
+	"voxly/internal/audio"
+	"voxly/internal/config"
+	"voxly/internal/speechkit"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// AudioTrimmer removes silence from downloaded audio before upload and reports
+// how much was cut from the start
+type AudioTrimmer interface {
+	Trim(ctx context.Context, data []byte) ([]byte, time.Duration, error)
+}
+
+// newAudioTrimmer returns the configured silence trimmer, or nil when trimming is
+// off or ffmpeg is missing
+func newAudioTrimmer(cfg *config.Config) AudioTrimmer {
+	if !cfg.Worker.TrimSilence {
+		return nil
+	}
+
+	trimmer, err := audio.NewSilenceTrimmer(cfg.Worker.SilenceThresholdDB, cfg.Worker.SilenceKeep)
+	if err != nil {
+		logger.Warn("Silence trimming disabled", zap.Error(err))
+		return nil
+	}
+	return trimmer
+}
+
+// trimSilence cuts silence at both ends of the audio and reports whether it
+// did, along with the length cut from the start. On failure the audio is
+// recognized as downloaded.
+func (p *Processor) trimSilence(ctx context.Context, log *zap.Logger, data []byte) ([]byte, time.Duration, bool) {
+	if p.trimmer == nil {
+		return data, 0, false
+	}
+
+	trimmed, lead, err := p.trimmer.Trim(ctx, data)
+	if err != nil {
+		log.Warn("Failed to trim silence, using untrimmed audio", zap.Error(err))
+		return data, 0, false
+	}
+
+	log.Info("Silence trimmed",
+		zap.Int("size_before", len(data)),
+		zap.Int("size_after", len(trimmed)),
+		zap.Duration("lead", lead))
+	return trimmed, lead, true
+}
+
+// prepareAudio trims the downloaded audio and describes what is uploaded, with
+// the length cut from the start that recognized timings are shifted by. The
+// recording rate is probed before trimming: ffmpeg re-encodes at 48kHz, which
+// would hide phone-quality audio from the model selection.
+func (p *Processor) prepareAudio(ctx context.Context, log *zap.Logger, data []byte) ([]byte, speechkit.AudioFormat, time.Duration) {
+	format, ok := speechkit.ProbeAudioFormat(data)
+	if !ok {
+		log.Warn("Failed to probe audio format, using defaults")
+	}
+
+	trimmed, lead, ok := p.trimSilence(ctx, log, data)
+	if !ok {
+		return data, format, 0
+	}
+
+	// The upload is encoded as trimmed but was recorded at the original rate
+	recordedAt := format.InputSampleRate
+	if recordedAt <= 0 {
+		recordedAt = format.SampleRate
+	}
+	format, _ = speechkit.ProbeAudioFormat(trimmed)
+	format.InputSampleRate = recordedAt
+	return trimmed, format, lead
+}
+
+// untrimResult moves recognized timings back to where they are in the original
+// recording, before the silence at its start was cut
+func untrimResult(result *speechkit.RecognitionResult, lead time.Duration) *speechkit.RecognitionResult {
+	if lead <= 0 {
+		return result
+	}
+
+	shifted := *result
+	shifted.Chunks = shiftChunks(result.Chunks, lead)
+	return &shifted
+}
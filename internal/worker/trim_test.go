@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/logger"
+	"voxly/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTrimmer returns preset audio or an error
+type fakeTrimmer struct {
+	trimmed []byte
+	lead    time.Duration
+	err     error
+}
+
+func (f *fakeTrimmer) Trim(ctx context.Context, data []byte) ([]byte, time.Duration, error) {
+	return f.trimmed, f.lead, f.err
+}
+
+func TestProcessor_TrimSilence(t *testing.T) {
+	log := logger.WithTask("task-1")
+	p := NewProcessor(testConfig(), new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	// Trimming is off by default
+	assert.Nil(t, p.trimmer)
+	data, lead, trimmed := p.trimSilence(context.Background(), log, []byte("audio"))
+	assert.Equal(t, []byte("audio"), data)
+	assert.Zero(t, lead)
+	assert.False(t, trimmed)
+
+	p.trimmer = &fakeTrimmer{trimmed: []byte("trimmed"), lead: 2 * time.Second}
+	data, lead, trimmed = p.trimSilence(context.Background(), log, []byte("audio"))
+	assert.Equal(t, []byte("trimmed"), data)
+	assert.Equal(t, 2*time.Second, lead)
+	assert.True(t, trimmed)
+
+	// A failed trim keeps the downloaded audio
+	p.trimmer = &fakeTrimmer{err: errors.New("ffmpeg exited")}
+	data, lead, trimmed = p.trimSilence(context.Background(), log, []byte("audio"))
+	assert.Equal(t, []byte("audio"), data)
+	assert.Zero(t, lead)
+	assert.False(t, trimmed)
+}
+
+// oggOpusAudio builds the first Ogg page of an Opus stream recorded at inputRate
+func oggOpusAudio(inputRate uint32) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 1, 0x38, 0x01)
+	head = binary.LittleEndian.AppendUint32(head, inputRate)
+	head = append(head, 0, 0, 0)
+
+	page := []byte("OggS")
+	page = append(page, make([]byte, 22)...)
+	page = append(page, 1, byte(len(head)))
+	return append(page, head...)
+}
+
+func TestProcessor_PrepareAudioKeepsRecordingRate(t *testing.T) {
+	log := logger.WithTask("task-1")
+	cfg := testConfig()
+	cfg.SpeechKit.Model = "general:rc"
+	cfg.SpeechKit.NarrowbandModel = "general"
+	p := NewProcessor(cfg, new(MockDB), new(MockS3), new(MockSpeechKit), nil, new(MockCache), nil)
+
+	// ffmpeg re-encodes a phone recording at 48kHz
+	phone := oggOpusAudio(8000)
+	trimmed := append(oggOpusAudio(48000), "trimmed"...)
+	p.trimmer = &fakeTrimmer{trimmed: trimmed}
+
+	data, format, _ := p.prepareAudio(context.Background(), log, phone)
+	assert.Equal(t, trimmed, data)
+	assert.Equal(t, speechkit.EncodingOggOpus, format.Encoding)
+	assert.Equal(t, 48000, format.SampleRate)
+	assert.True(t, format.Narrowband())
+
+	task := &model.Task{ID: "task-1", Meta: model.JSONB{}}
+	assert.Equal(t, speechkit.ModelGeneral, p.recognitionModel(task, 10, format))
+
+	// Without trimming the probe is the downloaded file's
+	p.trimmer = nil
+	data, format, _ = p.prepareAudio(context.Background(), log, phone)
+	assert.Equal(t, phone, data)
+	assert.Equal(t, 8000, format.InputSampleRate)
+}
+
+func TestUntrimResult(t *testing.T) {
+	result := &speechkit.RecognitionResult{Chunks: []speechkit.Chunk{{
+		StartTimeMs:  100,
+		EndTimeMs:    900,
+		Alternatives: []speechkit.Alternative{{Text: "Привет", Words: []speechkit.Word{{Word: "Привет", StartTimeMs: 100, EndTimeMs: 900}}}},
+	}}}
+
+	assert.Same(t, result, untrimResult(result, 0))
+
+	untrimmed := untrimResult(result, 1500*time.Millisecond)
+	assert.Equal(t, int64(1600), untrimmed.Chunks[0].StartTimeMs)
+	assert.Equal(t, int64(2400), untrimmed.Chunks[0].EndTimeMs)
+	assert.Equal(t, int64(1600), untrimmed.Chunks[0].Alternatives[0].Words[0].StartTimeMs)
+	// The recognized result is kept as is for the result cache
+	assert.Equal(t, int64(100), result.Chunks[0].StartTimeMs)
+	assert.Equal(t, int64(100), result.Chunks[0].Alternatives[0].Words[0].StartTimeMs)
+}
+
+func TestNewAudioTrimmerWithoutFFmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	cfg := testConfig()
+	cfg.Worker.TrimSilence = true
+	assert.Nil(t, newAudioTrimmer(cfg))
+}
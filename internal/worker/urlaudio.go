@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"voxly/internal/queue"
+	"voxly/pkg/httpclient"
+)
+
+// newAudioURLFetcher downloads audio sent as a link from public hosts only
+func newAudioURLFetcher() *httpclient.SafeFetcher {
+	return httpclient.NewSafeFetcher(httpclient.SafeOptions{MaxSize: queue.MaxFileSize})
+}
+
+// downloadAudio fetches the task's audio from Telegram or from the link it was sent as
+func (p *Processor) downloadAudio(ctx context.Context, voiceTask queue.VoiceTask) ([]byte, error) {
+	if voiceTask.SourceURL == "" {
+		return p.downloadTelegramFile(ctx, voiceTask.FileID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.downloadTimeout())
+	defer cancel()
+
+	data, err := p.urlFetcher.Fetch(ctx, voiceTask.SourceURL)
+	if errors.Is(err, httpclient.ErrRejected) {
+		return nil, fmt.Errorf("%w: %w", errAudioURLRejected, err)
+	}
+	return data, err
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"voxly/internal/i18n"
+	"voxly/internal/queue"
+	"voxly/pkg/httpclient"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAudioServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("ogg-data"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessor_DownloadAudioFromLink(t *testing.T) {
+	server := newAudioServer(t)
+
+	// The test server listens on loopback, which links normally may not reach
+	fetcher := httpclient.NewSafeFetcher(httpclient.SafeOptions{AllowAddr: func(netip.Addr) bool { return true }})
+	p := &Processor{cfg: testConfig(), urlFetcher: fetcher}
+
+	// No bot is set: a Telegram download would panic
+	data, err := p.downloadAudio(context.Background(), queue.VoiceTask{SourceURL: server.URL + "/voice.ogg"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ogg-data"), data)
+}
+
+func TestProcessor_DownloadAudioFromInternalLink(t *testing.T) {
+	server := newAudioServer(t)
+	p := &Processor{cfg: testConfig(), urlFetcher: newAudioURLFetcher()}
+
+	_, err := p.downloadAudio(context.Background(), queue.VoiceTask{SourceURL: server.URL + "/voice.ogg"})
+
+	assert.ErrorIs(t, err, errAudioURLRejected)
+	assert.ErrorIs(t, err, httpclient.ErrBlockedAddress)
+	assert.Equal(t, i18n.AudioURLRejected, classifyFailure(err).message)
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voxly/internal/config"
+	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
+
+	"go.uber.org/zap"
+)
+
+// warmupMaxInterval caps the pause between warm-up attempts
+const warmupMaxInterval = 30 * time.Second
+
+// warmupProbeKey is looked up to check S3 access; it doesn't have to exist
+const warmupProbeKey = "warmup/probe"
+
+// CredentialsChecker verifies credentials with a cheap API call
+type CredentialsChecker interface {
+	CheckCredentials(ctx context.Context) error
+}
+
+// ObjectChecker looks objects up in storage without downloading them
+type ObjectChecker interface {
+	ObjectExists(ctx context.Context, key string) (bool, error)
+}
+
+// newWarmupRetry reads the warm-up settings; nil means no warm-up
+func newWarmupRetry(cfg *config.Config) *resilience.RetryConfig {
+	if cfg.Worker.WarmupAttempts <= 0 {
+		return nil
+	}
+	return &resilience.RetryConfig{
+		MaxAttempts:     cfg.Worker.WarmupAttempts,
+		InitialInterval: cfg.Worker.WarmupInterval,
+		MaxInterval:     warmupMaxInterval,
+		Multiplier:      2.0,
+	}
+}
+
+// Warmup checks that SpeechKit accepts the credentials and the S3 bucket is
+// reachable, retrying with backoff. The worker should start consuming only
+// after it succeeds, so tasks aren't taken just to fail.
+func (p *Processor) Warmup(ctx context.Context) error {
+	if p.warmupRetry == nil {
+		return nil
+	}
+
+	attempt := 0
+	err := resilience.RetryWithExponentialBackoff(ctx, p.warmupRetry, func() error {
+		attempt++
+		if err := p.checkDependencies(ctx); err != nil {
+			logger.Warn("Worker warm-up check failed",
+				zap.Int("attempt", attempt),
+				zap.Int("max_attempts", p.warmupRetry.MaxAttempts),
+				zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("worker warm-up failed: %w", err)
+	}
+
+	logger.Info("Worker warm-up passed", zap.Int("attempts", attempt))
+	return nil
+}
+
+// checkDependencies runs the checks the configured clients support
+func (p *Processor) checkDependencies(ctx context.Context) error {
+	if checker, ok := p.speechkit.(CredentialsChecker); ok {
+		if err := checker.CheckCredentials(ctx); err != nil {
+			return fmt.Errorf("failed to check SpeechKit credentials: %w", err)
+		}
+	}
+
+	if checker, ok := p.s3.(ObjectChecker); ok {
+		ctx, cancel := p.s3Context(ctx)
+		defer cancel()
+		if _, err := checker.ObjectExists(ctx, warmupProbeKey); err != nil {
+			return fmt.Errorf("failed to check S3 access: %w", err)
+		}
+	}
+
+	return nil
+}
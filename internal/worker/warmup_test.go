@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"voxly/internal/speechkit"
+	"voxly/pkg/cache"
+	"voxly/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func warmupProcessor(mockS3 *MockS3, mockSK *MockSpeechKit, attempts int) *Processor {
+	p := NewProcessor(testConfig(), new(MockDB), mockS3, mockSK, nil, cache.NewNoopCache(), nil)
+	p.warmupRetry = &resilience.RetryConfig{
+		MaxAttempts:     attempts,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		Multiplier:      2.0,
+	}
+	return p
+}
+
+func TestProcessor_WarmupPasses(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockSK.On("CheckCredentials").Return(nil).Once()
+	mockS3.On("ObjectExists", mock.Anything, warmupProbeKey).Return(false, nil).Once()
+
+	err := warmupProcessor(mockS3, mockSK, 3).Warmup(context.Background())
+
+	assert.NoError(t, err)
+	mockSK.AssertExpectations(t)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessor_WarmupRetriesUntilDependenciesAreUp(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockSK.On("CheckCredentials").Return(nil)
+	mockS3.On("ObjectExists", mock.Anything, warmupProbeKey).Return(false, errors.New("connection refused")).Once()
+	mockS3.On("ObjectExists", mock.Anything, warmupProbeKey).Return(false, nil).Once()
+
+	err := warmupProcessor(mockS3, mockSK, 3).Warmup(context.Background())
+
+	assert.NoError(t, err)
+	mockSK.AssertNumberOfCalls(t, "CheckCredentials", 2)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessor_WarmupFailsAfterAttempts(t *testing.T) {
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+	mockSK.On("CheckCredentials").Return(speechkit.ErrUnauthorized)
+
+	err := warmupProcessor(mockS3, mockSK, 3).Warmup(context.Background())
+
+	assert.ErrorIs(t, err, speechkit.ErrUnauthorized)
+	mockSK.AssertNumberOfCalls(t, "CheckCredentials", 3)
+	// S3 isn't checked while the credentials are rejected
+	mockS3.AssertNotCalled(t, "ObjectExists", mock.Anything, mock.Anything)
+}
+
+func TestProcessor_WarmupDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.Worker.WarmupAttempts = 0
+	mockS3 := new(MockS3)
+	mockSK := new(MockSpeechKit)
+
+	p := NewProcessor(cfg, new(MockDB), mockS3, mockSK, nil, cache.NewNoopCache(), nil)
+
+	assert.NoError(t, p.Warmup(context.Background()))
+	mockSK.AssertNotCalled(t, "CheckCredentials")
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_log_user_created;
+DROP INDEX IF EXISTS idx_audit_log_chat_created;
+DROP TABLE IF EXISTS audit_log;
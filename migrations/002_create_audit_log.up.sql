@@ -0,0 +1,13 @@
+-- Table audit_log: user commands and received voice messages, for moderation and debugging
+CREATE TABLE IF NOT EXISTS audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  chat_id BIGINT NOT NULL,
+  action VARCHAR(64) NOT NULL,  -- command (/start, /stop, ...) or voice
+  payload TEXT,                 -- command arguments (if any)
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Indexes for looking up activity by chat and by user
+CREATE INDEX IF NOT EXISTS idx_audit_log_chat_created ON audit_log (chat_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_created ON audit_log (user_id, created_at);
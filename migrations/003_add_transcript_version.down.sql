@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transcripts_task_version;
+CREATE INDEX IF NOT EXISTS idx_transcripts_task_id ON transcripts (task_id);
+ALTER TABLE transcripts DROP COLUMN IF EXISTS version;
@@ -0,0 +1,15 @@
+-- Reprocessing a task adds a new transcript version instead of a duplicate row
+ALTER TABLE transcripts ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+-- Number transcripts that were already duplicated, oldest first
+UPDATE transcripts t
+SET version = v.version
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY task_id ORDER BY created_at, id) AS version
+  FROM transcripts
+) v
+WHERE t.id = v.id;
+
+-- One row per task version; also serves lookups of the latest version
+DROP INDEX IF EXISTS idx_transcripts_task_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transcripts_task_version ON transcripts (task_id, version);
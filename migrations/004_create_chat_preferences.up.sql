@@ -0,0 +1,6 @@
+-- Table chat_preferences: per-chat settings; unset keys fall back to configuration
+CREATE TABLE IF NOT EXISTS chat_preferences (
+  chat_id BIGINT PRIMARY KEY,
+  preferences JSONB NOT NULL DEFAULT '{}'::jsonb,  -- active, threshold, language, profanity_filter, parse_mode
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_queued_created_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,5 @@
+-- Workers polling the database record which of them claimed a task
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS claimed_by TEXT;
+
+-- Serves claiming the oldest queued task
+CREATE INDEX IF NOT EXISTS idx_tasks_queued_created_at ON tasks (created_at) WHERE status = 'queued';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_created_at;
+DROP INDEX IF EXISTS idx_transcripts_created_at;
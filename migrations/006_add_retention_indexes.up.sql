@@ -0,0 +1,3 @@
+-- Serve deleting transcripts and tasks past the retention period
+CREATE INDEX IF NOT EXISTS idx_transcripts_created_at ON transcripts (created_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at);
@@ -0,0 +1,2 @@
+-- Serves finding a task by the message its transcript was sent in
+CREATE INDEX IF NOT EXISTS idx_tasks_reply_message_id ON tasks (chat_id, (meta->>'reply_message_id'));
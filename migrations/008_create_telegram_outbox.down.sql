@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_telegram_outbox_task;
+DROP INDEX IF EXISTS idx_telegram_outbox_created;
+DROP TABLE IF EXISTS telegram_outbox;
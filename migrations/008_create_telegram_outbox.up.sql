@@ -0,0 +1,19 @@
+-- Table telegram_outbox: worker replies waiting for Telegram to become reachable again
+CREATE TABLE IF NOT EXISTS telegram_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,  -- pending replies go with their task
+  task_reply BOOLEAN NOT NULL DEFAULT FALSE,  -- first message of a transcript, stored as the task's reply
+  chat_id BIGINT NOT NULL,
+  thread_id INTEGER NOT NULL DEFAULT 0,
+  reply_to INTEGER NOT NULL DEFAULT 0,  -- message to reply to, 0 for a standalone message
+  parse_mode VARCHAR(16) NOT NULL DEFAULT '',
+  text TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  leased_until TIMESTAMPTZ  -- set while a worker is sending the reply
+);
+
+-- Replies are delivered oldest first
+CREATE INDEX IF NOT EXISTS idx_telegram_outbox_created ON telegram_outbox (created_at, id);
+
+-- Deleting a task drops its pending replies
+CREATE INDEX IF NOT EXISTS idx_telegram_outbox_task ON telegram_outbox (task_id);
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations into the binaries
+package migrations
+
+import "embed"
+
+// FS holds the *.sql migration files
+//
+//go:embed *.sql
+var FS embed.FS
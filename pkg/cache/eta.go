@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ProcessingRateCacheKey holds the moving average of processing time per
+// second of audio. Workers update it as tasks complete; the bot estimates
+// from it how long a new task will take.
+const ProcessingRateCacheKey = "stats:processing_rate"
+
+const (
+	// processingRateWeight is the share of the newest task in the moving average
+	processingRateWeight = 0.2
+	// processingRateTTL drops a rate no task has refreshed for a long time
+	processingRateTTL = 30 * 24 * time.Hour
+)
+
+// ProcessingRate returns the average seconds of processing per second of
+// audio; false means no task has completed yet or the cache failed
+func ProcessingRate(ctx context.Context, c Cache) (float64, bool) {
+	var rate float64
+	if err := c.Get(ctx, ProcessingRateCacheKey, &rate); err != nil || rate <= 0 {
+		return 0, false
+	}
+	return rate, true
+}
+
+// UpdateProcessingRate adds a task that took took to process audio of the
+// given length to the moving average. Only tasks recognized in full belong in
+// it: a result reused from cache or cut short by a timeout took less than the
+// audio needs. Workers completing tasks at the same
+// moment may overwrite each other's update; losing a sample barely moves the
+// average, so no lock is taken.
+func UpdateProcessingRate(ctx context.Context, c Cache, audio, took time.Duration) error {
+	// Tasks of unknown length say nothing about the rate
+	if audio <= 0 || took <= 0 {
+		return nil
+	}
+
+	sample := took.Seconds() / audio.Seconds()
+	rate, ok := ProcessingRate(ctx, c)
+	if ok {
+		sample = movingAverage(rate, sample)
+	}
+	return c.SetWithTTL(ctx, ProcessingRateCacheKey, sample, processingRateTTL)
+}
+
+// movingAverage moves avg towards sample by processingRateWeight
+func movingAverage(avg, sample float64) float64 {
+	return avg + processingRateWeight*(sample-avg)
+}
+
+// EstimateProcessing estimates how long audio of the given length will take
+// to process; false means there is no rate to estimate from yet
+func EstimateProcessing(ctx context.Context, c Cache, audio time.Duration) (time.Duration, bool) {
+	rate, ok := ProcessingRate(ctx, c)
+	if !ok || audio <= 0 {
+		return 0, false
+	}
+	return time.Duration(rate * float64(audio)), true
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovingAverage(t *testing.T) {
+	assert.InDelta(t, 1.2, movingAverage(1, 2), 1e-9)
+	assert.InDelta(t, 0.8, movingAverage(1, 0), 1e-9)
+	assert.InDelta(t, 1.0, movingAverage(1, 1), 1e-9)
+}
+
+func TestUpdateProcessingRate(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(time.Hour)
+
+	_, ok := ProcessingRate(ctx, c)
+	assert.False(t, ok)
+
+	// The first task sets the rate
+	assert.NoError(t, UpdateProcessingRate(ctx, c, 60*time.Second, 30*time.Second))
+	rate, ok := ProcessingRate(ctx, c)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.5, rate, 1e-9)
+
+	// Later ones move it
+	assert.NoError(t, UpdateProcessingRate(ctx, c, 10*time.Second, 15*time.Second))
+	rate, _ = ProcessingRate(ctx, c)
+	assert.InDelta(t, 0.5+0.2*(1.5-0.5), rate, 1e-9)
+
+	// Tasks of unknown length are ignored
+	assert.NoError(t, UpdateProcessingRate(ctx, c, 0, time.Minute))
+	rate, _ = ProcessingRate(ctx, c)
+	assert.InDelta(t, 0.7, rate, 1e-9)
+}
+
+func TestEstimateProcessing(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(time.Hour)
+
+	_, ok := EstimateProcessing(ctx, c, time.Minute)
+	assert.False(t, ok)
+
+	assert.NoError(t, UpdateProcessingRate(ctx, c, 10*time.Second, 5*time.Second))
+	eta, ok := EstimateProcessing(ctx, c, 10*time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, eta)
+
+	_, ok = EstimateProcessing(ctx, c, 0)
+	assert.False(t, ok)
+
+	// Nothing is stored without a cache
+	assert.NoError(t, UpdateProcessingRate(ctx, NewNoopCache(), time.Minute, time.Minute))
+	_, ok = EstimateProcessing(ctx, NewNoopCache(), time.Minute)
+	assert.False(t, ok)
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -14,3 +15,43 @@ type Cache interface {
 	Exists(ctx context.Context, key string) (bool, error)
 	Close() error
 }
+
+// Counter is implemented by caches that support atomic integer counters, such
+// as in-flight tracking per chat or user
+type Counter interface {
+	Increment(ctx context.Context, key string) (int64, error)
+	// DecrementNonNegative decrements the counter but never below zero, so a
+	// release without a matching acquire (e.g. after the key expired) can't
+	// leave it negative
+	DecrementNonNegative(ctx context.Context, key string) (int64, error)
+}
+
+// Claimer is implemented by caches that can set a key only if it is missing,
+// in one atomic step, so concurrent callers can't both take the same key
+type Claimer interface {
+	// SetIfAbsent stores value under key for ttl unless the key exists and
+	// reports whether it did
+	SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Cache drivers selectable via configuration
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverNoop   = "noop"
+)
+
+// New creates a cache for the given driver. Redis settings are only used by the redis driver.
+func New(driver string, opts RedisOptions, ttl time.Duration, optional bool) (Cache, error) {
+	switch driver {
+	case "", DriverRedis:
+		return NewCacheWithFallback(opts, ttl, optional)
+	case DriverMemory:
+		return NewMemoryCache(ttl), nil
+	case DriverNoop:
+		return NewNoopCache(), nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver: %s", driver)
+	}
+}
@@ -0,0 +1,25 @@
+package cache
+
+import "context"
+
+// MaintenanceCacheKey holds the global maintenance flag shared by bot and worker
+const MaintenanceCacheKey = "maintenance:enabled"
+
+// MaintenanceEnabled reports whether maintenance mode is on. Cache errors count
+// as off, so an unavailable cache never pauses the service.
+func MaintenanceEnabled(ctx context.Context, c Cache) bool {
+	var value string
+	if err := c.Get(ctx, MaintenanceCacheKey, &value); err != nil {
+		return false
+	}
+	return value == "true"
+}
+
+// SetMaintenance turns maintenance mode on or off. The flag expires with the
+// cache's default TTL, so a forgotten flag doesn't pause the service forever.
+func SetMaintenance(ctx context.Context, c Cache, enabled bool) error {
+	if !enabled {
+		return c.Delete(ctx, MaintenanceCacheKey)
+	}
+	return c.Set(ctx, MaintenanceCacheKey, "true")
+}
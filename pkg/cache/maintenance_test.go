@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceFlag(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(time.Hour)
+
+	assert.False(t, MaintenanceEnabled(ctx, c))
+
+	assert.NoError(t, SetMaintenance(ctx, c, true))
+	assert.True(t, MaintenanceEnabled(ctx, c))
+
+	assert.NoError(t, SetMaintenance(ctx, c, false))
+	assert.False(t, MaintenanceEnabled(ctx, c))
+
+	// A cache that stores nothing never reports maintenance
+	assert.NoError(t, SetMaintenance(ctx, NewNoopCache(), true))
+	assert.False(t, MaintenanceEnabled(ctx, NewNoopCache()))
+}
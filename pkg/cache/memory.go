@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryCache is a process-local Cache used when Redis is unavailable.
+// Values are stored JSON-encoded so Get behaves the same as with Redis.
+type MemoryCache struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+	ttl   time.Duration
+}
+
+type memoryItem struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		items: make(map[string]memoryItem),
+		ttl:   ttl,
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string, dest interface{}) error {
+	m.mu.RLock()
+	item, ok := m.items[key]
+	m.mu.RUnlock()
+
+	if !ok || item.expired(time.Now()) {
+		return fmt.Errorf("key not found: %s", key)
+	}
+
+	if err := json.Unmarshal(item.data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key string, value interface{}) error {
+	return m.SetWithTTL(ctx, key, value, m.ttl)
+}
+
+func (m *MemoryCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	item := memoryItem{data: data}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	m.items[key] = item
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MemoryCache) SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	item := memoryItem{data: data}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.items[key]; ok && !existing.expired(time.Now()) {
+		return false, nil
+	}
+	m.items[key] = item
+	return true, nil
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.items, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.RLock()
+	item, ok := m.items[key]
+	m.mu.RUnlock()
+
+	return ok && !item.expired(time.Now()), nil
+}
+
+func (m *MemoryCache) Increment(ctx context.Context, key string) (int64, error) {
+	return m.add(key, 1)
+}
+
+func (m *MemoryCache) DecrementNonNegative(ctx context.Context, key string) (int64, error) {
+	return m.add(key, -1)
+}
+
+// add changes a counter by delta, flooring it at zero. Like Redis INCR, a new
+// counter has no expiry and an existing one keeps its own.
+func (m *MemoryCache) add(key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current int64
+	item, ok := m.items[key]
+	if ok && !item.expired(time.Now()) {
+		if err := json.Unmarshal(item.data, &current); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal counter: %w", err)
+		}
+	} else {
+		if delta < 0 {
+			return 0, nil
+		}
+		item = memoryItem{}
+	}
+
+	current = max(current+delta, 0)
+	data, err := json.Marshal(current)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal: %w", err)
+	}
+	item.data = data
+	m.items[key] = item
+
+	return current, nil
+}
+
+func (m *MemoryCache) Close() error {
+	return nil
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_SetAndGet(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	type TestData struct {
+		ID   string
+		Name string
+	}
+
+	assert.NoError(t, c.Set(ctx, "test:key", TestData{ID: "123", Name: "test"}))
+
+	var retrieved TestData
+	assert.NoError(t, c.Get(ctx, "test:key", &retrieved))
+	assert.Equal(t, TestData{ID: "123", Name: "test"}, retrieved)
+
+	exists, err := c.Exists(ctx, "test:key")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestMemoryCache_Expiry(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	assert.NoError(t, c.SetWithTTL(ctx, "short", "value", 20*time.Millisecond))
+	time.Sleep(40 * time.Millisecond)
+
+	var value string
+	assert.Error(t, c.Get(ctx, "short", &value))
+
+	exists, err := c.Exists(ctx, "short")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMemoryCache_Delete(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Set(ctx, "test:key", "value"))
+	assert.NoError(t, c.Delete(ctx, "test:key"))
+
+	var value string
+	assert.Error(t, c.Get(ctx, "test:key", &value))
+}
+
+func TestMemoryCache_SetIfAbsent(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	set, err := c.SetIfAbsent(ctx, "claim", true, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	set, err = c.SetIfAbsent(ctx, "claim", true, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	// An expired key is free again
+	time.Sleep(20 * time.Millisecond)
+	set, err = c.SetIfAbsent(ctx, "claim", true, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, set)
+}
+
+func TestMemoryCache_DecrementNonNegative(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	val, err := c.Increment(ctx, "inflight")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+
+	val, err = c.DecrementNonNegative(ctx, "inflight")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	// An unmatched release stays at zero
+	val, err = c.DecrementNonNegative(ctx, "inflight")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	val, err = c.Increment(ctx, "inflight")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+}
+
+func TestMemoryCache_DecrementNonNegative_MissingKey(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	ctx := context.Background()
+
+	val, err := c.DecrementNonNegative(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	exists, err := c.Exists(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NoopCache is a Cache that stores nothing, for deployments without Redis.
+// Get always misses, writes succeed and Exists is always false.
+type NoopCache struct{}
+
+func NewNoopCache() *NoopCache {
+	return &NoopCache{}
+}
+
+func (n *NoopCache) Get(ctx context.Context, key string, dest interface{}) error {
+	return fmt.Errorf("key not found: %s", key)
+}
+
+func (n *NoopCache) Set(ctx context.Context, key string, value interface{}) error {
+	return nil
+}
+
+func (n *NoopCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return nil
+}
+
+// SetIfAbsent always succeeds, since nothing is ever stored
+func (n *NoopCache) SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (n *NoopCache) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (n *NoopCache) Exists(ctx context.Context, key string) (bool, error) {
+	return false, nil
+}
+
+func (n *NoopCache) Close() error {
+	return nil
+}
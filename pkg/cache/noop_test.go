@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Cache = (*NoopCache)(nil)
+	_ Cache = (*MemoryCache)(nil)
+	_ Cache = (*RedisCache)(nil)
+)
+
+func TestNoopCache(t *testing.T) {
+	c := NewNoopCache()
+	ctx := context.Background()
+
+	assert.NoError(t, c.Set(ctx, "key", "value"))
+	assert.NoError(t, c.SetWithTTL(ctx, "key", "value", time.Hour))
+
+	var value string
+	assert.Error(t, c.Get(ctx, "key", &value))
+	assert.Empty(t, value)
+
+	exists, err := c.Exists(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, c.Delete(ctx, "key"))
+	assert.NoError(t, c.Close())
+}
+
+func TestNew_SelectsDriver(t *testing.T) {
+	c, err := New(DriverNoop, RedisOptions{}, time.Hour, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &NoopCache{}, c)
+
+	c, err = New(DriverMemory, RedisOptions{}, time.Hour, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &MemoryCache{}, c)
+
+	_, err = New("memcached", RedisOptions{}, time.Hour, false)
+	assert.Error(t, err)
+}
@@ -5,26 +5,143 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+	"voxly/pkg/logger"
+	"voxly/pkg/resilience"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	ttl    time.Duration
 }
 
-func NewRedisCache(addr, password string, db int, ttl time.Duration) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
+// Connection retry defaults used when RedisOptions leaves them unset
+const (
+	DefaultConnectAttempts = 5
+	DefaultConnectInterval = time.Second
+
+	connectMaxInterval = 10 * time.Second
+	pingTimeout        = 5 * time.Second
+)
+
+// RedisOptions configures the Redis connection. ClusterAddrs selects
+// cluster mode, MasterName selects Sentinel; otherwise Addr is a single node.
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int // ignored in cluster mode
+
+	MasterName    string
+	SentinelAddrs []string
+	ClusterAddrs  []string
+
+	// ConnectAttempts bounds the startup pings; ConnectInterval is the first
+	// delay between them and doubles after each failure
+	ConnectAttempts int
+	ConnectInterval time.Duration
+}
+
+// Redis deployment modes
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// Mode reports which kind of deployment the options describe
+func (o RedisOptions) Mode() string {
+	switch {
+	case len(o.ClusterAddrs) > 0:
+		return RedisModeCluster
+	case o.MasterName != "":
+		return RedisModeSentinel
+	default:
+		return RedisModeSingle
+	}
+}
+
+// newRedisClient builds the client matching the configured mode
+func newRedisClient(opts RedisOptions) (redis.UniversalClient, error) {
+	switch opts.Mode() {
+	case RedisModeCluster:
+		if opts.MasterName != "" {
+			return nil, fmt.Errorf("redis master name and cluster addrs are mutually exclusive")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    opts.ClusterAddrs,
+			Password: opts.Password,
+		}), nil
+	case RedisModeSentinel:
+		if len(opts.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel addrs are required with master name %q", opts.MasterName)
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    opts.MasterName,
+			SentinelAddrs: opts.SentinelAddrs,
+			Password:      opts.Password,
+			DB:            opts.DB,
+		}), nil
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:     opts.Addr,
+			Password: opts.Password,
+			DB:       opts.DB,
+		}), nil
+	}
+}
+
+func (o RedisOptions) retryConfig() *resilience.RetryConfig {
+	cfg := &resilience.RetryConfig{
+		MaxAttempts:     o.ConnectAttempts,
+		InitialInterval: o.ConnectInterval,
+		MaxInterval:     connectMaxInterval,
+		Multiplier:      2.0,
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultConnectAttempts
+	}
+	if cfg.InitialInterval <= 0 {
+		cfg.InitialInterval = DefaultConnectInterval
+	}
+	return cfg
+}
+
+// pinger is the part of the Redis client used to check connectivity
+type pinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// pingWithRetry pings Redis until it answers or the attempts run out,
+// so a Redis that starts slightly later than the service doesn't crash it
+func pingWithRetry(ctx context.Context, client pinger, cfg *resilience.RetryConfig) error {
+	attempt := 0
+	return resilience.RetryWithExponentialBackoff(ctx, cfg, func() error {
+		attempt++
+
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+
+		err := client.Ping(pingCtx).Err()
+		if err != nil {
+			logger.Warn("Redis ping failed",
+				zap.Int("attempt", attempt),
+				zap.Int("max_attempts", cfg.MaxAttempts),
+				zap.Error(err))
+		}
+		return err
 	})
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func NewRedisCache(opts RedisOptions, ttl time.Duration) (*RedisCache, error) {
+	client, err := newRedisClient(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := pingWithRetry(context.Background(), client, opts.retryConfig()); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
@@ -34,6 +151,26 @@ func NewRedisCache(addr, password string, db int, ttl time.Duration) (*RedisCach
 	}, nil
 }
 
+// NewCacheWithFallback connects to Redis. When optional is set and Redis is
+// unreachable, it logs a warning and falls back to an in-memory cache.
+func NewCacheWithFallback(opts RedisOptions, ttl time.Duration, optional bool) (Cache, error) {
+	redisCache, err := NewRedisCache(opts, ttl)
+	if err == nil {
+		return redisCache, nil
+	}
+
+	if !optional {
+		return nil, err
+	}
+
+	logger.Warn("Redis unavailable, falling back to in-memory cache",
+		zap.String("mode", opts.Mode()),
+		zap.String("addr", opts.Addr),
+		zap.Error(err))
+
+	return NewMemoryCache(ttl), nil
+}
+
 func (r *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -76,6 +213,20 @@ func (r *RedisCache) SetWithTTL(ctx context.Context, key string, value interface
 	return nil
 }
 
+func (r *RedisCache) SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	set, err := r.client.SetNX(ctx, key, data, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set key: %w", err)
+	}
+
+	return set, nil
+}
+
 func (r *RedisCache) Delete(ctx context.Context, key string) error {
 	if err := r.client.Del(ctx, key).Err(); err != nil {
 		return fmt.Errorf("failed to delete key: %w", err)
@@ -133,6 +284,30 @@ func (r *RedisCache) Decrement(ctx context.Context, key string) (int64, error) {
 	return val, nil
 }
 
+// decrementNonNegative decrements KEYS[1] unless it is already zero or below,
+// in which case it is reset to zero. Missing keys are left missing and
+// non-integer values fail the same way DECR does.
+var decrementNonNegative = redis.NewScript(`
+local raw = redis.call('GET', KEYS[1])
+if not raw then
+	return 0
+end
+local current = tonumber(raw)
+if current ~= nil and current <= 0 then
+	redis.call('SET', KEYS[1], 0, 'KEEPTTL')
+	return 0
+end
+return redis.call('DECR', KEYS[1])
+`)
+
+func (r *RedisCache) DecrementNonNegative(ctx context.Context, key string) (int64, error) {
+	val, err := decrementNonNegative.Run(ctx, r.client, []string{key}).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrement: %w", err)
+	}
+	return val, nil
+}
+
 type CacheKey struct {
 	Prefix string
 	ID     string
@@ -146,10 +321,55 @@ func TaskCacheKey(taskID string) string {
 	return CacheKey{Prefix: "task", ID: taskID}.String()
 }
 
+// TranscriptTTL is how long finished tasks and transcripts stay cached
+const TranscriptTTL = 7 * 24 * time.Hour
+
 func TranscriptCacheKey(taskID string) string {
 	return CacheKey{Prefix: "transcript", ID: taskID}.String()
 }
 
+// HandledMessageCacheKey marks a queue message as handled, keyed by its AMQP message ID
+func HandledMessageCacheKey(messageID string) string {
+	return CacheKey{Prefix: "queue:handled", ID: messageID}.String()
+}
+
+// ChatActiveCacheKey is the legacy activation key, only read to import it into chat preferences
 func ChatActiveCacheKey(chatID int64) string {
 	return fmt.Sprintf("chat:active:%d", chatID)
 }
+
+// ChatThresholdCacheKey is the legacy threshold key, only read to import it into chat preferences
+func ChatThresholdCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat:threshold:%d", chatID)
+}
+
+// InactiveHintCacheKey marks a chat that was recently told how to turn recognition on
+func InactiveHintCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat:inactive_hint:%d", chatID)
+}
+
+// ChatPreferencesTTL bounds how long cached chat preferences can lag the database
+const ChatPreferencesTTL = time.Hour
+
+func ChatPreferencesCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat:preferences:%d", chatID)
+}
+
+// ChatLanguageTTL is how long detected languages are remembered after the last detection
+const ChatLanguageTTL = 24 * time.Hour
+
+func ChatLanguageCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat:language:%d", chatID)
+}
+
+// ChatLanguageSkipsCacheKey counts tasks requested in a chat's remembered
+// language since its last detection
+func ChatLanguageSkipsCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat:language_skips:%d", chatID)
+}
+
+// RecognitionResultCacheKey holds the full recognition result of audio with the
+// given content hash; variant covers the request options that change the result
+func RecognitionResultCacheKey(contentHash, variant string) string {
+	return CacheKey{Prefix: "result", ID: contentHash + ":" + variant}.String()
+}
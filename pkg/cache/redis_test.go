@@ -2,9 +2,12 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -134,3 +137,150 @@ func TestChatActiveCacheKey(t *testing.T) {
 	key := ChatActiveCacheKey(123456)
 	assert.Equal(t, "chat:active:123456", key)
 }
+
+func TestChatPreferencesCacheKey(t *testing.T) {
+	key := ChatPreferencesCacheKey(-100123)
+	assert.Equal(t, "chat:preferences:-100123", key)
+}
+
+func TestNewCacheWithFallback_UnavailableRedis(t *testing.T) {
+	// Nothing listens on port 1, so the ping fails immediately
+	opts := RedisOptions{Addr: "127.0.0.1:1", ConnectAttempts: 1}
+	c, err := NewCacheWithFallback(opts, time.Hour, true)
+	assert.NoError(t, err)
+	assert.IsType(t, &MemoryCache{}, c)
+
+	c, err = NewCacheWithFallback(opts, time.Hour, false)
+	assert.Error(t, err)
+	assert.Nil(t, c)
+}
+
+type stubPinger struct {
+	failures int
+	calls    int
+}
+
+func (s *stubPinger) Ping(ctx context.Context) *redis.StatusCmd {
+	s.calls++
+	if s.calls <= s.failures {
+		return redis.NewStatusResult("", errors.New("connection refused"))
+	}
+	return redis.NewStatusResult("PONG", nil)
+}
+
+func TestPingWithRetry(t *testing.T) {
+	cfg := RedisOptions{ConnectAttempts: 3, ConnectInterval: time.Millisecond}.retryConfig()
+
+	p := &stubPinger{failures: 2}
+	assert.NoError(t, pingWithRetry(context.Background(), p, cfg))
+	assert.Equal(t, 3, p.calls)
+
+	p = &stubPinger{failures: 3}
+	assert.Error(t, pingWithRetry(context.Background(), p, cfg))
+	assert.Equal(t, 3, p.calls)
+}
+
+func TestRedisOptions_RetryConfigDefaults(t *testing.T) {
+	cfg := RedisOptions{}.retryConfig()
+	assert.Equal(t, DefaultConnectAttempts, cfg.MaxAttempts)
+	assert.Equal(t, DefaultConnectInterval, cfg.InitialInterval)
+}
+
+func TestNewRedisClient_SelectsMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RedisOptions
+		mode    string
+		wantErr bool
+	}{
+		{"single", RedisOptions{Addr: "localhost:6379"}, RedisModeSingle, false},
+		{"sentinel", RedisOptions{MasterName: "mymaster", SentinelAddrs: []string{"s1:26379", "s2:26379"}}, RedisModeSentinel, false},
+		{"sentinel without addrs", RedisOptions{MasterName: "mymaster"}, RedisModeSentinel, true},
+		{"cluster", RedisOptions{ClusterAddrs: []string{"n1:6379", "n2:6379"}}, RedisModeCluster, false},
+		{"cluster with master", RedisOptions{MasterName: "mymaster", ClusterAddrs: []string{"n1:6379"}}, RedisModeCluster, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.mode, tt.opts.Mode())
+
+			client, err := newRedisClient(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, client)
+				return
+			}
+			assert.NoError(t, err)
+			defer client.Close()
+
+			switch tt.mode {
+			case RedisModeCluster:
+				assert.IsType(t, &redis.ClusterClient{}, client)
+			default:
+				assert.IsType(t, &redis.Client{}, client)
+			}
+		})
+	}
+}
+
+// newIntegrationRedis connects to TEST_REDIS_ADDR or skips the test
+func newIntegrationRedis(t *testing.T) *RedisCache {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("Skipping integration test: TEST_REDIS_ADDR is not set")
+	}
+
+	c, err := NewRedisCache(RedisOptions{Addr: addr, ConnectAttempts: 1}, time.Minute)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestRedisCache_DecrementNonNegative(t *testing.T) {
+	c := newIntegrationRedis(t)
+	ctx := context.Background()
+	key := "test:inflight:" + t.Name()
+	t.Cleanup(func() { c.Delete(context.Background(), key) })
+
+	val, err := c.DecrementNonNegative(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	exists, err := c.Exists(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = c.Increment(ctx, key)
+	assert.NoError(t, err)
+	val, err = c.DecrementNonNegative(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	val, err = c.DecrementNonNegative(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+
+	// A counter that already went negative through Decrement is reset
+	_, err = c.Decrement(ctx, key)
+	assert.NoError(t, err)
+	val, err = c.DecrementNonNegative(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), val)
+}
+
+func TestRedisCache_SetIfAbsent(t *testing.T) {
+	c := newIntegrationRedis(t)
+	ctx := context.Background()
+	key := "test:claim:" + t.Name()
+	t.Cleanup(func() { c.Delete(context.Background(), key) })
+
+	set, err := c.SetIfAbsent(ctx, key, true, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	set, err = c.SetIfAbsent(ctx, key, true, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, set)
+}
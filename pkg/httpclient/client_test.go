@@ -0,0 +1,24 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_TunesTransport(t *testing.T) {
+	client := New(DefaultOptions())
+
+	assert.Zero(t, client.Timeout)
+	transport, ok := client.Transport.(*http.Transport)
+	if assert.True(t, ok) {
+		assert.Equal(t, DefaultMaxIdleConns, transport.MaxIdleConns)
+		assert.Equal(t, DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, DefaultIdleConnTimeout, transport.IdleConnTimeout)
+		assert.NotNil(t, transport.Proxy)
+	}
+
+	// The default transport is cloned, not modified
+	assert.NotSame(t, http.DefaultTransport, client.Transport)
+}
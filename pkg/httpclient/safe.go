@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Errors of SafeFetcher. Each wraps ErrRejected: the URL or its response is
+// refused and fetching it again won't help.
+var (
+	ErrRejected          = errors.New("fetch rejected")
+	ErrBlockedAddress    = fmt.Errorf("%w: address is not public", ErrRejected)
+	ErrUnsupportedScheme = fmt.Errorf("%w: unsupported URL scheme", ErrRejected)
+	ErrTooManyRedirects  = fmt.Errorf("%w: too many redirects", ErrRejected)
+	ErrContentType       = fmt.Errorf("%w: unexpected content type", ErrRejected)
+	ErrTooLarge          = fmt.Errorf("%w: response too large", ErrRejected)
+)
+
+// Defaults for SafeFetcher
+const (
+	DefaultMaxRedirects = 3
+	DefaultMaxSize      = 20 << 20
+
+	responseHeaderTimeout = 30 * time.Second
+)
+
+// AudioContentTypes are the OGG/Opus media types. Many file hosts serve any
+// download as application/octet-stream, so it is accepted too.
+var AudioContentTypes = []string{
+	"audio/ogg",
+	"audio/opus",
+	"audio/x-opus+ogg",
+	"application/ogg",
+	"application/octet-stream",
+}
+
+// nonPublicPrefixes are special-purpose ranges that netip reports as global unicast
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// IsPublicAddr reports whether addr is routable on the internet, i.e. not
+// loopback, private, link-local (cloud metadata), multicast or reserved
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// SafeOptions configures SafeFetcher; zero values use the defaults
+type SafeOptions struct {
+	MaxSize      int64
+	MaxRedirects int
+	// ContentTypes are the accepted media types, AudioContentTypes by default
+	ContentTypes []string
+	// AllowAddr decides which resolved addresses may be dialed, IsPublicAddr by default
+	AllowAddr func(netip.Addr) bool
+}
+
+func (o SafeOptions) withDefaults() SafeOptions {
+	if o.MaxSize <= 0 {
+		o.MaxSize = DefaultMaxSize
+	}
+	if o.MaxRedirects <= 0 {
+		o.MaxRedirects = DefaultMaxRedirects
+	}
+	if len(o.ContentTypes) == 0 {
+		o.ContentTypes = AudioContentTypes
+	}
+	if o.AllowAddr == nil {
+		o.AllowAddr = IsPublicAddr
+	}
+	return o
+}
+
+// SafeFetcher downloads user-supplied URLs without letting them reach internal
+// hosts. Addresses are checked when connecting, after the host is resolved, so
+// a hostname can't point at an internal address and redirects get the same check.
+type SafeFetcher struct {
+	client *http.Client
+	opts   SafeOptions
+}
+
+func NewSafeFetcher(opts SafeOptions) *SafeFetcher {
+	f := &SafeFetcher{opts: opts.withDefaults()}
+
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+		Control:   f.checkDial,
+	}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// A proxy would connect on our behalf and skip the address check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   tlsHandshakeTimeout,
+			ResponseHeaderTimeout: responseHeaderTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       DefaultIdleConnTimeout,
+		},
+		CheckRedirect: f.checkRedirect,
+	}
+	return f
+}
+
+// checkDial rejects connections to addresses that aren't allowed
+func (f *SafeFetcher) checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockedAddress, err)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !f.opts.AllowAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+func (f *SafeFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.opts.MaxRedirects {
+		return ErrTooManyRedirects
+	}
+	return checkScheme(req.URL)
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+	return nil
+}
+
+// Fetch downloads the body of rawURL. Responses with an unexpected content type
+// or above the size cap are refused with errors wrapping ErrRejected.
+func (f *SafeFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch URL: status=%d", resp.StatusCode)
+	}
+
+	if !f.allowedContentType(resp.Header.Get("Content-Type")) {
+		return nil, fmt.Errorf("%w: %q", ErrContentType, resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength > f.opts.MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	// Content-Length may be missing or wrong, so the body is capped too
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(data)) > f.opts.MaxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, f.opts.MaxSize)
+	}
+
+	return data, nil
+}
+
+func (f *SafeFetcher) allowedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range f.opts.ContentTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
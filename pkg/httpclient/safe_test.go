@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func allowAnyAddr(netip.Addr) bool { return true }
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"93.184.216.34", true},
+		{"2a00:1450:4001:82b::200e", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"ff02::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.public, IsPublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func TestSafeFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("ogg-data"))
+	}))
+	defer server.Close()
+
+	data, err := NewSafeFetcher(SafeOptions{AllowAddr: allowAnyAddr}).Fetch(context.Background(), server.URL+"/voice.ogg")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ogg-data"), data)
+}
+
+func TestSafeFetcher_BlocksLoopback(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	_, err := NewSafeFetcher(SafeOptions{}).Fetch(context.Background(), server.URL+"/voice.ogg")
+
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.False(t, requested)
+}
+
+func TestSafeFetcher_BlocksRedirectToInternalHost(t *testing.T) {
+	internalRequested := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalRequested = true
+	}))
+	defer internal.Close()
+
+	// The link's host is allowed, the one it redirects to is not
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 is not available: %v", err)
+	}
+	public := httptest.NewUnstartedServer(http.RedirectHandler(internal.URL+"/voice.ogg", http.StatusFound))
+	public.Listener = listener
+	public.Start()
+	defer public.Close()
+
+	f := NewSafeFetcher(SafeOptions{AllowAddr: func(addr netip.Addr) bool {
+		return addr == netip.MustParseAddr("127.0.0.2")
+	}})
+	_, err = f.Fetch(context.Background(), public.URL+"/voice.ogg")
+
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	assert.False(t, internalRequested)
+}
+
+func TestSafeFetcher_LimitsRedirects(t *testing.T) {
+	var requests int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Redirect(w, r, server.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer server.Close()
+
+	f := NewSafeFetcher(SafeOptions{AllowAddr: allowAnyAddr, MaxRedirects: 2})
+	_, err := f.Fetch(context.Background(), server.URL+"/voice.ogg")
+
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+	assert.Equal(t, 3, requests)
+}
+
+func TestSafeFetcher_RejectsUnexpectedContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	_, err := NewSafeFetcher(SafeOptions{AllowAddr: allowAnyAddr}).Fetch(context.Background(), server.URL+"/voice.ogg")
+
+	assert.ErrorIs(t, err, ErrContentType)
+}
+
+func TestSafeFetcher_RejectsOversizedResponses(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength bool
+	}{
+		{"declared size", true},
+		{"streamed without size", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				if tt.contentLength {
+					w.Header().Set("Content-Length", "11")
+				} else {
+					// Flushing before the body forces a chunked response
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(strings.Repeat("x", 11)))
+			}))
+			defer server.Close()
+
+			f := NewSafeFetcher(SafeOptions{AllowAddr: allowAnyAddr, MaxSize: 10})
+			_, err := f.Fetch(context.Background(), server.URL+"/voice.ogg")
+
+			assert.ErrorIs(t, err, ErrTooLarge)
+		})
+	}
+}
+
+func TestSafeFetcher_RejectsOtherSchemes(t *testing.T) {
+	_, err := NewSafeFetcher(SafeOptions{}).Fetch(context.Background(), "file:///etc/passwd")
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+}
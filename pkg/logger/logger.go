@@ -4,7 +4,8 @@ import (
 	"go.uber.org/zap"
 )
 
-var Logger *zap.Logger
+// Logger is a no-op until Init is called, so packages can log safely in tests
+var Logger = zap.NewNop()
 
 // Init initializes the global logger
 func Init(debug bool) error {
@@ -25,6 +26,16 @@ func Init(debug bool) error {
 	return nil
 }
 
+// WithTask returns a child logger that tags every entry with the task ID
+func WithTask(taskID string) *zap.Logger {
+	return Logger.With(zap.String("task_id", taskID))
+}
+
+// WithChat returns a child logger that tags every entry with the chat ID
+func WithChat(chatID int64) *zap.Logger {
+	return Logger.With(zap.Int64("chat_id", chatID))
+}
+
 // Debug logs a debug message
 func Debug(msg string, fields ...zap.Field) {
 	Logger.Debug(msg, fields...)
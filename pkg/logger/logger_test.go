@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestChildLoggersCarryIDs(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	original := Logger
+	Logger = zap.New(core)
+	defer func() { Logger = original }()
+
+	WithTask("task-1").Info("task entry", zap.Int("size", 3))
+	WithChat(42).Warn("chat entry")
+	WithTask("task-2").With(zap.Int64("chat_id", 7)).Error("both")
+
+	entries := logs.AllUntimed()
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, map[string]interface{}{"task_id": "task-1", "size": int64(3)}, entries[0].ContextMap())
+		assert.Equal(t, map[string]interface{}{"chat_id": int64(42)}, entries[1].ContextMap())
+		assert.Equal(t, map[string]interface{}{"task_id": "task-2", "chat_id": int64(7)}, entries[2].ContextMap())
+	}
+
+	// Children don't leak their fields into the global logger
+	Info("plain")
+	assert.Empty(t, logs.FilterMessage("plain").All()[0].Context)
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"slices"
 	"time"
 )
 
@@ -14,6 +15,11 @@ const (
 	TaskStatusInProgress TaskStatus = "in_progress"
 	TaskStatusDone       TaskStatus = "done"
 	TaskStatusFailed     TaskStatus = "failed"
+	// TaskStatusNoSpeech is a final status for audio without recognizable speech
+	TaskStatusNoSpeech TaskStatus = "no_speech"
+	// TaskStatusTimeout is set when the per-task deadline stops processing; like
+	// failed it is final unless the task is retried
+	TaskStatusTimeout TaskStatus = "timeout"
 )
 
 // JSONB represents a JSONB field for PostgreSQL
@@ -42,6 +48,74 @@ func (j *JSONB) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// Decode unmarshals the value stored under key into dest.
+// Values read back from Postgres are generic maps, so they are round-tripped through JSON.
+func (j JSONB) Decode(key string, dest interface{}) bool {
+	value, ok := j[key]
+	if !ok || value == nil {
+		return false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, dest) == nil
+}
+
+// Meta keys used by the processing pipeline
+const (
+	MetaKeyTimings     = "timings"
+	MetaKeyThreadID    = "thread_id"
+	MetaKeyForwardFrom = "forward_from"
+	MetaKeyLanguage    = "language"
+	MetaKeySourceURL   = "source_url"
+	MetaKeyModel       = "model"
+	MetaKeyRecognition = "recognition"
+
+	// MetaKeyModelOverride is the model a user asked to recognize the task with again
+	MetaKeyModelOverride = "model_override"
+
+	MetaKeyProcessingMessageID = "processing_message_id"
+	MetaKeyReplyMessageID      = "reply_message_id"
+
+	// MetaKeySenderID is the Telegram user who sent the audio
+	MetaKeySenderID = "sender_id"
+
+	// MetaKeyChatType is the Telegram chat type the audio came from, e.g. "group"
+	MetaKeyChatType = "chat_type"
+
+	// MetaKeyAudioKeys are the S3 objects holding the task's audio
+	MetaKeyAudioKeys = "audio_keys"
+	// MetaKeyResultCacheKey is the cache entry of the task's recognition result
+	MetaKeyResultCacheKey = "result_cache_key"
+)
+
+// ButtonReprocessModel is the unique of the inline button under a low-confidence
+// transcript that recognizes it again with another model. Its data is the task ID.
+const ButtonReprocessModel = "reprocess_model"
+
+// Timings holds per-stage processing durations in milliseconds
+type Timings struct {
+	DownloadMs    int64 `json:"download_ms"`
+	UploadMs      int64 `json:"upload_ms"`
+	RecognitionMs int64 `json:"recognition_ms"`
+	TotalMs       int64 `json:"total_ms"`
+}
+
+// RecognitionInfo holds what SpeechKit reported about the operation that
+// recognized the task, for debugging and analytics
+type RecognitionInfo struct {
+	OperationID     string `json:"operation_id,omitempty"`
+	MetadataType    string `json:"metadata_type,omitempty"`
+	ProgressPercent int    `json:"progress_percent,omitempty"`
+	// AudioDurationMs is the audio length as measured by SpeechKit
+	AudioDurationMs int64 `json:"audio_duration_ms,omitempty"`
+	// OperationMs is how long the operation ran on SpeechKit's side
+	OperationMs int64 `json:"operation_ms,omitempty"`
+}
+
 // Task represents a voice message processing task
 type Task struct {
 	ID                string     `json:"id" db:"id"`
@@ -63,17 +137,112 @@ type Transcript struct {
 	TaskID      string          `json:"task_id" db:"task_id"`
 	Text        string          `json:"text" db:"text"`
 	RawResponse json.RawMessage `json:"raw_response,omitempty" db:"raw_response"`
+	Version     int             `json:"version" db:"version"` // 1 for the first run, incremented on reprocessing
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 }
 
+// AuditEntry records a user command or received voice message
+type AuditEntry struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	ChatID    int64     `json:"chat_id" db:"chat_id"`
+	Action    string    `json:"action" db:"action"`
+	Payload   string    `json:"payload,omitempty" db:"payload"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// OutboxMessage is a reply the worker couldn't send while Telegram was
+// unavailable, kept until it can be delivered
+type OutboxMessage struct {
+	ID     int64  `json:"id" db:"id"`
+	TaskID string `json:"task_id" db:"task_id"`
+	// TaskReply marks the first message of a transcript, which becomes the task's reply once sent
+	TaskReply bool      `json:"task_reply,omitempty" db:"task_reply"`
+	ChatID    int64     `json:"chat_id" db:"chat_id"`
+	ThreadID  int       `json:"thread_id,omitempty" db:"thread_id"`
+	ReplyTo   int       `json:"reply_to,omitempty" db:"reply_to"`
+	ParseMode string    `json:"parse_mode,omitempty" db:"parse_mode"`
+	Text      string    `json:"text" db:"text"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ChatPreferences holds a chat's settings. Unset fields fall back to configuration.
+// ChatID and UpdatedAt live in their own columns; the rest is stored as JSONB.
+type ChatPreferences struct {
+	ChatID          int64     `json:"-" db:"chat_id"`
+	Active          *bool     `json:"active,omitempty"`
+	Threshold       *float64  `json:"threshold,omitempty"`
+	Language        string    `json:"language,omitempty"`
+	ProfanityFilter bool      `json:"profanity_filter,omitempty"`
+	ParseMode       string    `json:"parse_mode,omitempty"`
+	Timestamps      bool      `json:"timestamps,omitempty"`
+	UpdatedAt       time.Time `json:"-" db:"updated_at"`
+}
+
+// IsActive reports whether the bot handles the chat's voice messages
+func (p *ChatPreferences) IsActive(defaultActive bool) bool {
+	if p == nil || p.Active == nil {
+		return defaultActive
+	}
+	return *p.Active
+}
+
+// SetActive turns voice message handling on or off for the chat
+func (p *ChatPreferences) SetActive(active bool) {
+	p.Active = &active
+}
+
+// ConfidenceThreshold returns the chat's low-confidence warning threshold
+func (p *ChatPreferences) ConfidenceThreshold(defaultThreshold float64) float64 {
+	if p == nil || p.Threshold == nil {
+		return defaultThreshold
+	}
+	return *p.Threshold
+}
+
+// SetThreshold sets the chat's low-confidence warning threshold; 0 disables warnings
+func (p *ChatPreferences) SetThreshold(threshold float64) {
+	p.Threshold = &threshold
+}
+
 // IsCompleted returns true if the task is in a final state
 func (t *Task) IsCompleted() bool {
-	return t.Status == TaskStatusDone || t.Status == TaskStatusFailed
+	switch t.Status {
+	case TaskStatusDone, TaskStatusFailed, TaskStatusNoSpeech, TaskStatusTimeout:
+		return true
+	default:
+		return false
+	}
 }
 
-// CanRetry returns true if the task can be retried
-func (t *Task) CanRetry() bool {
-	return t.Status == TaskStatusFailed && t.Attempts < 3 // максимум 3 попытки
+// statusTransitions lists the statuses a task may move to from each status.
+// Failed and timed out tasks stay open for retries; done and no_speech are only
+// left by deliberately requeueing the task.
+var statusTransitions = map[TaskStatus][]TaskStatus{
+	TaskStatusQueued:     {TaskStatusQueued, TaskStatusInProgress, TaskStatusDone, TaskStatusFailed, TaskStatusNoSpeech, TaskStatusTimeout},
+	TaskStatusInProgress: {TaskStatusQueued, TaskStatusInProgress, TaskStatusDone, TaskStatusFailed, TaskStatusNoSpeech, TaskStatusTimeout},
+	TaskStatusFailed:     {TaskStatusQueued, TaskStatusInProgress, TaskStatusDone, TaskStatusFailed, TaskStatusNoSpeech, TaskStatusTimeout},
+	TaskStatusTimeout:    {TaskStatusQueued, TaskStatusInProgress, TaskStatusDone, TaskStatusFailed, TaskStatusNoSpeech, TaskStatusTimeout},
+	TaskStatusDone:       {TaskStatusQueued},
+	TaskStatusNoSpeech:   {TaskStatusQueued},
+}
+
+// CanTransitionTo reports whether the task may move from its current status to status
+func (t *Task) CanTransitionTo(status TaskStatus) bool {
+	for _, next := range statusTransitions[t.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMaxAttempts is the retry budget used when none is configured
+const DefaultMaxAttempts = 3
+
+// CanRetry returns true if the failed or timed out task has attempts left out of maxAttempts
+func (t *Task) CanRetry(maxAttempts int) bool {
+	return (t.Status == TaskStatusFailed || t.Status == TaskStatusTimeout) && t.Attempts < maxAttempts
 }
 
 // IncrementAttempts increases the attempt counter
@@ -81,6 +250,21 @@ func (t *Task) IncrementAttempts() {
 	t.Attempts++
 }
 
+// ResetAttempts clears the attempt counter.
+// Use it when a task is deliberately put back in the queue, so earlier failures
+// don't count against its new retry budget.
+func (t *Task) ResetAttempts() {
+	t.Attempts = 0
+	t.UpdatedAt = time.Now()
+}
+
+// Requeue puts the task back in the queued state with a fresh retry budget
+func (t *Task) Requeue() {
+	t.Status = TaskStatusQueued
+	t.ErrorText = nil
+	t.ResetAttempts()
+}
+
 // SetError sets the task status to failed with error message
 func (t *Task) SetError(errorText string) {
 	t.Status = TaskStatusFailed
@@ -88,15 +272,294 @@ func (t *Task) SetError(errorText string) {
 	t.UpdatedAt = time.Now()
 }
 
-// SetCompleted sets the task status to done
+// timeoutErrorText is recorded for tasks stopped by their deadline
+const timeoutErrorText = "processing deadline exceeded"
+
+// SetTimedOut marks the task as stopped by its processing deadline
+func (t *Task) SetTimedOut() {
+	t.Status = TaskStatusTimeout
+	errorText := timeoutErrorText
+	t.ErrorText = &errorText
+	t.UpdatedAt = time.Now()
+}
+
+// SetCompleted sets the task status to done.
+// Attempts are kept as history of how many tries the task needed; CanRetry
+// only considers failed tasks, so a completed task is never judged by them.
 func (t *Task) SetCompleted() {
 	t.Status = TaskStatusDone
+	t.ErrorText = nil
 	t.UpdatedAt = time.Now()
 }
 
+// SetNoSpeech marks the task as finished without recognized speech
+func (t *Task) SetNoSpeech() {
+	t.Status = TaskStatusNoSpeech
+	t.ErrorText = nil
+	t.UpdatedAt = time.Now()
+}
+
+// CurrentOperationID returns the recognition operation of the task, or "" if none was started
+func (t *Task) CurrentOperationID() string {
+	if t.OperationID == nil {
+		return ""
+	}
+	return *t.OperationID
+}
+
 // SetInProgress sets the task status to in progress with operation ID
 func (t *Task) SetInProgress(operationID string) {
 	t.Status = TaskStatusInProgress
 	t.OperationID = &operationID
 	t.UpdatedAt = time.Now()
 }
+
+// SetTimings stores per-stage durations in task meta
+func (t *Task) SetTimings(timings Timings) {
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyTimings] = timings
+}
+
+// SetRecognitionInfo stores what SpeechKit reported about the recognition operation
+func (t *Task) SetRecognitionInfo(info RecognitionInfo) {
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyRecognition] = info
+}
+
+// RecognitionInfo returns what SpeechKit reported about the recognition operation
+func (t *Task) RecognitionInfo() (RecognitionInfo, bool) {
+	var info RecognitionInfo
+	ok := t.Meta.Decode(MetaKeyRecognition, &info)
+	return info, ok
+}
+
+// Timings returns per-stage durations recorded in task meta
+func (t *Task) Timings() (Timings, bool) {
+	var timings Timings
+	ok := t.Meta.Decode(MetaKeyTimings, &timings)
+	return timings, ok
+}
+
+// SetThreadID stores the forum topic the task was created in; zero means no topic
+func (t *Task) SetThreadID(threadID int) {
+	if threadID == 0 {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyThreadID] = threadID
+}
+
+// ThreadID returns the forum topic of the task, or zero for regular chats
+func (t *Task) ThreadID() int {
+	var threadID int
+	t.Meta.Decode(MetaKeyThreadID, &threadID)
+	return threadID
+}
+
+// SetProcessingMessageID stores the ID of the interim "processing" reply; zero means none was sent
+func (t *Task) SetProcessingMessageID(messageID int) {
+	if messageID == 0 {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyProcessingMessageID] = messageID
+}
+
+// ProcessingMessageID returns the ID of the interim "processing" reply, or zero
+func (t *Task) ProcessingMessageID() int {
+	var messageID int
+	t.Meta.Decode(MetaKeyProcessingMessageID, &messageID)
+	return messageID
+}
+
+// SetReplyMessageID stores the ID of the message the transcript was sent in; zero means none was sent
+func (t *Task) SetReplyMessageID(messageID int) {
+	if messageID == 0 {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyReplyMessageID] = messageID
+}
+
+// ReplyMessageID returns the ID of the transcript reply, or zero if none was sent yet
+func (t *Task) ReplyMessageID() int {
+	var messageID int
+	t.Meta.Decode(MetaKeyReplyMessageID, &messageID)
+	return messageID
+}
+
+// SetSenderID stores the Telegram user who sent the audio; zero means unknown
+func (t *Task) SetSenderID(userID int64) {
+	if userID == 0 {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeySenderID] = userID
+}
+
+// SenderID returns the Telegram user who sent the audio, or zero for tasks created before it was stored
+func (t *Task) SenderID() int64 {
+	var userID int64
+	t.Meta.Decode(MetaKeySenderID, &userID)
+	return userID
+}
+
+// SetChatType stores the Telegram chat type the audio came from; empty means unknown
+func (t *Task) SetChatType(chatType string) {
+	if chatType == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyChatType] = chatType
+}
+
+// ChatType returns the Telegram chat type of the task, or an empty string for tasks created before it was stored
+func (t *Task) ChatType() string {
+	var chatType string
+	t.Meta.Decode(MetaKeyChatType, &chatType)
+	return chatType
+}
+
+// SetForwardFrom stores who originally sent a forwarded voice message; empty means not forwarded
+func (t *Task) SetForwardFrom(name string) {
+	if name == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyForwardFrom] = name
+}
+
+// ForwardFrom returns the original sender of a forwarded voice message, or an empty string
+func (t *Task) ForwardFrom() string {
+	var name string
+	t.Meta.Decode(MetaKeyForwardFrom, &name)
+	return name
+}
+
+// SetLanguage stores the language the task was recognized in
+func (t *Task) SetLanguage(code string) {
+	if code == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyLanguage] = code
+}
+
+// Language returns the language the task was recognized in, or an empty string
+func (t *Task) Language() string {
+	var code string
+	t.Meta.Decode(MetaKeyLanguage, &code)
+	return code
+}
+
+// SetSourceURL stores the link audio sent as text is downloaded from; empty means a Telegram file
+func (t *Task) SetSourceURL(link string) {
+	if link == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeySourceURL] = link
+}
+
+// AddAudioKeys records S3 objects holding the task's audio, so they can be
+// deleted together with the task
+func (t *Task) AddAudioKeys(keys ...string) {
+	known := t.AudioKeys()
+	for _, key := range keys {
+		if key != "" && !slices.Contains(known, key) {
+			known = append(known, key)
+		}
+	}
+	if len(known) == 0 {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyAudioKeys] = known
+}
+
+// AudioKeys returns the S3 objects holding the task's audio
+func (t *Task) AudioKeys() []string {
+	var keys []string
+	t.Meta.Decode(MetaKeyAudioKeys, &keys)
+	return keys
+}
+
+// SetResultCacheKey records the cache entry of the task's recognition result
+func (t *Task) SetResultCacheKey(key string) {
+	if key == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyResultCacheKey] = key
+}
+
+// ResultCacheKey returns the cache entry of the task's recognition result, or an empty string
+func (t *Task) ResultCacheKey() string {
+	var key string
+	t.Meta.Decode(MetaKeyResultCacheKey, &key)
+	return key
+}
+
+// SourceURL returns the link the task's audio is downloaded from, or an empty string for Telegram files
+func (t *Task) SourceURL() string {
+	var link string
+	t.Meta.Decode(MetaKeySourceURL, &link)
+	return link
+}
+
+// SetModel stores the recognition model of the task's latest run
+func (t *Task) SetModel(name string) {
+	if name == "" {
+		return
+	}
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyModel] = name
+}
+
+// Model returns the recognition model of the task's latest run, or an empty string
+func (t *Task) Model() string {
+	var name string
+	t.Meta.Decode(MetaKeyModel, &name)
+	return name
+}
+
+// SetModelOverride makes the next runs recognize the task with the named model
+func (t *Task) SetModelOverride(name string) {
+	if t.Meta == nil {
+		t.Meta = JSONB{}
+	}
+	t.Meta[MetaKeyModelOverride] = name
+}
+
+// ModelOverride returns the model the task was asked to be recognized with, or an empty string
+func (t *Task) ModelOverride() string {
+	var name string
+	t.Meta.Decode(MetaKeyModelOverride, &name)
+	return name
+}
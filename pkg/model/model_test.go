@@ -0,0 +1,302 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask_TimingsRoundTrip(t *testing.T) {
+	task := &Task{}
+
+	_, ok := task.Timings()
+	assert.False(t, ok)
+
+	task.SetTimings(Timings{DownloadMs: 120, UploadMs: 80, RecognitionMs: 4000, TotalMs: 4250})
+
+	// Simulate a Postgres round trip, where meta comes back as a generic map
+	data, err := json.Marshal(task.Meta)
+	assert.NoError(t, err)
+
+	var meta JSONB
+	assert.NoError(t, meta.Scan(data))
+
+	loaded := &Task{Meta: meta}
+	timings, ok := loaded.Timings()
+	assert.True(t, ok)
+	assert.Equal(t, int64(120), timings.DownloadMs)
+	assert.Equal(t, int64(80), timings.UploadMs)
+	assert.Equal(t, int64(4000), timings.RecognitionMs)
+	assert.Equal(t, int64(4250), timings.TotalMs)
+}
+
+func TestTask_RecognitionInfoRoundTrip(t *testing.T) {
+	task := &Task{}
+
+	_, ok := task.RecognitionInfo()
+	assert.False(t, ok)
+
+	task.SetRecognitionInfo(RecognitionInfo{OperationID: "op-1", ProgressPercent: 100, AudioDurationMs: 12340, OperationMs: 7500})
+
+	data, err := json.Marshal(task.Meta)
+	assert.NoError(t, err)
+
+	var meta JSONB
+	assert.NoError(t, meta.Scan(data))
+
+	info, ok := (&Task{Meta: meta}).RecognitionInfo()
+	assert.True(t, ok)
+	assert.Equal(t, RecognitionInfo{OperationID: "op-1", ProgressPercent: 100, AudioDurationMs: 12340, OperationMs: 7500}, info)
+}
+
+func TestTask_SetCompletedKeepsAttempts(t *testing.T) {
+	task := &Task{Status: TaskStatusInProgress}
+
+	task.SetError("temporary failure")
+	task.IncrementAttempts()
+	task.IncrementAttempts()
+	assert.True(t, task.CanRetry(DefaultMaxAttempts))
+
+	task.SetCompleted()
+
+	assert.Equal(t, TaskStatusDone, task.Status)
+	assert.Equal(t, 2, task.Attempts)
+	assert.Nil(t, task.ErrorText)
+	assert.False(t, task.CanRetry(DefaultMaxAttempts))
+}
+
+func TestTask_ResetAttempts(t *testing.T) {
+	task := &Task{Status: TaskStatusInProgress}
+
+	for i := 0; i < 3; i++ {
+		task.SetError("failure")
+		task.IncrementAttempts()
+	}
+	assert.False(t, task.CanRetry(DefaultMaxAttempts))
+
+	task.ResetAttempts()
+	assert.Equal(t, 0, task.Attempts)
+
+	task.SetError("failure after requeue")
+	task.IncrementAttempts()
+	assert.True(t, task.CanRetry(DefaultMaxAttempts))
+}
+
+func TestTask_Requeue(t *testing.T) {
+	task := &Task{Status: TaskStatusInProgress}
+	for i := 0; i < 3; i++ {
+		task.SetError("failure")
+		task.IncrementAttempts()
+	}
+
+	task.Requeue()
+
+	assert.Equal(t, TaskStatusQueued, task.Status)
+	assert.Equal(t, 0, task.Attempts)
+	assert.Nil(t, task.ErrorText)
+}
+
+func TestTask_CanRetryBoundary(t *testing.T) {
+	tests := []struct {
+		name        string
+		attempts    int
+		maxAttempts int
+		expected    bool
+	}{
+		{"below limit", 4, 5, true},
+		{"at limit", 5, 5, false},
+		{"above limit", 6, 5, false},
+		{"single attempt allowed", 0, 1, true},
+		{"single attempt used", 1, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{Status: TaskStatusFailed, Attempts: tt.attempts}
+			assert.Equal(t, tt.expected, task.CanRetry(tt.maxAttempts))
+		})
+	}
+
+	done := &Task{Status: TaskStatusDone}
+	assert.False(t, done.CanRetry(5))
+}
+
+func TestTask_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from     TaskStatus
+		to       TaskStatus
+		expected bool
+	}{
+		{TaskStatusQueued, TaskStatusInProgress, true},
+		{TaskStatusInProgress, TaskStatusTimeout, true},
+		{TaskStatusInProgress, TaskStatusDone, true},
+		{TaskStatusTimeout, TaskStatusInProgress, true},
+		{TaskStatusTimeout, TaskStatusFailed, true},
+		{TaskStatusFailed, TaskStatusTimeout, true},
+		{TaskStatusDone, TaskStatusTimeout, false},
+		{TaskStatusDone, TaskStatusFailed, false},
+		{TaskStatusNoSpeech, TaskStatusTimeout, false},
+		{TaskStatusDone, TaskStatusQueued, true},
+		{TaskStatus("unknown"), TaskStatusQueued, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			task := &Task{Status: tt.from}
+			assert.Equal(t, tt.expected, task.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestTask_ThreadID(t *testing.T) {
+	task := &Task{}
+	task.SetThreadID(0)
+	assert.Nil(t, task.Meta)
+	assert.Equal(t, 0, task.ThreadID())
+
+	task.SetThreadID(15)
+	data, err := json.Marshal(task.Meta)
+	assert.NoError(t, err)
+	task.Meta = JSONB{}
+	assert.NoError(t, json.Unmarshal(data, &task.Meta))
+
+	assert.Equal(t, 15, task.ThreadID())
+}
+
+func TestTask_ProcessingMessageID(t *testing.T) {
+	task := &Task{}
+	task.SetProcessingMessageID(0)
+	assert.Nil(t, task.Meta)
+	assert.Equal(t, 0, task.ProcessingMessageID())
+
+	task.SetProcessingMessageID(99)
+	data, err := json.Marshal(task.Meta)
+	assert.NoError(t, err)
+	task.Meta = JSONB{}
+	assert.NoError(t, json.Unmarshal(data, &task.Meta))
+
+	assert.Equal(t, 99, task.ProcessingMessageID())
+}
+
+func TestTask_ReplyMessageID(t *testing.T) {
+	task := &Task{}
+	task.SetReplyMessageID(0)
+	assert.Nil(t, task.Meta)
+	assert.Equal(t, 0, task.ReplyMessageID())
+
+	task.SetReplyMessageID(12)
+	data, err := json.Marshal(task.Meta)
+	assert.NoError(t, err)
+	task.Meta = JSONB{}
+	assert.NoError(t, json.Unmarshal(data, &task.Meta))
+
+	assert.Equal(t, 12, task.ReplyMessageID())
+}
+
+func TestTask_ChatType(t *testing.T) {
+	task := &Task{}
+	task.SetChatType("")
+	assert.Nil(t, task.Meta)
+	assert.Equal(t, "", task.ChatType())
+
+	task.SetChatType("supergroup")
+	assert.Equal(t, "supergroup", task.ChatType())
+}
+
+func TestTask_ForwardFrom(t *testing.T) {
+	task := &Task{}
+	task.SetForwardFrom("")
+	assert.Nil(t, task.Meta)
+	assert.Equal(t, "", task.ForwardFrom())
+
+	task.SetForwardFrom("Анна (@anna)")
+	assert.Equal(t, "Анна (@anna)", task.ForwardFrom())
+}
+
+func TestTask_SetNoSpeech(t *testing.T) {
+	task := &Task{Status: TaskStatusInProgress, Attempts: 1}
+	task.SetNoSpeech()
+
+	assert.Equal(t, TaskStatusNoSpeech, task.Status)
+	assert.True(t, task.IsCompleted())
+	assert.False(t, task.CanRetry(DefaultMaxAttempts))
+}
+
+func TestTask_Language(t *testing.T) {
+	task := &Task{}
+	assert.Empty(t, task.Language())
+
+	task.SetLanguage("")
+	assert.Nil(t, task.Meta)
+
+	task.SetLanguage("en-US")
+	assert.Equal(t, "en-US", task.Language())
+}
+
+func TestChatPreferences_Defaults(t *testing.T) {
+	var unset *ChatPreferences
+	assert.True(t, unset.IsActive(true))
+	assert.Equal(t, 0.6, unset.ConfidenceThreshold(0.6))
+
+	prefs := &ChatPreferences{ChatID: 42}
+	assert.False(t, prefs.IsActive(false))
+	assert.Equal(t, 0.6, prefs.ConfidenceThreshold(0.6))
+
+	// Explicit values win over the defaults, including false and 0
+	prefs.SetActive(false)
+	prefs.SetThreshold(0)
+	assert.False(t, prefs.IsActive(true))
+	assert.Equal(t, 0.0, prefs.ConfidenceThreshold(0.6))
+}
+
+func TestChatPreferences_JSONRoundTrip(t *testing.T) {
+	prefs := &ChatPreferences{ChatID: 42, Language: "en-US", ProfanityFilter: true}
+	prefs.SetActive(true)
+	prefs.SetThreshold(0.8)
+
+	data, err := json.Marshal(prefs)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"active":true,"threshold":0.8,"language":"en-US","profanity_filter":true}`, string(data))
+
+	var decoded ChatPreferences
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	decoded.ChatID = 42
+	assert.Equal(t, prefs, &decoded)
+}
+
+func TestTask_SetTimedOut(t *testing.T) {
+	task := &Task{Status: TaskStatusInProgress}
+
+	task.SetTimedOut()
+	task.IncrementAttempts()
+
+	assert.Equal(t, TaskStatusTimeout, task.Status)
+	assert.True(t, task.IsCompleted())
+	if assert.NotNil(t, task.ErrorText) {
+		assert.Equal(t, "processing deadline exceeded", *task.ErrorText)
+	}
+	assert.True(t, task.CanRetry(DefaultMaxAttempts))
+	assert.False(t, task.CanRetry(1))
+
+	task.Requeue()
+	assert.Equal(t, TaskStatusQueued, task.Status)
+	assert.False(t, task.IsCompleted())
+}
+
+func TestTask_AudioKeys(t *testing.T) {
+	task := &Task{}
+	task.AddAudioKeys("")
+	assert.Nil(t, task.Meta)
+	assert.Empty(t, task.AudioKeys())
+
+	// A retry uploading to the same key doesn't record it twice
+	task.AddAudioKeys("content/abc.ogg")
+	task.AddAudioKeys("content/abc.ogg", "voice/2025/10/07/task-1.ogg")
+	assert.Equal(t, []string{"content/abc.ogg", "voice/2025/10/07/task-1.ogg"}, task.AudioKeys())
+
+	task.SetResultCacheKey("")
+	assert.Empty(t, task.ResultCacheKey())
+	task.SetResultCacheKey("result:abc")
+	assert.Equal(t, "result:abc", task.ResultCacheKey())
+}
@@ -0,0 +1,83 @@
+package resilience
+
+import (
+	"sort"
+	"sync"
+	"voxly/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Transition is a change of a breaker's state
+type Transition struct {
+	From State
+	To   State
+}
+
+// BreakerStatus describes a registered breaker for metrics
+type BreakerStatus struct {
+	Name        string
+	State       State
+	Transitions map[Transition]uint64
+}
+
+// BreakerRegistry names circuit breakers, logs their state changes and keeps
+// counts of them for metrics
+type BreakerRegistry struct {
+	mu          sync.Mutex
+	breakers    map[string]*CircuitBreaker
+	transitions map[string]map[Transition]uint64
+}
+
+func NewBreakerRegistry() *BreakerRegistry {
+	return &BreakerRegistry{
+		breakers:    make(map[string]*CircuitBreaker),
+		transitions: make(map[string]map[Transition]uint64),
+	}
+}
+
+// Register starts reporting the breaker's transitions under name. It replaces
+// any state change callback the breaker had.
+func (r *BreakerRegistry) Register(name string, cb *CircuitBreaker) {
+	r.mu.Lock()
+	r.breakers[name] = cb
+	r.transitions[name] = make(map[Transition]uint64)
+	r.mu.Unlock()
+
+	cb.OnStateChange(func(from, to State) {
+		r.record(name, Transition{From: from, To: to})
+	})
+}
+
+func (r *BreakerRegistry) record(name string, t Transition) {
+	r.mu.Lock()
+	r.transitions[name][t]++
+	r.mu.Unlock()
+
+	logger.Warn("Circuit breaker state changed",
+		zap.String("breaker", name),
+		zap.String("from", t.From.String()),
+		zap.String("to", t.To.String()))
+}
+
+// Statuses returns the registered breakers sorted by name
+func (r *BreakerRegistry) Statuses() []BreakerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(r.breakers))
+	for name, cb := range r.breakers {
+		transitions := make(map[Transition]uint64, len(r.transitions[name]))
+		for t, n := range r.transitions[name] {
+			transitions[t] = n
+		}
+		statuses = append(statuses, BreakerStatus{
+			Name:        name,
+			State:       cb.GetState(),
+			Transitions: transitions,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
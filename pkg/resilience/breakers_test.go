@@ -0,0 +1,66 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"voxly/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBreakerRegistry_ReportsTransitionsOnce(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = previous })
+
+	registry := NewBreakerRegistry()
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	registry.Register("s3", cb)
+
+	cb.Execute(func() error { return errors.New("error") })
+	cb.Execute(func() error { return errors.New("error") }) // rejected while open
+	time.Sleep(60 * time.Millisecond)
+	cb.Execute(func() error { return nil })
+	cb.Execute(func() error { return nil })
+
+	entries := logs.FilterMessage("Circuit breaker state changed").All()
+	if assert.Len(t, entries, 3) {
+		for _, entry := range entries {
+			assert.Equal(t, zapcore.WarnLevel, entry.Level)
+			assert.Equal(t, "s3", entry.ContextMap()["breaker"])
+		}
+		assert.Equal(t, "closed", entries[0].ContextMap()["from"])
+		assert.Equal(t, "open", entries[0].ContextMap()["to"])
+		assert.Equal(t, "half-open", entries[1].ContextMap()["to"])
+		assert.Equal(t, "closed", entries[2].ContextMap()["to"])
+	}
+
+	statuses := registry.Statuses()
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, "s3", statuses[0].Name)
+		assert.Equal(t, StateClosed, statuses[0].State)
+		assert.Equal(t, map[Transition]uint64{
+			{From: StateClosed, To: StateOpen}:     1,
+			{From: StateOpen, To: StateHalfOpen}:   1,
+			{From: StateHalfOpen, To: StateClosed}: 1,
+		}, statuses[0].Transitions)
+	}
+}
+
+func TestBreakerRegistry_StatusesSortedByName(t *testing.T) {
+	registry := NewBreakerRegistry()
+	registry.Register("speechkit", NewCircuitBreaker(1, time.Minute))
+	registry.Register("s3", NewCircuitBreaker(1, time.Minute))
+
+	statuses := registry.Statuses()
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, "s3", statuses[0].Name)
+		assert.Equal(t, "speechkit", statuses[1].Name)
+		assert.Empty(t, statuses[0].Transitions)
+	}
+}
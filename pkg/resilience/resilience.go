@@ -20,13 +20,30 @@ const (
 	StateHalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChangeFunc is told about every state transition of a breaker
+type StateChangeFunc func(from, to State)
+
 type CircuitBreaker struct {
-	maxFailures  uint32
-	timeout      time.Duration
-	state        State
-	failures     uint32
-	lastFailTime time.Time
-	mu           sync.RWMutex
+	maxFailures   uint32
+	timeout       time.Duration
+	state         State
+	failures      uint32
+	lastFailTime  time.Time
+	onStateChange StateChangeFunc
+	mu            sync.RWMutex
 }
 
 func NewCircuitBreaker(maxFailures uint32, timeout time.Duration) *CircuitBreaker {
@@ -37,56 +54,93 @@ func NewCircuitBreaker(maxFailures uint32, timeout time.Duration) *CircuitBreake
 	}
 }
 
+// OnStateChange sets fn to be called once per transition, outside the
+// breaker's lock. Transitions of concurrent calls may be reported out of order.
+func (cb *CircuitBreaker) OnStateChange(fn StateChangeFunc) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.onStateChange = fn
+}
+
 func (cb *CircuitBreaker) Execute(fn func() error) error {
 	cb.mu.Lock()
 
 	if cb.state == StateOpen {
 		if time.Since(cb.lastFailTime) > cb.timeout {
-			cb.state = StateHalfOpen
+			notify := cb.setState(StateHalfOpen)
 			cb.failures = 0
+			cb.mu.Unlock()
+			notify()
 		} else {
 			cb.mu.Unlock()
 			return ErrCircuitOpen
 		}
+	} else {
+		cb.mu.Unlock()
 	}
 
-	cb.mu.Unlock()
-
 	err := fn()
 
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
 
 	if err != nil {
 		cb.failures++
 		cb.lastFailTime = time.Now()
 
-		if cb.failures >= cb.maxFailures {
-			cb.state = StateOpen
+		// A failed trial call reopens the breaker right away
+		notify := func() {}
+		if cb.state == StateHalfOpen || cb.failures >= cb.maxFailures {
+			notify = cb.setState(StateOpen)
 		}
+		cb.mu.Unlock()
+		notify()
 
 		return err
 	}
 
+	notify := func() {}
 	if cb.state == StateHalfOpen {
-		cb.state = StateClosed
+		notify = cb.setState(StateClosed)
 	}
 
 	cb.failures = 0
+	cb.mu.Unlock()
+	notify()
 	return nil
 }
 
+// setState must be called with the lock held. The returned func reports the
+// transition and must be called after unlocking.
+func (cb *CircuitBreaker) setState(state State) func() {
+	from := cb.state
+	cb.state = state
+	if from == state || cb.onStateChange == nil {
+		return func() {}
+	}
+	fn := cb.onStateChange
+	return func() { fn(from, state) }
+}
+
 func (cb *CircuitBreaker) GetState() State {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 	return cb.state
 }
 
+// Ready reports whether Execute would call its function now: the breaker is
+// closed or half-open, or has been open for longer than its timeout
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.state != StateOpen || time.Since(cb.lastFailTime) > cb.timeout
+}
+
 func (cb *CircuitBreaker) Reset() {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	cb.state = StateClosed
+	notify := cb.setState(StateClosed)
 	cb.failures = 0
+	cb.mu.Unlock()
+	notify()
 }
 
 type RetryConfig struct {
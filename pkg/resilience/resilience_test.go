@@ -64,6 +64,21 @@ func TestCircuitBreaker_HalfOpenAfterTimeout(t *testing.T) {
 	assert.Equal(t, StateClosed, cb.GetState())
 }
 
+func TestCircuitBreaker_Ready(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	assert.True(t, cb.Ready())
+
+	cb.Execute(func() error {
+		return errors.New("error")
+	})
+	assert.False(t, cb.Ready())
+
+	// The timeout lets a trial call through
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, cb.Ready())
+	assert.Equal(t, StateOpen, cb.GetState())
+}
+
 func TestCircuitBreaker_Reset(t *testing.T) {
 	cb := NewCircuitBreaker(2, 5*time.Second)
 
@@ -80,6 +95,51 @@ func TestCircuitBreaker_Reset(t *testing.T) {
 	assert.Equal(t, StateClosed, cb.GetState())
 }
 
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	cb := NewCircuitBreaker(3, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		cb.Execute(func() error {
+			return errors.New("error")
+		})
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// A single failed trial call is enough to open the breaker again
+	cb.Execute(func() error {
+		return errors.New("error")
+	})
+
+	assert.Equal(t, StateOpen, cb.GetState())
+}
+
+func TestCircuitBreaker_ReportsEachTransitionOnce(t *testing.T) {
+	cb := NewCircuitBreaker(2, 50*time.Millisecond)
+
+	var transitions []Transition
+	cb.OnStateChange(func(from, to State) {
+		transitions = append(transitions, Transition{From: from, To: to})
+	})
+
+	fail := func() error { return errors.New("error") }
+	succeed := func() error { return nil }
+
+	cb.Execute(succeed)
+	cb.Execute(fail)
+	cb.Execute(fail) // opens
+	cb.Execute(fail) // rejected while open
+	time.Sleep(60 * time.Millisecond)
+	cb.Execute(succeed) // half-open, then closed
+	cb.Execute(succeed)
+	cb.Reset() // already closed
+
+	assert.Equal(t, []Transition{
+		{From: StateClosed, To: StateOpen},
+		{From: StateOpen, To: StateHalfOpen},
+		{From: StateHalfOpen, To: StateClosed},
+	}, transitions)
+}
+
 func TestRetryWithExponentialBackoff_Success(t *testing.T) {
 	ctx := context.Background()
 	config := DefaultRetryConfig()